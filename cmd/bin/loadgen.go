@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"ozon-test/internal/loadgen"
+	"strings"
+	"syscall"
+)
+
+// headerFlag collects repeated "Name: value" flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string { return "" }
+
+func (h headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("header %q must look like \"Name: value\"", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(val))
+	return nil
+}
+
+// runLoadgen drives a running server and prints latency percentiles.
+func runLoadgen(args []string) error {
+	opts := loadgen.DefaultOptions()
+	opts.Headers = http.Header{}
+	fs := flag.NewFlagSet("ozon-test loadgen", flag.ContinueOnError)
+	fs.StringVar(&opts.URL, "url", opts.URL, "GraphQL endpoint")
+	fs.DurationVar(&opts.Duration, "duration", opts.Duration, "how long to generate load")
+	fs.IntVar(&opts.Workers, "workers", opts.Workers, "concurrent request loops")
+	fs.IntVar(&opts.Subscribers, "subscribers", opts.Subscribers, "concurrent commentAdded subscriptions")
+	fs.Float64Var(&opts.MutationRatio, "mutations", opts.MutationRatio, "fraction of requests that are mutations")
+	fs.IntVar(&opts.PageSize, "page-size", opts.PageSize, "page size for list queries")
+	fs.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed")
+	fs.Var(headerFlag(opts.Headers), "header", `request header as "Name: value"; repeatable`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := loadgen.Run(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("loadgen: %w", err)
+	}
+	return report.Write(os.Stdout)
+}
@@ -2,48 +2,83 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-	"ozon-test/internal/gql"
-	"ozon-test/internal/inmemory"
-	"ozon-test/internal/models"
-	"ozon-test/internal/postgres"
-	"ozon-test/internal/pubsub"
-
-	"github.com/99designs/gqlgen/graphql/handler"
-	"github.com/99designs/gqlgen/graphql/playground"
-	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"ozon-test/internal/config"
+	"strings"
 )
 
-const defaultPort = "8080"
+const usage = `Usage: ozon-test [command] [flags]
+
+Commands:
+  serve          run the GraphQL server (default)
+  config print   print the effective configuration with secrets redacted
+  export         write all posts and comments as JSON lines (-o file)
+  import         load JSON lines produced by export (-i file, -dry-run)
+  seed           fill the configured storage with generated posts and threads
+  loadgen        drive a running server and report latency percentiles
+
+Run "ozon-test <command> -h" to list the configuration flags.
+`
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	storageType := os.Getenv("STORAGE_TYPE")
-	var storage models.Storage
-	var pubsub = pubsub.NewInMemoryPubSub()
-
-	if storageType == "postgres" {
-		db, err := sqlx.Connect("postgres", "host="+os.Getenv("DB_HOST")+" port="+os.Getenv("DB_PORT")+" user="+os.Getenv("DB_USER")+" password="+os.Getenv("DB_PASSWORD")+" dbname="+os.Getenv("DB_NAME")+" sslmode=disable")
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-		storage = postgres.NewPostgresStorage(db)
-	} else {
-		storage = inmemory.NewInMemoryStorage()
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "config":
+		err = runConfig(args)
+	case "export":
+		err = runExport(args)
+	case "import":
+		err = runImport(args)
+	case "seed":
+		err = runSeed(args)
+	case "loadgen":
+		err = runLoadgen(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: &gql.Resolver{Storage: storage, PubSub: pubsub}}))
+// loadConfig resolves the configuration for a subcommand from its flags and
+// the process environment.
+func loadConfig(name string, args []string) (config.Config, error) {
+	return config.Load("ozon-test "+name, args, os.LookupEnv)
+}
 
-	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", srv)
+// loadConfigFlags is like loadConfig for subcommands with flags of their own
+// registered on fs.
+func loadConfigFlags(fs *flag.FlagSet, args []string) (config.Config, error) {
+	return config.LoadFlags(fs, args, os.LookupEnv)
+}
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: ozon-test config print [flags]")
+	}
+
+	cfg, err := loadConfig("config print", args[1:])
+	if err != nil {
+		return err
+	}
+	return config.Print(os.Stdout, cfg)
 }
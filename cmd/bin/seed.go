@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"ozon-test/internal/seed"
+)
+
+// runSeed fills the configured storage with generated posts and threads.
+func runSeed(args []string) error {
+	opts := seed.DefaultOptions()
+	fs := flag.NewFlagSet("ozon-test seed", flag.ContinueOnError)
+	fs.IntVar(&opts.Users, "users", opts.Users, "number of distinct authors")
+	fs.IntVar(&opts.Posts, "posts", opts.Posts, "number of posts")
+	fs.Float64Var(&opts.CommentsPerPost, "comments", opts.CommentsPerPost, "mean number of comments per post")
+	fs.Float64Var(&opts.ReplyProbability, "reply-probability", opts.ReplyProbability, "chance that a comment is a reply")
+	fs.IntVar(&opts.MaxDepth, "max-depth", opts.MaxDepth, "maximum thread nesting")
+	fs.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed")
+	cfg, err := loadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	backend, err := openStorage(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer backend.close()
+
+	stats, err := seed.Generate(ctx, backend.storage, opts)
+	if err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "seeded %d posts, %d comments by %d users, max depth %d\n", stats.Posts, stats.Comments, stats.Users, stats.MaxDepth)
+	return nil
+}
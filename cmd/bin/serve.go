@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"ozon-test/internal/auth"
+	"ozon-test/internal/config"
+	"ozon-test/internal/contentfilter"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/health"
+	"ozon-test/internal/models"
+	"ozon-test/internal/outbox"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/ratelimit"
+	"ozon-test/internal/render"
+	"ozon-test/internal/tracing"
+	"ozon-test/internal/validation"
+	"ozon-test/internal/webhook"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+func runServe(args []string) error {
+	cfg, err := loadConfig("serve", args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
+	authenticator, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return err
+	}
+
+	healthHandler := health.NewHandler(cfg.Server.ReadinessTimeout)
+
+	backend, err := openStorage(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	if backend.ping != nil {
+		healthHandler.AddCheck(cfg.Storage.Backend, backend.ping)
+	}
+	// Only comments made through the API are limited; import and seed keep
+	// the nesting they are given.
+	backend.storage.SetCommentDepthLimit(models.DepthLimit{
+		MaxDepth: cfg.Limits.MaxCommentDepth,
+		Policy:   models.DepthPolicy(cfg.Limits.CommentDepthPolicy),
+	})
+
+	var postPubSub = pubsub.NewInMemoryPubSub()
+	var notificationPubSub = pubsub.NewInMemoryPubSub()
+	var commentPubSub = pubsub.NewInMemoryPubSub()
+	storage := tracing.NewStorage(backend.storage)
+	contentLimits := validation.Limits{
+		MaxTitleLength:   cfg.Limits.MaxTitleLength,
+		MaxPostLength:    cfg.Limits.MaxPostLength,
+		MaxCommentLength: cfg.Limits.MaxCommentLength,
+	}
+	webhooks := webhook.NewDispatcher(storage, webhook.Config{
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+		Timeout:        cfg.Webhooks.Timeout,
+	})
+	tracedCommentPubSub := tracing.NewPubSub(commentPubSub)
+	relay := outbox.NewRelay(storage, map[string]pubsub.PubSub{
+		models.OutboxCommentAdded: tracedCommentPubSub,
+	}, cfg.Outbox.BatchSize)
+	resolver := &gql.Resolver{
+		Storage:            storage,
+		PubSub:             tracedCommentPubSub,
+		PostPubSub:         tracing.NewPubSub(postPubSub),
+		NotificationPubSub: tracing.NewPubSub(notificationPubSub),
+		MaxPageSize:        cfg.Limits.MaxPageSize,
+		ContentLimits:      contentLimits,
+		Renderer:           render.NewRenderer(cfg.Render.CacheSize),
+		IdempotencyTTL:     cfg.Idempotency.TTL,
+		Webhooks:           webhooks,
+		Outbox:             relay,
+		ContentFilter:      newContentFilter(cfg.ContentFilter),
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	var jobs sync.WaitGroup
+	runEvery(jobsCtx, &jobs, cfg.Idempotency.PurgeInterval, func(ctx context.Context) {
+		purged, err := storage.PurgeIdempotencyKeys(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge idempotency keys", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged expired idempotency keys", "count", purged)
+		}
+	})
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		relay.Run(jobsCtx, cfg.Outbox.PollInterval)
+	}()
+	runEvery(jobsCtx, &jobs, cfg.Outbox.PurgeInterval, func(ctx context.Context) {
+		purged, err := storage.PurgeOutboxEvents(ctx, time.Now().Add(-cfg.Outbox.Retention))
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge outbox events", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged delivered outbox events", "count", purged)
+		}
+	})
+	runEvery(jobsCtx, &jobs, cfg.Scheduler.Interval, func(ctx context.Context) {
+		if _, err := resolver.PublishDuePosts(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to publish scheduled posts", "error", err)
+		}
+	})
+
+	var rateLimiter *ratelimit.Limiter
+	if len(cfg.RateLimit.Rules) > 0 {
+		// The rules were checked when the config was loaded.
+		limits, _ := ratelimit.ParseRules(cfg.RateLimit.Rules)
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimit.Backend == "postgres" {
+			store = backend.rateLimits
+			runEvery(jobsCtx, &jobs, cfg.RateLimit.PurgeInterval, func(ctx context.Context) {
+				if _, err := backend.rateLimits.Purge(ctx, time.Now()); err != nil && ctx.Err() == nil {
+					slog.Error("Failed to purge rate limit buckets", "error", err)
+				}
+			})
+		}
+		rateLimiter = ratelimit.NewLimiter(store, limits)
+	}
+
+	// Websocket connections are hijacked and therefore invisible to
+	// http.Server.Shutdown. Their contexts are tied to wsCtx so cancelling it
+	// makes gqlgen send a close frame and end every subscription.
+	wsCtx, closeWebsockets := context.WithCancel(context.Background())
+	defer closeWebsockets()
+
+	srv := newGraphQLServer(wsCtx, gql.NewExecutableSchema(gql.Config{Resolvers: resolver}), authenticator, rateLimiter, cfg.Limits)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", playground.Handler("GraphQL playground", "/query"))
+	mux.Handle("/query", tracing.Middleware(http.MaxBytesHandler(ratelimit.Middleware(cfg.RateLimit.ClientIPHeader, authenticator.Middleware(srv)), cfg.Limits.MaxRequestBodyBytes)))
+	mux.HandleFunc("/healthz", healthHandler.Liveness)
+	mux.HandleFunc("/readyz", healthHandler.Readiness)
+
+	port := strconv.Itoa(cfg.Server.Port)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("server failed: %w", err)
+		}
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received", "timeout", cfg.Server.ShutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	healthHandler.SetDraining()
+
+	// Stop accepting new connections and wait for in-flight requests (and
+	// their transactions) to finish, while telling subscribers to go away.
+	closeWebsockets()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down HTTP server gracefully", "error", err)
+	}
+
+	stopJobs()
+	jobs.Wait()
+	// Deliveries still waiting for a retry are dropped.
+	webhooks.Close()
+
+	if err := commentPubSub.Close(); err != nil {
+		slog.Error("Failed to close pubsub", "error", err)
+	}
+	if err := postPubSub.Close(); err != nil {
+		slog.Error("Failed to close pubsub", "error", err)
+	}
+	if err := notificationPubSub.Close(); err != nil {
+		slog.Error("Failed to close pubsub", "error", err)
+	}
+
+	if err := backend.close(); err != nil {
+		slog.Error("Failed to close storage", "error", err)
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server stopped")
+	return nil
+}
+
+// runEvery runs job in the background every interval until ctx is
+// cancelled.
+func runEvery(ctx context.Context, wg *sync.WaitGroup, interval time.Duration, job func(ctx context.Context)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job(ctx)
+			}
+		}
+	}()
+}
+
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	moderators := make([]uuid.UUID, 0, len(cfg.Moderators))
+	for _, id := range cfg.Moderators {
+		moderators = append(moderators, uuid.MustParse(id))
+	}
+	return auth.NewAuthenticator(auth.Config{
+		Mode:        cfg.Mode,
+		Header:      cfg.Header,
+		TokenSecret: []byte(cfg.TokenSecret),
+		Moderators:  moderators,
+	})
+}
+
+// newContentFilter builds the configured content filters, or returns nil if
+// none is enabled.
+func newContentFilter(cfg config.ContentFilterConfig) *contentfilter.Pipeline {
+	var filters []contentfilter.Filter
+	if len(cfg.BannedWords) > 0 {
+		filters = append(filters, contentfilter.NewBannedWords(cfg.BannedWords, contentfilter.Action(cfg.BannedWordsAction)))
+	}
+	if cfg.MaxLinks > 0 {
+		filters = append(filters, contentfilter.NewLinkLimit(cfg.MaxLinks, contentfilter.Action(cfg.LinksAction)))
+	}
+	if cfg.DuplicateWindow > 0 {
+		filters = append(filters, contentfilter.NewDuplicates(cfg.DuplicateWindow, contentfilter.Action(cfg.DuplicateAction)))
+	}
+	if cfg.RateLimit > 0 {
+		filters = append(filters, contentfilter.NewRate(cfg.RateLimit, cfg.RateWindow, contentfilter.Action(cfg.RateAction)))
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	slog.Info("Content filter enabled", "filters", len(filters))
+	return contentfilter.NewPipeline(filters...)
+}
+
+// newGraphQLServer mirrors handler.NewDefaultServer but binds websocket
+// connections to wsCtx so they can be closed on shutdown, authenticates them
+// from the init payload and applies the configured limits. A nil
+// rateLimiter leaves mutations unthrottled.
+func newGraphQLServer(wsCtx context.Context, es graphql.ExecutableSchema, authenticator *auth.Authenticator, rateLimiter *ratelimit.Limiter, limits config.LimitsConfig) *handler.Server {
+	srv := handler.New(es)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc: func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+			ctx, err := authenticator.WebsocketInit(ctx, initPayload)
+			if err != nil {
+				return nil, nil, err
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			context.AfterFunc(wsCtx, cancel)
+			return ctx, nil, nil
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	srv.SetQueryCache(lru.New(1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
+	if limits.QueryComplexity > 0 {
+		srv.Use(extension.FixedComplexityLimit(limits.QueryComplexity))
+	}
+	if rateLimiter != nil {
+		srv.Use(gql.RateLimit{Limiter: rateLimiter})
+	}
+	srv.Use(tracing.Extension{})
+
+	return srv
+}
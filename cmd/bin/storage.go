@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"ozon-test/internal/config"
+	"ozon-test/internal/filestore"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/postgres"
+	"ozon-test/internal/sqlite"
+	"path/filepath"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// backend is an opened storage together with its lifecycle hooks.
+type backend struct {
+	storage models.Storage
+	// ping reports whether the backing store is reachable; nil if it always is.
+	ping  func(ctx context.Context) error
+	close func() error
+	// rateLimits keeps rate limit buckets in the database; nil if the
+	// backend cannot share them between replicas.
+	rateLimits *postgres.RateLimitStore
+}
+
+// openStorage connects to the storage backend selected in cfg.
+func openStorage(ctx context.Context, cfg config.StorageConfig) (*backend, error) {
+	switch cfg.Backend {
+	case "postgres":
+		pg := cfg.Postgres
+		db, err := sqlx.ConnectContext(ctx, "postgres", pg.PostgresDSN())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		db.SetMaxOpenConns(pg.MaxOpenConns)
+		db.SetMaxIdleConns(pg.MaxIdleConns)
+		db.SetConnMaxLifetime(pg.ConnMaxLifetime)
+		return &backend{
+			storage:    postgres.NewPostgresStorage(db),
+			ping:       db.PingContext,
+			close:      db.Close,
+			rateLimits: postgres.NewRateLimitStore(db),
+		}, nil
+	case "file":
+		f := cfg.File
+		storage, err := filestore.Open(filestore.Options{
+			Dir:               f.Dir,
+			Sync:              filestore.SyncPolicy(f.Sync),
+			SyncInterval:      f.SyncInterval,
+			SnapshotInterval:  f.SnapshotInterval,
+			SnapshotThreshold: f.SnapshotThreshold,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file storage: %w", err)
+		}
+		return &backend{
+			storage: storage,
+			close:   storage.Close,
+		}, nil
+	case "sqlite":
+		path := cfg.SQLite.Path
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+		db, err := sqlite.Open(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{
+			storage: sqlite.NewSQLiteStorage(db),
+			ping:    db.PingContext,
+			close:   db.Close,
+		}, nil
+	case "inmemory":
+		return &backend{
+			storage: inmemory.NewInMemoryStorage(),
+			close:   func() error { return nil },
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"ozon-test/internal/transfer"
+)
+
+// runExport writes the contents of the configured storage as JSON lines.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("ozon-test export", flag.ContinueOnError)
+	output := fs.String("o", "-", "file to write to, - for stdout")
+	cfg, err := loadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	backend, err := openStorage(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer backend.close()
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	stats, err := transfer.Export(ctx, backend.storage, out)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d communities, %d posts, %d comments\n", stats.Communities, stats.Posts, stats.Comments)
+	return nil
+}
+
+// runImport loads JSON lines produced by export into the configured storage.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("ozon-test import", flag.ContinueOnError)
+	input := fs.String("i", "-", "file to read from, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "validate the input without writing it")
+	cfg, err := loadConfigFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	backend, err := openStorage(ctx, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer backend.close()
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	stats, err := transfer.Import(ctx, backend.storage, r, transfer.ImportOptions{DryRun: *dryRun})
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	verb := "imported"
+	if *dryRun {
+		verb = "validated"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d communities, %d posts, %d comments\n", verb, stats.Communities, stats.Posts, stats.Comments)
+	return nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Supported authentication modes.
+const (
+	// ModeNone never identifies a viewer.
+	ModeNone = "none"
+	// ModeHeader trusts a user ID header set by an upstream gateway.
+	ModeHeader = "header"
+	// ModeToken expects "Authorization: Bearer <token>" signed with SignToken.
+	ModeToken = "token"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+type Config struct {
+	Mode        string
+	Header      string
+	TokenSecret []byte
+	Moderators  []uuid.UUID
+}
+
+// Viewer is the authenticated user making the request.
+type Viewer struct {
+	UserID    uuid.UUID
+	Moderator bool
+}
+
+type viewerKey struct{}
+
+// WithViewer returns a copy of ctx carrying viewer.
+func WithViewer(ctx context.Context, viewer Viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer)
+}
+
+// ViewerFromContext returns the authenticated viewer, if any.
+func ViewerFromContext(ctx context.Context) (Viewer, bool) {
+	viewer, ok := ctx.Value(viewerKey{}).(Viewer)
+	return viewer, ok
+}
+
+// IsModerator reports whether the request was made by a moderator.
+func IsModerator(ctx context.Context) bool {
+	viewer, ok := ViewerFromContext(ctx)
+	return ok && viewer.Moderator
+}
+
+type Authenticator struct {
+	cfg        Config
+	moderators map[uuid.UUID]struct{}
+}
+
+// NewAuthenticator creates an Authenticator for the given configuration.
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+	switch cfg.Mode {
+	case "", ModeNone, ModeHeader, ModeToken:
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.Mode)
+	}
+
+	moderators := make(map[uuid.UUID]struct{}, len(cfg.Moderators))
+	for _, id := range cfg.Moderators {
+		moderators[id] = struct{}{}
+	}
+	return &Authenticator{cfg: cfg, moderators: moderators}, nil
+}
+
+// Middleware attaches the viewer identified by the request credentials.
+// Requests without credentials proceed anonymously; invalid credentials are
+// rejected with 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw string
+		switch a.cfg.Mode {
+		case ModeHeader:
+			raw = r.Header.Get(a.cfg.Header)
+		case ModeToken:
+			raw = r.Header.Get("Authorization")
+		}
+
+		ctx, err := a.authenticate(r.Context(), raw)
+		if err != nil {
+			slog.Warn("Rejected request with invalid credentials", "error", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WebsocketInit authenticates a websocket connection from its init payload,
+// for clients that cannot set headers on the upgrade request. The payload key
+// is the configured header in header mode and "Authorization" in token mode.
+func (a *Authenticator) WebsocketInit(ctx context.Context, payload map[string]any) (context.Context, error) {
+	if _, ok := ViewerFromContext(ctx); ok {
+		return ctx, nil
+	}
+
+	key := "Authorization"
+	if a.cfg.Mode == ModeHeader {
+		key = a.cfg.Header
+	}
+	raw, _ := payload[key].(string)
+	return a.authenticate(ctx, raw)
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, raw string) (context.Context, error) {
+	if raw == "" {
+		return ctx, nil
+	}
+
+	var userID uuid.UUID
+	var err error
+	switch a.cfg.Mode {
+	case ModeHeader:
+		userID, err = uuid.Parse(raw)
+	case ModeToken:
+		userID, err = a.verifyToken(strings.TrimPrefix(raw, "Bearer "))
+	default:
+		return ctx, nil
+	}
+	if err != nil {
+		return ctx, ErrInvalidCredentials
+	}
+
+	_, moderator := a.moderators[userID]
+	return WithViewer(ctx, Viewer{UserID: userID, Moderator: moderator}), nil
+}
+
+// SignToken issues a bearer token for userID.
+func SignToken(secret []byte, userID uuid.UUID) string {
+	return userID.String() + "." + signature(secret, userID)
+}
+
+func (a *Authenticator) verifyToken(token string) (uuid.UUID, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, ErrInvalidCredentials
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(a.cfg.TokenSecret, userID))) {
+		return uuid.Nil, ErrInvalidCredentials
+	}
+	return userID, nil
+}
+
+func signature(secret []byte, userID uuid.UUID) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(userID.String()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
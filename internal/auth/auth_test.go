@@ -0,0 +1,68 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ozon-test/internal/auth"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, a *auth.Authenticator, header, value string) (int, auth.Viewer, bool) {
+	var viewer auth.Viewer
+	var found bool
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		viewer, found = auth.ViewerFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	if value != "" {
+		req.Header.Set(header, value)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, viewer, found
+}
+
+func TestHeaderMode(t *testing.T) {
+	moderator := uuid.New()
+	a, err := auth.NewAuthenticator(auth.Config{Mode: auth.ModeHeader, Header: "X-User-ID", Moderators: []uuid.UUID{moderator}})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	code, viewer, ok := serve(t, a, "X-User-ID", userID.String())
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, ok, "Viewer should be set")
+	assert.Equal(t, userID, viewer.UserID)
+	assert.False(t, viewer.Moderator)
+
+	_, viewer, _ = serve(t, a, "X-User-ID", moderator.String())
+	assert.True(t, viewer.Moderator, "Configured moderators should be flagged")
+
+	code, _, ok = serve(t, a, "X-User-ID", "")
+	assert.Equal(t, http.StatusOK, code, "Anonymous requests are allowed")
+	assert.False(t, ok, "Anonymous requests have no viewer")
+
+	code, _, _ = serve(t, a, "X-User-ID", "not-a-uuid")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestTokenMode(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	a, err := auth.NewAuthenticator(auth.Config{Mode: auth.ModeToken, TokenSecret: secret})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	code, viewer, ok := serve(t, a, "Authorization", "Bearer "+auth.SignToken(secret, userID))
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, ok)
+	assert.Equal(t, userID, viewer.UserID)
+
+	forged := auth.SignToken([]byte("another secret of the same length!"), userID)
+	code, _, _ = serve(t, a, "Authorization", "Bearer "+forged)
+	assert.Equal(t, http.StatusUnauthorized, code, "Tokens signed with another secret are rejected")
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"ozon-test/internal/ratelimit"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config is the effective service configuration. Values are resolved from
+// defaults, then a YAML/TOML file, then environment variables, then flags.
+//
+// Every leaf field is addressable as a flag named after its dotted yaml path
+// (e.g. -storage.postgres.host). Fields tagged secret:"true" are redacted
+// when printed.
+type Config struct {
+	Server        ServerConfig        `yaml:"server" toml:"server"`
+	Storage       StorageConfig       `yaml:"storage" toml:"storage"`
+	PubSub        PubSubConfig        `yaml:"pubsub" toml:"pubsub"`
+	Limits        LimitsConfig        `yaml:"limits" toml:"limits"`
+	Idempotency   IdempotencyConfig   `yaml:"idempotency" toml:"idempotency"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler" toml:"scheduler"`
+	Render        RenderConfig        `yaml:"render" toml:"render"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks" toml:"webhooks"`
+	Outbox        OutboxConfig        `yaml:"outbox" toml:"outbox"`
+	ContentFilter ContentFilterConfig `yaml:"content_filter" toml:"content_filter"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit" toml:"rate_limit"`
+	Auth          AuthConfig          `yaml:"auth" toml:"auth"`
+	Tracing       TracingConfig       `yaml:"tracing" toml:"tracing"`
+}
+
+type ServerConfig struct {
+	Port             int           `yaml:"port" toml:"port" env:"PORT" usage:"HTTP listen port"`
+	ShutdownTimeout  time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"deadline for draining requests and subscriptions on shutdown"`
+	ReadinessTimeout time.Duration `yaml:"readiness_timeout" toml:"readiness_timeout" env:"READINESS_TIMEOUT" usage:"deadline for readiness checks"`
+}
+
+type StorageConfig struct {
+	Backend  string         `yaml:"backend" toml:"backend" env:"STORAGE_TYPE" usage:"storage backend: inmemory, file, sqlite or postgres"`
+	Postgres PostgresConfig `yaml:"postgres" toml:"postgres"`
+	File     FileConfig     `yaml:"file" toml:"file"`
+	SQLite   SQLiteConfig   `yaml:"sqlite" toml:"sqlite"`
+}
+
+type SQLiteConfig struct {
+	Path string `yaml:"path" toml:"path" env:"SQLITE_PATH" usage:"database file path"`
+}
+
+type FileConfig struct {
+	Dir               string        `yaml:"dir" toml:"dir" env:"STORAGE_FILE_DIR" usage:"directory holding the WAL and snapshots"`
+	Sync              string        `yaml:"sync" toml:"sync" env:"STORAGE_FILE_SYNC" usage:"WAL fsync policy: always, interval or never"`
+	SyncInterval      time.Duration `yaml:"sync_interval" toml:"sync_interval" env:"STORAGE_FILE_SYNC_INTERVAL" usage:"fsync period for the interval policy"`
+	SnapshotInterval  time.Duration `yaml:"snapshot_interval" toml:"snapshot_interval" env:"STORAGE_FILE_SNAPSHOT_INTERVAL" usage:"period between snapshots (0 = only by threshold)"`
+	SnapshotThreshold int           `yaml:"snapshot_threshold" toml:"snapshot_threshold" env:"STORAGE_FILE_SNAPSHOT_THRESHOLD" usage:"WAL records that trigger a snapshot (0 = only by interval)"`
+}
+
+type PostgresConfig struct {
+	// DSN overrides the individual connection fields when set.
+	DSN             string        `yaml:"dsn" toml:"dsn" env:"DB_DSN" secret:"true" usage:"full connection string; overrides host/port/user/password/dbname"`
+	Host            string        `yaml:"host" toml:"host" env:"DB_HOST" usage:"database host"`
+	Port            int           `yaml:"port" toml:"port" env:"DB_PORT" usage:"database port"`
+	User            string        `yaml:"user" toml:"user" env:"DB_USER" usage:"database user"`
+	Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true" usage:"database password"`
+	DBName          string        `yaml:"dbname" toml:"dbname" env:"DB_NAME" usage:"database name"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE" usage:"disable, require, verify-ca or verify-full"`
+	SSLRootCert     string        `yaml:"sslrootcert" toml:"sslrootcert" env:"DB_SSLROOTCERT" usage:"path to the CA certificate"`
+	SSLCert         string        `yaml:"sslcert" toml:"sslcert" env:"DB_SSLCERT" usage:"path to the client certificate"`
+	SSLKey          string        `yaml:"sslkey" toml:"sslkey" env:"DB_SSLKEY" usage:"path to the client key"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"maximum open connections (0 = unlimited)"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"maximum connection lifetime (0 = forever)"`
+}
+
+type PubSubConfig struct {
+	Backend string `yaml:"backend" toml:"backend" env:"PUBSUB_BACKEND" usage:"pubsub backend: inmemory"`
+}
+
+type LimitsConfig struct {
+	MaxPageSize         int    `yaml:"max_page_size" toml:"max_page_size" env:"MAX_PAGE_SIZE" usage:"largest pageSize accepted by list queries"`
+	QueryComplexity     int    `yaml:"query_complexity" toml:"query_complexity" env:"QUERY_COMPLEXITY" usage:"maximum GraphQL query complexity (0 = unlimited)"`
+	MaxRequestBodyBytes int64  `yaml:"max_request_body_bytes" toml:"max_request_body_bytes" env:"MAX_REQUEST_BODY_BYTES" usage:"maximum HTTP request body size"`
+	MaxTitleLength      int    `yaml:"max_title_length" toml:"max_title_length" env:"MAX_TITLE_LENGTH" usage:"longest post title in characters (0 = unlimited)"`
+	MaxPostLength       int    `yaml:"max_post_length" toml:"max_post_length" env:"MAX_POST_LENGTH" usage:"longest post content in characters (0 = unlimited)"`
+	MaxCommentLength    int    `yaml:"max_comment_length" toml:"max_comment_length" env:"MAX_COMMENT_LENGTH" usage:"longest comment in characters (0 = unlimited)"`
+	MaxCommentDepth     int    `yaml:"max_comment_depth" toml:"max_comment_depth" env:"MAX_COMMENT_DEPTH" usage:"deepest reply level, top-level comments being 0 (0 = unlimited)"`
+	CommentDepthPolicy  string `yaml:"comment_depth_policy" toml:"comment_depth_policy" env:"COMMENT_DEPTH_POLICY" usage:"what happens to deeper replies: reject, or reparent under the deepest allowed ancestor"`
+}
+
+type IdempotencyConfig struct {
+	TTL           time.Duration `yaml:"ttl" toml:"ttl" env:"IDEMPOTENCY_TTL" usage:"how long mutation idempotency keys are remembered"`
+	PurgeInterval time.Duration `yaml:"purge_interval" toml:"purge_interval" env:"IDEMPOTENCY_PURGE_INTERVAL" usage:"how often expired idempotency keys are deleted"`
+}
+
+type SchedulerConfig struct {
+	Interval time.Duration `yaml:"interval" toml:"interval" env:"SCHEDULER_INTERVAL" usage:"how often scheduled posts are checked for publishing"`
+}
+
+type RenderConfig struct {
+	CacheSize int `yaml:"cache_size" toml:"cache_size" env:"RENDER_CACHE_SIZE" usage:"rendered posts and comments kept in memory (0 = no cache)"`
+}
+
+type WebhooksConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" toml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" usage:"how often an event is sent to a failing webhook"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF" usage:"wait before the first retry; doubles after each further failure"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF" usage:"longest wait between retries"`
+	Timeout        time.Duration `yaml:"timeout" toml:"timeout" env:"WEBHOOK_TIMEOUT" usage:"deadline for a single delivery attempt"`
+}
+
+type OutboxConfig struct {
+	PollInterval  time.Duration `yaml:"poll_interval" toml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" usage:"how often the outbox is checked for events left behind by a restart or failed publish"`
+	BatchSize     int           `yaml:"batch_size" toml:"batch_size" env:"OUTBOX_BATCH_SIZE" usage:"outbox events read per query"`
+	Retention     time.Duration `yaml:"retention" toml:"retention" env:"OUTBOX_RETENTION" usage:"how long delivered outbox events are kept"`
+	PurgeInterval time.Duration `yaml:"purge_interval" toml:"purge_interval" env:"OUTBOX_PURGE_INTERVAL" usage:"how often delivered outbox events past their retention are deleted"`
+}
+
+// ContentFilterConfig enables the content filters; each is off until
+// configured. Actions are reject, flag or mask; duplicates and rate cannot
+// mask.
+type ContentFilterConfig struct {
+	BannedWords       []string      `yaml:"banned_words" toml:"banned_words" env:"CONTENT_FILTER_BANNED_WORDS" usage:"comma-separated words and phrases to filter"`
+	BannedWordsAction string        `yaml:"banned_words_action" toml:"banned_words_action" env:"CONTENT_FILTER_BANNED_WORDS_ACTION" usage:"what happens to content with banned words"`
+	MaxLinks          int           `yaml:"max_links" toml:"max_links" env:"CONTENT_FILTER_MAX_LINKS" usage:"most links in a post or comment (0 = unlimited)"`
+	LinksAction       string        `yaml:"links_action" toml:"links_action" env:"CONTENT_FILTER_LINKS_ACTION" usage:"what happens to content with too many links"`
+	DuplicateWindow   time.Duration `yaml:"duplicate_window" toml:"duplicate_window" env:"CONTENT_FILTER_DUPLICATE_WINDOW" usage:"how long an author's texts are remembered to catch repeats (0 = off)"`
+	DuplicateAction   string        `yaml:"duplicate_action" toml:"duplicate_action" env:"CONTENT_FILTER_DUPLICATE_ACTION" usage:"what happens to repeated content"`
+	RateLimit         int           `yaml:"rate_limit" toml:"rate_limit" env:"CONTENT_FILTER_RATE_LIMIT" usage:"posts and comments an author may create per rate_window (0 = off)"`
+	RateWindow        time.Duration `yaml:"rate_window" toml:"rate_window" env:"CONTENT_FILTER_RATE_WINDOW" usage:"period rate_limit applies to"`
+	RateAction        string        `yaml:"rate_action" toml:"rate_action" env:"CONTENT_FILTER_RATE_ACTION" usage:"what happens to content past the rate limit"`
+}
+
+// RateLimitConfig limits how often each user, or each client address for
+// unauthenticated requests, may run a mutation.
+type RateLimitConfig struct {
+	Rules          []string      `yaml:"rules" toml:"rules" env:"RATE_LIMIT_RULES" usage:"comma-separated operation=count/period mutation limits; * covers mutations without their own"`
+	Backend        string        `yaml:"backend" toml:"backend" env:"RATE_LIMIT_BACKEND" usage:"where buckets are kept: inmemory, or postgres to share them between replicas"`
+	ClientIPHeader string        `yaml:"client_ip_header" toml:"client_ip_header" env:"RATE_LIMIT_CLIENT_IP_HEADER" usage:"header in which a trusted proxy passes the client address, e.g. X-Forwarded-For (empty = connection address)"`
+	PurgeInterval  time.Duration `yaml:"purge_interval" toml:"purge_interval" env:"RATE_LIMIT_PURGE_INTERVAL" usage:"how often full buckets are deleted from postgres"`
+}
+
+type AuthConfig struct {
+	Mode        string   `yaml:"mode" toml:"mode" env:"AUTH_MODE" usage:"none, header or token"`
+	Header      string   `yaml:"header" toml:"header" env:"AUTH_HEADER" usage:"header carrying the user ID in header mode"`
+	TokenSecret string   `yaml:"token_secret" toml:"token_secret" env:"AUTH_TOKEN_SECRET" secret:"true" usage:"HMAC secret for bearer tokens in token mode"`
+	Moderators  []string `yaml:"moderators" toml:"moderators" env:"AUTH_MODERATORS" usage:"comma-separated user IDs with moderator rights"`
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" toml:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"none, stdout or otlp"`
+	Endpoint    string `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector host:port"`
+	Insecure    bool   `yaml:"insecure" toml:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE" usage:"disable TLS for the OTLP exporter"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME" usage:"service.name resource attribute"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:             8080,
+			ShutdownTimeout:  15 * time.Second,
+			ReadinessTimeout: 2 * time.Second,
+		},
+		Storage: StorageConfig{
+			Backend: "inmemory",
+			Postgres: PostgresConfig{
+				Host:         "localhost",
+				Port:         5432,
+				User:         "postgres",
+				DBName:       "ozon",
+				SSLMode:      "disable",
+				MaxOpenConns: 25,
+				MaxIdleConns: 25,
+			},
+			File: FileConfig{
+				Dir:               "data",
+				Sync:              "always",
+				SyncInterval:      time.Second,
+				SnapshotInterval:  5 * time.Minute,
+				SnapshotThreshold: 10000,
+			},
+			SQLite: SQLiteConfig{
+				Path: "data/ozon.db",
+			},
+		},
+		PubSub: PubSubConfig{
+			Backend: "inmemory",
+		},
+		Limits: LimitsConfig{
+			MaxPageSize:         100,
+			QueryComplexity:     200,
+			MaxRequestBodyBytes: 1 << 20,
+			MaxTitleLength:      200,
+			MaxPostLength:       20000,
+			MaxCommentLength:    5000,
+			CommentDepthPolicy:  "reject",
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:           24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Scheduler: SchedulerConfig{
+			Interval: 10 * time.Second,
+		},
+		Render: RenderConfig{
+			CacheSize: 10000,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+			Timeout:        10 * time.Second,
+		},
+		Outbox: OutboxConfig{
+			PollInterval:  time.Second,
+			BatchSize:     100,
+			Retention:     24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		ContentFilter: ContentFilterConfig{
+			BannedWordsAction: "mask",
+			LinksAction:       "reject",
+			DuplicateAction:   "reject",
+			RateWindow:        time.Minute,
+			RateAction:        "flag",
+		},
+		RateLimit: RateLimitConfig{
+			Backend:       "inmemory",
+			PurgeInterval: 10 * time.Minute,
+		},
+		Auth: AuthConfig{
+			Mode:   "none",
+			Header: "X-User-ID",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "ozon-test",
+		},
+	}
+}
+
+// Validate reports every invalid value at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.ReadinessTimeout > 0, "server.readiness_timeout must be positive")
+
+	switch c.Storage.Backend {
+	case "inmemory":
+	case "file":
+		f := c.Storage.File
+		check(f.Dir != "", "storage.file.dir is required")
+		check(oneOf(f.Sync, "always", "interval", "never"), "storage.file.sync %q is not supported", f.Sync)
+		check(f.Sync != "interval" || f.SyncInterval > 0, "storage.file.sync_interval must be positive for the interval policy")
+		check(f.SnapshotInterval >= 0, "storage.file.snapshot_interval must not be negative")
+		check(f.SnapshotThreshold >= 0, "storage.file.snapshot_threshold must not be negative")
+	case "sqlite":
+		check(c.Storage.SQLite.Path != "", "storage.sqlite.path is required")
+	case "postgres":
+		pg := c.Storage.Postgres
+		if pg.DSN == "" {
+			check(pg.Host != "", "storage.postgres.host is required")
+			check(pg.Port > 0 && pg.Port < 65536, "storage.postgres.port must be between 1 and 65535, got %d", pg.Port)
+			check(pg.User != "", "storage.postgres.user is required")
+			check(pg.DBName != "", "storage.postgres.dbname is required")
+		} else if strings.Contains(pg.DSN, "://") {
+			_, err := url.Parse(pg.DSN)
+			check(err == nil, "storage.postgres.dsn is not a valid URL")
+		}
+		check(oneOf(pg.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+			"storage.postgres.sslmode %q is not supported", pg.SSLMode)
+		if pg.SSLMode == "verify-ca" || pg.SSLMode == "verify-full" {
+			check(pg.SSLRootCert != "", "storage.postgres.sslrootcert is required for sslmode %s", pg.SSLMode)
+		}
+		check((pg.SSLCert == "") == (pg.SSLKey == ""), "storage.postgres.sslcert and sslkey must be set together")
+		check(pg.MaxOpenConns >= 0, "storage.postgres.max_open_conns must not be negative")
+		check(pg.MaxIdleConns >= 0, "storage.postgres.max_idle_conns must not be negative")
+		check(pg.MaxOpenConns == 0 || pg.MaxIdleConns <= pg.MaxOpenConns, "storage.postgres.max_idle_conns must not exceed max_open_conns")
+		check(pg.ConnMaxLifetime >= 0, "storage.postgres.conn_max_lifetime must not be negative")
+	default:
+		check(false, "storage.backend %q is not supported", c.Storage.Backend)
+	}
+
+	check(oneOf(c.PubSub.Backend, "inmemory"), "pubsub.backend %q is not supported", c.PubSub.Backend)
+
+	check(c.Limits.MaxPageSize > 0, "limits.max_page_size must be positive")
+	check(c.Limits.QueryComplexity >= 0, "limits.query_complexity must not be negative")
+	check(c.Limits.MaxRequestBodyBytes > 0, "limits.max_request_body_bytes must be positive")
+	check(c.Limits.MaxTitleLength >= 0, "limits.max_title_length must not be negative")
+	check(c.Limits.MaxPostLength >= 0, "limits.max_post_length must not be negative")
+	check(c.Limits.MaxCommentLength >= 0, "limits.max_comment_length must not be negative")
+	check(c.Limits.MaxCommentDepth >= 0, "limits.max_comment_depth must not be negative")
+	check(oneOf(c.Limits.CommentDepthPolicy, "reject", "reparent"), "limits.comment_depth_policy %q is not supported", c.Limits.CommentDepthPolicy)
+
+	check(c.Idempotency.TTL > 0, "idempotency.ttl must be positive")
+	check(c.Idempotency.PurgeInterval > 0, "idempotency.purge_interval must be positive")
+	check(c.Scheduler.Interval > 0, "scheduler.interval must be positive")
+	check(c.Render.CacheSize >= 0, "render.cache_size must not be negative")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+	check(c.Webhooks.InitialBackoff > 0, "webhooks.initial_backoff must be positive")
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff must not be less than initial_backoff")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+	check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	check(c.Outbox.Retention >= 0, "outbox.retention must not be negative")
+	check(c.Outbox.PurgeInterval > 0, "outbox.purge_interval must be positive")
+	check(oneOf(c.ContentFilter.BannedWordsAction, "reject", "flag", "mask"), "content_filter.banned_words_action %q is not supported", c.ContentFilter.BannedWordsAction)
+	check(c.ContentFilter.MaxLinks >= 0, "content_filter.max_links must not be negative")
+	check(oneOf(c.ContentFilter.LinksAction, "reject", "flag", "mask"), "content_filter.links_action %q is not supported", c.ContentFilter.LinksAction)
+	check(c.ContentFilter.DuplicateWindow >= 0, "content_filter.duplicate_window must not be negative")
+	check(oneOf(c.ContentFilter.DuplicateAction, "reject", "flag"), "content_filter.duplicate_action %q is not supported", c.ContentFilter.DuplicateAction)
+	check(c.ContentFilter.RateLimit >= 0, "content_filter.rate_limit must not be negative")
+	check(c.ContentFilter.RateLimit == 0 || c.ContentFilter.RateWindow > 0, "content_filter.rate_window must be positive when rate_limit is set")
+	check(oneOf(c.ContentFilter.RateAction, "reject", "flag"), "content_filter.rate_action %q is not supported", c.ContentFilter.RateAction)
+	if _, err := ratelimit.ParseRules(c.RateLimit.Rules); err != nil {
+		check(false, "rate_limit.rules: %v", err)
+	}
+	check(oneOf(c.RateLimit.Backend, "inmemory", "postgres"), "rate_limit.backend %q is not supported", c.RateLimit.Backend)
+	check(c.RateLimit.Backend != "postgres" || c.Storage.Backend == "postgres", "rate_limit.backend postgres requires storage.backend postgres")
+	check(c.RateLimit.PurgeInterval > 0, "rate_limit.purge_interval must be positive")
+
+	switch c.Auth.Mode {
+	case "none":
+	case "header":
+		check(c.Auth.Header != "", "auth.header is required in header mode")
+	case "token":
+		check(len(c.Auth.TokenSecret) >= 32, "auth.token_secret must be at least 32 characters in token mode")
+	default:
+		check(false, "auth.mode %q is not supported", c.Auth.Mode)
+	}
+	for _, id := range c.Auth.Moderators {
+		_, err := uuid.Parse(id)
+		check(err == nil, "auth.moderators: %q is not a valid user ID", id)
+	}
+
+	check(oneOf(c.Tracing.Exporter, "none", "stdout", "otlp"), "tracing.exporter %q is not supported", c.Tracing.Exporter)
+
+	return errors.Join(errs...)
+}
+
+// PostgresDSN returns the lib/pq connection string for the configured database.
+func (c PostgresConfig) PostgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", fmt.Sprint(c.Port)},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.DBName},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a key/value connection string value as lib/pq expects.
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
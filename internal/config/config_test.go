@@ -0,0 +1,126 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ozon-test/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := config.Load("test", nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), cfg, "Defaults should be valid and unchanged")
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  shutdown_timeout: 30s
+storage:
+  backend: postgres
+  postgres:
+    host: file-host
+    user: file-user
+    dbname: file-db
+`)
+
+	cfg, err := config.Load("test",
+		[]string{"-config", path, "-storage.postgres.user", "flag-user"},
+		env(map[string]string{"DB_HOST": "env-host", "DB_USER": "env-user"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 9000, cfg.Server.Port, "File overrides defaults")
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout, "Durations are parsed from the file")
+	assert.Equal(t, "env-host", cfg.Storage.Postgres.Host, "Env overrides the file")
+	assert.Equal(t, "flag-user", cfg.Storage.Postgres.User, "Flags override env")
+	assert.Equal(t, "file-db", cfg.Storage.Postgres.DBName)
+	assert.Equal(t, 5432, cfg.Storage.Postgres.Port, "Unset values keep their defaults")
+}
+
+func TestLoadTOMLFromEnv(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[auth]
+mode = "header"
+moderators = ["6f1c1a3e-6b9a-4c1e-9c53-6a0b8e1a2f11"]
+
+[limits]
+max_page_size = 50
+`)
+
+	cfg, err := config.Load("test", nil, env(map[string]string{config.ConfigFileEnv: path}))
+	require.NoError(t, err)
+	assert.Equal(t, "header", cfg.Auth.Mode)
+	assert.Equal(t, []string{"6f1c1a3e-6b9a-4c1e-9c53-6a0b8e1a2f11"}, cfg.Auth.Moderators)
+	assert.Equal(t, 50, cfg.Limits.MaxPageSize)
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  prot: 9000\n")
+	_, err := config.Load("test", []string{"-config", path}, env(nil))
+	assert.Error(t, err, "Typos in the config file should be reported")
+}
+
+func TestValidate(t *testing.T) {
+	_, err := config.Load("test", []string{"-server.port", "0", "-storage.backend", "mysql", "-auth.mode", "token", "-content_filter.duplicate_action", "mask", "-rate_limit.rules", "createComment=0/1m"}, env(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "storage.backend")
+	assert.Contains(t, err.Error(), "auth.token_secret")
+	assert.Contains(t, err.Error(), "content_filter.duplicate_action", "Only some filters can mask")
+	assert.Contains(t, err.Error(), "rate_limit.rules")
+
+	_, err = config.Load("test", nil, env(map[string]string{"DB_PORT": "abc"}))
+	assert.Error(t, err, "Malformed env values should be reported")
+}
+
+func TestPostgresDSN(t *testing.T) {
+	pg := config.Default().Storage.Postgres
+	pg.Password = "it's secret"
+	pg.SSLMode = "verify-full"
+	pg.SSLRootCert = "/etc/ssl/ca.pem"
+
+	assert.Equal(t,
+		`host=localhost port=5432 user=postgres password='it\'s secret' dbname=ozon sslmode=verify-full sslrootcert=/etc/ssl/ca.pem`,
+		pg.PostgresDSN())
+
+	pg.DSN = "postgres://u:p@db/ozon"
+	assert.Equal(t, "postgres://u:p@db/ozon", pg.PostgresDSN(), "An explicit DSN wins")
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage.Postgres.Password = "hunter2"
+	cfg.Auth.TokenSecret = "super-secret-token-signing-key-value"
+
+	var buf bytes.Buffer
+	require.NoError(t, config.Print(&buf, cfg))
+
+	out := buf.String()
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "super-secret")
+	assert.Contains(t, out, "password: <redacted>")
+	assert.Contains(t, out, `dsn: ""`, "Empty secrets are shown as empty")
+	assert.Contains(t, out, "shutdown_timeout: 15s")
+}
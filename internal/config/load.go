@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable used to locate the config
+// file when the -config flag is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a leaf configuration value together with its metadata.
+type field struct {
+	path   string
+	env    string
+	secret bool
+	usage  string
+	value  reflect.Value
+}
+
+// fields lists every leaf of cfg in declaration order.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			path := prefix + yamlName(sf)
+			fv := v.Field(i)
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				walk(fv, path+".")
+				continue
+			}
+			out = append(out, field{
+				path:   path,
+				env:    sf.Tag.Get("env"),
+				secret: sf.Tag.Get("secret") == "true",
+				usage:  sf.Tag.Get("usage"),
+				value:  fv,
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+func yamlName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// flagValue records a raw flag string so it can be applied after the file
+// and environment have been read.
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (f *flagValue) String() string     { return f.raw }
+func (f *flagValue) Set(s string) error { f.raw = s; return nil }
+func (f *flagValue) IsBoolFlag() bool   { return f.isBool }
+
+// Load resolves the configuration from defaults, the config file, the
+// environment and command-line flags, in increasing order of precedence,
+// and validates the result.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	return LoadFlags(flag.NewFlagSet(name, flag.ContinueOnError), args, lookupEnv)
+}
+
+// LoadFlags is like Load but parses args with fs, so that commands can
+// register their own flags alongside the configuration flags.
+func LoadFlags(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	all := fields(&cfg)
+
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (env "+ConfigFileEnv+")")
+	flagValues := make(map[string]*flagValue, len(all))
+	for _, f := range all {
+		fv := &flagValue{isBool: f.value.Kind() == reflect.Bool}
+		flagValues[f.path] = fv
+		usage := f.usage
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		fs.Var(fv, f.path, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(ConfigFileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range all {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := lookupEnv(f.env)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			return Config{}, fmt.Errorf("env %s: %w", f.env, err)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		fv, ok := flagValues[fl.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, f := range all {
+			if f.path == fl.Name {
+				if err := setValue(f.value, fv.raw); err != nil {
+					flagErr = fmt.Errorf("flag -%s: %w", fl.Name, err)
+				}
+				return
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file into cfg, rejecting unknown keys.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parse %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension, use .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// setValue parses raw into v according to v's type. Slices are comma-separated.
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
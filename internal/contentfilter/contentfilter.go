@@ -0,0 +1,98 @@
+// Package contentfilter screens posts and comments before they are stored.
+// Filters run in order and may reject content, flag it for moderation or
+// mask the offending parts.
+package contentfilter
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Action is what happens to content a filter matches.
+type Action string
+
+const (
+	// Reject refuses to store the content.
+	Reject Action = "reject"
+	// Flag stores the content and opens a report about it.
+	Flag Action = "flag"
+	// Mask stores the content with the matched parts replaced.
+	Mask Action = "mask"
+)
+
+// ReporterID is the reporter of reports opened for flagged content.
+var ReporterID = uuid.Nil
+
+// Content is a post or comment about to be stored. Filters that mask
+// rewrite Title and Text in place.
+type Content struct {
+	AuthorID uuid.UUID
+	// Comment is set for comments and unset for posts.
+	Comment bool
+	// Edit is set when existing content is being changed rather than new
+	// content created.
+	Edit  bool
+	Title string
+	Text  string
+	At    time.Time
+}
+
+// Match is a filter's objection to content.
+type Match struct {
+	Filter  string
+	Action  Action
+	Reason  models.ReportReason
+	Message string
+}
+
+// Filter checks content. A filter returning a Mask match must already
+// have masked the content.
+type Filter interface {
+	Check(ctx context.Context, content *Content) (*Match, error)
+}
+
+// RejectedError is returned for content a filter rejected.
+type RejectedError struct {
+	Match Match
+}
+
+func (e *RejectedError) Error() string {
+	return "content rejected: " + e.Match.Message
+}
+
+// Pipeline runs filters in order.
+type Pipeline struct {
+	filters []Filter
+}
+
+// NewPipeline creates a pipeline of filters, run in the given order.
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Check runs every filter over content. The first rejection stops the
+// pipeline and is returned as a *RejectedError; otherwise the flags raised
+// are returned so that the caller can report the content once it is
+// stored.
+func (p *Pipeline) Check(ctx context.Context, content *Content) ([]Match, error) {
+	var flags []Match
+	for _, filter := range p.filters {
+		match, err := filter.Check(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+		if match == nil {
+			continue
+		}
+		switch match.Action {
+		case Reject:
+			return nil, &RejectedError{Match: *match}
+		case Flag:
+			flags = append(flags, *match)
+		}
+	}
+	return flags, nil
+}
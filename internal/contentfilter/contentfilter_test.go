@@ -0,0 +1,134 @@
+package contentfilter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/contentfilter"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBannedWords(t *testing.T) {
+	ctx := context.Background()
+	filter := contentfilter.NewBannedWords([]string{"Spam", "buy now"}, contentfilter.Mask)
+
+	for _, text := range []string{
+		"no SPAM here",
+		"no ｓｐａｍ here",            // fullwidth letters
+		"no spám here",            // accent
+		"no sp\u200bam here",      // zero-width space
+		"no spa\u0301m here",      // combining accent
+		"please Buy  Now, thanks", // phrase across whitespace
+	} {
+		content := &contentfilter.Content{Title: "title", Text: text}
+		match, err := filter.Check(ctx, content)
+		require.NoError(t, err)
+		require.NotNil(t, match, text)
+		assert.Equal(t, contentfilter.Mask, match.Action)
+		assert.NotEqual(t, text, content.Text, "Matched words are masked")
+		assert.Contains(t, content.Text, "***")
+	}
+
+	content := &contentfilter.Content{Title: "Spam", Text: "no spam here"}
+	_, err := filter.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, "****", content.Title)
+	assert.Equal(t, "no **** here", content.Text)
+
+	content = &contentfilter.Content{Text: "spammer and antispam are fine"}
+	match, err := filter.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Nil(t, match, "Only whole words match")
+
+	flag := contentfilter.NewBannedWords([]string{"spam"}, contentfilter.Flag)
+	content = &contentfilter.Content{Text: "spam"}
+	match, err = flag.Check(ctx, content)
+	require.NoError(t, err)
+	require.NotNil(t, match)
+	assert.Equal(t, "spam", content.Text, "Only masking changes the content")
+}
+
+func TestLinkLimit(t *testing.T) {
+	ctx := context.Background()
+	text := "see https://a.example and www.b.example or [c](http://c.example)"
+
+	match, err := contentfilter.NewLinkLimit(3, contentfilter.Reject).Check(ctx, &contentfilter.Content{Text: text})
+	require.NoError(t, err)
+	assert.Nil(t, match)
+
+	match, err = contentfilter.NewLinkLimit(2, contentfilter.Reject).Check(ctx, &contentfilter.Content{Title: "http://d.example", Text: text})
+	require.NoError(t, err)
+	require.NotNil(t, match)
+	assert.Equal(t, contentfilter.Reject, match.Action)
+
+	content := &contentfilter.Content{Text: text}
+	_, err = contentfilter.NewLinkLimit(1, contentfilter.Mask).Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, "see https://a.example and [link removed] or [c]([link removed])", content.Text)
+}
+
+func TestDuplicates(t *testing.T) {
+	ctx := context.Background()
+	filter := contentfilter.NewDuplicates(time.Minute, contentfilter.Reject)
+	author := uuid.New()
+	now := time.Now()
+	check := func(content contentfilter.Content) *contentfilter.Match {
+		match, err := filter.Check(ctx, &content)
+		require.NoError(t, err)
+		return match
+	}
+
+	assert.Nil(t, check(contentfilter.Content{AuthorID: author, Comment: true, Text: "Hello there", At: now}))
+	assert.NotNil(t, check(contentfilter.Content{AuthorID: author, Comment: true, Text: " hello  THERE ", At: now.Add(time.Second)}),
+		"Case and whitespace do not make a copy new")
+	assert.Nil(t, check(contentfilter.Content{AuthorID: uuid.New(), Comment: true, Text: "Hello there", At: now}), "Other authors may say the same")
+	assert.Nil(t, check(contentfilter.Content{AuthorID: author, Text: "Hello there", At: now}), "Posts and comments are compared separately")
+	assert.Nil(t, check(contentfilter.Content{AuthorID: author, Comment: true, Edit: true, Text: "Hello there", At: now}), "Edits are not checked")
+	assert.Nil(t, check(contentfilter.Content{AuthorID: author, Comment: true, Text: "Hello there", At: now.Add(2 * time.Minute)}),
+		"Texts are forgotten after the window")
+}
+
+func TestRate(t *testing.T) {
+	ctx := context.Background()
+	filter := contentfilter.NewRate(2, time.Minute, contentfilter.Flag)
+	author := uuid.New()
+	now := time.Now()
+	check := func(at time.Time) *contentfilter.Match {
+		match, err := filter.Check(ctx, &contentfilter.Content{AuthorID: author, At: at})
+		require.NoError(t, err)
+		return match
+	}
+
+	assert.Nil(t, check(now))
+	assert.Nil(t, check(now.Add(time.Second)))
+	match := check(now.Add(2 * time.Second))
+	require.NotNil(t, match)
+	assert.Equal(t, contentfilter.Flag, match.Action)
+	assert.Nil(t, check(now.Add(61*time.Second)), "Only the window counts")
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	pipeline := contentfilter.NewPipeline(
+		contentfilter.NewBannedWords([]string{"darn"}, contentfilter.Mask),
+		contentfilter.NewLinkLimit(0, contentfilter.Flag),
+		contentfilter.NewDuplicates(time.Minute, contentfilter.Reject),
+	)
+	author := uuid.New()
+
+	content := &contentfilter.Content{AuthorID: author, Text: "darn, see http://a.example", At: time.Now()}
+	flags, err := pipeline.Check(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, "****, see http://a.example", content.Text)
+	require.Len(t, flags, 1, "Masking is not reported")
+	assert.Equal(t, "links", flags[0].Filter)
+
+	_, err = pipeline.Check(ctx, &contentfilter.Content{AuthorID: author, Text: "darn, see http://a.example", At: time.Now()})
+	var rejected *contentfilter.RejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "duplicates", rejected.Match.Filter)
+}
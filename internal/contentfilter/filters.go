@@ -0,0 +1,319 @@
+package contentfilter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"ozon-test/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+// BannedWords matches words and phrases from a list, ignoring case,
+// accents, compatibility forms such as fullwidth letters and invisible
+// characters hidden inside words. Masking replaces each matched word with
+// asterisks.
+type BannedWords struct {
+	action Action
+	// phrases holds the normalised words of each entry, keyed by its first
+	// word.
+	phrases map[string][][]string
+}
+
+// NewBannedWords creates a filter for words; entries of several words match
+// those words in sequence.
+func NewBannedWords(words []string, action Action) *BannedWords {
+	f := &BannedWords{action: action, phrases: make(map[string][][]string)}
+	for _, word := range words {
+		var phrase []string
+		for _, t := range tokenize(word) {
+			phrase = append(phrase, t.word)
+		}
+		if len(phrase) > 0 {
+			f.phrases[phrase[0]] = append(f.phrases[phrase[0]], phrase)
+		}
+	}
+	return f
+}
+
+// Check implements Filter.
+func (f *BannedWords) Check(ctx context.Context, content *Content) (*Match, error) {
+	title, titleHits := f.mask(content.Title)
+	text, textHits := f.mask(content.Text)
+	if titleHits+textHits == 0 {
+		return nil, nil
+	}
+	if f.action == Mask {
+		content.Title, content.Text = title, text
+	}
+	return &Match{Filter: "banned_words", Action: f.action, Reason: models.ReasonAbuse, Message: "contains banned words"}, nil
+}
+
+// mask returns s with banned words masked and how many were found.
+func (f *BannedWords) mask(s string) (string, int) {
+	tokens := tokenize(s)
+	var masked []token
+	for i := 0; i < len(tokens); i++ {
+		for _, phrase := range f.phrases[tokens[i].word] {
+			if matchesAt(tokens[i:], phrase) {
+				masked = append(masked, tokens[i:i+len(phrase)]...)
+				i += len(phrase) - 1
+				break
+			}
+		}
+	}
+	if len(masked) == 0 {
+		return s, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, t := range masked {
+		b.WriteString(s[last:t.start])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(t.word)))
+		last = t.end
+	}
+	b.WriteString(s[last:])
+	return b.String(), len(masked)
+}
+
+func matchesAt(tokens []token, phrase []string) bool {
+	if len(tokens) < len(phrase) {
+		return false
+	}
+	for i, word := range phrase {
+		if tokens[i].word != word {
+			return false
+		}
+	}
+	return true
+}
+
+// token is a word of a text: its normalised form and byte offsets.
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits s into runs of letters and digits. Combining marks and
+// invisible format characters do not end a word, so that they cannot be
+// used to split a banned word.
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range s {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			tokens = appendToken(tokens, s, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, s, start, len(s))
+	}
+	return tokens
+}
+
+func appendToken(tokens []token, s string, start, end int) []token {
+	if word := normalize(s[start:end]); word != "" {
+		tokens = append(tokens, token{word: word, start: start, end: end})
+	}
+	return tokens
+}
+
+// normalize folds s for comparison: compatibility forms are decomposed,
+// accents and format characters dropped and letters lower-cased.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>()\[\]]+`)
+
+// LinkLimit matches content with more than a number of links. Masking
+// removes the links past the limit.
+type LinkLimit struct {
+	max    int
+	action Action
+}
+
+// NewLinkLimit creates a filter allowing at most max links.
+func NewLinkLimit(max int, action Action) *LinkLimit {
+	return &LinkLimit{max: max, action: action}
+}
+
+// Check implements Filter.
+func (f *LinkLimit) Check(ctx context.Context, content *Content) (*Match, error) {
+	links := len(linkPattern.FindAllStringIndex(content.Title, -1)) + len(linkPattern.FindAllStringIndex(content.Text, -1))
+	if links <= f.max {
+		return nil, nil
+	}
+	if f.action == Mask {
+		kept := 0
+		strip := func(link string) string {
+			if kept++; kept <= f.max {
+				return link
+			}
+			return "[link removed]"
+		}
+		content.Title = linkPattern.ReplaceAllStringFunc(content.Title, strip)
+		content.Text = linkPattern.ReplaceAllStringFunc(content.Text, strip)
+	}
+	return &Match{Filter: "links", Action: f.action, Reason: models.ReasonSpam,
+		Message: fmt.Sprintf("contains %d links, more than the %d allowed", links, f.max)}, nil
+}
+
+// Duplicates matches an author posting the same text again within a window.
+// Texts are compared after normalisation, so changes of case, accents or
+// whitespace do not make a copy new. Edits are not checked. The history is
+// kept in memory and is not shared between instances.
+type Duplicates struct {
+	window time.Duration
+	action Action
+
+	mu    sync.Mutex
+	seen  map[uuid.UUID][]fingerprint
+	swept time.Time
+}
+
+type fingerprint struct {
+	hash [sha256.Size]byte
+	at   time.Time
+}
+
+// NewDuplicates creates a filter remembering each author's texts for
+// window.
+func NewDuplicates(window time.Duration, action Action) *Duplicates {
+	return &Duplicates{window: window, action: action, seen: make(map[uuid.UUID][]fingerprint)}
+}
+
+// Check implements Filter. Masking is not supported and only records the
+// text.
+func (f *Duplicates) Check(ctx context.Context, content *Content) (*Match, error) {
+	if content.Edit {
+		return nil, nil
+	}
+	kind := "post"
+	if content.Comment {
+		kind = "comment"
+	}
+	hash := sha256.Sum256([]byte(kind + "\x00" + strings.Join(strings.Fields(normalize(content.Title)), " ") +
+		"\x00" + strings.Join(strings.Fields(normalize(content.Text)), " ")))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	since := content.At.Add(-f.window)
+	f.sweep(content.At)
+	recent := pruneFingerprints(f.seen[content.AuthorID], since)
+	for _, fp := range recent {
+		if fp.hash == hash {
+			f.seen[content.AuthorID] = recent
+			if f.action == Mask {
+				return nil, nil
+			}
+			return &Match{Filter: "duplicates", Action: f.action, Reason: models.ReasonSpam,
+				Message: fmt.Sprintf("repeats a %s posted in the last %s", kind, f.window)}, nil
+		}
+	}
+	f.seen[content.AuthorID] = append(recent, fingerprint{hash: hash, at: content.At})
+	return nil, nil
+}
+
+// sweep forgets authors with nothing recent, at most once per window.
+func (f *Duplicates) sweep(now time.Time) {
+	if now.Sub(f.swept) < f.window {
+		return
+	}
+	f.swept = now
+	for author, fps := range f.seen {
+		if fps = pruneFingerprints(fps, now.Add(-f.window)); len(fps) == 0 {
+			delete(f.seen, author)
+		} else {
+			f.seen[author] = fps
+		}
+	}
+}
+
+func pruneFingerprints(fps []fingerprint, since time.Time) []fingerprint {
+	i := 0
+	for i < len(fps) && !fps[i].at.After(since) {
+		i++
+	}
+	return fps[i:]
+}
+
+// Rate matches authors creating more than a number of posts and comments
+// within a window, a common trait of spam bots. Edits are not counted. The
+// counts are kept in memory and are not shared between instances.
+type Rate struct {
+	limit  int
+	window time.Duration
+	action Action
+
+	mu     sync.Mutex
+	recent map[uuid.UUID][]time.Time
+	swept  time.Time
+}
+
+// NewRate creates a filter allowing limit posts and comments per author in
+// any window.
+func NewRate(limit int, window time.Duration, action Action) *Rate {
+	return &Rate{limit: limit, window: window, action: action, recent: make(map[uuid.UUID][]time.Time)}
+}
+
+// Check implements Filter. Masking is not supported and only counts the
+// content.
+func (f *Rate) Check(ctx context.Context, content *Content) (*Match, error) {
+	if content.Edit {
+		return nil, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(content.At)
+	times := append(pruneTimes(f.recent[content.AuthorID], content.At.Add(-f.window)), content.At)
+	f.recent[content.AuthorID] = times
+	if len(times) <= f.limit || f.action == Mask {
+		return nil, nil
+	}
+	return &Match{Filter: "rate", Action: f.action, Reason: models.ReasonSpam,
+		Message: fmt.Sprintf("more than %d posts and comments in %s", f.limit, f.window)}, nil
+}
+
+// sweep forgets authors with nothing recent, at most once per window.
+func (f *Rate) sweep(now time.Time) {
+	if now.Sub(f.swept) < f.window {
+		return
+	}
+	f.swept = now
+	for author, times := range f.recent {
+		if times = pruneTimes(times, now.Add(-f.window)); len(times) == 0 {
+			delete(f.recent, author)
+		} else {
+			f.recent[author] = times
+		}
+	}
+}
+
+func pruneTimes(times []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(since) {
+		i++
+	}
+	return times[i:]
+}
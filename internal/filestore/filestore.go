@@ -0,0 +1,790 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// SyncPolicy controls when WAL appends are flushed to disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every write; nothing acknowledged is ever lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs in the background every Options.SyncInterval; a
+	// crash may lose writes acknowledged within the last interval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+var ErrClosed = errors.New("file storage is closed")
+
+type Options struct {
+	Dir          string
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// SnapshotInterval triggers a periodic snapshot and WAL compaction; zero disables it.
+	SnapshotInterval time.Duration
+	// SnapshotThreshold compacts once this many records are in the WAL; zero disables it.
+	SnapshotThreshold int
+}
+
+// FileStorage is a durable models.Storage for single-node deployments. All
+// data is served from an InMemoryStorage; every mutation is first appended to
+// a write-ahead log, and the log is periodically compacted into a snapshot of
+// the in-memory maps.
+type FileStorage struct {
+	mem  *inmemory.InMemoryStorage
+	opts Options
+	// depthLimit is applied before a comment is logged, so that replay
+	// does not depend on it.
+	depthLimit models.DepthLimit
+
+	mu      sync.Mutex // serialises writes so WAL order matches apply order
+	wal     *os.File
+	size    int64 // offset just past the last complete WAL record
+	seq     uint64
+	pending int  // records in the WAL since the last snapshot
+	dirty   bool // unsynced WAL data under SyncInterval
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open recovers the storage in opts.Dir, creating it if necessary, and starts
+// background syncing and compaction.
+func Open(opts Options) (*FileStorage, error) {
+	switch opts.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if opts.SyncInterval <= 0 {
+			return nil, fmt.Errorf("sync interval must be positive for policy %q", opts.Sync)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q", opts.Sync)
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	s := &FileStorage{
+		mem:  inmemory.NewInMemoryStorage(),
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+
+	go s.background()
+	return s, nil
+}
+
+// recover loads the latest snapshot and replays the WAL on top of it,
+// truncating any torn tail left by a crash.
+func (s *FileStorage) recover() error {
+	snap, ok, err := readSnapshot(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+	if ok {
+		s.mem.Restore(snap.State)
+		s.seq = snap.Seq
+	}
+
+	wal, err := os.OpenFile(filepath.Join(s.opts.Dir, walFile), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("open wal: %w", err)
+	}
+
+	replayed := 0
+	end, err := readWAL(wal, func(rec record) error {
+		if rec.Seq <= s.seq {
+			return nil
+		}
+		// A record that failed when first written fails the same way now,
+		// because the state it is applied to is identical.
+		if err := s.apply(context.Background(), rec); err != nil {
+			slog.Debug("WAL record did not apply", "seq", rec.Seq, "op", rec.Op, "error", err)
+		}
+		s.seq = rec.Seq
+		s.pending++
+		replayed++
+		return nil
+	})
+	if err != nil {
+		wal.Close()
+		return fmt.Errorf("replay wal: %w", err)
+	}
+
+	if err := wal.Truncate(end); err != nil {
+		wal.Close()
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if _, err := wal.Seek(end, io.SeekStart); err != nil {
+		wal.Close()
+		return err
+	}
+	s.wal = wal
+	s.size = end
+
+	slog.Info("File storage recovered", "dir", s.opts.Dir, "snapshotSeq", snap.Seq, "replayed", replayed)
+	return nil
+}
+
+func (s *FileStorage) apply(ctx context.Context, rec record) error {
+	switch rec.Op {
+	case opCreatePost:
+		return s.mem.CreatePost(ctx, *rec.Post)
+	case opUpdatePost:
+		return s.mem.UpdatePost(ctx, *rec.Post)
+	case opCreateComment:
+		if rec.Outbox != nil {
+			return s.mem.CreateCommentWithEvent(ctx, *rec.Comment, *rec.Outbox)
+		}
+		return s.mem.CreateComment(ctx, *rec.Comment)
+	case opLockThread:
+		return s.mem.LockThread(ctx, rec.Comment.ID)
+	case opSetPostStatus:
+		return s.mem.SetPostStatus(ctx, rec.Post.ID, rec.Post.Status, rec.Post.PublishAt)
+	case opPublishDuePosts:
+		_, err := s.mem.PublishDuePosts(ctx, *rec.Before)
+		return err
+	case opReserveIdempotencyKey:
+		_, _, err := s.mem.ReserveIdempotencyKey(ctx, *rec.IdempotencyKey)
+		return err
+	case opDeleteIdempotencyKey:
+		key := rec.IdempotencyKey
+		return s.mem.DeleteIdempotencyKey(ctx, key.UserID, key.Key, key.ResourceID)
+	case opPurgeIdempotencyKeys:
+		_, err := s.mem.PurgeIdempotencyKeys(ctx, *rec.Before)
+		return err
+	case opSetUsername:
+		return s.mem.SetUsername(ctx, rec.Username.UserID, rec.Username.Name)
+	case opCreateNotifications:
+		return s.mem.CreateNotifications(ctx, rec.Notifications)
+	case opMarkNotificationsRead:
+		_, err := s.mem.MarkNotificationsRead(ctx, rec.MarkRead.UserID, rec.MarkRead.IDs)
+		return err
+	case opCreateWebhook:
+		return s.mem.CreateWebhook(ctx, *rec.Webhook)
+	case opDeleteWebhook:
+		return s.mem.DeleteWebhook(ctx, rec.Webhook.ID)
+	case opCreateWebhookDelivery:
+		return s.mem.CreateWebhookDelivery(ctx, *rec.WebhookDelivery)
+	case opMarkOutboxDelivered:
+		return s.mem.MarkOutboxEventsDelivered(ctx, rec.Delivered.IDs, rec.Delivered.At)
+	case opPurgeOutbox:
+		_, err := s.mem.PurgeOutboxEvents(ctx, *rec.Before)
+		return err
+	case opCreateReport:
+		return s.mem.CreateReport(ctx, *rec.Report)
+	case opCloseReport:
+		_, err := s.mem.CloseReport(ctx, rec.Report.ID, *rec.Decision)
+		return err
+	case opAddRelation:
+		return s.mem.AddUserRelation(ctx, *rec.Relation)
+	case opRemoveRelation:
+		return s.mem.RemoveUserRelation(ctx, rec.Relation.UserID, rec.Relation.TargetID, rec.Relation.Kind)
+	case opCreateCommunity:
+		return s.mem.CreateCommunity(ctx, *rec.Community)
+	case opUpdateCommunity:
+		return s.mem.UpdateCommunity(ctx, *rec.Community)
+	default:
+		return fmt.Errorf("unknown wal operation %q", rec.Op)
+	}
+}
+
+// write appends rec to the WAL and then applies it to memory.
+func (s *FileStorage) write(ctx context.Context, rec record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(ctx, rec)
+}
+
+// writeLocked is write for callers that already hold s.mu, e.g. to make a
+// read of the current state and the write that depends on it atomic.
+func (s *FileStorage) writeLocked(ctx context.Context, rec record) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	rec.Seq = s.seq + 1
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.wal.Write(line); err != nil {
+		slog.Error("Failed to append to WAL", "error", err)
+		s.rewind()
+		return err
+	}
+	if s.opts.Sync == SyncAlways {
+		if err := s.wal.Sync(); err != nil {
+			slog.Error("Failed to sync WAL", "error", err)
+			s.rewind()
+			return err
+		}
+	} else {
+		s.dirty = true
+	}
+	s.size += int64(len(line))
+	s.seq = rec.Seq
+	s.pending++
+
+	err = s.apply(ctx, rec)
+
+	if s.opts.SnapshotThreshold > 0 && s.pending >= s.opts.SnapshotThreshold {
+		if cErr := s.compactLocked(); cErr != nil {
+			slog.Error("Failed to compact WAL", "error", cErr)
+		}
+	}
+	return err
+}
+
+// rewind drops a partially written record so that later appends do not land
+// behind data that recovery would treat as a corrupt tail.
+func (s *FileStorage) rewind() {
+	if err := s.wal.Truncate(s.size); err != nil {
+		slog.Error("Failed to rewind WAL", "error", err)
+		return
+	}
+	if _, err := s.wal.Seek(s.size, io.SeekStart); err != nil {
+		slog.Error("Failed to rewind WAL", "error", err)
+	}
+}
+
+// Compact writes a snapshot of the current state and empties the WAL.
+func (s *FileStorage) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	return s.compactLocked()
+}
+
+func (s *FileStorage) compactLocked() error {
+	if err := writeSnapshot(s.opts.Dir, snapshot{Seq: s.seq, State: s.mem.Snapshot()}); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	// The snapshot covers every record, so a crash before the truncate below
+	// only leaves records that replay skips by sequence number.
+	if err := s.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.size = 0
+	s.pending = 0
+	s.dirty = false
+
+	slog.Info("WAL compacted", "seq", s.seq)
+	return nil
+}
+
+func (s *FileStorage) background() {
+	defer close(s.done)
+
+	var syncTick, snapshotTick <-chan time.Time
+	if s.opts.Sync == SyncInterval {
+		t := time.NewTicker(s.opts.SyncInterval)
+		defer t.Stop()
+		syncTick = t.C
+	}
+	if s.opts.SnapshotInterval > 0 {
+		t := time.NewTicker(s.opts.SnapshotInterval)
+		defer t.Stop()
+		snapshotTick = t.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-syncTick:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				if err := s.wal.Sync(); err != nil {
+					slog.Error("Failed to sync WAL", "error", err)
+				} else {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		case <-snapshotTick:
+			s.mu.Lock()
+			if s.pending > 0 && !s.closed {
+				if err := s.compactLocked(); err != nil {
+					slog.Error("Failed to compact WAL", "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close stops background work, flushes the WAL and releases the file.
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	syncErr := s.wal.Sync()
+	closeErr := s.wal.Close()
+	return errors.Join(syncErr, closeErr)
+}
+
+// CreatePost logs and stores a new post. The ID and creation time are fixed
+// before logging so that replay reproduces them exactly.
+func (s *FileStorage) CreatePost(ctx context.Context, post models.Post) error {
+	if post.ID == uuid.Nil {
+		post.ID = uuid.New()
+	}
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now()
+	}
+	return s.write(ctx, record{Op: opCreatePost, Post: &post})
+}
+
+// GetPostByID retrieves a post by its ID.
+func (s *FileStorage) GetPostByID(ctx context.Context, postID uuid.UUID) (models.Post, error) {
+	return s.mem.GetPostByID(ctx, postID)
+}
+
+// ListPosts retrieves a paginated list of posts.
+func (s *FileStorage) ListPosts(ctx context.Context, page, pageSize int) ([]models.Post, error) {
+	return s.mem.ListPosts(ctx, page, pageSize)
+}
+
+// ListPostsVisibleTo retrieves a paginated list of the posts viewerID may see.
+func (s *FileStorage) ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	return s.mem.ListPostsVisibleTo(ctx, viewerID, includeHidden, page, pageSize)
+}
+
+// ListCommunityPosts retrieves a paginated list of the posts of a community
+// viewerID may see.
+func (s *FileStorage) ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	return s.mem.ListCommunityPosts(ctx, communityID, viewerID, includeHidden, page, pageSize)
+}
+
+// SetPostStatus logs and applies a status change.
+func (s *FileStorage) SetPostStatus(ctx context.Context, postID uuid.UUID, status models.PostStatus, publishAt *time.Time) error {
+	return s.write(ctx, record{Op: opSetPostStatus, Post: &models.Post{ID: postID, Status: status, PublishAt: publishAt}})
+}
+
+// PublishDuePosts logs and applies the publication of due scheduled posts.
+// Nothing is logged when no post is due.
+func (s *FileStorage) PublishDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := s.mem.DuePosts(now)
+	if len(due) == 0 {
+		return nil, nil
+	}
+	if err := s.writeLocked(ctx, record{Op: opPublishDuePosts, Before: &now}); err != nil {
+		return nil, err
+	}
+	for i := range due {
+		due[i].Status = models.PostPublished
+	}
+	return due, nil
+}
+
+// CreateComment logs and stores a new comment. A reply is moved up the
+// thread, or rejected, before logging, as the depth limit requires.
+func (s *FileStorage) CreateComment(ctx context.Context, comment models.Comment) error {
+	return s.createComment(ctx, comment, nil)
+}
+
+// CreateCommentWithEvent is CreateComment with event logged in the same
+// record, so that a crash keeps both or neither.
+func (s *FileStorage) CreateCommentWithEvent(ctx context.Context, comment models.Comment, event models.OutboxEvent) error {
+	return s.createComment(ctx, comment, &event)
+}
+
+func (s *FileStorage) createComment(ctx context.Context, comment models.Comment, event *models.OutboxEvent) error {
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, err := s.mem.PlaceComment(comment, s.depthLimit)
+	if err != nil {
+		return err
+	}
+	return s.writeLocked(ctx, record{Op: opCreateComment, Comment: &comment, Outbox: event})
+}
+
+// SetCommentDepthLimit configures how deeply CreateComment lets comments nest.
+func (s *FileStorage) SetCommentDepthLimit(limit models.DepthLimit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depthLimit = limit
+}
+
+// ListThread retrieves a page of a post's comments no deeper than maxDepth.
+func (s *FileStorage) ListThread(ctx context.Context, postID uuid.UUID, maxDepth, page, pageSize int) ([]models.ThreadComment, error) {
+	return s.mem.ListThread(ctx, postID, maxDepth, page, pageSize)
+}
+
+// LockThread logs and applies the locking of a comment thread.
+func (s *FileStorage) LockThread(ctx context.Context, commentID uuid.UUID) error {
+	return s.write(ctx, record{Op: opLockThread, Comment: &models.Comment{ID: commentID}})
+}
+
+// GetCommentsByPostID retrieves a paginated list of comments for a post.
+func (s *FileStorage) GetCommentsByPostID(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.Comment, error) {
+	return s.mem.GetCommentsByPostID(ctx, postID, page, pageSize)
+}
+
+// ListVisibleComments retrieves a paginated list of a post's comments that
+// viewerID may see.
+func (s *FileStorage) ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Comment, error) {
+	return s.mem.ListVisibleComments(ctx, postID, viewerID, includeHidden, page, pageSize)
+}
+
+// UpdatePost logs and applies an update to an existing post.
+func (s *FileStorage) UpdatePost(ctx context.Context, post models.Post) error {
+	return s.write(ctx, record{Op: opUpdatePost, Post: &post})
+}
+
+// ListPostRevisions returns a page of a post's revisions, newest first.
+func (s *FileStorage) ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.PostRevision, error) {
+	return s.mem.ListPostRevisions(ctx, postID, page, pageSize)
+}
+
+// GetPostRevision returns a post as it was at version.
+func (s *FileStorage) GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (models.PostRevision, error) {
+	return s.mem.GetPostRevision(ctx, postID, version)
+}
+
+// GetCommentByID retrieves a comment by its ID.
+func (s *FileStorage) GetCommentByID(ctx context.Context, commentID uuid.UUID) (models.Comment, error) {
+	return s.mem.GetCommentByID(ctx, commentID)
+}
+
+// ReserveIdempotencyKey logs and stores key unless an unexpired key with the
+// same scope is already held. Nothing is logged in that case.
+func (s *FileStorage) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.mem.LookupIdempotencyKey(key.UserID, key.Key, key.CreatedAt); ok {
+		return existing, false, nil
+	}
+	if err := s.writeLocked(ctx, record{Op: opReserveIdempotencyKey, IdempotencyKey: &key}); err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	return key, true, nil
+}
+
+// DeleteIdempotencyKey logs and applies the release of a reservation.
+func (s *FileStorage) DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) error {
+	return s.write(ctx, record{Op: opDeleteIdempotencyKey, IdempotencyKey: &models.IdempotencyKey{UserID: userID, Key: key, ResourceID: resourceID}})
+}
+
+// PurgeIdempotencyKeys logs and applies the removal of expired keys.
+func (s *FileStorage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.mem.CountExpiredIdempotencyKeys(before)
+	if count == 0 {
+		return 0, nil
+	}
+	if err := s.writeLocked(ctx, record{Op: opPurgeIdempotencyKeys, Before: &before}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetUsername logs and applies a username change. A taken name is
+// rejected without logging anything.
+func (s *FileStorage) SetUsername(ctx context.Context, userID uuid.UUID, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders, err := s.mem.ResolveUsernames(ctx, []string{username})
+	if err != nil {
+		return err
+	}
+	if holder, ok := holders[username]; ok && holder != userID {
+		return models.ErrUsernameTaken
+	}
+	return s.writeLocked(ctx, record{Op: opSetUsername, Username: &models.Username{UserID: userID, Name: username}})
+}
+
+// ResolveUsernames maps each held username to its user.
+func (s *FileStorage) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error) {
+	return s.mem.ResolveUsernames(ctx, usernames)
+}
+
+// CreateNotifications logs and stores new notifications.
+func (s *FileStorage) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return s.write(ctx, record{Op: opCreateNotifications, Notifications: notifications})
+}
+
+// ListNotifications returns a page of userID's notifications, newest first.
+func (s *FileStorage) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]models.Notification, error) {
+	return s.mem.ListNotifications(ctx, userID, unreadOnly, page, pageSize)
+}
+
+// CountUnreadNotifications returns how many of userID's notifications are
+// unread.
+func (s *FileStorage) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int, error) {
+	return s.mem.CountUnreadNotifications(ctx, userID)
+}
+
+// MarkNotificationsRead logs and applies marking notifications as read.
+// Nothing is logged if none of them is unread.
+func (s *FileStorage) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.mem.UnreadNotifications(userID, ids)
+	if count == 0 {
+		return 0, nil
+	}
+	if err := s.writeLocked(ctx, record{Op: opMarkNotificationsRead, MarkRead: &markRead{UserID: userID, IDs: ids}}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateWebhook logs and stores a webhook.
+func (s *FileStorage) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	return s.write(ctx, record{Op: opCreateWebhook, Webhook: &webhook})
+}
+
+// ListWebhooks returns every webhook, oldest first.
+func (s *FileStorage) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return s.mem.ListWebhooks(ctx)
+}
+
+// DeleteWebhook logs and applies the removal of a webhook. A missing
+// webhook is reported without logging anything.
+func (s *FileStorage) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.mem.HasWebhook(webhookID) {
+		return models.ErrWebhookNotFound
+	}
+	return s.writeLocked(ctx, record{Op: opDeleteWebhook, Webhook: &models.Webhook{ID: webhookID}})
+}
+
+// CreateWebhookDelivery logs and stores a delivery attempt. Deliveries to
+// deleted webhooks are rejected without logging anything.
+func (s *FileStorage) CreateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.mem.HasWebhook(delivery.WebhookID) {
+		return models.ErrWebhookNotFound
+	}
+	return s.writeLocked(ctx, record{Op: opCreateWebhookDelivery, WebhookDelivery: &delivery})
+}
+
+// ListWebhookDeliveries returns a page of a webhook's deliveries, newest
+// first.
+func (s *FileStorage) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) ([]models.WebhookDelivery, error) {
+	return s.mem.ListWebhookDeliveries(ctx, webhookID, page, pageSize)
+}
+
+// PendingOutboxEvents returns up to limit undelivered events, oldest first.
+func (s *FileStorage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	return s.mem.PendingOutboxEvents(ctx, limit)
+}
+
+// MarkOutboxEventsDelivered logs and applies the delivery of events.
+func (s *FileStorage) MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.write(ctx, record{Op: opMarkOutboxDelivered, Delivered: &delivered{IDs: ids, At: at}})
+}
+
+// PurgeOutboxEvents logs and applies the removal of events delivered
+// before the given time. Nothing is logged if there are none.
+func (s *FileStorage) PurgeOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.mem.CountPurgeableOutboxEvents(before)
+	if count == 0 {
+		return 0, nil
+	}
+	if err := s.writeLocked(ctx, record{Op: opPurgeOutbox, Before: &before}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateReport logs and stores a report. A duplicate is rejected without
+// logging anything.
+func (s *FileStorage) CreateReport(ctx context.Context, report models.Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem.HasOpenReport(report) {
+		return models.ErrDuplicateReport
+	}
+	return s.writeLocked(ctx, record{Op: opCreateReport, Report: &report})
+}
+
+// GetReport retrieves a report by its ID.
+func (s *FileStorage) GetReport(ctx context.Context, reportID uuid.UUID) (models.Report, error) {
+	return s.mem.GetReport(ctx, reportID)
+}
+
+// ListReports retrieves a page of the reports matching filter, oldest
+// first.
+func (s *FileStorage) ListReports(ctx context.Context, filter models.ReportFilter, page, pageSize int) ([]models.Report, error) {
+	return s.mem.ListReports(ctx, filter, page, pageSize)
+}
+
+// CloseReport logs and applies a decision on an open report. Reports that
+// are missing or closed are rejected without logging anything.
+func (s *FileStorage) CloseReport(ctx context.Context, reportID uuid.UUID, decision models.ReportDecision) (models.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, err := s.mem.GetReport(ctx, reportID)
+	if err != nil {
+		return models.Report{}, err
+	}
+	if report.Status != models.ReportOpen {
+		return models.Report{}, models.ErrReportClosed
+	}
+	if err := s.writeLocked(ctx, record{Op: opCloseReport, Report: &models.Report{ID: reportID}, Decision: &decision}); err != nil {
+		return models.Report{}, err
+	}
+	return s.mem.GetReport(ctx, reportID)
+}
+
+// AddUserRelation logs and stores relation. A relation that exists is not
+// logged again.
+func (s *FileStorage) AddUserRelation(ctx context.Context, relation models.UserRelation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if set, err := s.hasRelation(ctx, relation.UserID, relation.TargetID, relation.Kind); err != nil || set {
+		return err
+	}
+	return s.writeLocked(ctx, record{Op: opAddRelation, Relation: &relation})
+}
+
+// RemoveUserRelation logs and removes userID's relation of kind to
+// targetID. Nothing is logged if there is none.
+func (s *FileStorage) RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if set, err := s.hasRelation(ctx, userID, targetID, kind); err != nil || !set {
+		return err
+	}
+	return s.writeLocked(ctx, record{Op: opRemoveRelation, Relation: &models.UserRelation{UserID: userID, TargetID: targetID, Kind: kind}})
+}
+
+func (s *FileStorage) hasRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) (bool, error) {
+	relations, err := s.mem.GetUserRelations(ctx, userID, targetID)
+	if err != nil {
+		return false, err
+	}
+	if kind == models.RelationBlock {
+		return relations.Blocked, nil
+	}
+	return relations.Muted, nil
+}
+
+// ListUserRelations retrieves a page of userID's relations of kind, newest
+// first.
+func (s *FileStorage) ListUserRelations(ctx context.Context, userID uuid.UUID, kind models.RelationKind, page, pageSize int) ([]models.UserRelation, error) {
+	return s.mem.ListUserRelations(ctx, userID, kind, page, pageSize)
+}
+
+// GetUserRelations returns what userID has set towards targetID.
+func (s *FileStorage) GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (models.Relations, error) {
+	return s.mem.GetUserRelations(ctx, userID, targetID)
+}
+
+// CreateCommunity logs and stores a community. A taken slug is rejected
+// without logging anything.
+func (s *FileStorage) CreateCommunity(ctx context.Context, community models.Community) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.mem.GetCommunityBySlug(ctx, community.Slug)
+	if err == nil {
+		return models.ErrCommunitySlugTaken
+	}
+	if !errors.Is(err, models.ErrCommunityNotFound) {
+		return err
+	}
+	return s.writeLocked(ctx, record{Op: opCreateCommunity, Community: &community})
+}
+
+// GetCommunity retrieves a community by its ID.
+func (s *FileStorage) GetCommunity(ctx context.Context, communityID uuid.UUID) (models.Community, error) {
+	return s.mem.GetCommunity(ctx, communityID)
+}
+
+// GetCommunityBySlug retrieves a community by its slug.
+func (s *FileStorage) GetCommunityBySlug(ctx context.Context, slug string) (models.Community, error) {
+	return s.mem.GetCommunityBySlug(ctx, slug)
+}
+
+// ListCommunities retrieves a page of communities, oldest first.
+func (s *FileStorage) ListCommunities(ctx context.Context, page, pageSize int) ([]models.Community, error) {
+	return s.mem.ListCommunities(ctx, page, pageSize)
+}
+
+// UpdateCommunity logs and applies changes to a community. Missing
+// communities are rejected without logging anything.
+func (s *FileStorage) UpdateCommunity(ctx context.Context, community models.Community) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.mem.GetCommunity(ctx, community.ID); err != nil {
+		return err
+	}
+	return s.writeLocked(ctx, record{Op: opUpdateCommunity, Community: &community})
+}
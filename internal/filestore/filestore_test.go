@@ -0,0 +1,347 @@
+package filestore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ozon-test/internal/filestore"
+	"ozon-test/internal/models"
+	"ozon-test/internal/storagetest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, dir string, opts filestore.Options) *filestore.FileStorage {
+	t.Helper()
+	opts.Dir = dir
+	if opts.Sync == "" {
+		opts.Sync = filestore.SyncAlways
+	}
+	storage, err := filestore.Open(opts)
+	require.NoError(t, err)
+	return storage
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) models.Storage {
+		storage := open(t, t.TempDir(), filestore.Options{})
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	})
+}
+
+func BenchmarkStorage(b *testing.B) {
+	for _, policy := range []filestore.SyncPolicy{filestore.SyncAlways, filestore.SyncNever} {
+		b.Run("sync="+string(policy), func(b *testing.B) {
+			storagetest.Bench(b, func(b *testing.B) models.Storage {
+				storage, err := filestore.Open(filestore.Options{Dir: b.TempDir(), Sync: policy})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.Cleanup(func() { storage.Close() })
+				return storage
+			})
+		})
+	}
+}
+
+func TestRecoverFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+
+	post := storagetest.MustCreatePost(t, storage)
+	comment := storagetest.MustCreateComment(t, storage, post.ID, nil)
+	reply := storagetest.MustCreateComment(t, storage, post.ID, &comment.ID)
+	post.Title = "Updated"
+	require.NoError(t, storage.UpdatePost(context.Background(), post))
+	require.NoError(t, storage.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	defer reopened.Close()
+
+	got, err := reopened.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", got.Title, "Updates should be replayed")
+	assert.True(t, post.CreatedAt.Equal(got.CreatedAt), "CreatedAt should survive a restart")
+
+	comments, err := reopened.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, comment.ID, comments[0].ID)
+	assert.Equal(t, reply.ID, comments[1].ID)
+	assert.Equal(t, comment.ID, *comments[1].ParentID)
+}
+
+func TestRecoverDiscardsTornTail(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	post := storagetest.MustCreatePost(t, storage)
+	require.NoError(t, storage.Close())
+
+	// Simulate a crash in the middle of appending the next record.
+	wal, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = wal.WriteString(`0badf00d {"seq":2,"op":"create_po`)
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	_, err = reopened.GetPostByID(context.Background(), post.ID)
+	assert.NoError(t, err, "Records before the torn tail should be recovered")
+
+	second := storagetest.MustCreatePost(t, reopened)
+	require.NoError(t, reopened.Close())
+
+	again := open(t, dir, filestore.Options{})
+	defer again.Close()
+	_, err = again.GetPostByID(context.Background(), second.ID)
+	assert.NoError(t, err, "Writes after recovery should not be hidden behind the torn tail")
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{SnapshotThreshold: 3})
+
+	var posts []models.Post
+	for i := 0; i < 4; i++ {
+		posts = append(posts, storagetest.MustCreatePost(t, storage))
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "wal.log"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size(), "Records after the snapshot stay in the WAL")
+	_, err = os.Stat(filepath.Join(dir, "snapshot.json"))
+	require.NoError(t, err, "Reaching the threshold should write a snapshot")
+
+	require.NoError(t, storage.Compact())
+	info, err = os.Stat(filepath.Join(dir, "wal.log"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "Compaction empties the WAL")
+	require.NoError(t, storage.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	defer reopened.Close()
+	listed, err := reopened.ListPosts(context.Background(), 1, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 4)
+	for i, post := range posts {
+		assert.Equal(t, post.ID, listed[i].ID, "Order should be preserved through the snapshot")
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{Sync: filestore.SyncInterval, SyncInterval: 10 * time.Millisecond, SnapshotInterval: 20 * time.Millisecond})
+	post := storagetest.MustCreatePost(t, storage)
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "snapshot.json"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "Periodic snapshots should be written")
+	require.NoError(t, storage.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	defer reopened.Close()
+	_, err := reopened.GetPostByID(context.Background(), post.ID)
+	assert.NoError(t, err)
+}
+
+func TestOpenRejectsUnknownSyncPolicy(t *testing.T) {
+	_, err := filestore.Open(filestore.Options{Dir: t.TempDir(), Sync: "sometimes"})
+	assert.Error(t, err)
+}
+
+func TestRecoverIdempotencyKeys(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	key := storagetest.NewIdempotencyKey(uuid.New(), "retry-me", now)
+	_, reserved, err := storage.ReserveIdempotencyKey(context.Background(), key)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.NoError(t, storage.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	defer reopened.Close()
+
+	retry := key
+	retry.ResourceID = uuid.New()
+	got, reserved, err := reopened.ReserveIdempotencyKey(context.Background(), retry)
+	require.NoError(t, err)
+	assert.False(t, reserved, "A reserved key should survive a restart")
+	assert.Equal(t, key.ResourceID, got.ResourceID)
+}
+
+func TestRecoverReparentedReply(t *testing.T) {
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	storage.SetCommentDepthLimit(models.DepthLimit{MaxDepth: 1, Policy: models.DepthReparent})
+	post := storagetest.MustCreatePost(t, storage)
+	root := storagetest.MustCreateComment(t, storage, post.ID, nil)
+	reply := storagetest.MustCreateComment(t, storage, post.ID, &root.ID)
+	deep := storagetest.MustCreateComment(t, storage, post.ID, &reply.ID)
+	require.NoError(t, storage.Close())
+
+	reopened := open(t, dir, filestore.Options{})
+	defer reopened.Close()
+
+	got, err := reopened.GetCommentByID(context.Background(), deep.ID)
+	require.NoError(t, err)
+	assert.Equal(t, root.ID, *got.ParentID, "Replay should not depend on the depth limit")
+}
+
+func TestRecoverNotifications(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	post := storagetest.MustCreatePost(t, storage)
+	user := uuid.New()
+	require.NoError(t, storage.SetUsername(ctx, user, "alice"))
+	notifications := []models.Notification{
+		{ID: uuid.New(), UserID: user, Kind: models.NotificationMention, ActorID: post.UserID, PostID: post.ID, CreatedAt: post.CreatedAt},
+		{ID: uuid.New(), UserID: user, Kind: models.NotificationMention, ActorID: post.UserID, PostID: post.ID, CreatedAt: post.CreatedAt},
+	}
+	require.NoError(t, storage.CreateNotifications(ctx, notifications))
+	_, err := storage.MarkNotificationsRead(ctx, user, []uuid.UUID{notifications[0].ID})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	// Once from the WAL, then from the snapshot the first pass compacts it
+	// into.
+	for _, compact := range []bool{true, false} {
+		reopened := open(t, dir, filestore.Options{})
+		users, err := reopened.ResolveUsernames(ctx, []string{"alice"})
+		require.NoError(t, err)
+		assert.Equal(t, user, users["alice"])
+		unread, err := reopened.ListNotifications(ctx, user, true, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, unread, 1)
+		assert.Equal(t, notifications[1].ID, unread[0].ID)
+		if compact {
+			require.NoError(t, reopened.Compact())
+		}
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestRecoverOutbox(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	post := storagetest.MustCreatePost(t, storage)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	var events []models.OutboxEvent
+	for i := 0; i < 2; i++ {
+		comment := storagetest.NewComment(post.ID, nil)
+		event := models.OutboxEvent{ID: uuid.New(), Channel: models.OutboxCommentAdded, Topic: post.ID,
+			Message: comment.ID.String(), CreatedAt: now}
+		require.NoError(t, storage.CreateCommentWithEvent(ctx, comment, event))
+		events = append(events, event)
+	}
+	require.NoError(t, storage.MarkOutboxEventsDelivered(ctx, []uuid.UUID{events[0].ID}, now))
+	require.NoError(t, storage.Close())
+
+	for _, compact := range []bool{true, false} {
+		reopened := open(t, dir, filestore.Options{})
+		pending, err := reopened.PendingOutboxEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, events[1:], pending, "An event pending at a crash is relayed after the restart")
+		_, err = reopened.GetCommentByID(ctx, uuid.MustParse(events[1].Message))
+		require.NoError(t, err)
+		if compact {
+			require.NoError(t, reopened.Compact())
+		}
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestRecoverReports(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	post := storagetest.MustCreatePost(t, storage)
+	comment := storagetest.MustCreateComment(t, storage, post.ID, nil)
+	closed := storagetest.NewReport(models.ReportComment, comment.ID)
+	pending := storagetest.NewReport(models.ReportPost, post.ID)
+	require.NoError(t, storage.CreateReport(ctx, closed))
+	require.NoError(t, storage.CreateReport(ctx, pending))
+	_, err := storage.CloseReport(ctx, closed.ID, models.ReportDecision{Status: models.ReportResolved,
+		ModeratorID: uuid.New(), At: time.Now().UTC().Truncate(time.Microsecond), HideTarget: true})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	for _, compact := range []bool{true, false} {
+		reopened := open(t, dir, filestore.Options{})
+		reports, err := reopened.ListReports(ctx, models.ReportFilter{Status: models.ReportOpen}, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []models.Report{pending}, reports)
+		got, err := reopened.GetReport(ctx, closed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReportResolved, got.Status)
+		hidden, err := reopened.GetCommentByID(ctx, comment.ID)
+		require.NoError(t, err)
+		assert.True(t, hidden.Hidden, "The decision to hide the target is replayed")
+		if compact {
+			require.NoError(t, reopened.Compact())
+		}
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestRecoverUserRelations(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	user, target := uuid.New(), uuid.New()
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, storage.AddUserRelation(ctx, models.UserRelation{UserID: user, TargetID: target, Kind: models.RelationMute, CreatedAt: at}))
+	require.NoError(t, storage.AddUserRelation(ctx, models.UserRelation{UserID: user, TargetID: target, Kind: models.RelationBlock, CreatedAt: at}))
+	require.NoError(t, storage.RemoveUserRelation(ctx, user, target, models.RelationMute))
+	require.NoError(t, storage.Close())
+
+	for _, compact := range []bool{true, false} {
+		reopened := open(t, dir, filestore.Options{})
+		got, err := reopened.GetUserRelations(ctx, user, target)
+		require.NoError(t, err)
+		assert.Equal(t, models.Relations{Blocked: true}, got, "The removal is replayed")
+		if compact {
+			require.NoError(t, reopened.Compact())
+		}
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestRecoverCommunities(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := open(t, dir, filestore.Options{})
+	community := storagetest.NewCommunity("golang")
+	require.NoError(t, storage.CreateCommunity(ctx, community))
+	community.CommentPolicy = models.CommentsClosed
+	community.Moderators = community.Moderators[:1]
+	require.NoError(t, storage.UpdateCommunity(ctx, community))
+	post := storagetest.NewPost()
+	post.CommunityID = &community.ID
+	require.NoError(t, storage.CreatePost(ctx, post))
+	require.NoError(t, storage.Close())
+
+	for _, compact := range []bool{true, false} {
+		reopened := open(t, dir, filestore.Options{})
+		got, err := reopened.GetCommunityBySlug(ctx, "golang")
+		require.NoError(t, err)
+		assert.Equal(t, community, got, "The update is replayed")
+		posts, err := reopened.ListCommunityPosts(ctx, community.ID, uuid.Nil, false, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		assert.Equal(t, post.ID, posts[0].ID)
+		if compact {
+			require.NoError(t, reopened.Compact())
+		}
+		require.NoError(t, reopened.Close())
+	}
+}
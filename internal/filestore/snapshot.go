@@ -0,0 +1,79 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"ozon-test/internal/inmemory"
+	"path/filepath"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	walFile      = "wal.log"
+)
+
+// snapshot is the on-disk image of the in-memory state after applying every
+// WAL record up to and including Seq.
+type snapshot struct {
+	Seq   uint64         `json:"seq"`
+	State inmemory.State `json:"state"`
+}
+
+// readSnapshot loads the snapshot in dir. A missing snapshot is not an error.
+func readSnapshot(dir string) (snapshot, bool, error) {
+	var snap snapshot
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, false, nil
+	}
+	if err != nil {
+		return snap, false, err
+	}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return snap, false, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return snap, true, nil
+}
+
+// writeSnapshot atomically replaces the snapshot in dir: it writes a
+// temporary file, syncs it and renames it over the previous one.
+func writeSnapshot(dir string, snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes renames and file creations in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
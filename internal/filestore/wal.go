@@ -0,0 +1,172 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Operation names stored in the write-ahead log.
+const (
+	opCreatePost    = "create_post"
+	opUpdatePost    = "update_post"
+	opCreateComment = "create_comment"
+	opLockThread    = "lock_thread"
+
+	opSetPostStatus   = "set_post_status"
+	opPublishDuePosts = "publish_due_posts"
+
+	opReserveIdempotencyKey = "reserve_idempotency_key"
+	opDeleteIdempotencyKey  = "delete_idempotency_key"
+	opPurgeIdempotencyKeys  = "purge_idempotency_keys"
+
+	opSetUsername           = "set_username"
+	opCreateNotifications   = "create_notifications"
+	opMarkNotificationsRead = "mark_notifications_read"
+
+	opCreateWebhook         = "create_webhook"
+	opDeleteWebhook         = "delete_webhook"
+	opCreateWebhookDelivery = "create_webhook_delivery"
+
+	opMarkOutboxDelivered = "mark_outbox_delivered"
+	opPurgeOutbox         = "purge_outbox"
+
+	opCreateReport = "create_report"
+	opCloseReport  = "close_report"
+
+	opAddRelation    = "add_relation"
+	opRemoveRelation = "remove_relation"
+
+	opCreateCommunity = "create_community"
+	opUpdateCommunity = "update_community"
+)
+
+// record is a single mutation in the write-ahead log. Exactly one payload
+// field is set, depending on Op, except that a created comment may carry
+// its outbox event.
+type record struct {
+	Seq     uint64          `json:"seq"`
+	Op      string          `json:"op"`
+	Post    *models.Post    `json:"post,omitempty"`
+	Comment *models.Comment `json:"comment,omitempty"`
+
+	IdempotencyKey *models.IdempotencyKey `json:"idempotency_key,omitempty"`
+	// Before is the cutoff of a purge, or the time due posts are
+	// published at.
+	Before *time.Time `json:"before,omitempty"`
+
+	Username      *models.Username      `json:"username,omitempty"`
+	Notifications []models.Notification `json:"notifications,omitempty"`
+	MarkRead      *markRead             `json:"mark_read,omitempty"`
+
+	// Webhook is the created webhook, or only the ID of a deleted one.
+	Webhook         *models.Webhook         `json:"webhook,omitempty"`
+	WebhookDelivery *models.WebhookDelivery `json:"webhook_delivery,omitempty"`
+
+	Outbox    *models.OutboxEvent `json:"outbox,omitempty"`
+	Delivered *delivered          `json:"delivered,omitempty"`
+
+	// Report is the created report, or only the ID of a closed one.
+	Report   *models.Report         `json:"report,omitempty"`
+	Decision *models.ReportDecision `json:"decision,omitempty"`
+
+	// Relation is the added relation, or the user, target and kind of a
+	// removed one.
+	Relation *models.UserRelation `json:"relation,omitempty"`
+
+	Community *models.Community `json:"community,omitempty"`
+}
+
+// markRead is the payload of MarkNotificationsRead; nil IDs means all.
+type markRead struct {
+	UserID uuid.UUID   `json:"user_id"`
+	IDs    []uuid.UUID `json:"ids,omitempty"`
+}
+
+// delivered is the payload of MarkOutboxEventsDelivered.
+type delivered struct {
+	IDs []uuid.UUID `json:"ids"`
+	At  time.Time   `json:"at"`
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptRecord = errors.New("corrupt wal record")
+
+// encodeRecord frames rec as "<crc32c hex> <json>\n" so that torn or
+// partially written tails can be detected on recovery.
+func encodeRecord(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(payload, crcTable))
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+func decodeRecord(line []byte) (record, error) {
+	var rec record
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) < 10 || line[8] != ' ' {
+		return rec, errCorruptRecord
+	}
+
+	var sum uint32
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &sum); err != nil {
+		return rec, errCorruptRecord
+	}
+	payload := line[9:]
+	if crc32.Checksum(payload, crcTable) != sum {
+		return rec, errCorruptRecord
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, errCorruptRecord
+	}
+	return rec, nil
+}
+
+// readWAL calls fn for every intact record in f and returns the offset just
+// past the last one. Reading stops at the first torn or corrupt record; the
+// caller truncates the file there.
+func readWAL(f *os.File, fn func(record) error) (int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				slog.Warn("Discarding torn WAL tail", "offset", offset, "bytes", len(line))
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		rec, err := decodeRecord(line)
+		if err != nil {
+			slog.Warn("Discarding corrupt WAL tail", "offset", offset, "error", err)
+			return offset, nil
+		}
+		if err := fn(rec); err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,108 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon-test/internal/auth"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"ozon-test/internal/validation"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+var errCommunityOwnersOnly = errors.New("only the community's owner can change its moderators")
+
+func toGQLCommunity(community models.Community) *gqlModel.Community {
+	moderatorIDs := make([]string, 0, len(community.Moderators))
+	for _, id := range community.Moderators {
+		moderatorIDs = append(moderatorIDs, id.String())
+	}
+	return &gqlModel.Community{
+		ID:            community.ID.String(),
+		Slug:          community.Slug,
+		Title:         community.Title,
+		Description:   community.Description,
+		OwnerID:       community.OwnerID.String(),
+		ModeratorIds:  moderatorIDs,
+		CommentPolicy: gqlModel.CommentPolicy(community.CommentPolicy),
+		CreatedAt:     community.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// optionalCommunityID returns the ID of the community post was made in,
+// if any.
+func optionalCommunityID(post models.Post) *string {
+	if post.CommunityID == nil {
+		return nil
+	}
+	id := post.CommunityID.String()
+	return &id
+}
+
+// communityBySlug returns the community with slug, which is matched
+// regardless of case.
+func (r *Resolver) communityBySlug(ctx context.Context, slug string) (models.Community, error) {
+	community, err := r.Storage.GetCommunityBySlug(ctx, strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil {
+		slog.Error("Failed to get community", "error", err, "slug", slug)
+		return models.Community{}, err
+	}
+	return community, nil
+}
+
+// checkCommentPolicy rejects comment on post if the post's community does
+// not let its author comment. Site moderators may always comment, and only
+// an authenticated viewer counts as a community moderator.
+func (r *Resolver) checkCommentPolicy(ctx context.Context, post models.Post, comment models.Comment) error {
+	if post.CommunityID == nil || auth.IsModerator(ctx) {
+		return nil
+	}
+	community, err := r.Storage.GetCommunity(ctx, *post.CommunityID)
+	if err != nil {
+		return err
+	}
+
+	switch community.CommentPolicy {
+	case models.CommentsClosed:
+		return models.ErrCommentsClosed
+	case models.CommentsModerators:
+		viewer, ok := auth.ViewerFromContext(ctx)
+		if !ok || !community.IsModerator(viewer.UserID) {
+			slog.Warn("Comment by non-moderator rejected", "userID", comment.UserID, "communityID", community.ID)
+			return models.ErrCommentsModeratorsOnly
+		}
+	}
+	return nil
+}
+
+// parseCommunityID parses a communityId argument.
+func parseCommunityID(id string) (uuid.UUID, error) {
+	communityID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, &validation.Error{Fields: []validation.FieldError{{Field: "communityId", Message: "must be a valid ID"}}}
+	}
+	return communityID, nil
+}
+
+// parseModerators turns moderator IDs into a list without duplicates or
+// the owner, who moderates anyway.
+func parseModerators(ids []string, owner uuid.UUID) ([]uuid.UUID, error) {
+	seen := map[uuid.UUID]bool{owner: true}
+	moderators := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		moderator, err := uuid.Parse(id)
+		if err != nil {
+			return nil, &validation.Error{Fields: []validation.FieldError{{Field: "moderatorIds", Message: fmt.Sprintf("%q is not a valid ID", id)}}}
+		}
+		if !seen[moderator] {
+			seen[moderator] = true
+			moderators = append(moderators, moderator)
+		}
+	}
+	return moderators, nil
+}
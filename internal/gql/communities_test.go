@@ -0,0 +1,170 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createCommunityMutation = `mutation($slug: String!, $policy: CommentPolicy) { createCommunity(slug: $slug, title: "Go", description: " All about Go ", commentPolicy: $policy) { id slug description ownerId } }`
+	updateCommunityMutation = `mutation($slug: String!, $title: String, $policy: CommentPolicy, $moderatorIds: [ID!]) { updateCommunity(slug: $slug, title: $title, commentPolicy: $policy, moderatorIds: $moderatorIds) { title moderatorIds commentPolicy } }`
+	communityPostMutation   = `mutation($userId: ID!, $communityId: ID) { createPost(title: "In a community", content: "body", userId: $userId, communityId: $communityId) { id } }`
+	communityPostsQuery     = `query($slug: String!) { community(slug: $slug) { slug posts { id communityId } } }`
+)
+
+type createCommunityResponse struct {
+	CreateCommunity struct {
+		ID          string
+		Slug        string
+		Description string
+		OwnerID     string
+	}
+}
+
+func createCommunity(t *testing.T, c *client.Client, slug string, owner uuid.UUID, options ...client.Option) string {
+	t.Helper()
+	var resp createCommunityResponse
+	options = append(options, client.Var("slug", slug), asViewer(owner))
+	require.NoError(t, c.Post(createCommunityMutation, &resp, options...))
+	return resp.CreateCommunity.ID
+}
+
+func TestCommunities(t *testing.T) {
+	postPubSub := pubsub.NewInMemoryPubSub()
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub(), PostPubSub: postPubSub}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+	owner, author := uuid.New(), uuid.New()
+
+	var created createCommunityResponse
+	require.NoError(t, c.Post(createCommunityMutation, &created, client.Var("slug", " Golang "), asViewer(owner)))
+	assert.Equal(t, "golang", created.CreateCommunity.Slug, "Slugs are lower-cased")
+	assert.Equal(t, "All about Go", created.CreateCommunity.Description)
+	assert.Equal(t, owner.String(), created.CreateCommunity.OwnerID)
+	communityID := created.CreateCommunity.ID
+
+	var resp map[string]any
+	err := c.Post(createCommunityMutation, &resp, client.Var("slug", "golang"), asViewer(author))
+	assert.ErrorContains(t, err, `"code":"CONFLICT"`)
+	err = c.Post(createCommunityMutation, &resp, client.Var("slug", "go lang"), asViewer(author))
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	announced, err := postPubSub.Subscribe(ctx, uuid.MustParse(communityID))
+	require.NoError(t, err)
+
+	var post createPostResponse
+	require.NoError(t, c.Post(communityPostMutation, &post, client.Var("userId", author.String()), client.Var("communityId", communityID)))
+	select {
+	case id := <-announced:
+		assert.Equal(t, post.CreatePost.ID, id, "Posts are announced to the community's subscribers")
+	case <-time.After(time.Second):
+		t.Fatal("Post was not announced to the community")
+	}
+
+	var outside createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &outside, client.Var("title", "Elsewhere"), client.Var("userId", author.String())))
+	err = c.Post(communityPostMutation, &resp, client.Var("userId", author.String()), client.Var("communityId", uuid.NewString()))
+	assert.ErrorContains(t, err, "community not found")
+	err = c.Post(communityPostMutation, &resp, client.Var("userId", author.String()), client.Var("communityId", "golang"))
+	assert.ErrorContains(t, err, `"field":"communityId"`)
+
+	var community struct {
+		Community struct {
+			Slug  string
+			Posts []struct {
+				ID          string
+				CommunityID *string
+			}
+		}
+	}
+	require.NoError(t, c.Post(communityPostsQuery, &community, client.Var("slug", "GoLang")))
+	require.Len(t, community.Community.Posts, 1, "Only the community's posts are listed")
+	assert.Equal(t, post.CreatePost.ID, community.Community.Posts[0].ID)
+	require.NotNil(t, community.Community.Posts[0].CommunityID)
+	assert.Equal(t, communityID, *community.Community.Posts[0].CommunityID)
+
+	var posts struct{ Posts []struct{ ID string } }
+	require.NoError(t, c.Post(postsQuery, &posts))
+	assert.Len(t, posts.Posts, 2, "Community posts are in the global list too")
+}
+
+func TestCommunityCommentPolicy(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	owner, moderator, member := uuid.New(), uuid.New(), uuid.New()
+	communityID := createCommunity(t, c, "golang", owner, client.Var("policy", "MODERATORS"))
+
+	var resp map[string]any
+	require.NoError(t, c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"),
+		client.Var("moderatorIds", []string{moderator.String()}), asViewer(owner)))
+
+	var post createPostResponse
+	require.NoError(t, c.Post(communityPostMutation, &post, client.Var("userId", owner.String()), client.Var("communityId", communityID)))
+	postID := post.CreatePost.ID
+
+	var comment createCommentResponse
+	err := c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", member.String()), asViewer(member))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+	err = c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", owner.String()))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Naming the owner does not make an anonymous request a moderator")
+	err = c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", owner.String()), asViewer(member))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Nor does it for another user")
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", moderator.String()), asViewer(moderator)))
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", owner.String()), asViewer(owner)))
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", member.String()),
+		withViewer(auth.Viewer{UserID: member, Moderator: true})), "Site moderators may comment anywhere")
+
+	require.NoError(t, c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"), client.Var("policy", "CLOSED"), asViewer(moderator)))
+	err = c.Post(createCommentMutation, &comment, client.Var("postId", postID), client.Var("userId", owner.String()))
+	assert.ErrorContains(t, err, "comments are closed")
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+}
+
+func TestUpdateCommunityPermissions(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	owner, moderator, stranger := uuid.New(), uuid.New(), uuid.New()
+	createCommunity(t, c, "golang", owner)
+
+	var updated struct {
+		UpdateCommunity struct {
+			Title         string
+			ModeratorIds  []string
+			CommentPolicy string
+		}
+	}
+	require.NoError(t, c.Post(updateCommunityMutation, &updated, client.Var("slug", "golang"),
+		client.Var("moderatorIds", []string{moderator.String(), owner.String(), moderator.String()}), asViewer(owner)))
+	assert.Equal(t, []string{moderator.String()}, updated.UpdateCommunity.ModeratorIds, "The owner and duplicates are dropped")
+	err := c.Post(updateCommunityMutation, &updated, client.Var("slug", "golang"), client.Var("moderatorIds", []string{"alice"}), asViewer(owner))
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+	assert.ErrorContains(t, err, `"field":"moderatorIds"`)
+
+	require.NoError(t, c.Post(updateCommunityMutation, &updated, client.Var("slug", "golang"), client.Var("title", "Gophers"), asViewer(moderator)))
+	assert.Equal(t, "Gophers", updated.UpdateCommunity.Title)
+	assert.Equal(t, "OPEN", updated.UpdateCommunity.CommentPolicy, "Fields left out are kept")
+	assert.Equal(t, []string{moderator.String()}, updated.UpdateCommunity.ModeratorIds)
+
+	var resp map[string]any
+	err = c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"), client.Var("moderatorIds", []string{}), asViewer(moderator))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Only the owner manages moderators")
+	err = c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"), client.Var("title", "Mine"), asViewer(stranger))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+	err = c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"), client.Var("title", "Mine"))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Anonymous requests cannot update communities")
+	require.NoError(t, c.Post(updateCommunityMutation, &resp, client.Var("slug", "golang"), client.Var("moderatorIds", []string{}),
+		withViewer(auth.Viewer{UserID: stranger, Moderator: true})), "Site moderators may do anything")
+}
@@ -0,0 +1,56 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/contentfilter"
+	"ozon-test/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// screen runs the content filter, if any, over content, masking it in
+// place. It returns the flags to report once the content is stored.
+func (r *Resolver) screen(ctx context.Context, content *contentfilter.Content) ([]contentfilter.Match, error) {
+	if r.ContentFilter == nil {
+		return nil, nil
+	}
+	content.At = time.Now()
+	flags, err := r.ContentFilter.Check(ctx, content)
+	var rejected *contentfilter.RejectedError
+	if errors.As(err, &rejected) {
+		slog.Info("Content rejected", "filter", rejected.Match.Filter, "authorID", content.AuthorID)
+	}
+	return flags, err
+}
+
+// flag opens a report on stored content the filter flagged. A report the
+// filter already has open on it is left alone.
+func (r *Resolver) flag(ctx context.Context, targetType models.ReportTarget, targetID uuid.UUID, flags []contentfilter.Match) {
+	if len(flags) == 0 {
+		return
+	}
+	messages := make([]string, len(flags))
+	for i, match := range flags {
+		messages[i] = match.Filter + ": " + match.Message
+	}
+	report := models.Report{
+		ID:         uuid.New(),
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReporterID: contentfilter.ReporterID,
+		Reason:     flags[0].Reason,
+		Details:    strings.Join(messages, "; "),
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now().UTC(),
+	}
+	err := r.Storage.CreateReport(ctx, report)
+	if err != nil && !errors.Is(err, models.ErrDuplicateReport) {
+		slog.Error("Failed to report flagged content", "error", err, "targetID", targetID)
+		return
+	}
+	slog.Info("Content flagged", "target", targetType, "targetID", targetID, "filters", len(flags))
+}
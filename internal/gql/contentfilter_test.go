@@ -0,0 +1,72 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/contentfilter"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilter(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	resolver := &gql.Resolver{Storage: storage, PubSub: pubsub.NewInMemoryPubSub(), IdempotencyTTL: time.Hour,
+		ContentFilter: contentfilter.NewPipeline(
+			contentfilter.NewBannedWords([]string{"darn"}, contentfilter.Mask),
+			contentfilter.NewLinkLimit(0, contentfilter.Flag),
+			contentfilter.NewDuplicates(time.Minute, contentfilter.Reject),
+		)}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+	ctx := context.Background()
+	user := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "Darn it"), client.Var("userId", user)))
+	stored, err := storage.GetPostByID(ctx, uuid.MustParse(post.CreatePost.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "**** it", stored.Title, "Banned words are masked before storing")
+
+	var updated updatePostResponse
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", post.CreatePost.ID), client.Var("title", "DARN again")))
+	assert.Equal(t, "**** again", updated.UpdatePost.Title, "Edits are filtered too")
+
+	var comment createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("key", "k1"), client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("key", "k1"), client.Var("postId", post.CreatePost.ID), client.Var("userId", user)),
+		"A retry is not a duplicate")
+	err = c.Post(createCommentMutation, &comment, client.Var("postId", post.CreatePost.ID), client.Var("userId", user))
+	assert.ErrorContains(t, err, `"code":"CONTENT_REJECTED"`)
+	assert.ErrorContains(t, err, `"filter":"duplicates"`)
+	comments, err := storage.GetCommentsByPostID(ctx, uuid.MustParse(post.CreatePost.ID), 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	const linkPost = `mutation($userId: ID!) { createPost(title: "Deals", content: "see https://example.com", userId: $userId) { id content } }`
+	var flagged struct {
+		CreatePost struct {
+			ID      string
+			Content string
+		}
+	}
+	require.NoError(t, c.Post(linkPost, &flagged, client.Var("userId", user)))
+	assert.Equal(t, "see https://example.com", flagged.CreatePost.Content, "Flagged content is stored as is")
+	reports, err := storage.ListReports(ctx, models.ReportFilter{Status: models.ReportOpen}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, flagged.CreatePost.ID, reports[0].TargetID.String())
+	assert.Equal(t, contentfilter.ReporterID, reports[0].ReporterID)
+	assert.Equal(t, models.ReasonSpam, reports[0].Reason)
+	assert.Contains(t, reports[0].Details, "links")
+}
@@ -0,0 +1,96 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"ozon-test/internal/contentfilter"
+	"ozon-test/internal/models"
+	"ozon-test/internal/ratelimit"
+	"ozon-test/internal/validation"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CodeConflict is reported in the "code" extension when a mutation lost a
+// race with another request and can be retried after re-reading.
+const CodeConflict = "CONFLICT"
+
+// CodeForbidden is reported in the "code" extension when the viewer may not
+// perform the operation.
+const CodeForbidden = "FORBIDDEN"
+
+// CodeValidationFailed is reported in the "code" extension when arguments
+// were rejected; the "fields" extension then says which and why.
+const CodeValidationFailed = "VALIDATION_FAILED"
+
+// CodeContentRejected is reported in the "code" extension when the content
+// filter refused a post or comment; the "filter" extension names the filter.
+const CodeContentRejected = "CONTENT_REJECTED"
+
+// CodeRateLimited is reported in the "code" extension when the viewer made
+// too many requests; the "retryAfter" extension is the number of seconds to
+// wait before trying again.
+const CodeRateLimited = "RATE_LIMITED"
+
+var errModeratorsOnly = errors.New("only moderators can do this")
+
+var errNotViewer = errors.New("userId must be the authenticated user")
+
+// ErrorPresenter adds a machine-readable "code" extension to errors that
+// clients are expected to handle.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if code := errorCode(err); code != "" {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		gqlErr.Extensions["code"] = code
+	}
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		gqlErr.Extensions["fields"] = invalid.Fields
+	}
+	var rejected *contentfilter.RejectedError
+	if errors.As(err, &rejected) {
+		gqlErr.Extensions["filter"] = rejected.Match.Filter
+	}
+	var limited *ratelimit.LimitedError
+	if errors.As(err, &limited) {
+		gqlErr.Extensions["retryAfter"] = max(int(math.Ceil(limited.RetryAfter.Seconds())), 1)
+	}
+	return gqlErr
+}
+
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, models.ErrPostVersionConflict),
+		errors.Is(err, models.ErrIdempotencyKeyReused),
+		errors.Is(err, models.ErrIdempotencyKeyInProgress),
+		errors.Is(err, models.ErrDuplicateReport),
+		errors.Is(err, models.ErrReportClosed),
+		errors.Is(err, models.ErrCommunitySlugTaken):
+		return CodeConflict
+	case errors.Is(err, errModeratorsOnly),
+		errors.Is(err, errNotViewer),
+		errors.Is(err, errCommunityOwnersOnly),
+		errors.Is(err, models.ErrBlocked),
+		errors.Is(err, models.ErrCommentsClosed),
+		errors.Is(err, models.ErrCommentsModeratorsOnly):
+		return CodeForbidden
+	}
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		return CodeValidationFailed
+	}
+	var rejected *contentfilter.RejectedError
+	if errors.As(err, &rejected) {
+		return CodeContentRejected
+	}
+	var limited *ratelimit.LimitedError
+	if errors.As(err, &limited) {
+		return CodeRateLimited
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ozon-test/internal/auth"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// DefaultIdempotencyTTL is used when Resolver.IdempotencyTTL is zero.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+const maxIdempotencyKeyLength = 255
+
+var errInvalidIdempotencyKey = errors.New("idempotencyKey must be between 1 and 255 characters")
+
+// idempotencyOwner scopes keys to the authenticated viewer, falling back to
+// the author named in the mutation when authentication is disabled.
+func idempotencyOwner(ctx context.Context, userID uuid.UUID) uuid.UUID {
+	if viewer, ok := auth.ViewerFromContext(ctx); ok {
+		return viewer.UserID
+	}
+	return userID
+}
+
+// reserveIdempotencyKey claims key for resourceID before a create mutation
+// runs. If an identical earlier request already claimed the key it returns
+// the resource that request created; otherwise it returns uuid.Nil and the
+// caller should create resourceID. args identify the request, so reusing a
+// key with different arguments fails with models.ErrIdempotencyKeyReused.
+func (r *Resolver) reserveIdempotencyKey(ctx context.Context, key string, operation string, owner, resourceID uuid.UUID, args ...any) (uuid.UUID, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return uuid.Nil, errInvalidIdempotencyKey
+	}
+
+	hash, err := requestHash(operation, args)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	ttl := r.IdempotencyTTL
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	now := time.Now().UTC()
+	stored, reserved, err := r.Storage.ReserveIdempotencyKey(ctx, models.IdempotencyKey{
+		UserID:      owner,
+		Key:         key,
+		Operation:   operation,
+		RequestHash: hash,
+		ResourceID:  resourceID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if reserved {
+		return uuid.Nil, nil
+	}
+	if stored.Operation != operation || stored.RequestHash != hash {
+		return uuid.Nil, models.ErrIdempotencyKeyReused
+	}
+
+	slog.Info("Replaying idempotent mutation", "operation", operation, "resourceID", stored.ResourceID)
+	return stored.ResourceID, nil
+}
+
+// releaseIdempotencyKey frees a key whose mutation failed, so that the
+// client can retry it.
+func (r *Resolver) releaseIdempotencyKey(ctx context.Context, key string, owner, resourceID uuid.UUID) {
+	if err := r.Storage.DeleteIdempotencyKey(context.WithoutCancel(ctx), owner, key, resourceID); err != nil {
+		slog.Error("Failed to release idempotency key", "error", err, "resourceID", resourceID)
+	}
+}
+
+func requestHash(operation string, args []any) (string, error) {
+	data, err := json.Marshal(append([]any{operation}, args...))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,88 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createPostMutation    = `mutation($key: String, $title: String!, $userId: ID!) { createPost(title: $title, content: "body", userId: $userId, idempotencyKey: $key) { id } }`
+	createCommentMutation = `mutation($key: String, $postId: ID!, $userId: ID!) { createComment(postId: $postId, content: "hi", userId: $userId, idempotencyKey: $key) { id } }`
+)
+
+type createPostResponse struct {
+	CreatePost struct{ ID string }
+}
+
+type createCommentResponse struct {
+	CreateComment struct{ ID string }
+}
+
+func TestCreatePostIdempotencyKey(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+	userID := uuid.NewString()
+
+	var first, second createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &first, client.Var("key", "k1"), client.Var("title", "A"), client.Var("userId", userID)))
+	require.NoError(t, c.Post(createPostMutation, &second, client.Var("key", "k1"), client.Var("title", "A"), client.Var("userId", userID)))
+	assert.Equal(t, first.CreatePost.ID, second.CreatePost.ID, "A retry should return the original post")
+
+	posts, err := storage.ListPosts(context.Background(), 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 1, "A retry should not create a duplicate")
+
+	var reused createPostResponse
+	err = c.Post(createPostMutation, &reused, client.Var("key", "k1"), client.Var("title", "B"), client.Var("userId", userID))
+	assert.ErrorContains(t, err, "different request")
+	assert.ErrorContains(t, err, `"code":"CONFLICT"`)
+
+	var other createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &other, client.Var("key", "k1"), client.Var("title", "A"), client.Var("userId", uuid.NewString())))
+	assert.NotEqual(t, first.CreatePost.ID, other.CreatePost.ID, "Keys should be scoped per user")
+
+	var tooLong createPostResponse
+	err = c.Post(createPostMutation, &tooLong, client.Var("key", string(make([]byte, 256))), client.Var("title", "A"), client.Var("userId", userID))
+	assert.ErrorContains(t, err, "idempotencyKey")
+}
+
+func TestCreateCommentIdempotencyKeyPublishesOnce(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	ps := pubsub.NewInMemoryPubSub()
+	c := newClient(storage, ps)
+	userID := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", userID)))
+	postID := uuid.MustParse(post.CreatePost.ID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := ps.Subscribe(ctx, postID)
+	require.NoError(t, err)
+
+	var first, second createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &first, client.Var("key", "c1"), client.Var("postId", postID.String()), client.Var("userId", userID)))
+	require.NoError(t, c.Post(createCommentMutation, &second, client.Var("key", "c1"), client.Var("postId", postID.String()), client.Var("userId", userID)))
+	assert.Equal(t, first.CreateComment.ID, second.CreateComment.ID)
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), postID, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	assert.Equal(t, first.CreateComment.ID, <-messages)
+	select {
+	case msg := <-messages:
+		t.Fatalf("A replay should not notify subscribers again, got %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -2,6 +2,12 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
 type Comment struct {
 	ID        string  `json:"id"`
 	PostID    string  `json:"postId"`
@@ -9,11 +15,60 @@ type Comment struct {
 	Content   string  `json:"content"`
 	UserID    string  `json:"userId"`
 	CreatedAt string  `json:"createdAt"`
+	// Locked comments accept no new replies anywhere beneath them.
+	Locked bool          `json:"locked"`
+	Format ContentFormat `json:"format"`
+	// Content rendered as sanitised HTML.
+	ContentHTML string `json:"contentHtml"`
+	// Taken down by a moderator. Other viewers do not get hidden comments from comments, and get them without content from thread.
+	Hidden bool `json:"hidden"`
+}
+
+// A group of posts with its own moderators.
+type Community struct {
+	ID string `json:"id"`
+	// Lowercase letters, digits and hyphens; fixed when the community is created.
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	OwnerID     string `json:"ownerId"`
+	// The users, besides the owner, who run the community.
+	ModeratorIds  []string      `json:"moderatorIds"`
+	CommentPolicy CommentPolicy `json:"commentPolicy"`
+	CreatedAt     string        `json:"createdAt"`
+	// The community's posts, newest first.
+	Posts []*Post `json:"posts"`
+}
+
+// A page of a user's notifications, with how many are unread in total.
+type Inbox struct {
+	UnreadCount   int             `json:"unreadCount"`
+	Notifications []*Notification `json:"notifications"`
+}
+
+// Sent whenever a user's inbox changes.
+type InboxUpdate struct {
+	UnreadCount int `json:"unreadCount"`
+	// The notification that arrived; null if notifications were marked read.
+	Notification *Notification `json:"notification,omitempty"`
 }
 
 type Mutation struct {
 }
 
+// Something that happened involving a user.
+type Notification struct {
+	ID   string           `json:"id"`
+	Kind NotificationKind `json:"kind"`
+	// Who caused it, e.g. the author who mentioned the user.
+	ActorID string `json:"actorId"`
+	PostID  string `json:"postId"`
+	// Null if it happened in the post itself.
+	CommentID *string `json:"commentId,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+	Read      bool    `json:"read"`
+}
+
 type Post struct {
 	ID            string `json:"id"`
 	Title         string `json:"title"`
@@ -21,10 +76,459 @@ type Post struct {
 	UserID        string `json:"userId"`
 	AllowComments bool   `json:"allowComments"`
 	CreatedAt     string `json:"createdAt"`
+	// Incremented by every update; pass it to updatePost as expectedVersion.
+	Version   int    `json:"version"`
+	UpdatedAt string `json:"updatedAt"`
+	// Null if the editor was not known.
+	UpdatedBy *string `json:"updatedBy,omitempty"`
+	// Drafts and scheduled posts are only visible to their author.
+	Status PostStatus `json:"status"`
+	// When a scheduled post goes live, or when a published post did (RFC 3339).
+	PublishAt *string `json:"publishAt,omitempty"`
+	// How content is written; fixed when the post is created.
+	Format ContentFormat `json:"format"`
+	// Content rendered as sanitised HTML.
+	ContentHTML string `json:"contentHtml"`
+	// Every version of the post, newest first.
+	Revisions []*PostRevision `json:"revisions"`
+	// Taken down by a moderator; hidden posts are only shown to moderators.
+	Hidden bool `json:"hidden"`
+	// Null for posts outside any community.
+	CommunityID *string `json:"communityId,omitempty"`
+}
+
+// A post as it was at one version.
+type PostRevision struct {
+	PostID        string `json:"postId"`
+	Version       int    `json:"version"`
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+	AllowComments bool   `json:"allowComments"`
+	// Null if the editor was not known.
+	EditorID  *string       `json:"editorId,omitempty"`
+	CreatedAt string        `json:"createdAt"`
+	Format    ContentFormat `json:"format"`
+	// Content rendered as sanitised HTML.
+	ContentHTML string `json:"contentHtml"`
 }
 
 type Query struct {
 }
 
+// A user's complaint about a post or comment.
+type Report struct {
+	ID         string       `json:"id"`
+	TargetType ReportTarget `json:"targetType"`
+	TargetID   string       `json:"targetId"`
+	ReporterID string       `json:"reporterId"`
+	Reason     ReportReason `json:"reason"`
+	Details    *string      `json:"details,omitempty"`
+	Status     ReportStatus `json:"status"`
+	CreatedAt  string       `json:"createdAt"`
+	// The moderator who resolved or dismissed the report.
+	ClosedBy *string `json:"closedBy,omitempty"`
+	ClosedAt *string `json:"closedAt,omitempty"`
+	// Whether closing the report hid its target.
+	TargetHidden bool `json:"targetHidden"`
+}
+
 type Subscription struct {
 }
+
+// A comment in a depth-limited view of a thread.
+type ThreadComment struct {
+	Comment *Comment `json:"comment"`
+	// 0 for top-level comments.
+	Depth int `json:"depth"`
+	// Replies below maxDepth under this comment; only set at maxDepth.
+	MoreReplies int `json:"moreReplies"`
+}
+
+// A user someone muted or blocked.
+type UserRelation struct {
+	UserID string `json:"userId"`
+	// When the user was muted or blocked.
+	CreatedAt string `json:"createdAt"`
+}
+
+// A URL that is sent signed JSON for the events it subscribes to.
+type Webhook struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedAt string         `json:"createdAt"`
+	// Delivery attempts, newest first.
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+// One attempt to send an event to a webhook.
+type WebhookDelivery struct {
+	ID string `json:"id"`
+	// Shared by every attempt to send the same event.
+	EventID string       `json:"eventId"`
+	Event   WebhookEvent `json:"event"`
+	// The JSON body that was sent.
+	Payload string `json:"payload"`
+	// 1 for the first attempt.
+	Attempt int `json:"attempt"`
+	// Null if no response was received.
+	StatusCode *int `json:"statusCode,omitempty"`
+	// Why the attempt failed; null if it succeeded.
+	Error     *string `json:"error,omitempty"`
+	Succeeded bool    `json:"succeeded"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// Who may comment on the posts of a community.
+type CommentPolicy string
+
+const (
+	CommentPolicyOpen CommentPolicy = "OPEN"
+	// Only the community's owner and moderators, who must be authenticated.
+	CommentPolicyModerators CommentPolicy = "MODERATORS"
+	// Nobody.
+	CommentPolicyClosed CommentPolicy = "CLOSED"
+)
+
+var AllCommentPolicy = []CommentPolicy{
+	CommentPolicyOpen,
+	CommentPolicyModerators,
+	CommentPolicyClosed,
+}
+
+func (e CommentPolicy) IsValid() bool {
+	switch e {
+	case CommentPolicyOpen, CommentPolicyModerators, CommentPolicyClosed:
+		return true
+	}
+	return false
+}
+
+func (e CommentPolicy) String() string {
+	return string(e)
+}
+
+func (e *CommentPolicy) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentPolicy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentPolicy", str)
+	}
+	return nil
+}
+
+func (e CommentPolicy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// How post and comment content is rendered to HTML.
+type ContentFormat string
+
+const (
+	// Text, with blank lines separating paragraphs.
+	ContentFormatPlain ContentFormat = "PLAIN"
+	// CommonMark with tables, strikethrough and autolinks. Raw HTML and images are dropped.
+	ContentFormatMarkdown ContentFormat = "MARKDOWN"
+)
+
+var AllContentFormat = []ContentFormat{
+	ContentFormatPlain,
+	ContentFormatMarkdown,
+}
+
+func (e ContentFormat) IsValid() bool {
+	switch e {
+	case ContentFormatPlain, ContentFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (e ContentFormat) String() string {
+	return string(e)
+}
+
+func (e *ContentFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentFormat", str)
+	}
+	return nil
+}
+
+func (e ContentFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NotificationKind string
+
+const (
+	// The user was @mentioned in a post or comment.
+	NotificationKindMention NotificationKind = "MENTION"
+	// Someone replied to the user's comment, or commented on their post.
+	NotificationKindReply NotificationKind = "REPLY"
+)
+
+var AllNotificationKind = []NotificationKind{
+	NotificationKindMention,
+	NotificationKindReply,
+}
+
+func (e NotificationKind) IsValid() bool {
+	switch e {
+	case NotificationKindMention, NotificationKindReply:
+		return true
+	}
+	return false
+}
+
+func (e NotificationKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationKind", str)
+	}
+	return nil
+}
+
+func (e NotificationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "DRAFT"
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportReason string
+
+const (
+	ReportReasonSpam     ReportReason = "SPAM"
+	ReportReasonAbuse    ReportReason = "ABUSE"
+	ReportReasonOffTopic ReportReason = "OFF_TOPIC"
+	ReportReasonOther    ReportReason = "OTHER"
+)
+
+var AllReportReason = []ReportReason{
+	ReportReasonSpam,
+	ReportReasonAbuse,
+	ReportReasonOffTopic,
+	ReportReasonOther,
+}
+
+func (e ReportReason) IsValid() bool {
+	switch e {
+	case ReportReasonSpam, ReportReasonAbuse, ReportReasonOffTopic, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+func (e ReportReason) String() string {
+	return string(e)
+}
+
+func (e *ReportReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportReason", str)
+	}
+	return nil
+}
+
+func (e ReportReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportStatus string
+
+const (
+	// Waiting for a moderator.
+	ReportStatusOpen ReportStatus = "OPEN"
+	// A moderator agreed with the report.
+	ReportStatusResolved ReportStatus = "RESOLVED"
+	// A moderator found nothing wrong.
+	ReportStatusDismissed ReportStatus = "DISMISSED"
+)
+
+var AllReportStatus = []ReportStatus{
+	ReportStatusOpen,
+	ReportStatusResolved,
+	ReportStatusDismissed,
+}
+
+func (e ReportStatus) IsValid() bool {
+	switch e {
+	case ReportStatusOpen, ReportStatusResolved, ReportStatusDismissed:
+		return true
+	}
+	return false
+}
+
+func (e ReportStatus) String() string {
+	return string(e)
+}
+
+func (e *ReportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportStatus", str)
+	}
+	return nil
+}
+
+func (e ReportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// What a report is about.
+type ReportTarget string
+
+const (
+	ReportTargetPost    ReportTarget = "POST"
+	ReportTargetComment ReportTarget = "COMMENT"
+)
+
+var AllReportTarget = []ReportTarget{
+	ReportTargetPost,
+	ReportTargetComment,
+}
+
+func (e ReportTarget) IsValid() bool {
+	switch e {
+	case ReportTargetPost, ReportTargetComment:
+		return true
+	}
+	return false
+}
+
+func (e ReportTarget) String() string {
+	return string(e)
+}
+
+func (e *ReportTarget) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportTarget(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportTarget", str)
+	}
+	return nil
+}
+
+func (e ReportTarget) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Something that happened to content, for which webhooks are called.
+type WebhookEvent string
+
+const (
+	WebhookEventPostCreated WebhookEvent = "POST_CREATED"
+	// An edit, revert or status change of a post.
+	WebhookEventPostUpdated    WebhookEvent = "POST_UPDATED"
+	WebhookEventCommentCreated WebhookEvent = "COMMENT_CREATED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventPostCreated,
+	WebhookEventPostUpdated,
+	WebhookEventCommentCreated,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventPostCreated, WebhookEventPostUpdated, WebhookEventCommentCreated:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
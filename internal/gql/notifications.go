@@ -0,0 +1,202 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/mention"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	errUserRequired              = errors.New("userId is required for unauthenticated requests")
+	errNotificationAddedDisabled = errors.New("notificationAdded subscriptions are not enabled")
+	errInboxUpdatedDisabled      = errors.New("inboxUpdated subscriptions are not enabled")
+)
+
+// inboxEvent is what NotificationPubSub carries for a user: a new
+// notification, or none when notifications were marked read.
+// Notifications never change once created, so events carry them whole
+// rather than an ID to look up.
+type inboxEvent struct {
+	Notification *models.Notification `json:"notification,omitempty"`
+}
+
+// recipientID is whose notifications a request is about: the authenticated
+// viewer, else the userId argument.
+func recipientID(ctx context.Context, userID *string) (uuid.UUID, error) {
+	id, err := editorID(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if id == uuid.Nil {
+		return uuid.Nil, errUserRequired
+	}
+	return id, nil
+}
+
+// postPublished tells postAdded subscribers and the users mentioned in a
+// post that has just gone live.
+func (r *Resolver) postPublished(ctx context.Context, post models.Post) {
+	r.announcePost(ctx, post)
+	notified := map[uuid.UUID]bool{post.UserID: true}
+	r.deliver(ctx, r.mentions(ctx, post.UserID, post.ID, nil, post.Title+"\n"+post.Content, notified))
+}
+
+// commentCreated notifies the author of what a new comment on post replies
+// to, the parent comment or else the post, and the users it mentions.
+// Comments on unpublished posts notify nobody, as only their author can
+// see them.
+func (r *Resolver) commentCreated(ctx context.Context, post models.Post, comment models.Comment) {
+	if post.Status != models.PostPublished {
+		return
+	}
+
+	now := time.Now()
+	notified := map[uuid.UUID]bool{comment.UserID: true}
+	var notifications []models.Notification
+	repliedTo := post.UserID
+	if comment.ParentID != nil {
+		parent, err := r.Storage.GetCommentByID(ctx, *comment.ParentID)
+		if err != nil {
+			slog.Error("Failed to get replied-to comment", "error", err, "commentID", *comment.ParentID)
+			repliedTo = uuid.Nil
+		} else {
+			repliedTo = parent.UserID
+		}
+	}
+	if repliedTo != uuid.Nil && !notified[repliedTo] {
+		notified[repliedTo] = true
+		notifications = append(notifications, newNotification(models.NotificationReply, repliedTo, comment.UserID, comment.PostID, &comment.ID, now))
+	}
+
+	notifications = append(notifications, r.mentions(ctx, comment.UserID, comment.PostID, &comment.ID, comment.Content, notified)...)
+	r.deliver(ctx, notifications)
+}
+
+// mentions returns a mention notification for each user named in text who
+// is not yet notified, and marks them notified.
+func (r *Resolver) mentions(ctx context.Context, actorID, postID uuid.UUID, commentID *uuid.UUID, text string, notified map[uuid.UUID]bool) []models.Notification {
+	names := mention.Parse(text)
+	if len(names) == 0 {
+		return nil
+	}
+	users, err := r.Storage.ResolveUsernames(ctx, names)
+	if err != nil {
+		slog.Error("Failed to resolve mentions", "error", err, "postID", postID)
+		return nil
+	}
+
+	var notifications []models.Notification
+	now := time.Now()
+	for _, name := range names {
+		userID, ok := users[name]
+		if !ok || notified[userID] {
+			continue
+		}
+		notified[userID] = true
+		notifications = append(notifications, newNotification(models.NotificationMention, userID, actorID, postID, commentID, now))
+	}
+	return notifications
+}
+
+func newNotification(kind models.NotificationKind, userID, actorID, postID uuid.UUID, commentID *uuid.UUID, now time.Time) models.Notification {
+	return models.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Kind:      kind,
+		ActorID:   actorID,
+		PostID:    postID,
+		CommentID: commentID,
+		CreatedAt: now,
+	}
+}
+
+// deliver stores notifications and publishes them to their recipients.
+// Failures are logged; the post or comment has already been stored.
+func (r *Resolver) deliver(ctx context.Context, notifications []models.Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+	if err := r.Storage.CreateNotifications(ctx, notifications); err != nil {
+		slog.Error("Failed to create notifications", "error", err, "postID", notifications[0].PostID)
+		return
+	}
+	slog.Info("Notifications created", "postID", notifications[0].PostID, "count", len(notifications))
+	for i := range notifications {
+		r.publishInboxEvent(ctx, notifications[i].UserID, inboxEvent{Notification: &notifications[i]})
+	}
+}
+
+// publishInboxEvent sends event to userID's notificationAdded and
+// inboxUpdated subscribers.
+func (r *Resolver) publishInboxEvent(ctx context.Context, userID uuid.UUID, event inboxEvent) {
+	if r.NotificationPubSub == nil {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err == nil {
+		err = r.NotificationPubSub.Publish(ctx, userID, string(payload))
+	}
+	if err != nil {
+		slog.Error("Failed to publish inbox event", "error", err, "userID", userID)
+	}
+}
+
+// subscribeInbox decodes userID's inbox events until ctx is done.
+func (r *Resolver) subscribeInbox(ctx context.Context, userID uuid.UUID) (<-chan inboxEvent, error) {
+	messages, err := r.NotificationPubSub.Subscribe(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to subscribe to notifications", "error", err, "userID", userID)
+		return nil, err
+	}
+
+	events := make(chan inboxEvent, 1)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event inboxEvent
+				if err := json.Unmarshal([]byte(message), &event); err != nil {
+					slog.Warn("Failed to decode inbox event", "error", err, "userID", userID)
+					continue
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func toGQLNotification(n models.Notification) *gqlModel.Notification {
+	var commentID *string
+	if n.CommentID != nil {
+		id := n.CommentID.String()
+		commentID = &id
+	}
+	return &gqlModel.Notification{
+		ID:        n.ID.String(),
+		Kind:      gqlModel.NotificationKind(n.Kind),
+		ActorID:   n.ActorID.String(),
+		PostID:    n.PostID.String(),
+		CommentID: commentID,
+		CreatedAt: n.CreatedAt.Format(time.RFC3339),
+		Read:      n.Read,
+	}
+}
@@ -0,0 +1,205 @@
+package gql_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ozon-test/internal/gql"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	setUsernameMutation    = `mutation($userId: ID!, $name: String!) { setUsername(username: $name, userId: $userId) }`
+	notificationsQuery     = `query($userId: ID!, $unreadOnly: Boolean) { notifications(userId: $userId, unreadOnly: $unreadOnly, page: 1, pageSize: 10) { id kind actorId postId commentId read } }`
+	markReadMutation       = `mutation($userId: ID!, $ids: [ID!]) { markRead(ids: $ids, userId: $userId) }`
+	mentionPostMutation    = `mutation($userId: ID!, $content: String!, $status: PostStatus) { createPost(title: "T", content: $content, userId: $userId, status: $status) { id } }`
+	mentionCommentMutation = `mutation($postId: ID!, $userId: ID!, $content: String!) { createComment(postId: $postId, content: $content, userId: $userId) { id } }`
+)
+
+type notificationsResponse struct {
+	Notifications []struct {
+		ID        string
+		Kind      string
+		ActorID   string
+		PostID    string
+		CommentID *string
+		Read      bool
+	}
+}
+
+func TestMentionNotifications(t *testing.T) {
+	notifications := pubsub.NewInMemoryPubSub()
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub(), NotificationPubSub: notifications}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+
+	alice, bob := uuid.NewString(), uuid.NewString()
+	var name struct{ SetUsername string }
+	require.NoError(t, c.Post(setUsernameMutation, &name, client.Var("userId", alice), client.Var("name", "@Alice")))
+	assert.Equal(t, "alice", name.SetUsername)
+	require.NoError(t, c.Post(setUsernameMutation, &name, client.Var("userId", bob), client.Var("name", "bob")))
+	err := c.Post(setUsernameMutation, &name, client.Var("userId", bob), client.Var("name", "ALICE"))
+	assert.ErrorContains(t, err, "is taken")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bobEvents, err := notifications.Subscribe(ctx, uuid.MustParse(bob))
+	require.NoError(t, err)
+
+	var post struct{ CreatePost struct{ ID string } }
+	require.NoError(t, c.Post(mentionPostMutation, &post, client.Var("userId", alice), client.Var("content", "Hi @bob, @alice and @nobody")))
+
+	var bobs notificationsResponse
+	require.NoError(t, c.Post(notificationsQuery, &bobs, client.Var("userId", bob)))
+	require.Len(t, bobs.Notifications, 1)
+	got := bobs.Notifications[0]
+	assert.Equal(t, "MENTION", got.Kind)
+	assert.Equal(t, alice, got.ActorID)
+	assert.Equal(t, post.CreatePost.ID, got.PostID)
+	assert.Nil(t, got.CommentID)
+	assert.False(t, got.Read)
+
+	nextEvent := func() (event struct{ Notification *models.Notification }) {
+		t.Helper()
+		select {
+		case msg := <-bobEvents:
+			require.NoError(t, json.Unmarshal([]byte(msg), &event))
+		case <-time.After(time.Second):
+			t.Fatal("Inbox event was not published")
+		}
+		return event
+	}
+	event := nextEvent()
+	require.NotNil(t, event.Notification)
+	assert.Equal(t, got.ID, event.Notification.ID.String())
+
+	var alices notificationsResponse
+	require.NoError(t, c.Post(notificationsQuery, &alices, client.Var("userId", alice)))
+	assert.Empty(t, alices.Notifications, "Authors are not notified of their own mentions")
+
+	var comment struct{ CreateComment struct{ ID string } }
+	require.NoError(t, c.Post(mentionCommentMutation, &comment, client.Var("postId", post.CreatePost.ID), client.Var("userId", bob),
+		client.Var("content", "thanks @alice")))
+	require.NoError(t, c.Post(notificationsQuery, &alices, client.Var("userId", alice)))
+	require.Len(t, alices.Notifications, 1)
+	assert.Equal(t, "REPLY", alices.Notifications[0].Kind, "A reply that also mentions its recipient notifies once")
+	require.NotNil(t, alices.Notifications[0].CommentID)
+	assert.Equal(t, comment.CreateComment.ID, *alices.Notifications[0].CommentID)
+
+	var draft struct{ CreatePost struct{ ID string } }
+	require.NoError(t, c.Post(mentionPostMutation, &draft, client.Var("userId", alice), client.Var("content", "Soon, @bob"),
+		client.Var("status", "DRAFT"), asViewer(uuid.MustParse(alice))))
+	require.NoError(t, c.Post(notificationsQuery, &bobs, client.Var("userId", bob)))
+	assert.Len(t, bobs.Notifications, 1, "Drafts mention nobody until published")
+	var published struct{ SetPostStatus postStatusResponse }
+	require.NoError(t, c.Post(setPostStatusMutation, &published, client.Var("id", draft.CreatePost.ID), client.Var("status", "PUBLISHED"),
+		asViewer(uuid.MustParse(alice))))
+	assert.NotNil(t, nextEvent().Notification)
+	require.NoError(t, c.Post(notificationsQuery, &bobs, client.Var("userId", bob)))
+	require.Len(t, bobs.Notifications, 2)
+	assert.Equal(t, draft.CreatePost.ID, bobs.Notifications[0].PostID, "Newest first")
+
+	var marked struct{ MarkRead int }
+	require.NoError(t, c.Post(markReadMutation, &marked, client.Var("userId", bob), client.Var("ids", []string{bobs.Notifications[1].ID})))
+	assert.Equal(t, 1, marked.MarkRead)
+	assert.Nil(t, nextEvent().Notification, "Marking read only refreshes the count")
+	require.NoError(t, c.Post(notificationsQuery, &bobs, client.Var("userId", bob), client.Var("unreadOnly", true)))
+	require.Len(t, bobs.Notifications, 1)
+	assert.Equal(t, draft.CreatePost.ID, bobs.Notifications[0].PostID)
+	require.NoError(t, c.Post(markReadMutation, &marked, client.Var("userId", bob)))
+	assert.Equal(t, 1, marked.MarkRead)
+	nextEvent()
+
+	assert.ErrorContains(t, c.Post(`query { notifications(page: 1, pageSize: 10) { id } }`, &bobs), "userId is required")
+	err = c.Post(notificationsQuery, &bobs, client.Var("userId", "zzz"))
+	assert.ErrorContains(t, err, `"field":"userId"`)
+	err = c.Post(markReadMutation, &marked, client.Var("userId", bob), client.Var("ids", []string{"first"}))
+	assert.ErrorContains(t, err, `"field":"ids"`)
+}
+
+func TestReplyInbox(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	resolver := &gql.Resolver{Storage: storage, PubSub: pubsub.NewInMemoryPubSub(), NotificationPubSub: pubsub.NewInMemoryPubSub()}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	alice, bob, carol := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, err := resolver.Subscription().InboxUpdated(ctx, &bob)
+	require.NoError(t, err)
+	nextUpdate := func() *gqlModel.InboxUpdate {
+		t.Helper()
+		select {
+		case update := <-updates:
+			return update
+		case <-time.After(time.Second):
+			t.Fatal("Inbox update was not sent")
+			return nil
+		}
+	}
+	assert.Equal(t, &gqlModel.InboxUpdate{UnreadCount: 0}, nextUpdate(), "The current count comes first")
+
+	var post struct{ CreatePost struct{ ID string } }
+	require.NoError(t, c.Post(mentionPostMutation, &post, client.Var("userId", alice), client.Var("content", "Hello")))
+	postID := post.CreatePost.ID
+	comment := func(userID string, parentID *string) string {
+		t.Helper()
+		var res struct{ CreateComment struct{ ID string } }
+		require.NoError(t, c.Post(`mutation($postId: ID!, $parentId: ID, $userId: ID!) { createComment(postId: $postId, parentId: $parentId, content: "re", userId: $userId) { id } }`,
+			&res, client.Var("postId", postID), client.Var("parentId", parentID), client.Var("userId", userID)))
+		return res.CreateComment.ID
+	}
+
+	bobs := comment(bob, nil)
+	alices := comment(alice, &bobs)
+	update := nextUpdate()
+	assert.Equal(t, 1, update.UnreadCount)
+	require.NotNil(t, update.Notification)
+	assert.Equal(t, alice, update.Notification.ActorID)
+	assert.Equal(t, alices, *update.Notification.CommentID)
+
+	comment(alice, &alices)
+	comment(bob, &bobs)
+	carols := comment(carol, &bobs)
+	assert.Equal(t, 2, nextUpdate().UnreadCount)
+
+	const inboxQuery = `query($userId: ID!) { inbox(userId: $userId, page: 1, pageSize: 1) { unreadCount notifications { kind actorId commentId } } }`
+	var inbox struct {
+		Inbox struct {
+			UnreadCount   int
+			Notifications []struct {
+				Kind      string
+				ActorID   string
+				CommentID string
+			}
+		}
+	}
+	require.NoError(t, c.Post(inboxQuery, &inbox, client.Var("userId", bob)))
+	assert.Equal(t, 2, inbox.Inbox.UnreadCount, "Replying to yourself notifies nobody")
+	require.Len(t, inbox.Inbox.Notifications, 1)
+	assert.Equal(t, "REPLY", inbox.Inbox.Notifications[0].Kind)
+	assert.Equal(t, carol, inbox.Inbox.Notifications[0].ActorID)
+	assert.Equal(t, carols, inbox.Inbox.Notifications[0].CommentID)
+
+	require.NoError(t, c.Post(inboxQuery, &inbox, client.Var("userId", alice)))
+	assert.Equal(t, 1, inbox.Inbox.UnreadCount, "Top-level comments notify the post author")
+
+	var marked struct{ MarkRead int }
+	require.NoError(t, c.Post(markReadMutation, &marked, client.Var("userId", bob)))
+	update = nextUpdate()
+	assert.Zero(t, update.UnreadCount)
+	assert.Nil(t, update.Notification)
+}
@@ -0,0 +1,39 @@
+package gql
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// storeComment creates comment and, if an outbox relay is configured, the
+// commentAdded event announcing it in the same transaction.
+func (r *Resolver) storeComment(ctx context.Context, comment models.Comment) error {
+	if r.Outbox == nil {
+		return r.Storage.CreateComment(ctx, comment)
+	}
+	event := models.OutboxEvent{
+		ID:        uuid.New(),
+		Channel:   models.OutboxCommentAdded,
+		Topic:     comment.PostID,
+		Message:   comment.ID.String(),
+		CreatedAt: time.Now().UTC(),
+	}
+	return r.Storage.CreateCommentWithEvent(ctx, comment, event)
+}
+
+// announceComment tells commentAdded subscribers about a stored comment.
+// Without an outbox relay it publishes directly, and an event that fails
+// to publish is lost.
+func (r *Resolver) announceComment(ctx context.Context, comment models.Comment) {
+	if r.Outbox != nil {
+		r.Outbox.Notify()
+		return
+	}
+	if err := r.PubSub.Publish(ctx, comment.PostID, comment.ID.String()); err != nil {
+		slog.Error("Failed to publish comment", "error", err, "commentID", comment.ID)
+	}
+}
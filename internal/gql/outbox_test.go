@@ -0,0 +1,54 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/outbox"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentAddedThroughOutbox(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewInMemoryStorage()
+	ps := pubsub.NewInMemoryPubSub()
+	defer ps.Close()
+	relay := outbox.NewRelay(storage, map[string]pubsub.PubSub{models.OutboxCommentAdded: ps}, 0)
+	resolver := &gql.Resolver{Storage: storage, PubSub: ps, Outbox: relay}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "Outbox"), client.Var("userId", uuid.NewString())))
+	messages, err := ps.Subscribe(ctx, uuid.MustParse(post.CreatePost.ID))
+	require.NoError(t, err)
+
+	var comment createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", post.CreatePost.ID), client.Var("userId", uuid.NewString())))
+	assert.Empty(t, messages, "The mutation only stores the event")
+	pending, err := storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, comment.CreateComment.ID, pending[0].Message)
+
+	published, err := relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	select {
+	case message := <-messages:
+		assert.Equal(t, comment.CreateComment.ID, message)
+	case <-time.After(time.Second):
+		t.Fatal("commentAdded was not published")
+	}
+}
@@ -0,0 +1,128 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon-test/internal/auth"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// postAddedTopic is the PostPubSub key every postAdded event is sent on.
+// Posts in a community are also sent on the community's ID.
+var postAddedTopic = uuid.Nil
+
+var errPostAddedDisabled = errors.New("postAdded subscriptions are not enabled")
+
+// viewerID is the authenticated user, or uuid.Nil for anonymous requests.
+func viewerID(ctx context.Context) uuid.UUID {
+	viewer, _ := auth.ViewerFromContext(ctx)
+	return viewer.UserID
+}
+
+// canSee reports whether the viewer may see post: it must be visible to
+// them and, unless they are a moderator, not hidden.
+func canSee(ctx context.Context, post models.Post) bool {
+	return post.VisibleTo(viewerID(ctx)) && (!post.Hidden || auth.IsModerator(ctx))
+}
+
+// visiblePost returns a post the viewer may see. Unpublished and hidden
+// posts are reported as missing rather than forbidden, so that their
+// existence is not revealed.
+func (r *Resolver) visiblePost(ctx context.Context, postID uuid.UUID) (models.Post, error) {
+	post, err := r.Storage.GetPostByID(ctx, postID)
+	if err != nil {
+		return models.Post{}, err
+	}
+	if !canSee(ctx, post) {
+		slog.Warn("Post is not visible to viewer", "postID", postID, "status", post.Status)
+		return models.Post{}, models.ErrPostNotFound
+	}
+	return post, nil
+}
+
+// checkCommentable rejects comment listings of posts the viewer may not
+// see. Posts that do not exist are left to the storage, as
+// they were before posts could be unpublished.
+func (r *Resolver) checkCommentable(ctx context.Context, postID uuid.UUID) error {
+	post, err := r.Storage.GetPostByID(ctx, postID)
+	if errors.Is(err, models.ErrPostNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !canSee(ctx, post) {
+		slog.Warn("Post is not visible to viewer", "postID", postID, "status", post.Status)
+		return models.ErrPostNotFound
+	}
+	return nil
+}
+
+// publishing validates a requested status and returns it with the time the
+// post is or will be published.
+func publishing(status gqlModel.PostStatus, publishAt *string, now time.Time) (models.PostStatus, *time.Time, error) {
+	switch status {
+	case gqlModel.PostStatusScheduled:
+		if publishAt == nil {
+			return "", nil, errors.New("publishAt is required for SCHEDULED posts")
+		}
+		at, err := time.Parse(time.RFC3339, *publishAt)
+		if err != nil {
+			return "", nil, fmt.Errorf("publishAt must be an RFC 3339 timestamp: %w", err)
+		}
+		if !at.After(now) {
+			return "", nil, errors.New("publishAt must be in the future")
+		}
+		at = at.UTC()
+		return models.PostScheduled, &at, nil
+	case gqlModel.PostStatusDraft, gqlModel.PostStatusPublished:
+		if publishAt != nil {
+			return "", nil, errors.New("publishAt is only allowed for SCHEDULED posts")
+		}
+		if status == gqlModel.PostStatusDraft {
+			return models.PostDraft, nil, nil
+		}
+		return models.PostPublished, &now, nil
+	default:
+		return "", nil, fmt.Errorf("unknown status %q", status)
+	}
+}
+
+// announcePost notifies postAdded subscribers about a published post: all
+// of them on postAddedTopic and those following its community on the
+// community's ID.
+func (r *Resolver) announcePost(ctx context.Context, post models.Post) {
+	if r.PostPubSub == nil {
+		return
+	}
+	topics := []uuid.UUID{postAddedTopic}
+	if post.CommunityID != nil {
+		topics = append(topics, *post.CommunityID)
+	}
+	for _, topic := range topics {
+		if err := r.PostPubSub.Publish(ctx, topic, post.ID.String()); err != nil {
+			slog.Error("Failed to announce post", "error", err, "postID", post.ID, "topic", topic)
+		}
+	}
+}
+
+// PublishDuePosts publishes the scheduled posts that are due at now,
+// announces them to postAdded subscribers, notifies mentioned users and
+// calls webhooks. It returns how many were published.
+func (r *Resolver) PublishDuePosts(ctx context.Context, now time.Time) (int, error) {
+	posts, err := r.Storage.PublishDuePosts(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	for _, post := range posts {
+		r.postPublished(ctx, post)
+		r.dispatchWebhooks(ctx, models.WebhookPostUpdated, post)
+	}
+	return len(posts), nil
+}
@@ -0,0 +1,109 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createDraftMutation   = `mutation($userId: ID!) { createPost(title: "Draft", content: "body", userId: $userId, status: DRAFT) { id } }`
+	setPostStatusMutation = `mutation($id: ID!, $status: PostStatus!, $publishAt: String) { setPostStatus(id: $id, status: $status, publishAt: $publishAt) { status publishAt } }`
+	postQuery             = `query($id: ID!) { post(id: $id) { id status } }`
+	postsQuery            = `query { posts(page: 1, pageSize: 10) { id } }`
+)
+
+type postStatusResponse struct {
+	Status    string
+	PublishAt *string
+}
+
+func asViewer(userID uuid.UUID) client.Option {
+	return withViewer(auth.Viewer{UserID: userID})
+}
+
+func withViewer(viewer auth.Viewer) client.Option {
+	return func(r *client.Request) {
+		r.HTTP = r.HTTP.WithContext(auth.WithViewer(r.HTTP.Context(), viewer))
+	}
+}
+
+func TestDraftAndScheduledPosts(t *testing.T) {
+	postPubSub := pubsub.NewInMemoryPubSub()
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub(), PostPubSub: postPubSub}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	c := client.New(srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	announced, err := postPubSub.Subscribe(ctx, uuid.Nil)
+	require.NoError(t, err)
+
+	author := uuid.New()
+	var draft struct{ CreatePost struct{ ID string } }
+	require.NoError(t, c.Post(createDraftMutation, &draft, client.Var("userId", author.String()), asViewer(author)))
+	id := draft.CreatePost.ID
+
+	var posts struct{ Posts []struct{ ID string } }
+	require.NoError(t, c.Post(postsQuery, &posts))
+	assert.Empty(t, posts.Posts, "Drafts must not be listed for other users")
+	require.NoError(t, c.Post(postsQuery, &posts, asViewer(author)))
+	assert.Len(t, posts.Posts, 1, "Authors see their own drafts")
+
+	var post struct {
+		Post *struct {
+			ID     string
+			Status string
+		}
+	}
+	assert.ErrorContains(t, c.Post(postQuery, &post, client.Var("id", id)), "post not found")
+	assert.ErrorContains(t, c.Post(setPostStatusMutation, &post, client.Var("id", id), client.Var("status", "PUBLISHED"), asViewer(uuid.New())), "post not found")
+	var updated updatePostResponse
+	assert.ErrorContains(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "Mine"), asViewer(uuid.New())), "post not found")
+	assert.ErrorContains(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "Mine")), "post not found")
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "Still a draft"), asViewer(author)))
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	var res struct{ SetPostStatus postStatusResponse }
+	assert.ErrorContains(t, c.Post(setPostStatusMutation, &res, client.Var("id", id), client.Var("status", "SCHEDULED"), client.Var("publishAt", past), asViewer(author)), "in the future")
+	assert.ErrorContains(t, c.Post(setPostStatusMutation, &res, client.Var("id", id), client.Var("status", "SCHEDULED"), asViewer(author)), "publishAt is required")
+
+	publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, c.Post(setPostStatusMutation, &res, client.Var("id", id), client.Var("status", "SCHEDULED"),
+		client.Var("publishAt", publishAt.Format(time.RFC3339)), asViewer(author)))
+	assert.Equal(t, "SCHEDULED", res.SetPostStatus.Status)
+
+	published, err := resolver.PublishDuePosts(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, published, "A post must not be published before its publishAt")
+
+	published, err = resolver.PublishDuePosts(context.Background(), publishAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	select {
+	case msg := <-announced:
+		assert.Equal(t, id, msg)
+	case <-time.After(time.Second):
+		t.Fatal("Published post was not announced")
+	}
+
+	require.NoError(t, c.Post(postQuery, &post, client.Var("id", id)))
+	require.NotNil(t, post.Post)
+	assert.Equal(t, id, post.Post.ID)
+	assert.Equal(t, "PUBLISHED", post.Post.Status)
+
+	err = c.Post(setPostStatusMutation, &res, client.Var("id", id), client.Var("status", "DRAFT"), asViewer(author))
+	assert.ErrorContains(t, err, "already published")
+}
@@ -0,0 +1,51 @@
+package gql
+
+import (
+	"context"
+	"ozon-test/internal/auth"
+	"ozon-test/internal/ratelimit"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// RateLimit is a gqlgen handler extension that applies Limiter to every
+// mutation, keyed by the authenticated user or else the client's IP
+// address. A userId argument is not trusted for this.
+type RateLimit struct {
+	Limiter *ratelimit.Limiter
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = RateLimit{}
+
+func (RateLimit) ExtensionName() string {
+	return "RateLimit"
+}
+
+func (RateLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptField takes a token before each mutation runs.
+func (e RateLimit) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Object != "Mutation" {
+		return next(ctx)
+	}
+	if err := e.Limiter.Allow(ctx, fc.Field.Name, rateLimitSubject(ctx)); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
+
+func rateLimitSubject(ctx context.Context) string {
+	if viewer, ok := auth.ViewerFromContext(ctx); ok {
+		return "user:" + viewer.UserID.String()
+	}
+	if ip, ok := ratelimit.ClientIPFromContext(ctx); ok {
+		return "ip:" + ip
+	}
+	return "ip:unknown"
+}
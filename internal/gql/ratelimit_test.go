@@ -0,0 +1,59 @@
+package gql_test
+
+import (
+	"testing"
+	"time"
+
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/ratelimit"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withClientIP(ip string) client.Option {
+	return func(r *client.Request) {
+		r.HTTP = r.HTTP.WithContext(ratelimit.WithClientIP(r.HTTP.Context(), ip))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub()}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	srv.Use(gql.RateLimit{Limiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		"createComment": {Tokens: 2, Per: time.Minute},
+	})})
+	c := client.New(srv)
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", uuid.NewString())))
+	comment := func(opts ...client.Option) error {
+		var res createCommentResponse
+		return c.Post(createCommentMutation, &res, append([]client.Option{client.Var("postId", post.CreatePost.ID), client.Var("userId", uuid.NewString())}, opts...)...)
+	}
+
+	require.NoError(t, comment(withClientIP("1.2.3.4")))
+	require.NoError(t, comment(withClientIP("1.2.3.4")))
+	err := comment(withClientIP("1.2.3.4"))
+	assert.ErrorContains(t, err, `"code":"RATE_LIMITED"`, "A fresh userId argument does not reset the limit")
+	assert.ErrorContains(t, err, `"retryAfter":30`)
+
+	require.NoError(t, comment(withClientIP("5.6.7.8")), "Other addresses have their own bucket")
+	viewerID := uuid.New()
+	viewer := asViewer(viewerID)
+	self := client.Var("userId", viewerID.String())
+	require.NoError(t, comment(withClientIP("1.2.3.4"), viewer, self), "Authenticated users are limited by user, not address")
+	require.NoError(t, comment(withClientIP("1.2.3.4"), viewer, self))
+	assert.ErrorContains(t, comment(withClientIP("5.6.7.8"), viewer, self), `"code":"RATE_LIMITED"`)
+
+	var posts struct{ Posts []struct{ ID string } }
+	require.NoError(t, c.Post(postsQuery, &posts, withClientIP("1.2.3.4")), "Queries are not limited")
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "B"), client.Var("userId", uuid.NewString()), withClientIP("1.2.3.4")),
+		"Mutations without a rule are not limited")
+}
@@ -0,0 +1,109 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"ozon-test/internal/validation"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// setRelation adds or removes the actor's relation of kind to targetID.
+func (r *Resolver) setRelation(ctx context.Context, targetID string, userID *string, kind models.RelationKind, set bool) (bool, error) {
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	target, err := uuid.Parse(targetID)
+	if err != nil {
+		return false, &validation.Error{Fields: []validation.FieldError{{Field: "targetId", Message: "must be a valid ID"}}}
+	}
+	if target == user {
+		return false, &validation.Error{Fields: []validation.FieldError{{Field: "targetId", Message: "must be another user"}}}
+	}
+
+	if set {
+		err = r.Storage.AddUserRelation(ctx, models.UserRelation{UserID: user, TargetID: target, Kind: kind, CreatedAt: time.Now()})
+	} else {
+		err = r.Storage.RemoveUserRelation(ctx, user, target, kind)
+	}
+	if err != nil {
+		slog.Error("Failed to change user relation", "error", err, "userID", user, "kind", kind, "set", set)
+		return false, err
+	}
+	slog.Info("User relation changed", "userID", user, "targetID", target, "kind", kind, "set", set)
+	return true, nil
+}
+
+// listRelations returns a page of the actor's relations of kind.
+func (r *Resolver) listRelations(ctx context.Context, userID *string, kind models.RelationKind, page, pageSize int) ([]*gqlModel.UserRelation, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	relations, err := r.Storage.ListUserRelations(ctx, user, kind, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list user relations", "error", err, "userID", user, "kind", kind)
+		return nil, err
+	}
+	result := make([]*gqlModel.UserRelation, 0, len(relations))
+	for _, relation := range relations {
+		result = append(result, &gqlModel.UserRelation{
+			UserID:    relation.TargetID.String(),
+			CreatedAt: relation.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
+
+// checkNotBlocked rejects comment on post if the author of the post or of
+// the comment it replies to has blocked its author. Missing parents are
+// left to the storage.
+func (r *Resolver) checkNotBlocked(ctx context.Context, post models.Post, comment models.Comment) error {
+	authors := []uuid.UUID{post.UserID}
+	if comment.ParentID != nil {
+		parent, err := r.Storage.GetCommentByID(ctx, *comment.ParentID)
+		if err == nil {
+			authors = append(authors, parent.UserID)
+		} else if !errors.Is(err, models.ErrCommentNotFound) {
+			return err
+		}
+	}
+
+	for _, author := range authors {
+		if author == comment.UserID {
+			continue
+		}
+		relations, err := r.Storage.GetUserRelations(ctx, author, comment.UserID)
+		if err != nil {
+			return err
+		}
+		if relations.Blocked {
+			slog.Warn("Comment by blocked user rejected", "userID", comment.UserID, "blockedBy", author)
+			return models.ErrBlocked
+		}
+	}
+	return nil
+}
+
+// hidesAuthor reports whether the viewer muted or blocked authorID.
+func (r *Resolver) hidesAuthor(ctx context.Context, authorID uuid.UUID) bool {
+	viewer := viewerID(ctx)
+	if viewer == uuid.Nil || viewer == authorID {
+		return false
+	}
+	relations, err := r.Storage.GetUserRelations(ctx, viewer, authorID)
+	if err != nil {
+		slog.Error("Failed to get user relations", "error", err, "userID", viewer)
+		return false
+	}
+	return relations.Hides()
+}
@@ -0,0 +1,135 @@
+package gql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	muteUserMutation    = `mutation($target: ID!) { muteUser(targetId: $target) }`
+	blockUserMutation   = `mutation($target: ID!) { blockUser(targetId: $target) }`
+	unblockUserMutation = `mutation($target: ID!) { unblockUser(targetId: $target) }`
+	mutedUsersQuery     = `query { mutedUsers(page: 1, pageSize: 10) { userId } }`
+	replyMutation       = `mutation($postId: ID!, $parentId: ID, $userId: ID!) { createComment(postId: $postId, parentId: $parentId, content: "hi", userId: $userId) { id } }`
+)
+
+func TestMuteUser(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	viewerID, mutedID := uuid.New(), uuid.New()
+	viewer := asViewer(viewerID)
+
+	var kept, muted createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &kept, client.Var("title", "Kept"), client.Var("userId", uuid.NewString())))
+	require.NoError(t, c.Post(createPostMutation, &muted, client.Var("title", "Muted"), client.Var("userId", mutedID.String())))
+	var comment createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", kept.CreatePost.ID), client.Var("userId", mutedID.String())))
+
+	var resp map[string]any
+	err := c.Post(muteUserMutation, &resp, client.Var("target", viewerID.String()), viewer)
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`, "Users cannot mute themselves")
+	err = c.Post(muteUserMutation, &resp, client.Var("target", "not-an-id"), viewer)
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+	assert.ErrorContains(t, err, `"field":"targetId"`)
+	require.NoError(t, c.Post(muteUserMutation, &resp, client.Var("target", mutedID.String()), viewer))
+	require.NoError(t, c.Post(muteUserMutation, &resp, client.Var("target", mutedID.String()), viewer), "Muting again is not an error")
+
+	var list struct{ MutedUsers []struct{ UserID string } }
+	require.NoError(t, c.Post(mutedUsersQuery, &list, viewer))
+	require.Len(t, list.MutedUsers, 1)
+	assert.Equal(t, mutedID.String(), list.MutedUsers[0].UserID)
+
+	var posts struct{ Posts []struct{ ID string } }
+	require.NoError(t, c.Post(postsQuery, &posts, viewer))
+	require.Len(t, posts.Posts, 1)
+	assert.Equal(t, kept.CreatePost.ID, posts.Posts[0].ID)
+	require.NoError(t, c.Post(postsQuery, &posts))
+	assert.Len(t, posts.Posts, 2, "Muting only affects the viewer")
+
+	var comments struct {
+		Comments []struct {
+			ID     string
+			Hidden bool
+		}
+	}
+	require.NoError(t, c.Post(commentsQuery, &comments, client.Var("postId", kept.CreatePost.ID), viewer))
+	assert.Empty(t, comments.Comments)
+	require.NoError(t, c.Post(commentsQuery, &comments, client.Var("postId", kept.CreatePost.ID)))
+	assert.Len(t, comments.Comments, 1)
+}
+
+func TestBlockUser(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+	authorID, blockedID := uuid.New(), uuid.New()
+	author := asViewer(authorID)
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "Mine"), client.Var("userId", authorID.String())))
+	postID := post.CreatePost.ID
+	var other createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &other, client.Var("title", "Theirs"), client.Var("userId", uuid.NewString())))
+	var parent createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &parent, client.Var("postId", other.CreatePost.ID), client.Var("userId", authorID.String())))
+
+	var resp map[string]any
+	require.NoError(t, c.Post(blockUserMutation, &resp, client.Var("target", blockedID.String()), author))
+
+	var comment createCommentResponse
+	err := c.Post(replyMutation, &comment, client.Var("postId", postID), client.Var("userId", blockedID.String()))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Blocked users cannot comment on the blocker's posts")
+	err = c.Post(replyMutation, &comment, client.Var("postId", other.CreatePost.ID), client.Var("parentId", parent.CreateComment.ID),
+		client.Var("userId", blockedID.String()))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Blocked users cannot reply to the blocker's comments")
+	require.NoError(t, c.Post(replyMutation, &comment, client.Var("postId", other.CreatePost.ID), client.Var("userId", blockedID.String())),
+		"Blocked users can still comment elsewhere")
+
+	err = c.Post(replyMutation, &comment, client.Var("postId", postID), client.Var("userId", uuid.NewString()), asViewer(blockedID))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`, "Signed-in users cannot comment as someone else")
+	comments, err := storage.GetCommentsByPostID(context.Background(), uuid.MustParse(postID), 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+
+	require.NoError(t, c.Post(unblockUserMutation, &resp, client.Var("target", blockedID.String()), author))
+	require.NoError(t, c.Post(replyMutation, &comment, client.Var("postId", postID), client.Var("userId", blockedID.String())))
+}
+
+func TestCommentAddedSkipsMutedAuthors(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	ps := pubsub.NewInMemoryPubSub()
+	defer ps.Close()
+	resolver := &gql.Resolver{Storage: storage, PubSub: ps}
+	viewerID, mutedID := uuid.New(), uuid.New()
+	require.NoError(t, storage.AddUserRelation(context.Background(),
+		models.UserRelation{UserID: viewerID, TargetID: mutedID, Kind: models.RelationMute, CreatedAt: time.Now()}))
+
+	ctx, cancel := context.WithCancel(auth.WithViewer(context.Background(), auth.Viewer{UserID: viewerID}))
+	defer cancel()
+	post := models.Post{ID: uuid.New(), UserID: uuid.New(), AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, storage.CreatePost(ctx, post))
+	events, err := resolver.Subscription().CommentAdded(ctx, post.ID.String())
+	require.NoError(t, err)
+
+	for _, author := range []uuid.UUID{mutedID, uuid.New()} {
+		comment := models.Comment{ID: uuid.New(), PostID: post.ID, UserID: author, Content: "hi", CreatedAt: time.Now()}
+		require.NoError(t, storage.CreateComment(ctx, comment))
+		require.NoError(t, ps.Publish(ctx, post.ID, comment.ID.String()))
+	}
+	select {
+	case comment := <-events:
+		assert.NotEqual(t, mutedID.String(), comment.UserID, "Comments by muted users are skipped")
+	case <-time.After(time.Second):
+		t.Fatal("commentAdded sent nothing")
+	}
+}
@@ -0,0 +1,48 @@
+package gql
+
+import (
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"ozon-test/internal/render"
+	"strconv"
+)
+
+// uncachedRenderer serves resolvers built without a Renderer.
+var uncachedRenderer = render.NewRenderer(0)
+
+func (r *Resolver) renderHTML(key string, format gqlModel.ContentFormat, content string) string {
+	renderer := r.Renderer
+	if renderer == nil {
+		renderer = uncachedRenderer
+	}
+	return renderer.HTML(key, models.ContentFormat(format), content)
+}
+
+// postRenderKey identifies a post's content at one version. A revision
+// shares the key of the post at that version, since versions never change.
+func postRenderKey(postID string, version int) string {
+	return "post:" + postID + ":" + strconv.Itoa(version)
+}
+
+// commentRenderKey identifies a comment's content; comments are never
+// edited.
+func commentRenderKey(commentID string) string {
+	return "comment:" + commentID
+}
+
+// contentFormat returns the format requested on creation, PLAIN if none.
+func contentFormat(format *gqlModel.ContentFormat) models.ContentFormat {
+	if format != nil && *format == gqlModel.ContentFormatMarkdown {
+		return models.FormatMarkdown
+	}
+	return models.FormatPlain
+}
+
+// toGQLFormat maps a stored format to the schema enum; content stored
+// before formats existed is plain text.
+func toGQLFormat(format models.ContentFormat) gqlModel.ContentFormat {
+	if format == models.FormatMarkdown {
+		return gqlModel.ContentFormatMarkdown
+	}
+	return gqlModel.ContentFormatPlain
+}
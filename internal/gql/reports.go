@@ -0,0 +1,94 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"ozon-test/internal/auth"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"ozon-test/internal/validation"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// MaxReportDetailsLength bounds the explanation a report may carry, in
+// characters.
+const MaxReportDetailsLength = 1000
+
+// reportDetails trims a report's explanation and checks its length.
+func reportDetails(details string) (string, error) {
+	details = strings.TrimSpace(details)
+	if utf8.RuneCountInString(details) > MaxReportDetailsLength {
+		return "", &validation.Error{Fields: []validation.FieldError{
+			{Field: "details", Message: fmt.Sprintf("must be at most %d characters", MaxReportDetailsLength)},
+		}}
+	}
+	return details, nil
+}
+
+// checkReportable rejects reports about content the viewer cannot see,
+// which is reported as missing.
+func (r *Resolver) checkReportable(ctx context.Context, targetType models.ReportTarget, targetID uuid.UUID) error {
+	postID := targetID
+	if targetType == models.ReportComment {
+		comment, err := r.Storage.GetCommentByID(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		if comment.Hidden && !auth.IsModerator(ctx) {
+			return models.ErrCommentNotFound
+		}
+		postID = comment.PostID
+	}
+	_, err := r.visiblePost(ctx, postID)
+	return err
+}
+
+// closeReport records a moderator's decision on an open report.
+func (r *Resolver) closeReport(ctx context.Context, id string, status models.ReportStatus, hideTarget bool) (*gqlModel.Report, error) {
+	viewer, ok := auth.ViewerFromContext(ctx)
+	if !ok || !viewer.Moderator {
+		return nil, errModeratorsOnly
+	}
+	reportID, err := parseID("id", id)
+	if err != nil {
+		return nil, err
+	}
+	report, err := r.Storage.CloseReport(ctx, reportID, models.ReportDecision{
+		Status:      status,
+		ModeratorID: viewer.UserID,
+		At:          time.Now().UTC(),
+		HideTarget:  hideTarget,
+	})
+	if err != nil {
+		slog.Error("Failed to close report", "error", err, "reportID", reportID)
+		return nil, err
+	}
+	slog.Info("Report closed", "reportID", reportID, "status", status, "hidden", hideTarget, "moderatorID", viewer.UserID)
+	return toGQLReport(report), nil
+}
+
+func toGQLReport(report models.Report) *gqlModel.Report {
+	result := &gqlModel.Report{
+		ID:           report.ID.String(),
+		TargetType:   gqlModel.ReportTarget(report.TargetType),
+		TargetID:     report.TargetID.String(),
+		ReporterID:   report.ReporterID.String(),
+		Reason:       gqlModel.ReportReason(report.Reason),
+		Status:       gqlModel.ReportStatus(report.Status),
+		CreatedAt:    report.CreatedAt.Format(time.RFC3339),
+		ClosedAt:     optionalTime(report.ClosedAt),
+		TargetHidden: report.TargetHidden,
+	}
+	if report.Details != "" {
+		result.Details = &report.Details
+	}
+	if report.ClosedBy != nil {
+		result.ClosedBy = optionalID(*report.ClosedBy)
+	}
+	return result
+}
@@ -0,0 +1,182 @@
+package gql_test
+
+import (
+	"testing"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/render"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	reportContentMutation = `mutation($type: ReportTarget!, $id: ID!, $reason: ReportReason!, $details: String) { reportContent(targetType: $type, targetId: $id, reason: $reason, details: $details) { id status details } }`
+	resolveReportMutation = `mutation($id: ID!, $hide: Boolean) { resolveReport(id: $id, hideTarget: $hide) { id status closedBy targetHidden } }`
+	dismissReportMutation = `mutation($id: ID!) { dismissReport(id: $id) { id status targetHidden } }`
+	reportsQuery          = `query($status: ReportStatus) { reports(status: $status, page: 1, pageSize: 10) { id targetId reason status } }`
+	commentsQuery         = `query($postId: ID!) { comments(postId: $postId, page: 1, pageSize: 10) { id hidden } }`
+)
+
+type reportResponse struct {
+	ID           string
+	Status       string
+	Details      *string
+	ClosedBy     *string
+	TargetHidden bool
+}
+
+type reportsResponse struct {
+	Reports []struct {
+		ID       string
+		TargetID string
+		Reason   string
+		Status   string
+	}
+}
+
+func TestReportAndHidePost(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	moderatorID := uuid.New()
+	moderator := withViewer(auth.Viewer{UserID: moderatorID, Moderator: true})
+	reporter := asViewer(uuid.New())
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "Buy now"), client.Var("userId", uuid.NewString())))
+	postID := post.CreatePost.ID
+
+	var report struct{ ReportContent reportResponse }
+	err := c.Post(reportContentMutation, &report, client.Var("type", "POST"), client.Var("id", postID), client.Var("reason", "SPAM"))
+	assert.ErrorContains(t, err, "userId is required", "Anonymous users must say who is reporting")
+	require.NoError(t, c.Post(reportContentMutation, &report, client.Var("type", "POST"), client.Var("id", postID),
+		client.Var("reason", "SPAM"), client.Var("details", " Casino links "), reporter))
+	assert.Equal(t, "OPEN", report.ReportContent.Status)
+	require.NotNil(t, report.ReportContent.Details)
+	assert.Equal(t, "Casino links", *report.ReportContent.Details)
+
+	err = c.Post(reportContentMutation, &report, client.Var("type", "POST"), client.Var("id", postID), client.Var("reason", "ABUSE"), reporter)
+	assert.ErrorContains(t, err, `"code":"CONFLICT"`, "One open report per reporter and target")
+	err = c.Post(reportContentMutation, &report, client.Var("type", "POST"), client.Var("id", uuid.NewString()), client.Var("reason", "SPAM"), reporter)
+	assert.ErrorContains(t, err, "post not found")
+	err = c.Post(reportContentMutation, &report, client.Var("type", "POST"), client.Var("id", "spam"), client.Var("reason", "SPAM"), reporter)
+	assert.ErrorContains(t, err, `"field":"targetId"`)
+
+	var queue reportsResponse
+	assert.ErrorContains(t, c.Post(reportsQuery, &queue, reporter), `"code":"FORBIDDEN"`)
+	err = c.Post(`query { reports(targetId: "spam", page: 1, pageSize: 10) { id } }`, &queue, moderator)
+	assert.ErrorContains(t, err, `"field":"targetId"`)
+	require.NoError(t, c.Post(reportsQuery, &queue, moderator))
+	require.Len(t, queue.Reports, 1)
+	assert.Equal(t, postID, queue.Reports[0].TargetID)
+
+	var resolved struct{ ResolveReport reportResponse }
+	assert.ErrorContains(t, c.Post(resolveReportMutation, &resolved, client.Var("id", queue.Reports[0].ID), client.Var("hide", true), reporter), `"code":"FORBIDDEN"`)
+	err = c.Post(resolveReportMutation, &resolved, client.Var("id", "first"), moderator)
+	assert.ErrorContains(t, err, `"field":"id"`)
+	require.NoError(t, c.Post(resolveReportMutation, &resolved, client.Var("id", queue.Reports[0].ID), client.Var("hide", true), moderator))
+	assert.Equal(t, "RESOLVED", resolved.ResolveReport.Status)
+	assert.True(t, resolved.ResolveReport.TargetHidden)
+	require.NotNil(t, resolved.ResolveReport.ClosedBy)
+	assert.Equal(t, moderatorID.String(), *resolved.ResolveReport.ClosedBy)
+	err = c.Post(resolveReportMutation, &resolved, client.Var("id", queue.Reports[0].ID), moderator)
+	assert.ErrorContains(t, err, `"code":"CONFLICT"`, "Closed reports cannot be decided again")
+
+	require.NoError(t, c.Post(reportsQuery, &queue, moderator))
+	assert.Empty(t, queue.Reports, "The queue only lists open reports by default")
+
+	var posts struct{ Posts []struct{ ID string } }
+	require.NoError(t, c.Post(postsQuery, &posts))
+	assert.Empty(t, posts.Posts, "Hidden posts are not listed")
+	var got struct{ Post *struct{ ID string } }
+	assert.ErrorContains(t, c.Post(postQuery, &got, client.Var("id", postID)), "post not found")
+	require.NoError(t, c.Post(postsQuery, &posts, moderator))
+	assert.Len(t, posts.Posts, 1, "Moderators still see hidden posts")
+}
+
+func TestReportAndHideComment(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	moderator := withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true})
+	user := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", user)))
+	var kept, reported createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &kept, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+	require.NoError(t, c.Post(createCommentMutation, &reported, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+
+	var report struct{ ReportContent reportResponse }
+	require.NoError(t, c.Post(reportContentMutation, &report, client.Var("type", "COMMENT"), client.Var("id", reported.CreateComment.ID),
+		client.Var("reason", "OFF_TOPIC"), asViewer(uuid.New())))
+	var dismissed struct{ DismissReport reportResponse }
+	require.NoError(t, c.Post(dismissReportMutation, &dismissed, client.Var("id", report.ReportContent.ID), moderator))
+	assert.Equal(t, "DISMISSED", dismissed.DismissReport.Status)
+	assert.False(t, dismissed.DismissReport.TargetHidden)
+
+	require.NoError(t, c.Post(reportContentMutation, &report, client.Var("type", "COMMENT"), client.Var("id", reported.CreateComment.ID),
+		client.Var("reason", "ABUSE"), asViewer(uuid.New())))
+	var resolved struct{ ResolveReport reportResponse }
+	require.NoError(t, c.Post(resolveReportMutation, &resolved, client.Var("id", report.ReportContent.ID), client.Var("hide", true), moderator))
+
+	var comments struct {
+		Comments []struct {
+			ID     string
+			Hidden bool
+		}
+	}
+	require.NoError(t, c.Post(commentsQuery, &comments, client.Var("postId", post.CreatePost.ID)))
+	require.Len(t, comments.Comments, 1, "Hidden comments are not listed")
+	assert.Equal(t, kept.CreateComment.ID, comments.Comments[0].ID)
+	require.NoError(t, c.Post(commentsQuery, &comments, client.Var("postId", post.CreatePost.ID), moderator))
+	require.Len(t, comments.Comments, 2)
+	assert.True(t, comments.Comments[1].Hidden)
+
+	err := c.Post(reportContentMutation, &report, client.Var("type", "COMMENT"), client.Var("id", reported.CreateComment.ID),
+		client.Var("reason", "ABUSE"), asViewer(uuid.New()))
+	assert.ErrorContains(t, err, "comment not found", "Hidden comments cannot be reported again")
+}
+
+func TestHiddenCommentInThread(t *testing.T) {
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub(), Renderer: render.NewRenderer(100)}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+	moderator := withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true})
+	user := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", user)))
+	var comment createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+	var report struct{ ReportContent reportResponse }
+	require.NoError(t, c.Post(reportContentMutation, &report, client.Var("type", "COMMENT"), client.Var("id", comment.CreateComment.ID),
+		client.Var("reason", "ABUSE"), asViewer(uuid.New())))
+	var resolved struct{ ResolveReport reportResponse }
+	require.NoError(t, c.Post(resolveReportMutation, &resolved, client.Var("id", report.ReportContent.ID), client.Var("hide", true), moderator))
+
+	const threadQuery = `query($postId: ID!) { thread(postId: $postId, maxDepth: 1, page: 1, pageSize: 10) { comment { id content contentHtml hidden } } }`
+	var thread struct {
+		Thread []struct {
+			Comment struct {
+				ID          string
+				Content     string
+				ContentHTML string
+				Hidden      bool
+			}
+		}
+	}
+	require.NoError(t, c.Post(threadQuery, &thread, client.Var("postId", post.CreatePost.ID), moderator))
+	require.Len(t, thread.Thread, 1)
+	assert.Equal(t, "<p>hi</p>\n", thread.Thread[0].Comment.ContentHTML, "Moderators see hidden comments")
+
+	require.NoError(t, c.Post(threadQuery, &thread, client.Var("postId", post.CreatePost.ID), asViewer(uuid.New())))
+	require.Len(t, thread.Thread, 1)
+	assert.True(t, thread.Thread[0].Comment.Hidden)
+	assert.Empty(t, thread.Thread[0].Comment.Content)
+	assert.Empty(t, thread.Thread[0].Comment.ContentHTML, "Rendered HTML is not served from the cache to other viewers")
+}
@@ -1,11 +1,198 @@
 package gql
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon-test/internal/auth"
+	"ozon-test/internal/contentfilter"
+	gqlModel "ozon-test/internal/gql/model"
 	"ozon-test/internal/models"
+	"ozon-test/internal/outbox"
 	"ozon-test/internal/pubsub"
+	"ozon-test/internal/render"
+	"ozon-test/internal/validation"
+	"ozon-test/internal/webhook"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 type Resolver struct {
 	Storage models.Storage
 	PubSub  pubsub.PubSub
+	// PostPubSub carries postAdded events; nil disables that subscription.
+	PostPubSub pubsub.PubSub
+	// NotificationPubSub carries notificationAdded events, keyed by
+	// recipient; nil disables that subscription.
+	NotificationPubSub pubsub.PubSub
+	// MaxPageSize caps pageSize in list queries; zero means unlimited.
+	MaxPageSize int
+	// ContentLimits bounds the length of titles, posts and comments.
+	ContentLimits validation.Limits
+	// Renderer turns content into HTML; nil renders without a cache.
+	Renderer *render.Renderer
+	// IdempotencyTTL is how long idempotency keys are remembered; zero
+	// means DefaultIdempotencyTTL.
+	IdempotencyTTL time.Duration
+	// Webhooks delivers content events; nil disables webhooks.
+	Webhooks *webhook.Dispatcher
+	// Outbox, if set, relays commentAdded events that are stored with
+	// their comment instead of being published directly through PubSub.
+	Outbox *outbox.Relay
+	// ContentFilter screens new and edited posts and comments; nil lets
+	// everything through.
+	ContentFilter *contentfilter.Pipeline
+}
+
+// updateAttempts bounds how often updatePost retries after losing a race
+// with another update.
+const updateAttempts = 3
+
+// checkPageSize rejects page sizes above the configured limit.
+func (r *Resolver) checkPageSize(pageSize int) error {
+	if r.MaxPageSize > 0 && pageSize > r.MaxPageSize {
+		return fmt.Errorf("pageSize must not exceed %d", r.MaxPageSize)
+	}
+	return nil
+}
+
+// parseID parses the ID passed in the argument named field.
+func parseID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &validation.Error{Fields: []validation.FieldError{{Field: field, Message: "must be a valid ID"}}}
+	}
+	return id, nil
+}
+
+// editorID identifies who is editing a post: the authenticated viewer, else
+// the userId argument, else nobody (uuid.Nil).
+func editorID(ctx context.Context, userID *string) (uuid.UUID, error) {
+	if viewer, ok := auth.ViewerFromContext(ctx); ok {
+		return viewer.UserID, nil
+	}
+	if userID != nil {
+		return parseID("userId", *userID)
+	}
+	return uuid.Nil, nil
+}
+
+// authorID identifies the author of new content: the userId argument, which
+// on authenticated requests must be the viewer's own ID.
+func authorID(ctx context.Context, userID string) (uuid.UUID, error) {
+	id, err := parseID("userId", userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if viewer, ok := auth.ViewerFromContext(ctx); ok && viewer.UserID != id {
+		slog.Warn("Rejected content on behalf of another user", "viewerID", viewer.UserID, "userID", id)
+		return uuid.Nil, errNotViewer
+	}
+	return id, nil
+}
+
+// editPost applies change to the current version of a post the viewer may
+// see and stores the result as a new version by editor. With expectedVersion
+// set, a post that has moved on fails with models.ErrPostVersionConflict;
+// without it, the client only cares about the fields change sets, so losing
+// a race with another update is handled by re-reading and retrying.
+func (r *Resolver) editPost(ctx context.Context, postID uuid.UUID, expectedVersion *int, editor uuid.UUID, change func(*models.Post)) (models.Post, error) {
+	for attempt := 1; ; attempt++ {
+		post, err := r.visiblePost(ctx, postID)
+		if err != nil {
+			slog.Error("Failed to get post by ID", "error", err, "postID", postID)
+			return models.Post{}, err
+		}
+		if expectedVersion != nil && post.Version != *expectedVersion {
+			slog.Warn("Stale post update", "postID", postID, "expected", *expectedVersion, "actual", post.Version)
+			return models.Post{}, models.ErrPostVersionConflict
+		}
+
+		change(&post)
+		post.UpdatedAt = time.Now()
+		post.UpdatedBy = editor
+
+		err = r.Storage.UpdatePost(ctx, post)
+		if errors.Is(err, models.ErrPostVersionConflict) && expectedVersion == nil && attempt < updateAttempts {
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to update post", "error", err, "postID", postID)
+			return models.Post{}, err
+		}
+
+		post.Version++
+		slog.Info("Post updated", "postID", postID, "version", post.Version)
+		return post, nil
+	}
+}
+
+func optionalID(id uuid.UUID) *string {
+	if id == uuid.Nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func optionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+func toGQLPost(post models.Post) *gqlModel.Post {
+	return &gqlModel.Post{
+		ID:            post.ID.String(),
+		Title:         post.Title,
+		Content:       post.Content,
+		UserID:        post.UserID.String(),
+		AllowComments: post.AllowComments,
+		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
+		Version:       post.Version,
+		UpdatedAt:     post.UpdatedAt.Format(time.RFC3339),
+		UpdatedBy:     optionalID(post.UpdatedBy),
+		Status:        gqlModel.PostStatus(post.Status),
+		PublishAt:     optionalTime(post.PublishAt),
+		Format:        toGQLFormat(post.Format),
+		Hidden:        post.Hidden,
+		CommunityID:   optionalCommunityID(post),
+	}
+}
+
+// toGQLPostRevision converts a revision of a post written in format.
+func toGQLPostRevision(revision models.PostRevision, format models.ContentFormat) *gqlModel.PostRevision {
+	return &gqlModel.PostRevision{
+		PostID:        revision.PostID.String(),
+		Version:       revision.Version,
+		Title:         revision.Title,
+		Content:       revision.Content,
+		AllowComments: revision.AllowComments,
+		EditorID:      optionalID(revision.EditorID),
+		CreatedAt:     revision.CreatedAt.Format(time.RFC3339),
+		Format:        toGQLFormat(format),
+	}
+}
+
+func toGQLComment(comment models.Comment) *gqlModel.Comment {
+	var parentID *string
+	if comment.ParentID != nil {
+		id := comment.ParentID.String()
+		parentID = &id
+	}
+	return &gqlModel.Comment{
+		ID:        comment.ID.String(),
+		PostID:    comment.PostID.String(),
+		ParentID:  parentID,
+		Content:   comment.Content,
+		UserID:    comment.UserID.String(),
+		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+		Locked:    comment.Locked,
+		Format:    toGQLFormat(comment.Format),
+		Hidden:    comment.Hidden,
+	}
 }
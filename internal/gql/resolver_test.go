@@ -0,0 +1,321 @@
+package gql_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(storage *inmemory.InMemoryStorage, ps pubsub.PubSub) *client.Client {
+	resolver := &gql.Resolver{Storage: storage, PubSub: ps, IdempotencyTTL: time.Hour}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	return client.New(srv)
+}
+
+const updatePostMutation = `mutation($id: ID!, $title: String, $expectedVersion: Int) { updatePost(id: $id, title: $title, expectedVersion: $expectedVersion) { title version } }`
+
+type updatePostResponse struct {
+	UpdatePost struct {
+		Title   string
+		Version int
+	}
+}
+
+func TestUpdatePostExpectedVersion(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", uuid.NewString())))
+	id := post.CreatePost.ID
+
+	var updated updatePostResponse
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "B"), client.Var("expectedVersion", 1)))
+	assert.Equal(t, "B", updated.UpdatePost.Title)
+	assert.Equal(t, 2, updated.UpdatePost.Version)
+
+	var stale updatePostResponse
+	err := c.Post(updatePostMutation, &stale, client.Var("id", id), client.Var("title", "C"), client.Var("expectedVersion", 1))
+	assert.ErrorContains(t, err, `"code":"CONFLICT"`)
+
+	got, err := storage.GetPostByID(context.Background(), uuid.MustParse(id))
+	require.NoError(t, err)
+	assert.Equal(t, "B", got.Title, "A stale update must not be applied")
+}
+
+func TestConcurrentUpdatesWithoutExpectedVersion(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", uuid.NewString())))
+
+	const updates = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res updatePostResponse
+			err := c.Post(updatePostMutation, &res, client.Var("id", post.CreatePost.ID), client.Var("title", "B"))
+			if err != nil {
+				// Only a client that keeps losing the race may fail.
+				assert.ErrorContains(t, err, `"code":"CONFLICT"`)
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	got, err := storage.GetPostByID(context.Background(), uuid.MustParse(post.CreatePost.ID))
+	require.NoError(t, err)
+	assert.NotZero(t, succeeded)
+	assert.Equal(t, 1+succeeded, got.Version, "Every successful update should bump the version exactly once")
+}
+
+func TestRevertPost(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+	author := uuid.NewString()
+	editor := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", author)))
+	id := post.CreatePost.ID
+
+	var updated updatePostResponse
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "B")))
+
+	var revision struct {
+		PostRevision struct {
+			Title    string
+			EditorID *string
+		}
+	}
+	require.NoError(t, c.Post(`query($id: ID!) { postRevision(id: $id, version: 1) { title editorId } }`, &revision, client.Var("id", id)))
+	assert.Equal(t, "A", revision.PostRevision.Title)
+	require.NotNil(t, revision.PostRevision.EditorID)
+	assert.Equal(t, author, *revision.PostRevision.EditorID)
+
+	var reverted struct {
+		RevertPost struct {
+			Title     string
+			Version   int
+			UpdatedBy string
+			Revisions []struct {
+				Version  int
+				Title    string
+				EditorID *string
+			}
+		}
+	}
+	require.NoError(t, c.Post(`mutation($id: ID!, $userId: ID) { revertPost(id: $id, version: 1, expectedVersion: 2, userId: $userId) { title version updatedBy revisions { version title editorId } } }`,
+		&reverted, client.Var("id", id), client.Var("userId", editor)))
+	assert.Equal(t, "A", reverted.RevertPost.Title)
+	assert.Equal(t, 3, reverted.RevertPost.Version, "A revert is a new version")
+	assert.Equal(t, editor, reverted.RevertPost.UpdatedBy)
+
+	history := reverted.RevertPost.Revisions
+	require.Len(t, history, 3)
+	assert.Equal(t, []string{"A", "B", "A"}, []string{history[0].Title, history[1].Title, history[2].Title}, "Newest first")
+	assert.Nil(t, history[1].EditorID, "The editor of an anonymous update is unknown")
+
+	err := c.Post(`mutation($id: ID!) { revertPost(id: $id, version: 9) { id } }`, &reverted, client.Var("id", id))
+	assert.ErrorContains(t, err, "revision not found")
+}
+
+func TestLockThread(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+	user := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", user)))
+	var root createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &root, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+
+	const lockThread = `mutation($id: ID!) { lockThread(commentId: $id) { id locked } }`
+	var locked struct {
+		LockThread struct {
+			ID     string
+			Locked bool
+		}
+	}
+	err := c.Post(lockThread, &locked, client.Var("id", root.CreateComment.ID), asViewer(uuid.New()))
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+
+	require.NoError(t, c.Post(lockThread, &locked, client.Var("id", root.CreateComment.ID), withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true})))
+	assert.True(t, locked.LockThread.Locked)
+	err = c.Post(lockThread, &locked, client.Var("id", "root"), withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true}))
+	assert.ErrorContains(t, err, `"field":"commentId"`)
+
+	const reply = `mutation($postId: ID!, $parentId: ID, $userId: ID!) { createComment(postId: $postId, parentId: $parentId, content: "re", userId: $userId) { id } }`
+	var res createCommentResponse
+	err = c.Post(reply, &res, client.Var("postId", post.CreatePost.ID), client.Var("parentId", root.CreateComment.ID), client.Var("userId", user))
+	assert.ErrorContains(t, err, "comment thread is locked")
+	require.NoError(t, c.Post(reply, &res, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)), "The rest of the post stays open")
+}
+
+func TestCommentAdded(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	ps := pubsub.NewInMemoryPubSub()
+	defer ps.Close()
+	resolver := &gql.Resolver{Storage: storage, PubSub: ps}
+	ctx, cancel := context.WithCancel(auth.WithViewer(context.Background(), auth.Viewer{UserID: uuid.New()}))
+	defer cancel()
+
+	_, err := resolver.Subscription().CommentAdded(ctx, "post")
+	assert.ErrorContains(t, err, "postId: must be a valid ID")
+	draft := models.Post{ID: uuid.New(), UserID: uuid.New(), AllowComments: true, CreatedAt: time.Now(), Status: models.PostDraft}
+	require.NoError(t, storage.CreatePost(ctx, draft))
+	_, err = resolver.Subscription().CommentAdded(ctx, draft.ID.String())
+	assert.ErrorIs(t, err, models.ErrPostNotFound, "Only posts the viewer may see can be followed")
+
+	post := models.Post{ID: uuid.New(), UserID: uuid.New(), AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, storage.CreatePost(ctx, post))
+	events, err := resolver.Subscription().CommentAdded(ctx, post.ID.String())
+	require.NoError(t, err)
+
+	comment := models.Comment{ID: uuid.New(), PostID: post.ID, UserID: uuid.New(), Content: "hi", CreatedAt: time.Now()}
+	require.NoError(t, storage.CreateComment(ctx, comment))
+	require.NoError(t, storage.LockThread(ctx, comment.ID))
+	require.NoError(t, ps.Publish(ctx, post.ID, comment.ID.String()))
+	select {
+	case added := <-events:
+		assert.Equal(t, comment.ID.String(), added.ID)
+		assert.True(t, added.Locked, "Comments are sent as stored")
+	case <-time.After(time.Second):
+		t.Fatal("commentAdded sent nothing")
+	}
+}
+
+func TestThreadDepthLimit(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	storage.SetCommentDepthLimit(models.DepthLimit{MaxDepth: 1, Policy: models.DepthReparent})
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+	user := uuid.NewString()
+
+	var post createPostResponse
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", "A"), client.Var("userId", user)))
+	var root createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &root, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+
+	const reply = `mutation($postId: ID!, $parentId: ID, $userId: ID!) { createComment(postId: $postId, parentId: $parentId, content: "re", userId: $userId) { id parentId } }`
+	var res struct {
+		CreateComment struct {
+			ID       string
+			ParentID string
+		}
+	}
+	require.NoError(t, c.Post(reply, &res, client.Var("postId", post.CreatePost.ID), client.Var("parentId", root.CreateComment.ID), client.Var("userId", user)))
+	first := res.CreateComment.ID
+	require.NoError(t, c.Post(reply, &res, client.Var("postId", post.CreatePost.ID), client.Var("parentId", first), client.Var("userId", user)))
+	assert.Equal(t, root.CreateComment.ID, res.CreateComment.ParentID, "The response should show where the reply was attached")
+
+	var thread struct {
+		Thread []struct {
+			Comment     struct{ ID string }
+			Depth       int
+			MoreReplies int
+		}
+	}
+	require.NoError(t, c.Post(`query($postId: ID!) { thread(postId: $postId, maxDepth: 0, page: 1, pageSize: 10) { comment { id } depth moreReplies } }`,
+		&thread, client.Var("postId", post.CreatePost.ID)))
+	require.Len(t, thread.Thread, 1)
+	assert.Equal(t, root.CreateComment.ID, thread.Thread[0].Comment.ID)
+	assert.Equal(t, 2, thread.Thread[0].MoreReplies)
+}
+
+func TestValidationErrors(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	c := newClient(storage, pubsub.NewInMemoryPubSub())
+
+	var post createPostResponse
+	err := c.Post(`mutation { createPost(title: "  ", content: "", userId: "`+uuid.NewString()+`") { id } }`, &post)
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+	assert.ErrorContains(t, err, `"fields":[{"field":"title","message":"must not be empty"},{"field":"content","message":"must not be empty"}]`)
+
+	require.NoError(t, c.Post(createPostMutation, &post, client.Var("title", " Padded\u0000 "), client.Var("userId", uuid.NewString())))
+	got, err := storage.GetPostByID(context.Background(), uuid.MustParse(post.CreatePost.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Padded", got.Title, "Titles are stored normalised")
+
+	var updated updatePostResponse
+	err = c.Post(updatePostMutation, &updated, client.Var("id", post.CreatePost.ID), client.Var("title", "\n"))
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+
+	var comment createCommentResponse
+	err = c.Post(`mutation($postId: ID!) { createComment(postId: $postId, content: " ", userId: "`+uuid.NewString()+`") { id } }`, &comment, client.Var("postId", post.CreatePost.ID))
+	assert.ErrorContains(t, err, `"field":"content"`)
+}
+
+func TestContentHTML(t *testing.T) {
+	c := newClient(inmemory.NewInMemoryStorage(), pubsub.NewInMemoryPubSub())
+	user := uuid.NewString()
+
+	const createPost = `mutation($userId: ID!, $content: String!, $format: ContentFormat) {
+		createPost(title: "T", content: $content, userId: $userId, format: $format) { id format contentHtml } }`
+	var post struct {
+		CreatePost struct {
+			ID          string
+			Format      string
+			ContentHTML string
+		}
+	}
+	require.NoError(t, c.Post(createPost, &post, client.Var("userId", user), client.Var("content", "**hi** <script>x</script>"),
+		client.Var("format", "MARKDOWN")))
+	assert.Equal(t, "MARKDOWN", post.CreatePost.Format)
+	assert.Equal(t, "<p><strong>hi</strong> x</p>\n", post.CreatePost.ContentHTML, "Raw HTML tags are dropped")
+
+	var plain struct {
+		CreatePost struct {
+			ID          string
+			Format      string
+			ContentHTML string
+		}
+	}
+	require.NoError(t, c.Post(createPost, &plain, client.Var("userId", user), client.Var("content", "**hi** <b>")))
+	assert.Equal(t, "PLAIN", plain.CreatePost.Format, "Content is plain text by default")
+	assert.Equal(t, "<p>**hi** &lt;b&gt;</p>\n", plain.CreatePost.ContentHTML)
+
+	const createComment = `mutation($postId: ID!, $userId: ID!) {
+		createComment(postId: $postId, content: "_reply_", userId: $userId, format: MARKDOWN) { contentHtml } }`
+	var comment struct{ CreateComment struct{ ContentHTML string } }
+	require.NoError(t, c.Post(createComment, &comment, client.Var("postId", post.CreatePost.ID), client.Var("userId", user)))
+	assert.Equal(t, "<p><em>reply</em></p>\n", comment.CreateComment.ContentHTML)
+
+	const revisions = `query($id: ID!) { post(id: $id) { revisions { format contentHtml } } }`
+	var res struct {
+		Post struct {
+			Revisions []struct {
+				Format      string
+				ContentHTML string
+			}
+		}
+	}
+	require.NoError(t, c.Post(revisions, &res, client.Var("id", post.CreatePost.ID)))
+	require.Len(t, res.Post.Revisions, 1)
+	assert.Equal(t, "MARKDOWN", res.Post.Revisions[0].Format)
+	assert.Equal(t, post.CreatePost.ContentHTML, res.Post.Revisions[0].ContentHTML)
+}
@@ -6,51 +6,155 @@ package gql
 
 import (
 	"context"
+	"errors"
+	"ozon-test/internal/auth"
+	"ozon-test/internal/contentfilter"
 	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/mention"
 	"ozon-test/internal/models"
+	"ozon-test/internal/validation"
+	"ozon-test/internal/webhook"
 	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/exp/slog"
 )
 
+// ContentHTML is the resolver for the contentHtml field.
+func (r *commentResolver) ContentHTML(ctx context.Context, obj *gqlModel.Comment) (string, error) {
+	if obj.Hidden && !auth.IsModerator(ctx) {
+		// The render cache still holds what the comment said.
+		return "", nil
+	}
+	return r.renderHTML(commentRenderKey(obj.ID), obj.Format, obj.Content), nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *communityResolver) Posts(ctx context.Context, obj *gqlModel.Community, page int, pageSize int) ([]*gqlModel.Post, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	communityID := uuid.MustParse(obj.ID)
+	posts, err := r.Storage.ListCommunityPosts(ctx, communityID, viewerID(ctx), auth.IsModerator(ctx), page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list community posts", "error", err, "communityID", communityID)
+		return nil, err
+	}
+
+	result := make([]*gqlModel.Post, 0, len(posts))
+	for _, post := range posts {
+		result = append(result, toGQLPost(post))
+	}
+	return result, nil
+}
+
 // CreatePost is the resolver for the createPost field.
-func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, userID string) (*gqlModel.Post, error) {
+func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, userID string, idempotencyKey *string, status *gqlModel.PostStatus, publishAt *string, format *gqlModel.ContentFormat, communityID *string) (*gqlModel.Post, error) {
+	cleanTitle, cleanContent, err := r.ContentLimits.Post(title, content)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		published := gqlModel.PostStatusPublished
+		status = &published
+	}
+	postStatus, publishTime, err := publishing(*status, publishAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	author, err := authorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	post := models.Post{
 		ID:            uuid.New(),
-		Title:         title,
-		Content:       content,
-		UserID:        uuid.MustParse(userID),
+		Title:         cleanTitle,
+		Content:       cleanContent,
+		UserID:        author,
 		AllowComments: true,
 		CreatedAt:     time.Now(),
+		Version:       1,
+		Status:        postStatus,
+		PublishAt:     publishTime,
+		Format:        contentFormat(format),
+	}
+	post.UpdatedAt = post.CreatedAt
+	post.UpdatedBy = post.UserID
+	if communityID != nil {
+		id, err := parseCommunityID(*communityID)
+		if err != nil {
+			return nil, err
+		}
+		community, err := r.Storage.GetCommunity(ctx, id)
+		if err != nil {
+			slog.Error("Failed to get community", "error", err, "communityID", *communityID)
+			return nil, err
+		}
+		post.CommunityID = &community.ID
+	}
+
+	owner := idempotencyOwner(ctx, post.UserID)
+	if idempotencyKey != nil {
+		existingID, err := r.reserveIdempotencyKey(ctx, *idempotencyKey, "createPost", owner, post.ID, title, content, userID, *status, publishAt, post.Format, communityID)
+		if err != nil {
+			return nil, err
+		}
+		if existingID != uuid.Nil {
+			existing, err := r.Storage.GetPostByID(ctx, existingID)
+			if errors.Is(err, models.ErrPostNotFound) {
+				return nil, models.ErrIdempotencyKeyInProgress
+			}
+			if err != nil {
+				return nil, err
+			}
+			return toGQLPost(existing), nil
+		}
 	}
 
-	err := r.Storage.CreatePost(ctx, post)
+	screened := contentfilter.Content{AuthorID: post.UserID, Title: post.Title, Text: post.Content}
+	flags, err := r.screen(ctx, &screened)
+	if err == nil {
+		post.Title, post.Content = screened.Title, screened.Text
+		err = r.Storage.CreatePost(ctx, post)
+	}
 	if err != nil {
 		slog.Error("Failed to create post", "error", err)
+		if idempotencyKey != nil {
+			r.releaseIdempotencyKey(ctx, *idempotencyKey, owner, post.ID)
+		}
 		return nil, err
 	}
+	r.flag(ctx, models.ReportPost, post.ID, flags)
 
-	slog.Info("Post created", "postID", post.ID)
+	slog.Info("Post created", "postID", post.ID, "status", post.Status)
+	if post.Status == models.PostPublished {
+		r.postPublished(ctx, post)
+	}
+	r.dispatchPostWebhooks(ctx, models.WebhookPostCreated, post)
 
-	return &gqlModel.Post{
-		ID:            post.ID.String(),
-		Title:         post.Title,
-		Content:       post.Content,
-		UserID:        post.UserID.String(),
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return toGQLPost(post), nil
 }
 
 // CreateComment is the resolver for the createComment field.
-func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, content string, userID string) (*gqlModel.Comment, error) {
+func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, content string, userID string, idempotencyKey *string, format *gqlModel.ContentFormat) (*gqlModel.Comment, error) {
+	cleanContent, err := r.ContentLimits.Comment(content)
+	if err != nil {
+		return nil, err
+	}
+	author, err := authorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	comment := models.Comment{
 		ID:        uuid.New(),
 		PostID:    uuid.MustParse(postID),
-		Content:   content,
-		UserID:    uuid.MustParse(userID),
+		Content:   cleanContent,
+		UserID:    author,
 		CreatedAt: time.Now(),
+		Format:    contentFormat(format),
 	}
 
 	if parentID != nil {
@@ -58,86 +162,492 @@ func (r *mutationResolver) CreateComment(ctx context.Context, postID string, par
 		comment.ParentID = &parsedParentID
 	}
 
-	err := r.Storage.CreateComment(ctx, comment)
+	post, err := r.visiblePost(ctx, comment.PostID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkNotBlocked(ctx, post, comment); err != nil {
+		return nil, err
+	}
+	if err := r.checkCommentPolicy(ctx, post, comment); err != nil {
+		return nil, err
+	}
+
+	owner := idempotencyOwner(ctx, comment.UserID)
+	if idempotencyKey != nil {
+		existingID, err := r.reserveIdempotencyKey(ctx, *idempotencyKey, "createComment", owner, comment.ID, postID, parentID, content, userID, comment.Format)
+		if err != nil {
+			return nil, err
+		}
+		if existingID != uuid.Nil {
+			// The original request already notified subscribers.
+			existing, err := r.Storage.GetCommentByID(ctx, existingID)
+			if errors.Is(err, models.ErrCommentNotFound) {
+				return nil, models.ErrIdempotencyKeyInProgress
+			}
+			if err != nil {
+				return nil, err
+			}
+			return toGQLComment(existing), nil
+		}
+	}
+
+	screened := contentfilter.Content{AuthorID: comment.UserID, Comment: true, Text: comment.Content}
+	flags, err := r.screen(ctx, &screened)
+	if err == nil {
+		comment.Content = screened.Text
+		err = r.storeComment(ctx, comment)
+	}
 	if err != nil {
 		slog.Error("Failed to create comment", "error", err)
+		if idempotencyKey != nil {
+			r.releaseIdempotencyKey(ctx, *idempotencyKey, owner, comment.ID)
+		}
 		return nil, err
 	}
+	r.flag(ctx, models.ReportComment, comment.ID, flags)
+	if comment.ParentID != nil {
+		// The depth limit may have attached the reply higher up the thread.
+		if stored, err := r.Storage.GetCommentByID(ctx, comment.ID); err == nil {
+			comment = stored
+		}
+	}
 
-	// Publish the new comment to subscribers
-	r.PubSub.Publish(ctx, uuid.MustParse(postID), comment.ID.String())
+	r.announceComment(ctx, comment)
+	r.commentCreated(ctx, post, comment)
+	if post.Status == models.PostPublished {
+		r.dispatchWebhooks(ctx, models.WebhookCommentCreated, comment)
+	}
 
 	slog.Info("Comment created", "commentID", comment.ID)
 
-	return &gqlModel.Comment{
-		ID:        comment.ID.String(),
-		PostID:    comment.PostID.String(),
-		ParentID:  parentID,
-		Content:   comment.Content,
-		UserID:    comment.UserID.String(),
-		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return toGQLComment(comment), nil
 }
 
 // UpdatePost is the resolver for the updatePost field.
-func (r *mutationResolver) UpdatePost(ctx context.Context, id string, title *string, content *string, allowComments *bool) (*gqlModel.Post, error) {
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, title *string, content *string, allowComments *bool, expectedVersion *int, userID *string) (*gqlModel.Post, error) {
+	if err := r.ContentLimits.PostUpdate(title, content); err != nil {
+		return nil, err
+	}
+	editor, err := editorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var flags []contentfilter.Match
+	if title != nil || content != nil {
+		screened := contentfilter.Content{AuthorID: editor, Edit: true}
+		if title != nil {
+			screened.Title = *title
+		}
+		if content != nil {
+			screened.Text = *content
+		}
+		if flags, err = r.screen(ctx, &screened); err != nil {
+			return nil, err
+		}
+		if title != nil {
+			title = &screened.Title
+		}
+		if content != nil {
+			content = &screened.Text
+		}
+	}
+	post, err := r.editPost(ctx, uuid.MustParse(id), expectedVersion, editor, func(post *models.Post) {
+		if title != nil {
+			post.Title = *title
+		}
+		if content != nil {
+			post.Content = *content
+		}
+		if allowComments != nil {
+			post.AllowComments = *allowComments
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.flag(ctx, models.ReportPost, post.ID, flags)
+	r.dispatchPostWebhooks(ctx, models.WebhookPostUpdated, post)
+	return toGQLPost(post), nil
+}
+
+// SetPostStatus is the resolver for the setPostStatus field.
+func (r *mutationResolver) SetPostStatus(ctx context.Context, id string, status gqlModel.PostStatus, publishAt *string) (*gqlModel.Post, error) {
 	postID := uuid.MustParse(id)
+	if _, err := r.visiblePost(ctx, postID); err != nil {
+		return nil, err
+	}
+	postStatus, publishTime, err := publishing(status, publishAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.Storage.SetPostStatus(ctx, postID, postStatus, publishTime)
+	if err != nil {
+		slog.Error("Failed to set post status", "error", err, "postID", postID)
+		return nil, err
+	}
 	post, err := r.Storage.GetPostByID(ctx, postID)
 	if err != nil {
-		slog.Error("Failed to get post by ID", "error", err, "postID", postID)
 		return nil, err
 	}
 
+	slog.Info("Post status changed", "postID", postID, "status", post.Status)
+	if post.Status == models.PostPublished {
+		r.postPublished(ctx, post)
+	}
+	r.dispatchPostWebhooks(ctx, models.WebhookPostUpdated, post)
+	return toGQLPost(post), nil
+}
+
+// LockThread is the resolver for the lockThread field.
+func (r *mutationResolver) LockThread(ctx context.Context, commentID string) (*gqlModel.Comment, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, errModeratorsOnly
+	}
+
+	id, err := parseID("commentId", commentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Storage.LockThread(ctx, id); err != nil {
+		slog.Error("Failed to lock thread", "error", err, "commentID", id)
+		return nil, err
+	}
+	comment, err := r.Storage.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Thread locked", "commentID", id)
+	return toGQLComment(comment), nil
+}
+
+// RevertPost is the resolver for the revertPost field.
+func (r *mutationResolver) RevertPost(ctx context.Context, id string, version int, expectedVersion *int, userID *string) (*gqlModel.Post, error) {
+	postID := uuid.MustParse(id)
+	revision, err := r.Storage.GetPostRevision(ctx, postID, version)
+	if err != nil {
+		slog.Error("Failed to get post revision", "error", err, "postID", postID, "version", version)
+		return nil, err
+	}
+
+	editor, err := editorID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	post, err := r.editPost(ctx, postID, expectedVersion, editor, func(post *models.Post) {
+		post.Title = revision.Title
+		post.Content = revision.Content
+		post.AllowComments = revision.AllowComments
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Post reverted", "postID", postID, "toVersion", version)
+	r.dispatchPostWebhooks(ctx, models.WebhookPostUpdated, post)
+	return toGQLPost(post), nil
+}
+
+// SetUsername is the resolver for the setUsername field.
+func (r *mutationResolver) SetUsername(ctx context.Context, username string, userID *string) (string, error) {
+	name, err := mention.Username(username)
+	if err != nil {
+		return "", err
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.Storage.SetUsername(ctx, user, name)
+	if errors.Is(err, models.ErrUsernameTaken) {
+		return "", &validation.Error{Fields: []validation.FieldError{{Field: "username", Message: "is taken"}}}
+	}
+	if err != nil {
+		slog.Error("Failed to set username", "error", err, "userID", user)
+		return "", err
+	}
+	slog.Info("Username set", "userID", user, "username", name)
+	return name, nil
+}
+
+// MarkRead is the resolver for the markRead field.
+func (r *mutationResolver) MarkRead(ctx context.Context, ids []string, userID *string) (int, error) {
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var notificationIDs []uuid.UUID
+	if ids != nil {
+		notificationIDs = make([]uuid.UUID, 0, len(ids))
+		for _, id := range ids {
+			notificationID, err := parseID("ids", id)
+			if err != nil {
+				return 0, err
+			}
+			notificationIDs = append(notificationIDs, notificationID)
+		}
+	}
+
+	marked, err := r.Storage.MarkNotificationsRead(ctx, user, notificationIDs)
+	if err != nil {
+		slog.Error("Failed to mark notifications read", "error", err, "userID", user)
+		return 0, err
+	}
+	if marked > 0 {
+		r.publishInboxEvent(ctx, user, inboxEvent{})
+	}
+	return marked, nil
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, url string, secret string, events []gqlModel.WebhookEvent) (*gqlModel.Webhook, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, errModeratorsOnly
+	}
+	hook := models.Webhook{
+		ID:        uuid.New(),
+		URL:       url,
+		Secret:    secret,
+		Events:    make([]models.WebhookEvent, 0, len(events)),
+		CreatedAt: time.Now(),
+	}
+	for _, event := range events {
+		if !hook.Subscribes(models.WebhookEvent(event)) {
+			hook.Events = append(hook.Events, models.WebhookEvent(event))
+		}
+	}
+	if err := webhook.Validate(hook.URL, hook.Secret, hook.Events); err != nil {
+		return nil, err
+	}
+
+	if err := r.Storage.CreateWebhook(ctx, hook); err != nil {
+		slog.Error("Failed to create webhook", "error", err)
+		return nil, err
+	}
+	slog.Info("Webhook created", "webhookID", hook.ID, "events", hook.Events)
+	return toGQLWebhook(hook), nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if !auth.IsModerator(ctx) {
+		return false, errModeratorsOnly
+	}
+	webhookID, err := parseID("id", id)
+	if err != nil {
+		return false, err
+	}
+	if err := r.Storage.DeleteWebhook(ctx, webhookID); err != nil {
+		slog.Error("Failed to delete webhook", "error", err, "webhookID", webhookID)
+		return false, err
+	}
+	slog.Info("Webhook deleted", "webhookID", webhookID)
+	return true, nil
+}
+
+// ReportContent is the resolver for the reportContent field.
+func (r *mutationResolver) ReportContent(ctx context.Context, targetType gqlModel.ReportTarget, targetID string, reason gqlModel.ReportReason, details *string, userID *string) (*gqlModel.Report, error) {
+	reporter, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parseID("targetId", targetID)
+	if err != nil {
+		return nil, err
+	}
+	report := models.Report{
+		ID:         uuid.New(),
+		TargetType: models.ReportTarget(targetType),
+		TargetID:   target,
+		ReporterID: reporter,
+		Reason:     models.ReportReason(reason),
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if details != nil {
+		if report.Details, err = reportDetails(*details); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.checkReportable(ctx, report.TargetType, report.TargetID); err != nil {
+		return nil, err
+	}
+
+	if err := r.Storage.CreateReport(ctx, report); err != nil {
+		slog.Error("Failed to create report", "error", err, "targetID", report.TargetID)
+		return nil, err
+	}
+	slog.Info("Content reported", "reportID", report.ID, "target", report.TargetType, "targetID", report.TargetID, "reason", report.Reason)
+	return toGQLReport(report), nil
+}
+
+// ResolveReport is the resolver for the resolveReport field.
+func (r *mutationResolver) ResolveReport(ctx context.Context, id string, hideTarget *bool) (*gqlModel.Report, error) {
+	return r.closeReport(ctx, id, models.ReportResolved, hideTarget != nil && *hideTarget)
+}
+
+// DismissReport is the resolver for the dismissReport field.
+func (r *mutationResolver) DismissReport(ctx context.Context, id string) (*gqlModel.Report, error) {
+	return r.closeReport(ctx, id, models.ReportDismissed, false)
+}
+
+// MuteUser is the resolver for the muteUser field.
+func (r *mutationResolver) MuteUser(ctx context.Context, targetID string, userID *string) (bool, error) {
+	return r.setRelation(ctx, targetID, userID, models.RelationMute, true)
+}
+
+// UnmuteUser is the resolver for the unmuteUser field.
+func (r *mutationResolver) UnmuteUser(ctx context.Context, targetID string, userID *string) (bool, error) {
+	return r.setRelation(ctx, targetID, userID, models.RelationMute, false)
+}
+
+// BlockUser is the resolver for the blockUser field.
+func (r *mutationResolver) BlockUser(ctx context.Context, targetID string, userID *string) (bool, error) {
+	return r.setRelation(ctx, targetID, userID, models.RelationBlock, true)
+}
+
+// UnblockUser is the resolver for the unblockUser field.
+func (r *mutationResolver) UnblockUser(ctx context.Context, targetID string, userID *string) (bool, error) {
+	return r.setRelation(ctx, targetID, userID, models.RelationBlock, false)
+}
+
+// CreateCommunity is the resolver for the createCommunity field.
+func (r *mutationResolver) CreateCommunity(ctx context.Context, slug string, title string, description *string, commentPolicy *gqlModel.CommentPolicy, userID *string) (*gqlModel.Community, error) {
+	owner, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if description == nil {
+		description = new(string)
+	}
+	cleanSlug, cleanTitle, cleanDescription, err := r.ContentLimits.Community(slug, title, *description)
+	if err != nil {
+		return nil, err
+	}
+	policy := models.CommentsOpen
+	if commentPolicy != nil {
+		policy = models.CommentPolicy(*commentPolicy)
+	}
+
+	community := models.Community{
+		ID:            uuid.New(),
+		Slug:          cleanSlug,
+		Title:         cleanTitle,
+		Description:   cleanDescription,
+		OwnerID:       owner,
+		Moderators:    []uuid.UUID{},
+		CommentPolicy: policy,
+		CreatedAt:     time.Now(),
+	}
+	if err := r.Storage.CreateCommunity(ctx, community); err != nil {
+		slog.Error("Failed to create community", "error", err, "slug", community.Slug)
+		return nil, err
+	}
+
+	slog.Info("Community created", "communityID", community.ID, "slug", community.Slug)
+	return toGQLCommunity(community), nil
+}
+
+// UpdateCommunity is the resolver for the updateCommunity field.
+func (r *mutationResolver) UpdateCommunity(ctx context.Context, slug string, title *string, description *string, commentPolicy *gqlModel.CommentPolicy, moderatorIds []string) (*gqlModel.Community, error) {
+	// Only an authenticated viewer can prove to own or moderate the
+	// community; userId could name anyone.
+	viewer, ok := auth.ViewerFromContext(ctx)
+	if !ok {
+		return nil, errModeratorsOnly
+	}
+	actor := viewer.UserID
+	if err := r.ContentLimits.CommunityUpdate(title, description); err != nil {
+		return nil, err
+	}
+	community, err := r.communityBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	owns := actor == community.OwnerID || auth.IsModerator(ctx)
+	if !owns && !community.IsModerator(actor) {
+		return nil, errModeratorsOnly
+	}
+	if moderatorIds != nil && !owns {
+		return nil, errCommunityOwnersOnly
+	}
+
 	if title != nil {
-		post.Title = *title
+		community.Title = *title
 	}
-	if content != nil {
-		post.Content = *content
+	if description != nil {
+		community.Description = *description
 	}
-	if allowComments != nil {
-		post.AllowComments = *allowComments
+	if commentPolicy != nil {
+		community.CommentPolicy = models.CommentPolicy(*commentPolicy)
+	}
+	if moderatorIds != nil {
+		if community.Moderators, err = parseModerators(moderatorIds, community.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.Storage.UpdateCommunity(ctx, community); err != nil {
+		slog.Error("Failed to update community", "error", err, "communityID", community.ID)
+		return nil, err
+	}
+
+	slog.Info("Community updated", "communityID", community.ID, "editorID", actor)
+	return toGQLCommunity(community), nil
+}
+
+// ContentHTML is the resolver for the contentHtml field.
+func (r *postResolver) ContentHTML(ctx context.Context, obj *gqlModel.Post) (string, error) {
+	return r.renderHTML(postRenderKey(obj.ID, obj.Version), obj.Format, obj.Content), nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *gqlModel.Post, page int, pageSize int) ([]*gqlModel.PostRevision, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
 	}
 
-	err = r.Storage.UpdatePost(ctx, post)
+	postID := uuid.MustParse(obj.ID)
+	revisions, err := r.Storage.ListPostRevisions(ctx, postID, page, pageSize)
 	if err != nil {
-		slog.Error("Failed to update post", "error", err, "postID", postID)
+		slog.Error("Failed to list post revisions", "error", err, "postID", postID)
 		return nil, err
 	}
 
-	slog.Info("Post updated", "postID", postID)
+	result := make([]*gqlModel.PostRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, toGQLPostRevision(revision, models.ContentFormat(obj.Format)))
+	}
+	return result, nil
+}
 
-	return &gqlModel.Post{
-		ID:            post.ID.String(),
-		Title:         post.Title,
-		Content:       post.Content,
-		UserID:        post.UserID.String(),
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+// ContentHTML is the resolver for the contentHtml field.
+func (r *postRevisionResolver) ContentHTML(ctx context.Context, obj *gqlModel.PostRevision) (string, error) {
+	return r.renderHTML(postRenderKey(obj.PostID, obj.Version), obj.Format, obj.Content), nil
 }
 
 // Post is the resolver for the post field.
 func (r *queryResolver) Post(ctx context.Context, id string) (*gqlModel.Post, error) {
 	postID := uuid.MustParse(id)
-	post, err := r.Storage.GetPostByID(ctx, postID)
+	post, err := r.visiblePost(ctx, postID)
 	if err != nil {
 		slog.Error("Failed to get post by ID", "error", err, "postID", postID)
 		return nil, err
 	}
 
-	return &gqlModel.Post{
-		ID:            post.ID.String(),
-		Title:         post.Title,
-		Content:       post.Content,
-		UserID:        post.UserID.String(),
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return toGQLPost(post), nil
 }
 
 // Posts is the resolver for the posts field.
 func (r *queryResolver) Posts(ctx context.Context, page int, pageSize int) ([]*gqlModel.Post, error) {
-	posts, err := r.Storage.ListPosts(ctx, page, pageSize)
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	posts, err := r.Storage.ListPostsVisibleTo(ctx, viewerID(ctx), auth.IsModerator(ctx), page, pageSize)
 	if err != nil {
 		slog.Error("Failed to list posts", "error", err, "page", page, "pageSize", pageSize)
 		return nil, err
@@ -145,14 +655,7 @@ func (r *queryResolver) Posts(ctx context.Context, page int, pageSize int) ([]*g
 
 	var result []*gqlModel.Post
 	for _, post := range posts {
-		result = append(result, &gqlModel.Post{
-			ID:            post.ID.String(),
-			Title:         post.Title,
-			Content:       post.Content,
-			UserID:        post.UserID.String(),
-			AllowComments: post.AllowComments,
-			CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-		})
+		result = append(result, toGQLPost(post))
 	}
 
 	slog.Info("Listed posts", "page", page, "pageSize", pageSize)
@@ -162,7 +665,15 @@ func (r *queryResolver) Posts(ctx context.Context, page int, pageSize int) ([]*g
 
 // Comments is the resolver for the comments field.
 func (r *queryResolver) Comments(ctx context.Context, postID string, page int, pageSize int) ([]*gqlModel.Comment, error) {
-	comments, err := r.Storage.GetCommentsByPostID(ctx, uuid.MustParse(postID), page, pageSize)
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkCommentable(ctx, uuid.MustParse(postID)); err != nil {
+		return nil, err
+	}
+
+	comments, err := r.Storage.ListVisibleComments(ctx, uuid.MustParse(postID), viewerID(ctx), auth.IsModerator(ctx), page, pageSize)
 	if err != nil {
 		slog.Error("Failed to get comments by post ID", "error", err, "postID", postID)
 		return nil, err
@@ -182,6 +693,9 @@ func (r *queryResolver) Comments(ctx context.Context, postID string, page int, p
 			Content:   comment.Content,
 			UserID:    comment.UserID.String(),
 			CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+			Locked:    comment.Locked,
+			Format:    toGQLFormat(comment.Format),
+			Hidden:    comment.Hidden,
 		})
 	}
 
@@ -190,9 +704,200 @@ func (r *queryResolver) Comments(ctx context.Context, postID string, page int, p
 	return result, nil
 }
 
+// Thread is the resolver for the thread field.
+func (r *queryResolver) Thread(ctx context.Context, postID string, maxDepth int, page int, pageSize int) ([]*gqlModel.ThreadComment, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+	if maxDepth < 0 {
+		return nil, errors.New("maxDepth must not be negative")
+	}
+	if err := r.checkCommentable(ctx, uuid.MustParse(postID)); err != nil {
+		return nil, err
+	}
+
+	comments, err := r.Storage.ListThread(ctx, uuid.MustParse(postID), maxDepth, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list thread", "error", err, "postID", postID)
+		return nil, err
+	}
+
+	moderator := auth.IsModerator(ctx)
+	result := make([]*gqlModel.ThreadComment, 0, len(comments))
+	for _, comment := range comments {
+		if comment.Hidden && !moderator {
+			// Kept so that the replies beneath it stay in place.
+			comment.Content = ""
+		}
+		result = append(result, &gqlModel.ThreadComment{
+			Comment:     toGQLComment(comment.Comment),
+			Depth:       comment.Depth,
+			MoreReplies: comment.MoreReplies,
+		})
+	}
+	return result, nil
+}
+
+// PostRevision is the resolver for the postRevision field.
+func (r *queryResolver) PostRevision(ctx context.Context, id string, version int) (*gqlModel.PostRevision, error) {
+	postID := uuid.MustParse(id)
+	post, err := r.visiblePost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := r.Storage.GetPostRevision(ctx, postID, version)
+	if err != nil {
+		slog.Error("Failed to get post revision", "error", err, "postID", postID, "version", version)
+		return nil, err
+	}
+	return toGQLPostRevision(revision, post.Format), nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, userID *string, unreadOnly *bool, page int, pageSize int) ([]*gqlModel.Notification, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := r.Storage.ListNotifications(ctx, user, unreadOnly != nil && *unreadOnly, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list notifications", "error", err, "userID", user)
+		return nil, err
+	}
+	result := make([]*gqlModel.Notification, 0, len(notifications))
+	for _, n := range notifications {
+		result = append(result, toGQLNotification(n))
+	}
+	return result, nil
+}
+
+// Inbox is the resolver for the inbox field.
+func (r *queryResolver) Inbox(ctx context.Context, userID *string, unreadOnly *bool, page int, pageSize int) (*gqlModel.Inbox, error) {
+	notifications, err := r.Notifications(ctx, userID, unreadOnly, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := r.Storage.CountUnreadNotifications(ctx, user)
+	if err != nil {
+		slog.Error("Failed to count unread notifications", "error", err, "userID", user)
+		return nil, err
+	}
+	return &gqlModel.Inbox{UnreadCount: count, Notifications: notifications}, nil
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*gqlModel.Webhook, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, errModeratorsOnly
+	}
+	webhooks, err := r.Storage.ListWebhooks(ctx)
+	if err != nil {
+		slog.Error("Failed to list webhooks", "error", err)
+		return nil, err
+	}
+
+	result := make([]*gqlModel.Webhook, 0, len(webhooks))
+	for _, hook := range webhooks {
+		result = append(result, toGQLWebhook(hook))
+	}
+	return result, nil
+}
+
+// Reports is the resolver for the reports field.
+func (r *queryResolver) Reports(ctx context.Context, status *gqlModel.ReportStatus, reason *gqlModel.ReportReason, targetType *gqlModel.ReportTarget, targetID *string, page int, pageSize int) ([]*gqlModel.Report, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, errModeratorsOnly
+	}
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	var filter models.ReportFilter
+	if status != nil {
+		filter.Status = models.ReportStatus(*status)
+	}
+	if reason != nil {
+		filter.Reason = models.ReportReason(*reason)
+	}
+	if targetType != nil {
+		filter.TargetType = models.ReportTarget(*targetType)
+	}
+	if targetID != nil {
+		target, err := parseID("targetId", *targetID)
+		if err != nil {
+			return nil, err
+		}
+		filter.TargetID = target
+	}
+	reports, err := r.Storage.ListReports(ctx, filter, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list reports", "error", err)
+		return nil, err
+	}
+
+	result := make([]*gqlModel.Report, 0, len(reports))
+	for _, report := range reports {
+		result = append(result, toGQLReport(report))
+	}
+	return result, nil
+}
+
+// MutedUsers is the resolver for the mutedUsers field.
+func (r *queryResolver) MutedUsers(ctx context.Context, userID *string, page int, pageSize int) ([]*gqlModel.UserRelation, error) {
+	return r.listRelations(ctx, userID, models.RelationMute, page, pageSize)
+}
+
+// BlockedUsers is the resolver for the blockedUsers field.
+func (r *queryResolver) BlockedUsers(ctx context.Context, userID *string, page int, pageSize int) ([]*gqlModel.UserRelation, error) {
+	return r.listRelations(ctx, userID, models.RelationBlock, page, pageSize)
+}
+
+// Community is the resolver for the community field.
+func (r *queryResolver) Community(ctx context.Context, slug string) (*gqlModel.Community, error) {
+	community, err := r.communityBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGQLCommunity(community), nil
+}
+
+// Communities is the resolver for the communities field.
+func (r *queryResolver) Communities(ctx context.Context, page int, pageSize int) ([]*gqlModel.Community, error) {
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	communities, err := r.Storage.ListCommunities(ctx, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list communities", "error", err, "page", page, "pageSize", pageSize)
+		return nil, err
+	}
+
+	result := make([]*gqlModel.Community, 0, len(communities))
+	for _, community := range communities {
+		result = append(result, toGQLCommunity(community))
+	}
+	return result, nil
+}
+
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *gqlModel.Comment, error) {
-	postUUID := uuid.MustParse(postID)
+	postUUID, err := parseID("postId", postID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.visiblePost(ctx, postUUID); err != nil {
+		return nil, err
+	}
 	events := make(chan *gqlModel.Comment, 1)
 
 	commentsChan, err := r.PubSub.Subscribe(ctx, postUUID)
@@ -217,22 +922,11 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 					slog.Warn("Failed to get comment by ID", "error", err, "commentID", commentUUID)
 					continue
 				}
-
-				events <- &gqlModel.Comment{
-					ID:     comment.ID.String(),
-					PostID: comment.PostID.String(),
-					ParentID: func() *string {
-						if comment.ParentID != nil {
-							id := comment.ParentID.String()
-							return &id
-						} else {
-							return nil
-						}
-					}(),
-					Content:   comment.Content,
-					UserID:    comment.UserID.String(),
-					CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+				if r.hidesAuthor(ctx, comment.UserID) || (comment.Hidden && !auth.IsModerator(ctx)) {
+					continue
 				}
+
+				events <- toGQLComment(comment)
 			}
 		}
 	}()
@@ -242,15 +936,187 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 	return events, nil
 }
 
+// PostAdded is the resolver for the postAdded field.
+func (r *subscriptionResolver) PostAdded(ctx context.Context, communityID *string) (<-chan *gqlModel.Post, error) {
+	if r.PostPubSub == nil {
+		return nil, errPostAddedDisabled
+	}
+
+	topic := postAddedTopic
+	if communityID != nil {
+		var err error
+		if topic, err = parseCommunityID(*communityID); err != nil {
+			return nil, err
+		}
+	}
+
+	events := make(chan *gqlModel.Post, 1)
+	postsChan, err := r.PostPubSub.Subscribe(ctx, topic)
+	if err != nil {
+		slog.Error("Failed to subscribe to posts", "error", err)
+		return nil, err
+	}
+
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case postID, ok := <-postsChan:
+				if !ok {
+					return
+				}
+				post, err := r.Storage.GetPostByID(ctx, uuid.MustParse(postID))
+				if err != nil {
+					slog.Warn("Failed to get post by ID", "error", err, "postID", postID)
+					continue
+				}
+
+				select {
+				case events <- toGQLPost(post):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context, userID *string) (<-chan *gqlModel.Notification, error) {
+	if r.NotificationPubSub == nil {
+		return nil, errNotificationAddedDisabled
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inbox, err := r.subscribeInbox(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *gqlModel.Notification, 1)
+	go func() {
+		defer close(events)
+		for event := range inbox {
+			if event.Notification == nil {
+				continue
+			}
+			select {
+			case events <- toGQLNotification(*event.Notification):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// InboxUpdated is the resolver for the inboxUpdated field.
+func (r *subscriptionResolver) InboxUpdated(ctx context.Context, userID *string) (<-chan *gqlModel.InboxUpdate, error) {
+	if r.NotificationPubSub == nil {
+		return nil, errInboxUpdatedDisabled
+	}
+	user, err := recipientID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	inbox, err := r.subscribeInbox(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *gqlModel.InboxUpdate, 1)
+	go func() {
+		defer close(events)
+		// Start with the current count, so that clients need no separate
+		// query that could race with the first event.
+		update := &gqlModel.InboxUpdate{}
+		for {
+			count, err := r.Storage.CountUnreadNotifications(ctx, user)
+			if err != nil {
+				slog.Warn("Failed to count unread notifications", "error", err, "userID", user)
+			} else {
+				update.UnreadCount = count
+				select {
+				case events <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			event, ok := <-inbox
+			if !ok {
+				return
+			}
+			update = &gqlModel.InboxUpdate{}
+			if event.Notification != nil {
+				update.Notification = toGQLNotification(*event.Notification)
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Deliveries is the resolver for the deliveries field.
+func (r *webhookResolver) Deliveries(ctx context.Context, obj *gqlModel.Webhook, page int, pageSize int) ([]*gqlModel.WebhookDelivery, error) {
+	if !auth.IsModerator(ctx) {
+		return nil, errModeratorsOnly
+	}
+	if err := r.checkPageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	webhookID := uuid.MustParse(obj.ID)
+	deliveries, err := r.Storage.ListWebhookDeliveries(ctx, webhookID, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list webhook deliveries", "error", err, "webhookID", webhookID)
+		return nil, err
+	}
+
+	result := make([]*gqlModel.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, toGQLWebhookDelivery(delivery))
+	}
+	return result, nil
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
+// Community returns CommunityResolver implementation.
+func (r *Resolver) Community() CommunityResolver { return &communityResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
+// PostRevision returns PostRevisionResolver implementation.
+func (r *Resolver) PostRevision() PostRevisionResolver { return &postRevisionResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+// Webhook returns WebhookResolver implementation.
+func (r *Resolver) Webhook() WebhookResolver { return &webhookResolver{r} }
+
+type commentResolver struct{ *Resolver }
+type communityResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
+type postRevisionResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
@@ -0,0 +1,60 @@
+package gql
+
+import (
+	"context"
+	gqlModel "ozon-test/internal/gql/model"
+	"ozon-test/internal/models"
+	"time"
+)
+
+// dispatchWebhooks sends event about data to the subscribed webhooks, if
+// webhooks are enabled.
+func (r *Resolver) dispatchWebhooks(ctx context.Context, event models.WebhookEvent, data any) {
+	if r.Webhooks == nil {
+		return
+	}
+	r.Webhooks.Dispatch(ctx, event, data)
+}
+
+// dispatchPostWebhooks sends event about post, unless the post is a draft
+// or scheduled: receivers only hear about posts, and comments on them, once
+// they are published.
+func (r *Resolver) dispatchPostWebhooks(ctx context.Context, event models.WebhookEvent, post models.Post) {
+	if post.Status != models.PostPublished {
+		return
+	}
+	r.dispatchWebhooks(ctx, event, post)
+}
+
+// toGQLWebhook converts a webhook, leaving out its secret.
+func toGQLWebhook(webhook models.Webhook) *gqlModel.Webhook {
+	events := make([]gqlModel.WebhookEvent, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = gqlModel.WebhookEvent(event)
+	}
+	return &gqlModel.Webhook{
+		ID:        webhook.ID.String(),
+		URL:       webhook.URL,
+		Events:    events,
+		CreatedAt: webhook.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toGQLWebhookDelivery(delivery models.WebhookDelivery) *gqlModel.WebhookDelivery {
+	result := &gqlModel.WebhookDelivery{
+		ID:        delivery.ID.String(),
+		EventID:   delivery.EventID.String(),
+		Event:     gqlModel.WebhookEvent(delivery.Event),
+		Payload:   delivery.Payload,
+		Attempt:   delivery.Attempt,
+		Succeeded: delivery.Succeeded(),
+		CreatedAt: delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if delivery.StatusCode != 0 {
+		result.StatusCode = &delivery.StatusCode
+	}
+	if delivery.Error != "" {
+		result.Error = &delivery.Error
+	}
+	return result
+}
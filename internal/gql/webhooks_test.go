@@ -0,0 +1,160 @@
+package gql_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ozon-test/internal/auth"
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/webhook"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	createWebhookMutation = `mutation($url: String!, $secret: String!, $events: [WebhookEvent!]!) { createWebhook(url: $url, secret: $secret, events: $events) { id url events } }`
+	webhooksQuery         = `query { webhooks { id deliveries(page: 1, pageSize: 10) { eventId event payload attempt statusCode error succeeded } } }`
+)
+
+type webhooksResponse struct {
+	Webhooks []struct {
+		ID         string
+		Deliveries []struct {
+			EventID    string
+			Event      string
+			Payload    string
+			Attempt    int
+			StatusCode *int
+			Error      *string
+			Succeeded  bool
+		}
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	const secret = "0123456789abcdef"
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	storage := inmemory.NewInMemoryStorage()
+	dispatcher := webhook.NewDispatcher(storage, webhook.Config{})
+	resolver := &gql.Resolver{Storage: storage, PubSub: pubsub.NewInMemoryPubSub(), Webhooks: dispatcher}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+	moderator := withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true})
+
+	var created struct {
+		CreateWebhook struct {
+			ID     string
+			URL    string
+			Events []string
+		}
+	}
+	args := []client.Option{client.Var("url", receiver.URL), client.Var("secret", secret), client.Var("events", []string{"COMMENT_CREATED", "POST_CREATED"})}
+	err := c.Post(createWebhookMutation, &created, append(args, asViewer(uuid.New()))...)
+	assert.ErrorContains(t, err, `"code":"FORBIDDEN"`)
+	err = c.Post(createWebhookMutation, &created, client.Var("url", "ftp://example.com"), client.Var("secret", "short"), client.Var("events", []string{}), moderator)
+	assert.ErrorContains(t, err, `"code":"VALIDATION_FAILED"`)
+	for _, field := range []string{"url", "secret", "events"} {
+		assert.ErrorContains(t, err, `"field":"`+field+`"`)
+	}
+	require.NoError(t, c.Post(createWebhookMutation, &created, append(args, moderator)...))
+	assert.Equal(t, []string{"COMMENT_CREATED", "POST_CREATED"}, created.CreateWebhook.Events)
+
+	var post struct{ CreatePost struct{ ID string } }
+	require.NoError(t, c.Post(mentionPostMutation, &post, client.Var("userId", uuid.NewString()), client.Var("content", "Hello")))
+	req := <-received
+	body := <-bodies
+	assert.Equal(t, "POST_CREATED", req.Header.Get(webhook.EventHeader))
+	assert.Equal(t, webhook.Sign(secret, body), req.Header.Get(webhook.SignatureHeader))
+	var payload struct {
+		Event string
+		Data  struct{ ID string }
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, post.CreatePost.ID, payload.Data.ID)
+
+	var updated updatePostResponse
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", post.CreatePost.ID), client.Var("title", "Edited")))
+	dispatcher.Close()
+	assert.Empty(t, received, "Only subscribed events are delivered")
+
+	var hooks webhooksResponse
+	assert.ErrorContains(t, c.Post(webhooksQuery, &hooks), `"code":"FORBIDDEN"`)
+	require.NoError(t, c.Post(webhooksQuery, &hooks, moderator))
+	require.Len(t, hooks.Webhooks, 1)
+	require.Len(t, hooks.Webhooks[0].Deliveries, 1)
+	delivery := hooks.Webhooks[0].Deliveries[0]
+	assert.Equal(t, req.Header.Get(webhook.EventIDHeader), delivery.EventID)
+	assert.Equal(t, "POST_CREATED", delivery.Event)
+	assert.JSONEq(t, string(body), delivery.Payload)
+	assert.Equal(t, 1, delivery.Attempt)
+	require.NotNil(t, delivery.StatusCode)
+	assert.Equal(t, http.StatusNoContent, *delivery.StatusCode)
+	assert.Nil(t, delivery.Error)
+	assert.True(t, delivery.Succeeded)
+
+	const deleteWebhook = `mutation($id: ID!) { deleteWebhook(id: $id) }`
+	var deleted struct{ DeleteWebhook bool }
+	err = c.Post(deleteWebhook, &deleted, client.Var("id", "hook"), moderator)
+	assert.ErrorContains(t, err, `"field":"id"`)
+	require.NoError(t, c.Post(deleteWebhook, &deleted, client.Var("id", created.CreateWebhook.ID), moderator))
+	assert.True(t, deleted.DeleteWebhook)
+	require.NoError(t, c.Post(webhooksQuery, &hooks, moderator))
+	assert.Empty(t, hooks.Webhooks)
+}
+
+func TestWebhooksSkipUnpublishedPosts(t *testing.T) {
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(webhook.EventHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	storage := inmemory.NewInMemoryStorage()
+	dispatcher := webhook.NewDispatcher(storage, webhook.Config{})
+	resolver := &gql.Resolver{Storage: storage, PubSub: pubsub.NewInMemoryPubSub(), Webhooks: dispatcher}
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver}))
+	srv.SetErrorPresenter(gql.ErrorPresenter)
+	c := client.New(srv)
+
+	var hook map[string]any
+	require.NoError(t, c.Post(createWebhookMutation, &hook, client.Var("url", receiver.URL), client.Var("secret", "0123456789abcdef"),
+		client.Var("events", []string{"POST_CREATED", "POST_UPDATED", "COMMENT_CREATED"}), withViewer(auth.Viewer{UserID: uuid.New(), Moderator: true})))
+
+	author := uuid.New()
+	var draft createPostResponse
+	require.NoError(t, c.Post(createDraftMutation, &draft, client.Var("userId", author.String()), asViewer(author)))
+	id := draft.CreatePost.ID
+	var updated updatePostResponse
+	require.NoError(t, c.Post(updatePostMutation, &updated, client.Var("id", id), client.Var("title", "Still a draft"), asViewer(author)))
+	var comment createCommentResponse
+	require.NoError(t, c.Post(createCommentMutation, &comment, client.Var("postId", id), client.Var("userId", author.String()), asViewer(author)))
+	var status struct{ SetPostStatus postStatusResponse }
+	require.NoError(t, c.Post(setPostStatusMutation, &status, client.Var("id", id), client.Var("status", "SCHEDULED"),
+		client.Var("publishAt", time.Now().Add(time.Hour).Format(time.RFC3339)), asViewer(author)))
+
+	require.NoError(t, c.Post(setPostStatusMutation, &status, client.Var("id", id), client.Var("status", "PUBLISHED"), asViewer(author)))
+	dispatcher.Close()
+	require.Len(t, received, 1, "Unpublished posts send no events")
+	assert.Equal(t, "POST_UPDATED", <-received, "Publishing a post sends an update")
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+// Handler serves liveness and readiness probes.
+type Handler struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining atomic.Bool
+	timeout  time.Duration
+}
+
+// NewHandler creates a Handler whose readiness checks are bounded by timeout.
+func NewHandler(timeout time.Duration) *Handler {
+	return &Handler{
+		checks:  make(map[string]Check),
+		timeout: timeout,
+	}
+}
+
+// AddCheck registers a named dependency check used by the readiness probe.
+func (h *Handler) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// SetDraining makes the readiness probe fail so that load balancers stop
+// routing new traffic while the server shuts down.
+func (h *Handler) SetDraining() {
+	h.draining.Store(true)
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Liveness reports that the process is up. It does not touch dependencies.
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, response{Status: "ok"})
+}
+
+// Readiness runs every registered check and reports 503 if any of them fail
+// or the server is draining.
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, response{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	h.mu.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := response{Status: "ok", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+	for _, name := range names {
+		if err := h.checks[name](ctx); err != nil {
+			slog.Warn("Readiness check failed", "check", name, "error", err)
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+	h.mu.RUnlock()
+
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write health response", "error", err)
+	}
+}
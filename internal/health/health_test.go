@@ -0,0 +1,54 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ozon-test/internal/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveness(t *testing.T) {
+	h := health.NewHandler(time.Second)
+	h.AddCheck("db", func(ctx context.Context) error { return errors.New("down") })
+
+	rec := httptest.NewRecorder()
+	h.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code, "Liveness should not depend on checks")
+}
+
+func TestReadiness(t *testing.T) {
+	h := health.NewHandler(time.Second)
+	dbErr := error(nil)
+	h.AddCheck("db", func(ctx context.Context) error { return dbErr })
+
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Ready when all checks pass")
+	assert.JSONEq(t, `{"status":"ok","checks":{"db":"ok"}}`, rec.Body.String())
+
+	dbErr = errors.New("connection refused")
+	rec = httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "Not ready when a check fails")
+	assert.JSONEq(t, `{"status":"unavailable","checks":{"db":"connection refused"}}`, rec.Body.String())
+}
+
+func TestReadinessWhileDraining(t *testing.T) {
+	h := health.NewHandler(time.Second)
+	h.SetDraining()
+
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "Not ready while draining")
+
+	rec = httptest.NewRecorder()
+	h.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Still alive while draining")
+}
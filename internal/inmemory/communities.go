@@ -0,0 +1,93 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// CreateCommunity adds a community unless its slug is taken.
+func (s *InMemoryStorage) CreateCommunity(ctx context.Context, community models.Community) error {
+	s.communitiesMutex.Lock()
+	defer s.communitiesMutex.Unlock()
+
+	if _, taken := s.communitySlugs[community.Slug]; taken {
+		return models.ErrCommunitySlugTaken
+	}
+	community.Moderators = append([]uuid.UUID(nil), community.Moderators...)
+	s.communities[community.ID] = community
+	s.communitySlugs[community.Slug] = community.ID
+	s.communityOrder = append(s.communityOrder, community.ID)
+	slog.Info("Community created", "communityID", community.ID, "slug", community.Slug)
+	return nil
+}
+
+// GetCommunity retrieves a community by its ID.
+func (s *InMemoryStorage) GetCommunity(ctx context.Context, communityID uuid.UUID) (models.Community, error) {
+	s.communitiesMutex.RLock()
+	defer s.communitiesMutex.RUnlock()
+
+	community, ok := s.communities[communityID]
+	if !ok {
+		return models.Community{}, models.ErrCommunityNotFound
+	}
+	return copyCommunity(community), nil
+}
+
+// GetCommunityBySlug retrieves a community by its slug.
+func (s *InMemoryStorage) GetCommunityBySlug(ctx context.Context, slug string) (models.Community, error) {
+	s.communitiesMutex.RLock()
+	defer s.communitiesMutex.RUnlock()
+
+	communityID, ok := s.communitySlugs[slug]
+	if !ok {
+		return models.Community{}, models.ErrCommunityNotFound
+	}
+	return copyCommunity(s.communities[communityID]), nil
+}
+
+// ListCommunities retrieves a page of communities, oldest first.
+func (s *InMemoryStorage) ListCommunities(ctx context.Context, page, pageSize int) ([]models.Community, error) {
+	if page <= 0 || pageSize <= 0 {
+		slog.Warn("Invalid page or pageSize parameter", "page", page, "pageSize", pageSize)
+		return nil, errors.New("invalid page or pageSize parameter")
+	}
+
+	s.communitiesMutex.RLock()
+	defer s.communitiesMutex.RUnlock()
+
+	communities := []models.Community{}
+	for i := (page - 1) * pageSize; i < len(s.communityOrder) && len(communities) < pageSize; i++ {
+		communities = append(communities, copyCommunity(s.communities[s.communityOrder[i]]))
+	}
+	return communities, nil
+}
+
+// UpdateCommunity replaces a community's title, description, moderators and
+// comment policy.
+func (s *InMemoryStorage) UpdateCommunity(ctx context.Context, community models.Community) error {
+	s.communitiesMutex.Lock()
+	defer s.communitiesMutex.Unlock()
+
+	stored, ok := s.communities[community.ID]
+	if !ok {
+		return models.ErrCommunityNotFound
+	}
+	stored.Title = community.Title
+	stored.Description = community.Description
+	stored.Moderators = append([]uuid.UUID(nil), community.Moderators...)
+	stored.CommentPolicy = community.CommentPolicy
+	s.communities[community.ID] = stored
+	slog.Info("Community updated", "communityID", community.ID)
+	return nil
+}
+
+// copyCommunity returns community with its own copy of Moderators, so that
+// callers cannot change what is stored.
+func copyCommunity(community models.Community) models.Community {
+	community.Moderators = append([]uuid.UUID(nil), community.Moderators...)
+	return community
+}
@@ -0,0 +1,83 @@
+package inmemory
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type idempotencyScope struct {
+	userID uuid.UUID
+	key    string
+}
+
+// ReserveIdempotencyKey stores key unless an unexpired key with the same
+// scope already exists.
+func (s *InMemoryStorage) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (models.IdempotencyKey, bool, error) {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	scope := idempotencyScope{key.UserID, key.Key}
+	if existing, ok := s.idempotencyKeys[scope]; ok && existing.ExpiresAt.After(key.CreatedAt) {
+		return existing, false, nil
+	}
+	s.idempotencyKeys[scope] = key
+	return key, true, nil
+}
+
+// LookupIdempotencyKey returns the key that ReserveIdempotencyKey would
+// report as already held at now, if any.
+func (s *InMemoryStorage) LookupIdempotencyKey(userID uuid.UUID, key string, now time.Time) (models.IdempotencyKey, bool) {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	existing, ok := s.idempotencyKeys[idempotencyScope{userID, key}]
+	if !ok || !existing.ExpiresAt.After(now) {
+		return models.IdempotencyKey{}, false
+	}
+	return existing, true
+}
+
+// DeleteIdempotencyKey removes the key if it still guards resourceID.
+func (s *InMemoryStorage) DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) error {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	scope := idempotencyScope{userID, key}
+	if existing, ok := s.idempotencyKeys[scope]; ok && existing.ResourceID == resourceID {
+		delete(s.idempotencyKeys, scope)
+	}
+	return nil
+}
+
+// CountExpiredIdempotencyKeys reports how many keys PurgeIdempotencyKeys
+// would remove for before.
+func (s *InMemoryStorage) CountExpiredIdempotencyKeys(before time.Time) int {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	count := 0
+	for _, key := range s.idempotencyKeys {
+		if key.ExpiresAt.Before(before) {
+			count++
+		}
+	}
+	return count
+}
+
+// PurgeIdempotencyKeys removes keys that expired before the given time.
+func (s *InMemoryStorage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+
+	purged := 0
+	for scope, key := range s.idempotencyKeys {
+		if key.ExpiresAt.Before(before) {
+			delete(s.idempotencyKeys, scope)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -13,22 +13,65 @@ import (
 
 type InMemoryStorage struct {
 	posts         map[uuid.UUID]models.Post
+	revisions     map[uuid.UUID][]models.PostRevision // oldest first, guarded by postsMutex
 	comments      map[uuid.UUID]models.Comment
 	structure     map[uuid.UUID][]models.StructureTree
 	postOrder     []uuid.UUID
 	commentOrder  map[uuid.UUID][]uuid.UUID
 	postsMutex    sync.RWMutex
 	commentsMutex sync.RWMutex
+	depthLimit    models.DepthLimit
+
+	idempotencyKeys  map[idempotencyScope]models.IdempotencyKey
+	idempotencyMutex sync.Mutex
+
+	usernames          map[string]uuid.UUID
+	usernameOf         map[uuid.UUID]string
+	notifications      map[uuid.UUID][]models.Notification // by recipient, oldest first
+	notificationsMutex sync.RWMutex
+
+	webhooks          []models.Webhook                       // oldest first
+	webhookDeliveries map[uuid.UUID][]models.WebhookDelivery // by webhook, oldest first
+	webhooksMutex     sync.RWMutex
+
+	outbox      []models.OutboxEvent // oldest first
+	outboxMutex sync.Mutex
+
+	reports      map[uuid.UUID]models.Report
+	reportOrder  []uuid.UUID // oldest first
+	reportsMutex sync.RWMutex
+
+	relations      []models.UserRelation // oldest first
+	relationsMutex sync.RWMutex
+
+	communities      map[uuid.UUID]models.Community
+	communitySlugs   map[string]uuid.UUID
+	communityOrder   []uuid.UUID // oldest first
+	communitiesMutex sync.RWMutex
 }
 
 // NewInMemoryStorage creates a new instance of InMemoryStorage.
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
 		posts:        make(map[uuid.UUID]models.Post),
+		revisions:    make(map[uuid.UUID][]models.PostRevision),
 		comments:     make(map[uuid.UUID]models.Comment),
 		structure:    make(map[uuid.UUID][]models.StructureTree),
 		postOrder:    []uuid.UUID{},
 		commentOrder: make(map[uuid.UUID][]uuid.UUID),
+
+		idempotencyKeys: make(map[idempotencyScope]models.IdempotencyKey),
+
+		usernames:     make(map[string]uuid.UUID),
+		usernameOf:    make(map[uuid.UUID]string),
+		notifications: make(map[uuid.UUID][]models.Notification),
+
+		webhookDeliveries: make(map[uuid.UUID][]models.WebhookDelivery),
+
+		reports: make(map[uuid.UUID]models.Report),
+
+		communities:    make(map[uuid.UUID]models.Community),
+		communitySlugs: make(map[string]uuid.UUID),
 	}
 }
 
@@ -37,10 +80,23 @@ func (s *InMemoryStorage) CreatePost(ctx context.Context, post models.Post) erro
 	s.postsMutex.Lock()
 	defer s.postsMutex.Unlock()
 
-	post.ID = uuid.New()
-	post.CreatedAt = time.Now()
+	if post.ID == uuid.Nil {
+		post.ID = uuid.New()
+	}
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now()
+	}
+	post = post.WithDefaultStatus()
+	post.Hidden = false
+	if post.UpdatedAt.IsZero() {
+		post.UpdatedAt = post.CreatedAt
+	}
+	if post.UpdatedBy == uuid.Nil {
+		post.UpdatedBy = post.UserID
+	}
 	s.posts[post.ID] = post
 	s.postOrder = append(s.postOrder, post.ID)
+	s.revisions[post.ID] = []models.PostRevision{models.RevisionOf(post)}
 
 	slog.Info("Post created", "postID", post.ID)
 	return nil
@@ -54,7 +110,7 @@ func (s *InMemoryStorage) GetPostByID(ctx context.Context, postID uuid.UUID) (mo
 	post, exists := s.posts[postID]
 	if !exists {
 		slog.Warn("Post not found", "postID", postID)
-		return models.Post{}, errors.New("post not found")
+		return models.Post{}, models.ErrPostNotFound
 	}
 	return post, nil
 }
@@ -90,11 +146,32 @@ func (s *InMemoryStorage) ListPosts(ctx context.Context, page, pageSize int) ([]
 
 // CreateComment adds a new comment to the in-memory storage.
 func (s *InMemoryStorage) CreateComment(ctx context.Context, comment models.Comment) error {
+	return s.createComment(comment, nil)
+}
+
+// CreateCommentWithEvent adds a new comment and queues event in the outbox
+// before either becomes visible.
+func (s *InMemoryStorage) CreateCommentWithEvent(ctx context.Context, comment models.Comment, event models.OutboxEvent) error {
+	return s.createComment(comment, &event)
+}
+
+func (s *InMemoryStorage) createComment(comment models.Comment, event *models.OutboxEvent) error {
 	s.commentsMutex.Lock()
 	defer s.commentsMutex.Unlock()
 
-	comment.CreatedAt = time.Now()
-	s.comments[comment.ID] = comment
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	comment.Locked = false
+	comment.Hidden = false
+
+	comment, err := s.placeComment(comment, s.depthLimit)
+	if err != nil {
+		return err
+	}
 
 	var ancestorID uuid.UUID
 	var level int
@@ -103,15 +180,12 @@ func (s *InMemoryStorage) CreateComment(ctx context.Context, comment models.Comm
 		ancestorID = comment.ID
 		level = 0
 	} else {
-		parentComment, exists := s.comments[*comment.ParentID]
-		if !exists {
-			slog.Warn("Parent comment not found", "parentID", comment.ParentID)
-			return errors.New("parent comment not found")
-		}
-		ancestorID = parentComment.ID
+		ancestorID = *comment.ParentID
 		level = 1
 	}
 
+	s.comments[comment.ID] = comment
+
 	s.structure[comment.PostID] = append(s.structure[comment.PostID], models.StructureTree{
 		AncestorID:        ancestorID,
 		DescendantID:      comment.ID,
@@ -122,6 +196,12 @@ func (s *InMemoryStorage) CreateComment(ctx context.Context, comment models.Comm
 
 	s.commentOrder[comment.PostID] = append(s.commentOrder[comment.PostID], comment.ID)
 
+	if event != nil {
+		s.outboxMutex.Lock()
+		s.outbox = append(s.outbox, *event)
+		s.outboxMutex.Unlock()
+	}
+
 	slog.Info("Comment created", "commentID", comment.ID, "postID", comment.PostID)
 	return nil
 }
@@ -134,7 +214,7 @@ func (s *InMemoryStorage) GetCommentByID(ctx context.Context, commentID uuid.UUI
 	comment, exists := s.comments[commentID]
 	if !exists {
 		slog.Warn("Comment not found", "commentID", commentID)
-		return models.Comment{}, errors.New("comment not found")
+		return models.Comment{}, models.ErrCommentNotFound
 	}
 	return comment, nil
 }
@@ -173,18 +253,59 @@ func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postID uuid.U
 	return comments, nil
 }
 
-// UpdatePost updates an existing post in the in-memory storage.
+// ListVisibleComments retrieves a paginated list of a post's comments that
+// viewerID may see.
+func (s *InMemoryStorage) ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Comment, error) {
+	if page <= 0 || pageSize <= 0 {
+		slog.Warn("Invalid page or pageSize parameter", "page", page, "pageSize", pageSize)
+		return nil, errors.New("invalid page or pageSize parameter")
+	}
+
+	hiddenAuthors := s.hiddenAuthors(viewerID)
+	s.commentsMutex.RLock()
+	defer s.commentsMutex.RUnlock()
+
+	skip := (page - 1) * pageSize
+	comments := []models.Comment{}
+	for _, commentID := range s.commentOrder[postID] {
+		comment := s.comments[commentID]
+		if (comment.Hidden && !includeHidden) || hiddenAuthors[comment.UserID] {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		comments = append(comments, comment)
+		if len(comments) == pageSize {
+			break
+		}
+	}
+	return comments, nil
+}
+
+// UpdatePost updates an existing post in the in-memory storage if its
+// version has not changed since post was read.
 func (s *InMemoryStorage) UpdatePost(ctx context.Context, post models.Post) error {
 	s.postsMutex.Lock()
 	defer s.postsMutex.Unlock()
 
-	_, exists := s.posts[post.ID]
+	existing, exists := s.posts[post.ID]
 	if !exists {
 		slog.Warn("Post not found", "postID", post.ID)
-		return errors.New("post not found")
+		return models.ErrPostNotFound
+	}
+	if existing.Version != post.Version {
+		slog.Warn("Post version conflict", "postID", post.ID, "expected", post.Version, "actual", existing.Version)
+		return models.ErrPostVersionConflict
 	}
 
+	post.Status = existing.Status
+	post.PublishAt = existing.PublishAt
+	post.Hidden = existing.Hidden
+	post.Version++
 	s.posts[post.ID] = post
+	s.revisions[post.ID] = append(s.revisions[post.ID], models.RevisionOf(post))
 	slog.Info("Post updated", "postID", post.ID)
 	return nil
 }
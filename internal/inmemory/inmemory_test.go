@@ -4,6 +4,7 @@ import (
 	"context"
 	"ozon-test/internal/inmemory"
 	"ozon-test/internal/models"
+	"ozon-test/internal/storagetest"
 	"testing"
 	"time"
 
@@ -11,6 +12,18 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) models.Storage {
+		return inmemory.NewInMemoryStorage()
+	})
+}
+
+func BenchmarkStorage(b *testing.B) {
+	storagetest.Bench(b, func(b *testing.B) models.Storage {
+		return inmemory.NewInMemoryStorage()
+	})
+}
+
 func TestCreatePost(t *testing.T) {
 	storage := inmemory.NewInMemoryStorage()
 	post := models.Post{
@@ -0,0 +1,126 @@
+package inmemory
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SetUsername gives userID the username, releasing the one it had.
+func (s *InMemoryStorage) SetUsername(ctx context.Context, userID uuid.UUID, username string) error {
+	s.notificationsMutex.Lock()
+	defer s.notificationsMutex.Unlock()
+
+	if holder, ok := s.usernames[username]; ok && holder != userID {
+		return models.ErrUsernameTaken
+	}
+	if old, ok := s.usernameOf[userID]; ok {
+		delete(s.usernames, old)
+	}
+	s.usernames[username] = userID
+	s.usernameOf[userID] = username
+	return nil
+}
+
+// ResolveUsernames maps each held username to its user.
+func (s *InMemoryStorage) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error) {
+	s.notificationsMutex.RLock()
+	defer s.notificationsMutex.RUnlock()
+
+	users := make(map[string]uuid.UUID, len(usernames))
+	for _, name := range usernames {
+		if userID, ok := s.usernames[name]; ok {
+			users[name] = userID
+		}
+	}
+	return users, nil
+}
+
+// CreateNotifications adds unread notifications to their users' lists.
+func (s *InMemoryStorage) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	s.notificationsMutex.Lock()
+	defer s.notificationsMutex.Unlock()
+
+	for _, n := range notifications {
+		n.Read = false
+		if n.CommentID != nil {
+			commentID := *n.CommentID
+			n.CommentID = &commentID
+		}
+		s.notifications[n.UserID] = append(s.notifications[n.UserID], n)
+	}
+	return nil
+}
+
+// ListNotifications returns a page of userID's notifications, newest first.
+func (s *InMemoryStorage) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]models.Notification, error) {
+	s.notificationsMutex.RLock()
+	defer s.notificationsMutex.RUnlock()
+
+	result := []models.Notification{}
+	skip := (page - 1) * pageSize
+	all := s.notifications[userID]
+	for i := len(all) - 1; i >= 0 && len(result) < pageSize; i-- {
+		if unreadOnly && all[i].Read {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		result = append(result, all[i])
+	}
+	return result, nil
+}
+
+// MarkNotificationsRead marks userID's notifications with the given IDs, or
+// all of them if ids is nil, as read.
+func (s *InMemoryStorage) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error) {
+	s.notificationsMutex.Lock()
+	defer s.notificationsMutex.Unlock()
+
+	marked := 0
+	all := s.notifications[userID]
+	for i := range all {
+		if !all[i].Read && selected(all[i].ID, ids) {
+			all[i].Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// CountUnreadNotifications returns how many of userID's notifications are
+// unread.
+func (s *InMemoryStorage) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int, error) {
+	return s.UnreadNotifications(userID, nil), nil
+}
+
+// UnreadNotifications returns how many notifications MarkNotificationsRead
+// would mark.
+func (s *InMemoryStorage) UnreadNotifications(userID uuid.UUID, ids []uuid.UUID) int {
+	s.notificationsMutex.RLock()
+	defer s.notificationsMutex.RUnlock()
+
+	count := 0
+	for _, n := range s.notifications[userID] {
+		if !n.Read && selected(n.ID, ids) {
+			count++
+		}
+	}
+	return count
+}
+
+// selected reports whether id is among ids, a nil ids selecting everything.
+func selected(id uuid.UUID, ids []uuid.UUID) bool {
+	if ids == nil {
+		return true
+	}
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package inmemory
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingOutboxEvents returns up to limit undelivered events, oldest first.
+func (s *InMemoryStorage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+
+	events := []models.OutboxEvent{}
+	for _, event := range s.outbox {
+		if len(events) >= limit {
+			break
+		}
+		if event.DeliveredAt == nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// MarkOutboxEventsDelivered records that the events were published at the
+// given time. Unknown IDs are ignored.
+func (s *InMemoryStorage) MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+
+	delivered := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	for i := range s.outbox {
+		if delivered[s.outbox[i].ID] && s.outbox[i].DeliveredAt == nil {
+			at := at
+			s.outbox[i].DeliveredAt = &at
+		}
+	}
+	return nil
+}
+
+// PurgeOutboxEvents deletes events delivered before the given time.
+func (s *InMemoryStorage) PurgeOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+
+	kept := s.outbox[:0]
+	for _, event := range s.outbox {
+		if event.DeliveredAt == nil || !event.DeliveredAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	purged := len(s.outbox) - len(kept)
+	clear(s.outbox[len(kept):])
+	s.outbox = kept
+	return purged, nil
+}
+
+// CountPurgeableOutboxEvents reports how many events PurgeOutboxEvents
+// would delete.
+func (s *InMemoryStorage) CountPurgeableOutboxEvents(before time.Time) int {
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+
+	count := 0
+	for _, event := range s.outbox {
+		if event.DeliveredAt != nil && event.DeliveredAt.Before(before) {
+			count++
+		}
+	}
+	return count
+}
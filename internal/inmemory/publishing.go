@@ -0,0 +1,125 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// ListPostsVisibleTo retrieves a paginated list of the posts viewerID may see.
+func (s *InMemoryStorage) ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	return s.listVisiblePosts(nil, viewerID, includeHidden, page, pageSize)
+}
+
+// ListCommunityPosts retrieves a paginated list of the posts of a community
+// viewerID may see.
+func (s *InMemoryStorage) ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	return s.listVisiblePosts(&communityID, viewerID, includeHidden, page, pageSize)
+}
+
+// listVisiblePosts lists the posts viewerID may see, only those of
+// communityID if it is set.
+func (s *InMemoryStorage) listVisiblePosts(communityID *uuid.UUID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	if page <= 0 || pageSize <= 0 {
+		slog.Warn("Invalid page or pageSize parameter", "page", page, "pageSize", pageSize)
+		return nil, errors.New("invalid page or pageSize parameter")
+	}
+
+	hiddenAuthors := s.hiddenAuthors(viewerID)
+	s.postsMutex.RLock()
+	defer s.postsMutex.RUnlock()
+
+	skip := (page - 1) * pageSize
+	posts := []models.Post{}
+	for _, postID := range s.postOrder {
+		post := s.posts[postID]
+		if !post.VisibleTo(viewerID) || (post.Hidden && !includeHidden) || hiddenAuthors[post.UserID] {
+			continue
+		}
+		if communityID != nil && (post.CommunityID == nil || *post.CommunityID != *communityID) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		posts = append(posts, post)
+		if len(posts) == pageSize {
+			break
+		}
+	}
+
+	slog.Info("Listed visible posts", "page", page, "pageSize", pageSize)
+	return posts, nil
+}
+
+// SetPostStatus moves an unpublished post to status.
+func (s *InMemoryStorage) SetPostStatus(ctx context.Context, postID uuid.UUID, status models.PostStatus, publishAt *time.Time) error {
+	s.postsMutex.Lock()
+	defer s.postsMutex.Unlock()
+
+	post, exists := s.posts[postID]
+	if !exists {
+		slog.Warn("Post not found", "postID", postID)
+		return models.ErrPostNotFound
+	}
+	if post.Status == models.PostPublished {
+		return models.ErrPostAlreadyPublished
+	}
+
+	post.Status = status
+	post.PublishAt = nil
+	if publishAt != nil {
+		t := *publishAt
+		post.PublishAt = &t
+	}
+	s.posts[postID] = post
+
+	slog.Info("Post status changed", "postID", postID, "status", status)
+	return nil
+}
+
+// PublishDuePosts publishes every scheduled post due at now.
+func (s *InMemoryStorage) PublishDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	s.postsMutex.Lock()
+	defer s.postsMutex.Unlock()
+
+	var published []models.Post
+	for _, postID := range s.postOrder {
+		post := s.posts[postID]
+		if !isDue(post, now) {
+			continue
+		}
+		post.Status = models.PostPublished
+		s.posts[postID] = post
+		published = append(published, post)
+	}
+
+	if len(published) > 0 {
+		slog.Info("Published scheduled posts", "count", len(published))
+	}
+	return published, nil
+}
+
+// DuePosts returns the scheduled posts PublishDuePosts would publish at now,
+// without publishing them.
+func (s *InMemoryStorage) DuePosts(now time.Time) []models.Post {
+	s.postsMutex.RLock()
+	defer s.postsMutex.RUnlock()
+
+	var due []models.Post
+	for _, postID := range s.postOrder {
+		if post := s.posts[postID]; isDue(post, now) {
+			due = append(due, post)
+		}
+	}
+	return due
+}
+
+func isDue(post models.Post, now time.Time) bool {
+	return post.Status == models.PostScheduled && post.PublishAt != nil && !post.PublishAt.After(now)
+}
@@ -0,0 +1,108 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// AddUserRelation stores relation unless it exists.
+func (s *InMemoryStorage) AddUserRelation(ctx context.Context, relation models.UserRelation) error {
+	s.relationsMutex.Lock()
+	defer s.relationsMutex.Unlock()
+
+	for _, r := range s.relations {
+		if r.UserID == relation.UserID && r.TargetID == relation.TargetID && r.Kind == relation.Kind {
+			return nil
+		}
+	}
+	s.relations = append(s.relations, relation)
+	slog.Info("User relation added", "userID", relation.UserID, "targetID", relation.TargetID, "kind", relation.Kind)
+	return nil
+}
+
+// RemoveUserRelation removes userID's relation of kind to targetID, if any.
+func (s *InMemoryStorage) RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) error {
+	s.relationsMutex.Lock()
+	defer s.relationsMutex.Unlock()
+
+	for i, r := range s.relations {
+		if r.UserID == userID && r.TargetID == targetID && r.Kind == kind {
+			s.relations = append(s.relations[:i], s.relations[i+1:]...)
+			slog.Info("User relation removed", "userID", userID, "targetID", targetID, "kind", kind)
+			break
+		}
+	}
+	return nil
+}
+
+// ListUserRelations retrieves a page of userID's relations of kind, newest
+// first.
+func (s *InMemoryStorage) ListUserRelations(ctx context.Context, userID uuid.UUID, kind models.RelationKind, page, pageSize int) ([]models.UserRelation, error) {
+	if page <= 0 || pageSize <= 0 {
+		slog.Warn("Invalid page or pageSize parameter", "page", page, "pageSize", pageSize)
+		return nil, errors.New("invalid page or pageSize parameter")
+	}
+
+	s.relationsMutex.RLock()
+	defer s.relationsMutex.RUnlock()
+
+	skip := (page - 1) * pageSize
+	relations := []models.UserRelation{}
+	for i := len(s.relations) - 1; i >= 0; i-- {
+		r := s.relations[i]
+		if r.UserID != userID || r.Kind != kind {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		relations = append(relations, r)
+		if len(relations) == pageSize {
+			break
+		}
+	}
+	return relations, nil
+}
+
+// GetUserRelations returns what userID has set towards targetID.
+func (s *InMemoryStorage) GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (models.Relations, error) {
+	s.relationsMutex.RLock()
+	defer s.relationsMutex.RUnlock()
+
+	var relations models.Relations
+	for _, r := range s.relations {
+		if r.UserID != userID || r.TargetID != targetID {
+			continue
+		}
+		switch r.Kind {
+		case models.RelationMute:
+			relations.Muted = true
+		case models.RelationBlock:
+			relations.Blocked = true
+		}
+	}
+	return relations, nil
+}
+
+// hiddenAuthors returns the users whose content viewerID muted or blocked.
+func (s *InMemoryStorage) hiddenAuthors(viewerID uuid.UUID) map[uuid.UUID]bool {
+	if viewerID == uuid.Nil {
+		return nil
+	}
+
+	s.relationsMutex.RLock()
+	defer s.relationsMutex.RUnlock()
+
+	hidden := make(map[uuid.UUID]bool)
+	for _, r := range s.relations {
+		if r.UserID == viewerID {
+			hidden[r.TargetID] = true
+		}
+	}
+	return hidden
+}
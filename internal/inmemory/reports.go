@@ -0,0 +1,140 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// CreateReport adds an open report unless the reporter already has one
+// open on the same target.
+func (s *InMemoryStorage) CreateReport(ctx context.Context, report models.Report) error {
+	s.reportsMutex.Lock()
+	defer s.reportsMutex.Unlock()
+
+	if s.hasOpenReport(report) {
+		return models.ErrDuplicateReport
+	}
+	report.Status = models.ReportOpen
+	s.reports[report.ID] = report
+	s.reportOrder = append(s.reportOrder, report.ID)
+	slog.Info("Report created", "reportID", report.ID, "target", report.TargetType, "targetID", report.TargetID)
+	return nil
+}
+
+// HasOpenReport reports whether CreateReport would reject report as a
+// duplicate.
+func (s *InMemoryStorage) HasOpenReport(report models.Report) bool {
+	s.reportsMutex.RLock()
+	defer s.reportsMutex.RUnlock()
+	return s.hasOpenReport(report)
+}
+
+func (s *InMemoryStorage) hasOpenReport(report models.Report) bool {
+	for _, reportID := range s.reportOrder {
+		r := s.reports[reportID]
+		if r.Status == models.ReportOpen && r.ReporterID == report.ReporterID &&
+			r.TargetType == report.TargetType && r.TargetID == report.TargetID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReport retrieves a report by its ID.
+func (s *InMemoryStorage) GetReport(ctx context.Context, reportID uuid.UUID) (models.Report, error) {
+	s.reportsMutex.RLock()
+	defer s.reportsMutex.RUnlock()
+
+	report, ok := s.reports[reportID]
+	if !ok {
+		return models.Report{}, models.ErrReportNotFound
+	}
+	return report, nil
+}
+
+// ListReports retrieves a page of the reports matching filter, oldest
+// first.
+func (s *InMemoryStorage) ListReports(ctx context.Context, filter models.ReportFilter, page, pageSize int) ([]models.Report, error) {
+	if page <= 0 || pageSize <= 0 {
+		slog.Warn("Invalid page or pageSize parameter", "page", page, "pageSize", pageSize)
+		return nil, errors.New("invalid page or pageSize parameter")
+	}
+
+	s.reportsMutex.RLock()
+	defer s.reportsMutex.RUnlock()
+
+	skip := (page - 1) * pageSize
+	reports := []models.Report{}
+	for _, reportID := range s.reportOrder {
+		report := s.reports[reportID]
+		if !filter.Matches(report) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		reports = append(reports, report)
+		if len(reports) == pageSize {
+			break
+		}
+	}
+	return reports, nil
+}
+
+// CloseReport applies decision to an open report and the other open
+// reports on its target.
+func (s *InMemoryStorage) CloseReport(ctx context.Context, reportID uuid.UUID, decision models.ReportDecision) (models.Report, error) {
+	// Content is locked before reports, in the order Snapshot uses.
+	if decision.HideTarget {
+		s.postsMutex.Lock()
+		defer s.postsMutex.Unlock()
+		s.commentsMutex.Lock()
+		defer s.commentsMutex.Unlock()
+	}
+	s.reportsMutex.Lock()
+	defer s.reportsMutex.Unlock()
+
+	report, ok := s.reports[reportID]
+	if !ok {
+		return models.Report{}, models.ErrReportNotFound
+	}
+	if report.Status != models.ReportOpen {
+		return models.Report{}, models.ErrReportClosed
+	}
+
+	if decision.HideTarget {
+		switch report.TargetType {
+		case models.ReportPost:
+			if post, ok := s.posts[report.TargetID]; ok {
+				post.Hidden = true
+				s.posts[post.ID] = post
+			}
+		case models.ReportComment:
+			if comment, ok := s.comments[report.TargetID]; ok {
+				comment.Hidden = true
+				s.comments[comment.ID] = comment
+			}
+		}
+	}
+
+	moderatorID, at := decision.ModeratorID, decision.At
+	for _, id := range s.reportOrder {
+		r := s.reports[id]
+		if r.Status != models.ReportOpen || r.TargetType != report.TargetType || r.TargetID != report.TargetID {
+			continue
+		}
+		r.Status = decision.Status
+		r.ClosedBy = &moderatorID
+		r.ClosedAt = &at
+		r.TargetHidden = decision.HideTarget
+		s.reports[id] = r
+	}
+
+	slog.Info("Report closed", "reportID", reportID, "status", decision.Status, "hidden", decision.HideTarget)
+	return s.reports[reportID], nil
+}
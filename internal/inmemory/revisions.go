@@ -0,0 +1,47 @@
+package inmemory
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// ListPostRevisions returns a page of a post's revisions, newest first.
+func (s *InMemoryStorage) ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.PostRevision, error) {
+	s.postsMutex.RLock()
+	defer s.postsMutex.RUnlock()
+
+	if _, exists := s.posts[postID]; !exists {
+		slog.Warn("Post not found", "postID", postID)
+		return nil, models.ErrPostNotFound
+	}
+
+	revisions := s.revisions[postID]
+	start := (page - 1) * pageSize
+	if start < 0 || start >= len(revisions) {
+		return []models.PostRevision{}, nil
+	}
+	end := min(start+pageSize, len(revisions))
+
+	result := make([]models.PostRevision, 0, end-start)
+	for i := start; i < end; i++ {
+		result = append(result, revisions[len(revisions)-1-i])
+	}
+	return result, nil
+}
+
+// GetPostRevision returns a post as it was at version.
+func (s *InMemoryStorage) GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (models.PostRevision, error) {
+	s.postsMutex.RLock()
+	defer s.postsMutex.RUnlock()
+
+	for _, revision := range s.revisions[postID] {
+		if revision.Version == version {
+			return revision, nil
+		}
+	}
+	slog.Warn("Revision not found", "postID", postID, "version", version)
+	return models.PostRevision{}, models.ErrRevisionNotFound
+}
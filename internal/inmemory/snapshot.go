@@ -0,0 +1,202 @@
+package inmemory
+
+import (
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// State is a serialisable copy of everything held by InMemoryStorage.
+// Posts and comments are kept in insertion order so that pagination is
+// preserved across a Snapshot/Restore round trip.
+type State struct {
+	Posts     []models.Post                        `json:"posts"`
+	Comments  map[uuid.UUID][]models.Comment       `json:"comments"`
+	Structure map[uuid.UUID][]models.StructureTree `json:"structure"`
+
+	Revisions map[uuid.UUID][]models.PostRevision `json:"revisions,omitempty"`
+
+	IdempotencyKeys []models.IdempotencyKey `json:"idempotency_keys,omitempty"`
+
+	Usernames []models.Username `json:"usernames,omitempty"`
+	// Notifications are oldest first for each user.
+	Notifications []models.Notification `json:"notifications,omitempty"`
+
+	Webhooks []models.Webhook `json:"webhooks,omitempty"`
+	// WebhookDeliveries are oldest first for each webhook.
+	WebhookDeliveries []models.WebhookDelivery `json:"webhook_deliveries,omitempty"`
+
+	// Outbox is oldest first.
+	Outbox []models.OutboxEvent `json:"outbox,omitempty"`
+
+	// Reports are oldest first.
+	Reports []models.Report `json:"reports,omitempty"`
+
+	// Relations are oldest first.
+	Relations []models.UserRelation `json:"relations,omitempty"`
+
+	// Communities are oldest first.
+	Communities []models.Community `json:"communities,omitempty"`
+}
+
+// Snapshot returns a consistent copy of the storage contents.
+func (s *InMemoryStorage) Snapshot() State {
+	s.postsMutex.RLock()
+	defer s.postsMutex.RUnlock()
+	s.commentsMutex.RLock()
+	defer s.commentsMutex.RUnlock()
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+	s.notificationsMutex.RLock()
+	defer s.notificationsMutex.RUnlock()
+	s.webhooksMutex.RLock()
+	defer s.webhooksMutex.RUnlock()
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+	s.reportsMutex.RLock()
+	defer s.reportsMutex.RUnlock()
+	s.relationsMutex.RLock()
+	defer s.relationsMutex.RUnlock()
+	s.communitiesMutex.RLock()
+	defer s.communitiesMutex.RUnlock()
+
+	state := State{
+		Posts:     make([]models.Post, 0, len(s.postOrder)),
+		Comments:  make(map[uuid.UUID][]models.Comment, len(s.commentOrder)),
+		Structure: make(map[uuid.UUID][]models.StructureTree, len(s.structure)),
+		Revisions: make(map[uuid.UUID][]models.PostRevision, len(s.revisions)),
+	}
+	for _, postID := range s.postOrder {
+		state.Posts = append(state.Posts, s.posts[postID])
+	}
+	for postID, commentIDs := range s.commentOrder {
+		comments := make([]models.Comment, 0, len(commentIDs))
+		for _, commentID := range commentIDs {
+			comments = append(comments, s.comments[commentID])
+		}
+		state.Comments[postID] = comments
+	}
+	for postID, rows := range s.structure {
+		state.Structure[postID] = append([]models.StructureTree(nil), rows...)
+	}
+	for postID, revisions := range s.revisions {
+		state.Revisions[postID] = append([]models.PostRevision(nil), revisions...)
+	}
+	for _, key := range s.idempotencyKeys {
+		state.IdempotencyKeys = append(state.IdempotencyKeys, key)
+	}
+	for name, userID := range s.usernames {
+		state.Usernames = append(state.Usernames, models.Username{UserID: userID, Name: name})
+	}
+	for _, notifications := range s.notifications {
+		state.Notifications = append(state.Notifications, notifications...)
+	}
+	state.Webhooks = append(state.Webhooks, s.webhooks...)
+	for _, deliveries := range s.webhookDeliveries {
+		state.WebhookDeliveries = append(state.WebhookDeliveries, deliveries...)
+	}
+	state.Outbox = append(state.Outbox, s.outbox...)
+	for _, reportID := range s.reportOrder {
+		state.Reports = append(state.Reports, s.reports[reportID])
+	}
+	state.Relations = append(state.Relations, s.relations...)
+	for _, communityID := range s.communityOrder {
+		state.Communities = append(state.Communities, copyCommunity(s.communities[communityID]))
+	}
+	return state
+}
+
+// Restore replaces the storage contents with state.
+func (s *InMemoryStorage) Restore(state State) {
+	s.postsMutex.Lock()
+	defer s.postsMutex.Unlock()
+	s.commentsMutex.Lock()
+	defer s.commentsMutex.Unlock()
+	s.idempotencyMutex.Lock()
+	defer s.idempotencyMutex.Unlock()
+	s.notificationsMutex.Lock()
+	defer s.notificationsMutex.Unlock()
+	s.webhooksMutex.Lock()
+	defer s.webhooksMutex.Unlock()
+	s.outboxMutex.Lock()
+	defer s.outboxMutex.Unlock()
+	s.reportsMutex.Lock()
+	defer s.reportsMutex.Unlock()
+	s.relationsMutex.Lock()
+	defer s.relationsMutex.Unlock()
+	s.communitiesMutex.Lock()
+	defer s.communitiesMutex.Unlock()
+
+	s.posts = make(map[uuid.UUID]models.Post, len(state.Posts))
+	s.postOrder = make([]uuid.UUID, 0, len(state.Posts))
+	s.revisions = make(map[uuid.UUID][]models.PostRevision, len(state.Posts))
+	for _, post := range state.Posts {
+		post = post.WithDefaultStatus()
+		s.posts[post.ID] = post
+		s.postOrder = append(s.postOrder, post.ID)
+
+		revisions := append([]models.PostRevision(nil), state.Revisions[post.ID]...)
+		if len(revisions) == 0 {
+			// Snapshots taken before revisions were recorded only know
+			// the current version.
+			revisions = append(revisions, models.RevisionOf(post))
+		}
+		s.revisions[post.ID] = revisions
+	}
+
+	s.comments = make(map[uuid.UUID]models.Comment)
+	s.commentOrder = make(map[uuid.UUID][]uuid.UUID, len(state.Comments))
+	for postID, comments := range state.Comments {
+		for _, comment := range comments {
+			s.comments[comment.ID] = comment
+			s.commentOrder[postID] = append(s.commentOrder[postID], comment.ID)
+		}
+	}
+
+	s.structure = make(map[uuid.UUID][]models.StructureTree, len(state.Structure))
+	for postID, rows := range state.Structure {
+		s.structure[postID] = append([]models.StructureTree(nil), rows...)
+	}
+
+	s.idempotencyKeys = make(map[idempotencyScope]models.IdempotencyKey, len(state.IdempotencyKeys))
+	for _, key := range state.IdempotencyKeys {
+		s.idempotencyKeys[idempotencyScope{key.UserID, key.Key}] = key
+	}
+
+	s.usernames = make(map[string]uuid.UUID, len(state.Usernames))
+	s.usernameOf = make(map[uuid.UUID]string, len(state.Usernames))
+	for _, u := range state.Usernames {
+		s.usernames[u.Name] = u.UserID
+		s.usernameOf[u.UserID] = u.Name
+	}
+	s.notifications = make(map[uuid.UUID][]models.Notification)
+	for _, n := range state.Notifications {
+		s.notifications[n.UserID] = append(s.notifications[n.UserID], n)
+	}
+
+	s.webhooks = append([]models.Webhook(nil), state.Webhooks...)
+	s.webhookDeliveries = make(map[uuid.UUID][]models.WebhookDelivery)
+	for _, d := range state.WebhookDeliveries {
+		s.webhookDeliveries[d.WebhookID] = append(s.webhookDeliveries[d.WebhookID], d)
+	}
+
+	s.outbox = append([]models.OutboxEvent(nil), state.Outbox...)
+
+	s.reports = make(map[uuid.UUID]models.Report, len(state.Reports))
+	s.reportOrder = make([]uuid.UUID, 0, len(state.Reports))
+	for _, report := range state.Reports {
+		s.reports[report.ID] = report
+		s.reportOrder = append(s.reportOrder, report.ID)
+	}
+
+	s.relations = append([]models.UserRelation(nil), state.Relations...)
+
+	s.communities = make(map[uuid.UUID]models.Community, len(state.Communities))
+	s.communitySlugs = make(map[string]uuid.UUID, len(state.Communities))
+	s.communityOrder = make([]uuid.UUID, 0, len(state.Communities))
+	for _, community := range state.Communities {
+		s.communities[community.ID] = copyCommunity(community)
+		s.communitySlugs[community.Slug] = community.ID
+		s.communityOrder = append(s.communityOrder, community.ID)
+	}
+}
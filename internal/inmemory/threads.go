@@ -0,0 +1,132 @@
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// SetCommentDepthLimit configures how deeply CreateComment lets comments nest.
+func (s *InMemoryStorage) SetCommentDepthLimit(limit models.DepthLimit) {
+	s.commentsMutex.Lock()
+	defer s.commentsMutex.Unlock()
+	s.depthLimit = limit
+}
+
+// LockThread locks a comment so that it and its descendants accept no new
+// replies.
+func (s *InMemoryStorage) LockThread(ctx context.Context, commentID uuid.UUID) error {
+	s.commentsMutex.Lock()
+	defer s.commentsMutex.Unlock()
+
+	comment, exists := s.comments[commentID]
+	if !exists {
+		slog.Warn("Comment not found", "commentID", commentID)
+		return models.ErrCommentNotFound
+	}
+	comment.Locked = true
+	s.comments[commentID] = comment
+
+	slog.Info("Thread locked", "commentID", commentID)
+	return nil
+}
+
+// PlaceComment returns comment as CreateComment would store it under limit,
+// or the error CreateComment would fail with.
+func (s *InMemoryStorage) PlaceComment(comment models.Comment, limit models.DepthLimit) (models.Comment, error) {
+	s.commentsMutex.RLock()
+	defer s.commentsMutex.RUnlock()
+	return s.placeComment(comment, limit)
+}
+
+// placeComment checks that comment's parent exists on the same post and
+// takes replies, and
+// moves the comment up the thread if limit requires it. The caller must
+// hold commentsMutex.
+func (s *InMemoryStorage) placeComment(comment models.Comment, limit models.DepthLimit) (models.Comment, error) {
+	if comment.ParentID == nil {
+		return comment, nil
+	}
+	parent, exists := s.comments[*comment.ParentID]
+	if !exists || parent.PostID != comment.PostID {
+		slog.Warn("Parent comment not found", "parentID", comment.ParentID, "postID", comment.PostID)
+		return comment, models.ErrParentNotFound
+	}
+
+	chain := s.ancestors(parent)
+	for _, ancestor := range chain {
+		if ancestor.Locked {
+			slog.Warn("Reply to locked thread", "parentID", parent.ID)
+			return comment, models.ErrThreadLocked
+		}
+	}
+	up, err := limit.Place(len(chain) - 1)
+	if err != nil {
+		slog.Warn("Reply nested too deeply", "parentID", parent.ID, "maxDepth", limit.MaxDepth)
+		return comment, err
+	}
+
+	// Do not share the caller's pointer, which it may reuse.
+	parentID := chain[up].ID
+	comment.ParentID = &parentID
+	return comment, nil
+}
+
+// ancestors returns comment followed by its ancestors up to the top-level
+// comment, so that a comment's depth is len(ancestors)-1. The caller must
+// hold commentsMutex.
+func (s *InMemoryStorage) ancestors(comment models.Comment) []models.Comment {
+	chain := []models.Comment{comment}
+	for comment.ParentID != nil {
+		parent, exists := s.comments[*comment.ParentID]
+		if !exists {
+			break
+		}
+		chain = append(chain, parent)
+		comment = parent
+	}
+	return chain
+}
+
+// ListThread retrieves a page of a post's comments no deeper than maxDepth,
+// oldest first, counting the replies hidden below the cut.
+func (s *InMemoryStorage) ListThread(ctx context.Context, postID uuid.UUID, maxDepth, page, pageSize int) ([]models.ThreadComment, error) {
+	if page <= 0 || pageSize <= 0 || maxDepth < 0 {
+		slog.Warn("Invalid thread parameters", "page", page, "pageSize", pageSize, "maxDepth", maxDepth)
+		return nil, errors.New("invalid page, pageSize or maxDepth parameter")
+	}
+
+	s.commentsMutex.RLock()
+	defer s.commentsMutex.RUnlock()
+
+	var visible []models.ThreadComment
+	index := make(map[uuid.UUID]int)
+	hidden := make(map[uuid.UUID]int)
+	for _, commentID := range s.commentOrder[postID] {
+		chain := s.ancestors(s.comments[commentID])
+		depth := len(chain) - 1
+		if depth > maxDepth {
+			hidden[chain[depth-maxDepth].ID]++
+			continue
+		}
+		index[commentID] = len(visible)
+		visible = append(visible, models.ThreadComment{Comment: chain[0], Depth: depth})
+	}
+	for commentID, count := range hidden {
+		if i, ok := index[commentID]; ok {
+			visible[i].MoreReplies = count
+		}
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(visible) {
+		return []models.ThreadComment{}, nil
+	}
+	end := min(start+pageSize, len(visible))
+
+	slog.Info("Listed thread for post", "postID", postID, "maxDepth", maxDepth, "page", page, "pageSize", pageSize)
+	return visible[start:end], nil
+}
@@ -0,0 +1,89 @@
+package inmemory
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CreateWebhook adds a webhook.
+func (s *InMemoryStorage) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	s.webhooksMutex.Lock()
+	defer s.webhooksMutex.Unlock()
+
+	webhook.Events = append([]models.WebhookEvent(nil), webhook.Events...)
+	s.webhooks = append(s.webhooks, webhook)
+	return nil
+}
+
+// ListWebhooks returns every webhook, oldest first.
+func (s *InMemoryStorage) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	s.webhooksMutex.RLock()
+	defer s.webhooksMutex.RUnlock()
+
+	webhooks := make([]models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhook.Events = append([]models.WebhookEvent(nil), webhook.Events...)
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries.
+func (s *InMemoryStorage) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	s.webhooksMutex.Lock()
+	defer s.webhooksMutex.Unlock()
+
+	for i, webhook := range s.webhooks {
+		if webhook.ID == webhookID {
+			s.webhooks = append(s.webhooks[:i:i], s.webhooks[i+1:]...)
+			delete(s.webhookDeliveries, webhookID)
+			return nil
+		}
+	}
+	return models.ErrWebhookNotFound
+}
+
+// CreateWebhookDelivery records a delivery attempt of an existing webhook.
+func (s *InMemoryStorage) CreateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.webhooksMutex.Lock()
+	defer s.webhooksMutex.Unlock()
+
+	if !s.hasWebhook(delivery.WebhookID) {
+		return models.ErrWebhookNotFound
+	}
+	s.webhookDeliveries[delivery.WebhookID] = append(s.webhookDeliveries[delivery.WebhookID], delivery)
+	return nil
+}
+
+// ListWebhookDeliveries returns a page of a webhook's deliveries, newest
+// first.
+func (s *InMemoryStorage) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) ([]models.WebhookDelivery, error) {
+	s.webhooksMutex.RLock()
+	defer s.webhooksMutex.RUnlock()
+
+	result := []models.WebhookDelivery{}
+	all := s.webhookDeliveries[webhookID]
+	for i := len(all) - 1 - (page-1)*pageSize; i >= 0 && len(result) < pageSize; i-- {
+		result = append(result, all[i])
+	}
+	return result, nil
+}
+
+// HasWebhook reports whether a webhook exists, so that FileStorage can
+// reject deliveries to deleted webhooks before logging them.
+func (s *InMemoryStorage) HasWebhook(webhookID uuid.UUID) bool {
+	s.webhooksMutex.RLock()
+	defer s.webhooksMutex.RUnlock()
+	return s.hasWebhook(webhookID)
+}
+
+func (s *InMemoryStorage) hasWebhook(webhookID uuid.UUID) bool {
+	for _, webhook := range s.webhooks {
+		if webhook.ID == webhookID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+)
+
+// client sends GraphQL operations over HTTP.
+type client struct {
+	url     string
+	headers http.Header
+	http    *http.Client
+}
+
+type gqlRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []gqlError      `json:"errors"`
+}
+
+// do executes query and decodes its data into out, which may be nil.
+func (c *client) do(ctx context.Context, query string, variables map[string]any, out any) error {
+	body, err := json.Marshal(gqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var res gqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if len(res.Errors) > 0 {
+		return errors.New(res.Errors[0].Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Data, out)
+}
+
+// Message types of the graphql-transport-ws protocol.
+const (
+	msgConnectionInit = "connection_init"
+	msgConnectionAck  = "connection_ack"
+	msgSubscribe      = "subscribe"
+	msgNext           = "next"
+	msgError          = "error"
+	msgComplete       = "complete"
+	msgPing           = "ping"
+	msgPong           = "pong"
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscription is a single graphql-transport-ws subscription.
+type subscription struct {
+	conn *websocket.Conn
+}
+
+// subscribe opens a websocket to the endpoint and starts query. Headers are
+// sent in the connection_init payload, which is where the server looks for
+// credentials on websocket connections.
+func (c *client) subscribe(ctx context.Context, query string, variables map[string]any) (*subscription, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", u, err)
+	}
+
+	init := make(map[string]string, len(c.headers))
+	for name := range c.headers {
+		init[name] = c.headers.Get(name)
+	}
+	if err := writeMessage(conn, "", msgConnectionInit, init); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var ack wsMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ack.Type != msgConnectionAck {
+		conn.Close()
+		return nil, fmt.Errorf("expected %s, got %s: %s", msgConnectionAck, ack.Type, ack.Payload)
+	}
+
+	if err := writeMessage(conn, "1", msgSubscribe, gqlRequest{Query: query, Variables: variables}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &subscription{conn: conn}, nil
+}
+
+func writeMessage(conn *websocket.Conn, id, typ string, payload any) error {
+	msg := wsMessage{ID: id, Type: typ}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = raw
+	}
+	return conn.WriteJSON(msg)
+}
+
+// next blocks until the next result and decodes its data into out.
+func (s *subscription) next(out any) error {
+	for {
+		var msg wsMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case msgPing:
+			if err := writeMessage(s.conn, "", msgPong, nil); err != nil {
+				return err
+			}
+		case msgPong:
+		case msgNext:
+			var res gqlResponse
+			if err := json.Unmarshal(msg.Payload, &res); err != nil {
+				return err
+			}
+			if len(res.Errors) > 0 {
+				return errors.New(res.Errors[0].Message)
+			}
+			return json.Unmarshal(res.Data, out)
+		case msgError:
+			return fmt.Errorf("subscription error: %s", msg.Payload)
+		case msgComplete:
+			return errors.New("subscription completed by server")
+		default:
+			return fmt.Errorf("unexpected message type %q", msg.Type)
+		}
+	}
+}
+
+func (s *subscription) close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,302 @@
+// Package loadgen drives a running GraphQL server with a mix of queries,
+// mutations and commentAdded subscribers, and reports latency percentiles.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Options controls the generated load.
+type Options struct {
+	// URL is the GraphQL endpoint, e.g. http://localhost:8080/query.
+	URL string
+	// Headers are sent with every request and in the websocket
+	// connection_init payload, e.g. for authentication.
+	Headers http.Header
+	// Duration is how long workers keep sending requests.
+	Duration time.Duration
+	// Workers is the number of concurrent request loops.
+	Workers int
+	// Subscribers is the number of concurrent commentAdded subscriptions.
+	Subscribers int
+	// MutationRatio is the fraction of requests that create content.
+	MutationRatio float64
+	// PageSize is used for list queries.
+	PageSize int
+	// Seed makes the operation mix reproducible.
+	Seed int64
+}
+
+// DefaultOptions returns a light load for a local server.
+func DefaultOptions() Options {
+	return Options{
+		URL:           "http://localhost:8080/query",
+		Duration:      30 * time.Second,
+		Workers:       8,
+		Subscribers:   16,
+		MutationRatio: 0.1,
+		PageSize:      20,
+		Seed:          1,
+	}
+}
+
+// Operation names used in the report.
+const (
+	OpListPosts     = "query posts"
+	OpGetPost       = "query post"
+	OpListComments  = "query comments"
+	OpCreatePost    = "mutation createPost"
+	OpCreateComment = "mutation createComment"
+	// OpDelivery measures the time from sending createComment to receiving
+	// the comment on a commentAdded subscription.
+	OpDelivery = "subscription commentAdded"
+)
+
+// contentPrefix marks comments created by the load generator; the send time
+// follows it so that subscribers can measure delivery latency.
+const contentPrefix = "loadgen "
+
+const (
+	listPostsQuery     = `query($page: Int!, $pageSize: Int!) { posts(page: $page, pageSize: $pageSize) { id } }`
+	getPostQuery       = `query($id: ID!) { post(id: $id) { id title content } }`
+	listCommentsQuery  = `query($postId: ID!, $page: Int!, $pageSize: Int!) { comments(postId: $postId, page: $page, pageSize: $pageSize) { id parentId } }`
+	createPostQuery    = `mutation($title: String!, $content: String!, $userId: ID!) { createPost(title: $title, content: $content, userId: $userId) { id } }`
+	createCommentQuery = `mutation($postId: ID!, $parentId: ID, $content: String!, $userId: ID!) { createComment(postId: $postId, parentId: $parentId, content: $content, userId: $userId) { id } }`
+	commentAddedQuery  = `subscription($postId: ID!) { commentAdded(postId: $postId) { id content } }`
+)
+
+type idResult struct {
+	ID string `json:"id"`
+}
+
+// Run generates load until opts.Duration elapses or ctx is cancelled.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Workers < 1 {
+		return nil, errors.New("workers must be positive")
+	}
+	if opts.PageSize < 1 {
+		return nil, errors.New("page size must be positive")
+	}
+
+	c := &client{url: opts.URL, headers: opts.Headers, http: &http.Client{Timeout: 30 * time.Second}}
+	l := &loadgen{opts: opts, client: c, recorder: newRecorder()}
+
+	if err := l.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	subCtx, stopSubscribers := context.WithCancel(ctx)
+	defer stopSubscribers()
+	var subscribers sync.WaitGroup
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	for i := 0; i < opts.Subscribers; i++ {
+		sub, err := c.subscribe(subCtx, commentAddedQuery, map[string]any{"postId": l.pickPost(rnd)})
+		if err != nil {
+			return nil, fmt.Errorf("subscribe: %w", err)
+		}
+		subscribers.Add(1)
+		go func() {
+			defer subscribers.Done()
+			l.receive(subCtx, sub)
+		}()
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	start := time.Now()
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func(seed int64) {
+			defer workers.Done()
+			l.work(runCtx, rand.New(rand.NewSource(seed)))
+		}(opts.Seed + int64(i) + 1)
+	}
+	workers.Wait()
+	elapsed := time.Since(start)
+
+	// Give in-flight deliveries a moment before hanging up.
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-ctx.Done():
+	}
+	stopSubscribers()
+	subscribers.Wait()
+
+	return l.recorder.report(elapsed), nil
+}
+
+type loadgen struct {
+	opts     Options
+	client   *client
+	recorder *recorder
+
+	mu       sync.Mutex
+	posts    []string
+	comments map[string][]string // recent comment IDs by post
+}
+
+// discover loads existing post IDs, creating a few posts if there are none.
+func (l *loadgen) discover(ctx context.Context) error {
+	var res struct {
+		Posts []idResult `json:"posts"`
+	}
+	if err := l.client.do(ctx, listPostsQuery, map[string]any{"page": 1, "pageSize": l.opts.PageSize}, &res); err != nil {
+		return fmt.Errorf("list posts: %w", err)
+	}
+
+	l.comments = make(map[string][]string)
+	for _, post := range res.Posts {
+		l.posts = append(l.posts, post.ID)
+	}
+	for len(l.posts) < l.opts.Workers {
+		var created struct {
+			CreatePost idResult `json:"createPost"`
+		}
+		vars := map[string]any{"title": "loadgen", "content": "loadgen", "userId": uuid.NewString()}
+		if err := l.client.do(ctx, createPostQuery, vars, &created); err != nil {
+			return fmt.Errorf("create post: %w", err)
+		}
+		l.posts = append(l.posts, created.CreatePost.ID)
+	}
+	slog.Info("Load generator ready", "posts", len(l.posts))
+	return nil
+}
+
+// pickPost chooses a post, favouring the newest ones like real traffic does.
+func (l *loadgen) pickPost(r *rand.Rand) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := int(r.ExpFloat64() * float64(len(l.posts)) / 4)
+	if i >= len(l.posts) {
+		i = r.Intn(len(l.posts))
+	}
+	return l.posts[i]
+}
+
+func (l *loadgen) work(ctx context.Context, r *rand.Rand) {
+	userID := uuid.NewString()
+	for ctx.Err() == nil {
+		if r.Float64() < l.opts.MutationRatio {
+			if r.Float64() < 0.05 {
+				l.createPost(ctx, userID)
+			} else {
+				l.createComment(ctx, r, userID)
+			}
+			continue
+		}
+
+		switch r.Intn(3) {
+		case 0:
+			vars := map[string]any{"page": 1 + r.Intn(3), "pageSize": l.opts.PageSize}
+			l.timed(ctx, OpListPosts, func() error { return l.client.do(ctx, listPostsQuery, vars, nil) })
+		case 1:
+			vars := map[string]any{"id": l.pickPost(r)}
+			l.timed(ctx, OpGetPost, func() error { return l.client.do(ctx, getPostQuery, vars, nil) })
+		default:
+			vars := map[string]any{"postId": l.pickPost(r), "page": 1, "pageSize": l.opts.PageSize}
+			l.timed(ctx, OpListComments, func() error { return l.client.do(ctx, listCommentsQuery, vars, nil) })
+		}
+	}
+}
+
+func (l *loadgen) createPost(ctx context.Context, userID string) {
+	var res struct {
+		CreatePost idResult `json:"createPost"`
+	}
+	vars := map[string]any{"title": "loadgen", "content": "loadgen", "userId": userID}
+	if l.timed(ctx, OpCreatePost, func() error { return l.client.do(ctx, createPostQuery, vars, &res) }) {
+		l.mu.Lock()
+		l.posts = append([]string{res.CreatePost.ID}, l.posts...)
+		l.mu.Unlock()
+	}
+}
+
+func (l *loadgen) createComment(ctx context.Context, r *rand.Rand, userID string) {
+	postID := l.pickPost(r)
+	vars := map[string]any{
+		"postId":  postID,
+		"content": contentPrefix + strconv.FormatInt(time.Now().UnixNano(), 10),
+		"userId":  userID,
+	}
+
+	// Most comments reply to a recent one, which grows deep threads.
+	l.mu.Lock()
+	recent := l.comments[postID]
+	if len(recent) > 0 && r.Float64() < 0.7 {
+		vars["parentId"] = recent[len(recent)-1-r.Intn(len(recent))]
+	}
+	l.mu.Unlock()
+
+	var res struct {
+		CreateComment idResult `json:"createComment"`
+	}
+	if l.timed(ctx, OpCreateComment, func() error { return l.client.do(ctx, createCommentQuery, vars, &res) }) {
+		l.mu.Lock()
+		recent = append(l.comments[postID], res.CreateComment.ID)
+		if len(recent) > 20 {
+			recent = recent[len(recent)-20:]
+		}
+		l.comments[postID] = recent
+		l.mu.Unlock()
+	}
+}
+
+// timed runs op and records its latency; it reports whether op succeeded.
+// Operations cut short by the end of the run are not recorded.
+func (l *loadgen) timed(ctx context.Context, name string, op func() error) bool {
+	start := time.Now()
+	err := op()
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+	l.recorder.record(name, time.Since(start), err)
+	return err == nil
+}
+
+// receive records the delivery latency of every comment the load generator
+// created that arrives on sub.
+func (l *loadgen) receive(ctx context.Context, sub *subscription) {
+	stop := context.AfterFunc(ctx, func() { sub.close() })
+	defer stop()
+	defer sub.close()
+
+	for {
+		var res struct {
+			CommentAdded struct {
+				ID      string `json:"id"`
+				Content string `json:"content"`
+			} `json:"commentAdded"`
+		}
+		err := sub.next(&res)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			l.recorder.record(OpDelivery, 0, err)
+			slog.Warn("Subscription failed", "error", err)
+			return
+		}
+
+		sent, ok := strings.CutPrefix(res.CommentAdded.Content, contentPrefix)
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(sent, 10, 64)
+		if err != nil {
+			continue
+		}
+		l.recorder.record(OpDelivery, time.Since(time.Unix(0, nanos)), nil)
+	}
+}
@@ -0,0 +1,54 @@
+package loadgen_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/loadgen"
+	"ozon-test/internal/pubsub"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	resolver := &gql.Resolver{Storage: inmemory.NewInMemoryStorage(), PubSub: pubsub.NewInMemoryPubSub()}
+	server := httptest.NewServer(handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: resolver})))
+	defer server.Close()
+
+	opts := loadgen.DefaultOptions()
+	opts.URL = server.URL
+	opts.Duration = 300 * time.Millisecond
+	opts.Workers = 2
+	opts.Subscribers = 2
+	opts.MutationRatio = 0.5
+
+	report, err := loadgen.Run(context.Background(), opts)
+	require.NoError(t, err)
+
+	for _, name := range []string{loadgen.OpCreateComment, loadgen.OpListComments, loadgen.OpDelivery} {
+		op, ok := report.Op(name)
+		require.True(t, ok, "%s should be recorded", name)
+		assert.NotZero(t, op.Count, name)
+		assert.Zero(t, op.Errors, name)
+		assert.LessOrEqual(t, op.P50, op.P99, name)
+		assert.LessOrEqual(t, op.P99, op.Max, name)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "p99")
+}
+
+func TestRunRejectsUnreachableServer(t *testing.T) {
+	opts := loadgen.DefaultOptions()
+	opts.URL = "http://127.0.0.1:1/query"
+	_, err := loadgen.Run(context.Background(), opts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// OpStats summarises one kind of operation.
+type OpStats struct {
+	Name   string
+	Count  int
+	Errors int
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// Report is the outcome of a load run.
+type Report struct {
+	Elapsed time.Duration
+	Ops     []OpStats
+}
+
+// Op returns the statistics for name, if any were recorded.
+func (r *Report) Op(name string) (OpStats, bool) {
+	for _, op := range r.Ops {
+		if op.Name == name {
+			return op, true
+		}
+	}
+	return OpStats{}, false
+}
+
+// Write prints the report as a table.
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "operation\tcount\terrors\trate/s\tp50\tp90\tp99\tmax\t\n")
+	for _, op := range r.Ops {
+		rate := float64(op.Count) / r.Elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			op.Name, op.Count, op.Errors, rate, round(op.P50), round(op.P90), round(op.P99), round(op.Max))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// recorder collects latencies from concurrent workers.
+type recorder struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func newRecorder() *recorder {
+	return &recorder{series: make(map[string]*series)}
+}
+
+// record adds a sample for name; failed operations only count as errors.
+func (r *recorder) record(name string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.series[name]
+	if !ok {
+		s = &series{}
+		r.series[name] = s
+	}
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+func (r *recorder) report(elapsed time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{Elapsed: elapsed}
+	for name, s := range r.series {
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		op := OpStats{Name: name, Count: len(s.latencies), Errors: s.errors}
+		if n := len(s.latencies); n > 0 {
+			op.P50 = percentile(s.latencies, 0.50)
+			op.P90 = percentile(s.latencies, 0.90)
+			op.P99 = percentile(s.latencies, 0.99)
+			op.Max = s.latencies[n-1]
+		}
+		report.Ops = append(report.Ops, op)
+	}
+	sort.Slice(report.Ops, func(i, j int) bool { return report.Ops[i].Name < report.Ops[j].Name })
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
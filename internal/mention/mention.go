@@ -0,0 +1,51 @@
+// Package mention finds @username mentions in post and comment text.
+package mention
+
+import (
+	"fmt"
+	"ozon-test/internal/validation"
+	"regexp"
+	"strings"
+)
+
+const (
+	minLength = 3
+	maxLength = 30
+)
+
+var (
+	usernamePattern = regexp.MustCompile(fmt.Sprintf(`^[a-z0-9_]{%d,%d}$`, minLength, maxLength))
+	// A mention starts the text or follows a character that cannot be part
+	// of a name or an e-mail address, and must not run into more name
+	// characters.
+	mentionPattern = regexp.MustCompile(fmt.Sprintf(`(?:^|[^A-Za-z0-9_@.])@([A-Za-z0-9_]{%d,%d})\b`, minLength, maxLength))
+)
+
+// Username normalises a username to lower case without a leading @. It
+// returns a *validation.Error unless the name is 3 to 30 letters, digits or
+// underscores.
+func Username(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+	if !usernamePattern.MatchString(name) {
+		return "", &validation.Error{Fields: []validation.FieldError{{
+			Field:   "username",
+			Message: fmt.Sprintf("must be %d to %d letters, digits or underscores", minLength, maxLength),
+		}}}
+	}
+	return name, nil
+}
+
+// Parse returns the usernames mentioned in text, in lower case, each once,
+// in order of first mention.
+func Parse(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
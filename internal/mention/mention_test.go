@@ -0,0 +1,37 @@
+package mention_test
+
+import (
+	"testing"
+
+	"ozon-test/internal/mention"
+	"ozon-test/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for text, want := range map[string][]string{
+		"@alice hi":                                    {"alice"},
+		"cc @Alice, @bob_2 and @ALICE.":                {"alice", "bob_2"},
+		"(@carol) **@dave**":                           {"carol", "dave"},
+		"mail me at me@example.com":                    nil,
+		"@al is too short, @@eve doubled":              nil,
+		"a lone @":                                     nil,
+		"@abcdefghijklmnopqrstuvwxyz12345 is too long": nil,
+	} {
+		assert.Equal(t, want, mention.Parse(text), text)
+	}
+}
+
+func TestUsername(t *testing.T) {
+	name, err := mention.Username(" @Alice_1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "alice_1", name)
+
+	for _, bad := range []string{"al", "alice smith", "алиса", "a-b-c"} {
+		_, err := mention.Username(bad)
+		var verr *validation.Error
+		assert.ErrorAs(t, err, &verr, bad)
+	}
+}
@@ -15,6 +15,85 @@ type Post struct {
 	UserID        uuid.UUID `db:"user_id" json:"user_id"`
 	AllowComments bool      `db:"allow_comments" json:"allow_comments"`
 	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	// Version is incremented by every update and guards against lost
+	// updates; new posts start at 1.
+	Version int `db:"version" json:"version"`
+	// UpdatedAt and UpdatedBy record who produced the current version; for
+	// a new post they are CreatedAt and UserID.
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	UpdatedBy uuid.UUID `db:"updated_by" json:"updated_by"`
+	// Status controls who can see the post; CreatePost treats an empty
+	// status as PostPublished. PublishAt is when a scheduled post goes
+	// live, or when a published post did; it is nil for drafts.
+	Status    PostStatus `db:"status" json:"status"`
+	PublishAt *time.Time `db:"publish_at" json:"publish_at,omitempty"`
+	// Format is how Content is rendered; it is fixed when the post is
+	// created.
+	Format ContentFormat `db:"format" json:"format,omitempty"`
+	// Hidden posts were taken down by a moderator; see CloseReport.
+	Hidden bool `db:"hidden" json:"hidden,omitempty"`
+	// CommunityID is the community the post was made in, or nil for posts
+	// outside any community. It is fixed when the post is created.
+	CommunityID *uuid.UUID `db:"community_id" json:"community_id,omitempty"`
+}
+
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "DRAFT"
+	PostScheduled PostStatus = "SCHEDULED"
+	PostPublished PostStatus = "PUBLISHED"
+)
+
+// ContentFormat says how the content of a post or comment is rendered to
+// HTML. The empty format renders like FormatPlain.
+type ContentFormat string
+
+const (
+	FormatPlain    ContentFormat = "PLAIN"
+	FormatMarkdown ContentFormat = "MARKDOWN"
+)
+
+// WithDefaultStatus returns p, published at its creation time if it has no
+// status, so that callers that predate statuses keep publishing immediately.
+func (p Post) WithDefaultStatus() Post {
+	if p.Status == "" {
+		p.Status = PostPublished
+		publishAt := p.CreatedAt
+		p.PublishAt = &publishAt
+	}
+	return p
+}
+
+// VisibleTo reports whether viewerID may see post. Unpublished posts are
+// only visible to their author.
+func (p Post) VisibleTo(viewerID uuid.UUID) bool {
+	return p.Status == PostPublished || (viewerID != uuid.Nil && p.UserID == viewerID)
+}
+
+// PostRevision is a post as it was at one version. CreatePost and
+// UpdatePost record one for every version they store.
+type PostRevision struct {
+	PostID        uuid.UUID `db:"post_id" json:"post_id"`
+	Version       int       `db:"version" json:"version"`
+	Title         string    `db:"title" json:"title"`
+	Content       string    `db:"content" json:"content"`
+	AllowComments bool      `db:"allow_comments" json:"allow_comments"`
+	EditorID      uuid.UUID `db:"editor_id" json:"editor_id"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// RevisionOf returns the revision describing post at its current version.
+func RevisionOf(post Post) PostRevision {
+	return PostRevision{
+		PostID:        post.ID,
+		Version:       post.Version,
+		Title:         post.Title,
+		Content:       post.Content,
+		AllowComments: post.AllowComments,
+		EditorID:      post.UpdatedBy,
+		CreatedAt:     post.UpdatedAt,
+	}
 }
 
 type Comment struct {
@@ -24,6 +103,51 @@ type Comment struct {
 	Content   string     `db:"content" json:"content"`
 	UserID    uuid.UUID  `db:"user_id" json:"user_id"`
 	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	// Locked comments accept no new replies anywhere beneath them.
+	Locked bool          `db:"locked" json:"locked,omitempty"`
+	Format ContentFormat `db:"format" json:"format,omitempty"`
+	// Hidden comments were taken down by a moderator; see CloseReport.
+	Hidden bool `db:"hidden" json:"hidden,omitempty"`
+}
+
+// ThreadComment is a comment in a depth-limited view of a post's thread.
+// Depth is 0 for top-level comments. MoreReplies counts the replies below
+// the view's depth limit, and is only set at that depth.
+type ThreadComment struct {
+	Comment
+	Depth       int `db:"depth" json:"depth"`
+	MoreReplies int `db:"more_replies" json:"more_replies"`
+}
+
+// DepthPolicy says what CreateComment does with a reply deeper than a
+// DepthLimit allows.
+type DepthPolicy string
+
+const (
+	// DepthReject fails the reply with ErrCommentTooDeep.
+	DepthReject DepthPolicy = "reject"
+	// DepthReparent attaches the reply to its deepest ancestor that can
+	// still take replies, continuing the thread there.
+	DepthReparent DepthPolicy = "reparent"
+)
+
+// DepthLimit bounds how deeply comments nest. Top-level comments are at
+// depth 0; a zero MaxDepth means unlimited.
+type DepthLimit struct {
+	MaxDepth int
+	Policy   DepthPolicy
+}
+
+// Place returns how many levels above its parent, at parentDepth, a new
+// reply has to be attached to respect the limit.
+func (l DepthLimit) Place(parentDepth int) (int, error) {
+	if l.MaxDepth <= 0 || parentDepth < l.MaxDepth {
+		return 0, nil
+	}
+	if l.Policy == DepthReparent {
+		return parentDepth - l.MaxDepth + 1, nil
+	}
+	return 0, ErrCommentTooDeep
 }
 
 type StructureTree struct {
@@ -34,15 +158,419 @@ type StructureTree struct {
 	SubjectID         uuid.UUID `db:"subject_id" json:"subject_id"`
 }
 
+// IdempotencyKey records which resource a keyed mutation created, so that a
+// retried request returns that resource instead of creating a duplicate.
+// Keys are scoped to UserID and forgotten after ExpiresAt.
+type IdempotencyKey struct {
+	UserID      uuid.UUID `db:"user_id" json:"user_id"`
+	Key         string    `db:"key" json:"key"`
+	Operation   string    `db:"operation" json:"operation"`
+	RequestHash string    `db:"request_hash" json:"request_hash"`
+	ResourceID  uuid.UUID `db:"resource_id" json:"resource_id"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// Username is the name a user is @mentioned by. Names are stored in lower
+// case and are unique.
+type Username struct {
+	UserID uuid.UUID `db:"user_id" json:"user_id"`
+	Name   string    `db:"username" json:"username"`
+}
+
+type NotificationKind string
+
+const (
+	// NotificationMention is an @mention of the user in a post or comment.
+	NotificationMention NotificationKind = "MENTION"
+	// NotificationReply is a reply to the user's comment, or a top-level
+	// comment on their post.
+	NotificationReply NotificationKind = "REPLY"
+)
+
+// Notification tells UserID that ActorID did something involving them,
+// e.g. mentioned them in PostID, or in CommentID on that post.
+type Notification struct {
+	ID        uuid.UUID        `db:"id" json:"id"`
+	UserID    uuid.UUID        `db:"user_id" json:"user_id"`
+	Kind      NotificationKind `db:"kind" json:"kind"`
+	ActorID   uuid.UUID        `db:"actor_id" json:"actor_id"`
+	PostID    uuid.UUID        `db:"post_id" json:"post_id"`
+	CommentID *uuid.UUID       `db:"comment_id" json:"comment_id,omitempty"`
+	CreatedAt time.Time        `db:"created_at" json:"created_at"`
+	Read      bool             `db:"read" json:"read,omitempty"`
+}
+
+// WebhookEvent names something that happened to content, for which
+// webhooks are called.
+type WebhookEvent string
+
+const (
+	WebhookPostCreated    WebhookEvent = "POST_CREATED"
+	WebhookPostUpdated    WebhookEvent = "POST_UPDATED"
+	WebhookCommentCreated WebhookEvent = "COMMENT_CREATED"
+)
+
+// Webhook is a URL that is sent the events it subscribes to, signed with
+// Secret.
+type Webhook struct {
+	ID        uuid.UUID      `json:"id"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Subscribes reports whether w is called for event.
+func (w Webhook) Subscribes(event WebhookEvent) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one attempt to send an event to a webhook.
+// Retries of the same event share EventID. StatusCode is 0 if no response
+// was received, in which case Error says why.
+type WebhookDelivery struct {
+	ID         uuid.UUID    `db:"id" json:"id"`
+	WebhookID  uuid.UUID    `db:"webhook_id" json:"webhook_id"`
+	EventID    uuid.UUID    `db:"event_id" json:"event_id"`
+	Event      WebhookEvent `db:"event" json:"event"`
+	Payload    string       `db:"payload" json:"payload"`
+	Attempt    int          `db:"attempt" json:"attempt"`
+	StatusCode int          `db:"status_code" json:"status_code,omitempty"`
+	Error      string       `db:"error" json:"error,omitempty"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
+}
+
+// Succeeded reports whether the webhook accepted the delivery.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// ReportTarget is the kind of content a report is about.
+type ReportTarget string
+
+const (
+	ReportPost    ReportTarget = "POST"
+	ReportComment ReportTarget = "COMMENT"
+)
+
+// ReportReason is why content was reported.
+type ReportReason string
+
+const (
+	ReasonSpam     ReportReason = "SPAM"
+	ReasonAbuse    ReportReason = "ABUSE"
+	ReasonOffTopic ReportReason = "OFF_TOPIC"
+	ReasonOther    ReportReason = "OTHER"
+)
+
+// ReportStatus is where a report is in the moderation queue.
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "OPEN"
+	ReportResolved  ReportStatus = "RESOLVED"
+	ReportDismissed ReportStatus = "DISMISSED"
+)
+
+// Report is a user's complaint about a post or comment. It stays open
+// until a moderator resolves or dismisses it.
+type Report struct {
+	ID         uuid.UUID    `db:"id" json:"id"`
+	TargetType ReportTarget `db:"target_type" json:"target_type"`
+	TargetID   uuid.UUID    `db:"target_id" json:"target_id"`
+	ReporterID uuid.UUID    `db:"reporter_id" json:"reporter_id"`
+	Reason     ReportReason `db:"reason" json:"reason"`
+	Details    string       `db:"details" json:"details,omitempty"`
+	Status     ReportStatus `db:"status" json:"status"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
+	// ClosedBy and ClosedAt are set once the report is no longer open.
+	ClosedBy *uuid.UUID `db:"closed_by" json:"closed_by,omitempty"`
+	ClosedAt *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+	// TargetHidden is whether closing the report hid its target.
+	TargetHidden bool `db:"target_hidden" json:"target_hidden,omitempty"`
+}
+
+// ReportFilter selects reports; zero fields match every report.
+type ReportFilter struct {
+	Status     ReportStatus
+	Reason     ReportReason
+	TargetType ReportTarget
+	TargetID   uuid.UUID
+}
+
+// Matches reports whether r is selected by f.
+func (f ReportFilter) Matches(r Report) bool {
+	return (f.Status == "" || r.Status == f.Status) &&
+		(f.Reason == "" || r.Reason == f.Reason) &&
+		(f.TargetType == "" || r.TargetType == f.TargetType) &&
+		(f.TargetID == uuid.Nil || r.TargetID == f.TargetID)
+}
+
+// ReportDecision is a moderator's verdict on an open report.
+type ReportDecision struct {
+	// Status is ReportResolved or ReportDismissed.
+	Status      ReportStatus `json:"status"`
+	ModeratorID uuid.UUID    `json:"moderator_id"`
+	At          time.Time    `json:"at"`
+	// HideTarget hides the reported post or comment.
+	HideTarget bool `json:"hide_target,omitempty"`
+}
+
+// RelationKind is how a user has limited another user.
+type RelationKind string
+
+const (
+	// RelationMute hides the other user's posts and comments.
+	RelationMute RelationKind = "MUTE"
+	// RelationBlock hides the other user's posts and comments and stops
+	// them from replying to the user's posts and comments.
+	RelationBlock RelationKind = "BLOCK"
+)
+
+// UserRelation records that UserID has muted or blocked TargetID. A user
+// may both mute and block another; each is removed on its own.
+type UserRelation struct {
+	UserID    uuid.UUID    `db:"user_id" json:"user_id"`
+	TargetID  uuid.UUID    `db:"target_id" json:"target_id"`
+	Kind      RelationKind `db:"kind" json:"kind"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+}
+
+// Relations is what one user has set towards another.
+type Relations struct {
+	Muted   bool
+	Blocked bool
+}
+
+// Hides reports whether the other user's content is hidden from the user.
+func (r Relations) Hides() bool {
+	return r.Muted || r.Blocked
+}
+
+// Community groups posts under a slug, e.g. golang, and sets who may
+// comment on them.
+type Community struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Slug        string    `db:"slug" json:"slug"`
+	Title       string    `db:"title" json:"title"`
+	Description string    `db:"description" json:"description"`
+	OwnerID     uuid.UUID `db:"owner_id" json:"owner_id"`
+	// Moderators are the users, besides the owner, who run the community.
+	Moderators    []uuid.UUID   `db:"-" json:"moderators,omitempty"`
+	CommentPolicy CommentPolicy `db:"comment_policy" json:"comment_policy"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+}
+
+// IsModerator reports whether userID is the community's owner or one of
+// its moderators.
+func (c Community) IsModerator(userID uuid.UUID) bool {
+	if userID == c.OwnerID {
+		return true
+	}
+	for _, id := range c.Moderators {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CommentPolicy says who may comment on a community's posts.
+type CommentPolicy string
+
+const (
+	// CommentsOpen lets anyone comment.
+	CommentsOpen CommentPolicy = "OPEN"
+	// CommentsModerators lets only the community's owner and moderators
+	// comment.
+	CommentsModerators CommentPolicy = "MODERATORS"
+	// CommentsClosed lets nobody comment.
+	CommentsClosed CommentPolicy = "CLOSED"
+)
+
+// OutboxCommentAdded is the outbox channel of commentAdded events, keyed
+// by post ID and carrying the comment ID.
+const OutboxCommentAdded = "comment_added"
+
+// OutboxEvent is a message for a pubsub channel, stored together with the
+// change it announces so that a crash in between cannot lose it. A relay
+// publishes pending events, oldest first, and marks them delivered.
+type OutboxEvent struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	Channel     string     `db:"channel" json:"channel"`
+	Topic       uuid.UUID  `db:"topic" json:"topic"`
+	Message     string     `db:"message" json:"message"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	DeliveredAt *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+}
+
 type Storage interface {
 	CreatePost(ctx context.Context, post Post) error
 	GetPostByID(ctx context.Context, postID uuid.UUID) (Post, error)
+	// ListPosts returns a page of every post, whatever its status.
 	ListPosts(ctx context.Context, page, pageSize int) ([]Post, error)
+	// ListPostsVisibleTo is ListPosts restricted to published posts and
+	// viewerID's own drafts and scheduled posts, without posts by authors
+	// viewerID muted or blocked. viewerID may be uuid.Nil. Hidden posts are
+	// left out unless includeHidden is set.
+	ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]Post, error)
+	// SetPostStatus moves an unpublished post to status. publishAt must be
+	// set for PostScheduled and PostPublished and nil for PostDraft. A
+	// published post cannot change status: ErrPostAlreadyPublished.
+	SetPostStatus(ctx context.Context, postID uuid.UUID, status PostStatus, publishAt *time.Time) error
+	// PublishDuePosts publishes every scheduled post whose PublishAt is not
+	// after now and returns them. Each post is returned by one call only.
+	PublishDuePosts(ctx context.Context, now time.Time) ([]Post, error)
+	// CreateComment stores a new, unlocked comment. It returns
+	// ErrThreadLocked if the parent or any of its ancestors is locked. A
+	// reply deeper than the comment depth limit is rejected or stored under
+	// an ancestor, depending on the limit's policy.
 	CreateComment(ctx context.Context, comment Comment) error
+	// CreateCommentWithEvent is CreateComment that also stores event, in
+	// the same transaction, for the outbox relay to publish.
+	CreateCommentWithEvent(ctx context.Context, comment Comment, event OutboxEvent) error
+	// SetCommentDepthLimit configures CreateComment. It must not be called
+	// concurrently with other methods.
+	SetCommentDepthLimit(limit DepthLimit)
+	// ListThread returns a page of a post's comments no deeper than
+	// maxDepth, oldest first.
+	ListThread(ctx context.Context, postID uuid.UUID, maxDepth, page, pageSize int) ([]ThreadComment, error)
+	// LockThread locks a comment so that no new replies can be added to it
+	// or to any comment beneath it. Locking is idempotent.
+	LockThread(ctx context.Context, commentID uuid.UUID) error
 	GetCommentsByPostID(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]Comment, error)
+	// ListVisibleComments is GetCommentsByPostID without comments by
+	// authors viewerID muted or blocked and, unless includeHidden is set,
+	// without hidden comments. viewerID may be uuid.Nil.
+	ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]Comment, error)
+	// UpdatePost replaces a post's editable fields if its stored version
+	// still equals post.Version, and stores it as post.Version+1. Otherwise
+	// it returns ErrPostVersionConflict. Status and PublishAt are left alone;
+	// see SetPostStatus.
 	UpdatePost(ctx context.Context, post Post) error
 	GetCommentByID(ctx context.Context, commentID uuid.UUID) (Comment, error)
+	// ListPostRevisions returns a page of a post's revisions, newest first.
+	ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]PostRevision, error)
+	GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (PostRevision, error)
+
+	// ReserveIdempotencyKey stores key unless the same user already holds
+	// the same key that has not expired at key.CreatedAt. It returns the
+	// stored key and whether it was newly reserved.
+	ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey) (IdempotencyKey, bool, error)
+	// DeleteIdempotencyKey releases a reservation for resourceID, e.g. after
+	// the mutation it guarded failed.
+	DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) error
+	// PurgeIdempotencyKeys deletes keys that expired before the given time
+	// and returns how many were removed.
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+
+	// SetUsername gives userID the username, replacing the one it had. A
+	// name held by another user fails with ErrUsernameTaken.
+	SetUsername(ctx context.Context, userID uuid.UUID, username string) error
+	// ResolveUsernames maps each of usernames that is held to its user.
+	ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error)
+	// CreateNotifications stores new, unread notifications.
+	CreateNotifications(ctx context.Context, notifications []Notification) error
+	// ListNotifications returns a page of userID's notifications, newest
+	// first, optionally only the unread ones.
+	ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]Notification, error)
+	// CountUnreadNotifications returns how many of userID's notifications
+	// are unread.
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int, error)
+	// MarkNotificationsRead marks userID's notifications with the given
+	// IDs, or all of them if ids is nil, as read. It returns how many were
+	// unread.
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error)
+
+	CreateWebhook(ctx context.Context, webhook Webhook) error
+	// ListWebhooks returns every webhook, oldest first.
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	// DeleteWebhook removes a webhook and its deliveries, or returns
+	// ErrWebhookNotFound.
+	DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error
+	// CreateWebhookDelivery records a delivery attempt. It returns
+	// ErrWebhookNotFound once the webhook has been deleted.
+	CreateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	// ListWebhookDeliveries returns a page of a webhook's deliveries,
+	// newest first.
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) ([]WebhookDelivery, error)
+
+	// PendingOutboxEvents returns up to limit undelivered events, oldest
+	// first.
+	PendingOutboxEvents(ctx context.Context, limit int) ([]OutboxEvent, error)
+	// MarkOutboxEventsDelivered records that the events were published at
+	// the given time.
+	MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) error
+	// PurgeOutboxEvents deletes events delivered before the given time and
+	// returns how many were removed.
+	PurgeOutboxEvents(ctx context.Context, before time.Time) (int, error)
+
+	// CreateReport stores a new, open report. A reporter with an open
+	// report on the same target gets ErrDuplicateReport.
+	CreateReport(ctx context.Context, report Report) error
+	// GetReport returns a report or ErrReportNotFound.
+	GetReport(ctx context.Context, reportID uuid.UUID) (Report, error)
+	// ListReports returns a page of the reports matching filter, oldest
+	// first.
+	ListReports(ctx context.Context, filter ReportFilter, page, pageSize int) ([]Report, error)
+	// CloseReport applies decision to an open report and to every other
+	// open report on the same target, hiding the target if the decision
+	// says so, and returns the report. A report that is not open fails with
+	// ErrReportClosed.
+	CloseReport(ctx context.Context, reportID uuid.UUID, decision ReportDecision) (Report, error)
+
+	// AddUserRelation stores relation. Adding one that exists keeps the
+	// original.
+	AddUserRelation(ctx context.Context, relation UserRelation) error
+	// RemoveUserRelation removes userID's relation of kind to targetID, if
+	// any.
+	RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind RelationKind) error
+	// ListUserRelations returns a page of userID's relations of kind,
+	// newest first.
+	ListUserRelations(ctx context.Context, userID uuid.UUID, kind RelationKind, page, pageSize int) ([]UserRelation, error)
+	// GetUserRelations returns what userID has set towards targetID.
+	GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (Relations, error)
+
+	// CreateCommunity stores a new community. A slug in use fails with
+	// ErrCommunitySlugTaken.
+	CreateCommunity(ctx context.Context, community Community) error
+	// GetCommunity and GetCommunityBySlug fail with ErrCommunityNotFound
+	// for communities that do not exist.
+	GetCommunity(ctx context.Context, communityID uuid.UUID) (Community, error)
+	GetCommunityBySlug(ctx context.Context, slug string) (Community, error)
+	// ListCommunities returns a page of communities, oldest first.
+	ListCommunities(ctx context.Context, page, pageSize int) ([]Community, error)
+	// UpdateCommunity replaces a community's title, description,
+	// moderators and comment policy, leaving its slug and owner alone.
+	UpdateCommunity(ctx context.Context, community Community) error
+	// ListCommunityPosts is ListPostsVisibleTo restricted to the posts of
+	// a community.
+	ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]Post, error)
 }
 
 var ErrPostNotFound = errors.New("post not found")
 var ErrCommentNotFound = errors.New("comment not found")
+var ErrParentNotFound = errors.New("parent comment not found")
+var ErrThreadLocked = errors.New("comment thread is locked")
+var ErrCommentTooDeep = errors.New("comment is nested too deeply")
+var ErrPostAlreadyPublished = errors.New("post is already published")
+var ErrRevisionNotFound = errors.New("revision not found")
+var ErrPostVersionConflict = errors.New("post was modified by another request")
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+var ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+var ErrUsernameTaken = errors.New("username is taken")
+var ErrWebhookNotFound = errors.New("webhook not found")
+var ErrReportNotFound = errors.New("report not found")
+var ErrDuplicateReport = errors.New("content was already reported by this user")
+var ErrReportClosed = errors.New("report is already closed")
+var ErrBlocked = errors.New("the author has blocked you")
+var ErrCommunityNotFound = errors.New("community not found")
+var ErrCommunitySlugTaken = errors.New("community slug is taken")
+var ErrCommentsClosed = errors.New("comments are closed in this community")
+var ErrCommentsModeratorsOnly = errors.New("only community moderators can comment in this community")
@@ -0,0 +1,115 @@
+// Package outbox publishes events that were stored in the same transaction
+// as the change they announce, so that a crash or a failed publish delays
+// an event instead of losing it. Delivery is at least once: an event whose
+// publish succeeded may be published again if it could not be marked
+// delivered.
+package outbox
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// DefaultBatchSize is how many events a relay reads at a time if not told
+// otherwise.
+const DefaultBatchSize = 100
+
+// Relay publishes pending outbox events to the pubsub of their channel, in
+// the order they were stored.
+type Relay struct {
+	storage   models.Storage
+	channels  map[string]pubsub.PubSub
+	batchSize int
+	wake      chan struct{}
+	mu        sync.Mutex // serialises Flush
+}
+
+// NewRelay creates a relay that reads events from storage and publishes
+// each one to channels[event.Channel].
+func NewRelay(storage models.Storage, channels map[string]pubsub.PubSub, batchSize int) *Relay {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Relay{
+		storage:   storage,
+		channels:  channels,
+		batchSize: batchSize,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Notify tells a running relay that an event was stored, so that it is
+// published without waiting for the next poll. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run flushes the outbox when notified and every interval, which picks up
+// events stored before a restart or left behind by a failed publish, until
+// ctx is cancelled.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to relay outbox events", "error", err)
+		}
+	}
+}
+
+// Flush publishes pending events until none are left and returns how many
+// were published. It stops at the first event that cannot be published so
+// that later ones are not delivered ahead of it.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	published := 0
+	for {
+		events, err := r.storage.PendingOutboxEvents(ctx, r.batchSize)
+		if err != nil {
+			return published, err
+		}
+
+		delivered := make([]uuid.UUID, 0, len(events))
+		var publishErr error
+		for _, event := range events {
+			ps, ok := r.channels[event.Channel]
+			if !ok {
+				// Nothing can ever publish it; holding it back would
+				// stall every event after it.
+				slog.Error("Dropping outbox event for unknown channel", "eventID", event.ID, "channel", event.Channel)
+			} else if publishErr = ps.Publish(ctx, event.Topic, event.Message); publishErr != nil {
+				break
+			} else {
+				published++
+			}
+			delivered = append(delivered, event.ID)
+		}
+
+		if err := r.storage.MarkOutboxEventsDelivered(ctx, delivered, time.Now().UTC()); err != nil {
+			return published, err
+		}
+		if publishErr != nil {
+			return published, publishErr
+		}
+		if len(events) < r.batchSize {
+			return published, nil
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/outbox"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/storagetest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPubSub fails every publish while down is set.
+type flakyPubSub struct {
+	pubsub.PubSub
+	down bool
+}
+
+func (ps *flakyPubSub) Publish(ctx context.Context, postID uuid.UUID, message string) error {
+	if ps.down {
+		return errors.New("broker unavailable")
+	}
+	return ps.PubSub.Publish(ctx, postID, message)
+}
+
+// storeComments creates n comments on postID, each with a commentAdded
+// event, and returns their IDs.
+func storeComments(t *testing.T, storage models.Storage, postID uuid.UUID, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := 0; i < n; i++ {
+		comment := storagetest.NewComment(postID, nil)
+		event := models.OutboxEvent{ID: uuid.New(), Channel: models.OutboxCommentAdded, Topic: postID,
+			Message: comment.ID.String(), CreatedAt: time.Now()}
+		require.NoError(t, storage.CreateCommentWithEvent(context.Background(), comment, event))
+		ids = append(ids, comment.ID.String())
+	}
+	return ids
+}
+
+// receive reads n messages from ch. It may run in its own goroutine.
+func receive(t *testing.T, ch <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for i := 0; i < n; i++ {
+		select {
+		case message := <-ch:
+			got = append(got, message)
+		case <-time.After(time.Second):
+			t.Errorf("received %d of %d messages", i, n)
+			return got
+		}
+	}
+	return got
+}
+
+func TestFlushPublishesInOrder(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, storage)
+	ps := pubsub.NewInMemoryPubSub()
+	defer ps.Close()
+	messages, err := ps.Subscribe(ctx, post.ID)
+	require.NoError(t, err)
+
+	relay := outbox.NewRelay(storage, map[string]pubsub.PubSub{models.OutboxCommentAdded: ps}, 2)
+	ids := storeComments(t, storage, post.ID, 3)
+	done := make(chan []string)
+	go func() { done <- receive(t, messages, 3) }()
+	published, err := relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published, "Batches are read until the outbox is empty")
+	assert.Equal(t, ids, <-done)
+
+	pending, err := storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	published, err = relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "Delivered events are not published again")
+}
+
+func TestFlushRetriesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	storage := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, storage)
+	ps := &flakyPubSub{PubSub: pubsub.NewInMemoryPubSub(), down: true}
+	defer ps.Close()
+	messages, err := ps.Subscribe(ctx, post.ID)
+	require.NoError(t, err)
+
+	relay := outbox.NewRelay(storage, map[string]pubsub.PubSub{models.OutboxCommentAdded: ps}, 0)
+	ids := storeComments(t, storage, post.ID, 2)
+	_, err = relay.Flush(ctx)
+	assert.Error(t, err)
+	pending, err := storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2, "Events stay in the outbox while publishing fails")
+
+	ps.down = false
+	done := make(chan []string)
+	go func() { done <- receive(t, messages, 2) }()
+	_, err = relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ids, <-done)
+}
+
+func TestRunPublishesWhenNotified(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, storage)
+	ps := pubsub.NewInMemoryPubSub()
+	defer ps.Close()
+	messages, err := ps.Subscribe(ctx, post.ID)
+	require.NoError(t, err)
+
+	relay := outbox.NewRelay(storage, map[string]pubsub.PubSub{models.OutboxCommentAdded: ps}, 0)
+	stopped := make(chan struct{})
+	go func() {
+		relay.Run(ctx, time.Hour)
+		close(stopped)
+	}()
+
+	ids := storeComments(t, storage, post.ID, 1)
+	relay.Notify()
+	assert.Equal(t, ids, receive(t, messages, 1))
+	cancel()
+	<-stopped
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"golang.org/x/exp/slog"
+)
+
+const communityColumns = `id, slug, title, description, owner_id, comment_policy, created_at`
+
+// CreateCommunity inserts a community and its moderators unless the slug is
+// taken.
+func (s *PostgresStorage) CreateCommunity(ctx context.Context, community models.Community) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO communities (` + communityColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (slug) DO NOTHING`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	res, err := tx.ExecContext(qctx, query, community.ID, community.Slug, community.Title, community.Description, community.OwnerID,
+		community.CommentPolicy, community.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to create community", "error", err, "slug", community.Slug)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrCommunitySlugTaken
+	}
+	if err := insertModerators(ctx, tx, community); err != nil {
+		return err
+	}
+	return commit(ctx, tx)
+}
+
+func insertModerators(ctx context.Context, tx *sqlx.Tx, community models.Community) error {
+	query := `INSERT INTO community_moderators (community_id, user_id, position) VALUES ($1, $2, $3)`
+	for i, userID := range community.Moderators {
+		qctx, span := startQuerySpan(ctx, "INSERT", query)
+		_, err := tx.ExecContext(qctx, query, community.ID, userID, i)
+		endQuerySpan(span, err)
+		if err != nil {
+			slog.Error("Failed to add community moderator", "error", err, "communityID", community.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCommunity retrieves a community by its ID.
+func (s *PostgresStorage) GetCommunity(ctx context.Context, communityID uuid.UUID) (models.Community, error) {
+	return s.getCommunity(ctx, `id = $1`, communityID)
+}
+
+// GetCommunityBySlug retrieves a community by its slug.
+func (s *PostgresStorage) GetCommunityBySlug(ctx context.Context, slug string) (models.Community, error) {
+	return s.getCommunity(ctx, `slug = $1`, slug)
+}
+
+func (s *PostgresStorage) getCommunity(ctx context.Context, where string, arg any) (models.Community, error) {
+	var community models.Community
+	query := `SELECT ` + communityColumns + ` FROM communities WHERE ` + where
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &community, query, arg)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Community{}, models.ErrCommunityNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get community", "error", err)
+		return models.Community{}, err
+	}
+	communities := []models.Community{community}
+	if err := s.loadModerators(ctx, communities); err != nil {
+		return models.Community{}, err
+	}
+	return communities[0], nil
+}
+
+// ListCommunities retrieves a page of communities, oldest first.
+func (s *PostgresStorage) ListCommunities(ctx context.Context, page, pageSize int) ([]models.Community, error) {
+	communities := []models.Community{}
+	query := `SELECT ` + communityColumns + ` FROM communities ORDER BY created_at, id LIMIT $1 OFFSET $2`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &communities, query, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list communities", "error", err)
+		return nil, err
+	}
+	if err := s.loadModerators(ctx, communities); err != nil {
+		return nil, err
+	}
+	return communities, nil
+}
+
+// loadModerators fills in the moderators of communities.
+func (s *PostgresStorage) loadModerators(ctx context.Context, communities []models.Community) error {
+	if len(communities) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*models.Community, len(communities))
+	ids := make([]string, 0, len(communities))
+	for i := range communities {
+		byID[communities[i].ID] = &communities[i]
+		ids = append(ids, communities[i].ID.String())
+	}
+
+	var rows []struct {
+		CommunityID uuid.UUID `db:"community_id"`
+		UserID      uuid.UUID `db:"user_id"`
+	}
+	query := `SELECT community_id, user_id FROM community_moderators
+              WHERE community_id = ANY($1::uuid[]) ORDER BY community_id, position`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &rows, query, pq.Array(ids))
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list community moderators", "error", err)
+		return err
+	}
+	for _, row := range rows {
+		community := byID[row.CommunityID]
+		community.Moderators = append(community.Moderators, row.UserID)
+	}
+	return nil
+}
+
+// UpdateCommunity replaces a community's title, description, moderators and
+// comment policy.
+func (s *PostgresStorage) UpdateCommunity(ctx context.Context, community models.Community) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE communities SET title = $1, description = $2, comment_policy = $3 WHERE id = $4`
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	res, err := tx.ExecContext(qctx, query, community.Title, community.Description, community.CommentPolicy, community.ID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to update community", "error", err, "communityID", community.ID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrCommunityNotFound
+	}
+
+	query = `DELETE FROM community_moderators WHERE community_id = $1`
+	qctx, span = startQuerySpan(ctx, "DELETE", query)
+	_, err = tx.ExecContext(qctx, query, community.ID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to clear community moderators", "error", err, "communityID", community.ID)
+		return err
+	}
+	if err := insertModerators(ctx, tx, community); err != nil {
+		return err
+	}
+	return commit(ctx, tx)
+}
+
+// ListCommunityPosts retrieves a paginated list of the posts of a community
+// viewerID may see.
+func (s *PostgresStorage) ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	var posts []models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts
+              WHERE community_id = $1 AND (status = 'PUBLISHED' OR user_id = $2) AND ($3 OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = $2)
+              ORDER BY created_at DESC LIMIT $4 OFFSET $5`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &posts, query, communityID, viewerID, includeHidden, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list community posts", "error", err, "communityID", communityID)
+	}
+	return posts, err
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// reserveAttempts bounds retries when a conflicting key disappears between
+// the insert and the lookup of the key that blocked it.
+const reserveAttempts = 3
+
+// ReserveIdempotencyKey inserts key, replacing an expired key with the same
+// scope, or returns the unexpired key that blocks it.
+func (s *PostgresStorage) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (models.IdempotencyKey, bool, error) {
+	insert := `INSERT INTO idempotency_keys (user_id, key, operation, request_hash, resource_id, created_at, expires_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)
+              ON CONFLICT (user_id, key) DO UPDATE
+              SET operation = EXCLUDED.operation, request_hash = EXCLUDED.request_hash, resource_id = EXCLUDED.resource_id,
+                  created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+              WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`
+	lookup := `SELECT user_id, key, operation, request_hash, resource_id, created_at, expires_at
+              FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	for attempt := 0; attempt < reserveAttempts; attempt++ {
+		qctx, span := startQuerySpan(ctx, "INSERT", insert)
+		res, err := s.db.ExecContext(qctx, insert, key.UserID, key.Key, key.Operation, key.RequestHash, key.ResourceID, key.CreatedAt.UTC(), key.ExpiresAt.UTC())
+		endQuerySpan(span, err)
+		if err != nil {
+			slog.Error("Failed to reserve idempotency key", "error", err, "userID", key.UserID)
+			return models.IdempotencyKey{}, false, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return models.IdempotencyKey{}, false, err
+		} else if n == 1 {
+			return key, true, nil
+		}
+
+		var existing models.IdempotencyKey
+		qctx, span = startQuerySpan(ctx, "SELECT", lookup)
+		err = s.db.GetContext(qctx, &existing, lookup, key.UserID, key.Key)
+		endQuerySpan(span, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to get idempotency key", "error", err, "userID", key.UserID)
+			return models.IdempotencyKey{}, false, err
+		}
+		return existing, false, nil
+	}
+	return models.IdempotencyKey{}, false, errors.New("idempotency key changed concurrently")
+}
+
+// DeleteIdempotencyKey removes the key if it still guards resourceID.
+func (s *PostgresStorage) DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND resource_id = $3`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	_, err := s.db.ExecContext(qctx, query, userID, key, resourceID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to delete idempotency key", "error", err, "userID", userID)
+	}
+	return err
+}
+
+// PurgeIdempotencyKeys deletes keys that expired before the given time.
+func (s *PostgresStorage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at < $1`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	res, err := s.db.ExecContext(qctx, query, before.UTC())
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to purge idempotency keys", "error", err)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,22 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_communities.sql
+CREATE TABLE IF NOT EXISTS communities (
+    id UUID PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id UUID NOT NULL,
+    comment_policy TEXT NOT NULL DEFAULT 'OPEN',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Moderators are listed in position order.
+CREATE TABLE IF NOT EXISTS community_moderators (
+    community_id UUID NOT NULL REFERENCES communities(id),
+    user_id UUID NOT NULL,
+    position INT NOT NULL,
+    PRIMARY KEY (community_id, user_id)
+);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS community_id UUID REFERENCES communities(id);
+CREATE INDEX IF NOT EXISTS posts_community_idx ON posts (community_id, created_at);
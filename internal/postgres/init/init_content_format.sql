@@ -0,0 +1,6 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_content_format.sql
+-- An empty format is rendered as plain text, which is how every existing
+-- post and comment was written.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_indexes.sql
+CREATE INDEX IF NOT EXISTS posts_created_at_idx ON posts (created_at);
+CREATE INDEX IF NOT EXISTS comments_post_created_at_idx ON comments (post_id, created_at);
+CREATE INDEX IF NOT EXISTS structure_tree_descendant_idx ON structure_tree (descendant_id);
@@ -0,0 +1,18 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_notifications.sql
+CREATE TABLE IF NOT EXISTS usernames (
+    username TEXT PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    actor_id UUID NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts(id),
+    comment_id UUID REFERENCES comments(id),
+    created_at TIMESTAMP NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, created_at DESC);
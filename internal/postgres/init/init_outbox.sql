@@ -0,0 +1,13 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_outbox.sql
+CREATE TABLE IF NOT EXISTS outbox (
+    -- events are relayed in seq order
+    seq BIGSERIAL UNIQUE,
+    id UUID PRIMARY KEY,
+    channel TEXT NOT NULL,
+    topic UUID NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (seq) WHERE delivered_at IS NULL;
@@ -0,0 +1,8 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_rate_limits.sql
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    -- when the token bucket will be full again
+    full_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS rate_limits_full_at_idx ON rate_limits (full_at);
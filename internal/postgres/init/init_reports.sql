@@ -0,0 +1,22 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_reports.sql
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY,
+    target_type TEXT NOT NULL,
+    target_id UUID NOT NULL,
+    reporter_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    closed_by UUID,
+    closed_at TIMESTAMP,
+    target_hidden BOOLEAN NOT NULL DEFAULT FALSE
+);
+-- A user has at most one open report per target.
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_reporter_idx ON reports (reporter_id, target_type, target_id) WHERE status = 'OPEN';
+CREATE INDEX IF NOT EXISTS reports_queue_idx ON reports (status, created_at);
+CREATE INDEX IF NOT EXISTS reports_target_idx ON reports (target_type, target_id);
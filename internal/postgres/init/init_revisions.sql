@@ -0,0 +1,21 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_revisions.sql
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS updated_by UUID;
+UPDATE posts SET updated_at = created_at, updated_by = user_id WHERE updated_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id UUID NOT NULL REFERENCES posts(id),
+    version INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    allow_comments BOOLEAN NOT NULL,
+    editor_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (post_id, version)
+);
+
+-- Earlier edits were not recorded, so history starts at the current version.
+INSERT INTO post_revisions (post_id, version, title, content, allow_comments, editor_id, created_at)
+SELECT id, version, title, content, allow_comments, updated_by, updated_at FROM posts
+ON CONFLICT DO NOTHING;
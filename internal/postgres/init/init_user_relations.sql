@@ -0,0 +1,10 @@
+-- Runs after init.sql on a fresh database. Safe to apply by hand to an
+-- existing one: psql -f internal/postgres/init/init_user_relations.sql
+CREATE TABLE IF NOT EXISTS user_relations (
+    user_id UUID NOT NULL,
+    target_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, target_id, kind)
+);
+CREATE INDEX IF NOT EXISTS user_relations_list_idx ON user_relations (user_id, kind, created_at);
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/exp/slog"
+)
+
+// SetUsername gives userID the username, releasing the one it had.
+func (s *PostgresStorage) SetUsername(ctx context.Context, userID uuid.UUID, username string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM usernames WHERE user_id = $1`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	_, err = tx.ExecContext(qctx, query, userID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to release username", "error", err, "userID", userID)
+		return err
+	}
+
+	query = `INSERT INTO usernames (username, user_id) VALUES ($1, $2) ON CONFLICT (username) DO NOTHING`
+	qctx, span = startQuerySpan(ctx, "INSERT", query)
+	res, err := tx.ExecContext(qctx, query, username, userID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to set username", "error", err, "userID", userID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		slog.Warn("Username taken", "username", username)
+		return models.ErrUsernameTaken
+	}
+
+	return commit(ctx, tx)
+}
+
+// ResolveUsernames maps each held username to its user.
+func (s *PostgresStorage) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error) {
+	users := make(map[string]uuid.UUID, len(usernames))
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	var rows []models.Username
+	query := `SELECT user_id, username FROM usernames WHERE username = ANY($1)`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &rows, query, pq.Array(usernames))
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to resolve usernames", "error", err)
+		return nil, err
+	}
+	for _, row := range rows {
+		users[row.Name] = row.UserID
+	}
+	return users, nil
+}
+
+// CreateNotifications inserts new, unread notifications.
+func (s *PostgresStorage) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO notifications (id, user_id, kind, actor_id, post_id, comment_id, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	for _, n := range notifications {
+		qctx, span := startQuerySpan(ctx, "INSERT", query)
+		_, err := tx.ExecContext(qctx, query, n.ID, n.UserID, n.Kind, n.ActorID, n.PostID, n.CommentID, n.CreatedAt)
+		endQuerySpan(span, err)
+		if err != nil {
+			slog.Error("Failed to create notification", "error", err, "userID", n.UserID)
+			return err
+		}
+	}
+
+	return commit(ctx, tx)
+}
+
+// ListNotifications retrieves a page of userID's notifications, newest first.
+func (s *PostgresStorage) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	query := `SELECT id, user_id, kind, actor_id, post_id, comment_id, created_at, read
+              FROM notifications
+              WHERE user_id = $1 AND (NOT read OR NOT $2)
+              ORDER BY created_at DESC, id DESC
+              LIMIT $3 OFFSET $4`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &notifications, query, userID, unreadOnly, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list notifications", "error", err, "userID", userID)
+	}
+	return notifications, err
+}
+
+// CountUnreadNotifications returns how many of userID's notifications are
+// unread.
+func (s *PostgresStorage) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND NOT read`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &count, query, userID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to count unread notifications", "error", err, "userID", userID)
+	}
+	return count, err
+}
+
+// MarkNotificationsRead marks userID's notifications with the given IDs, or
+// all of them if ids is nil, as read.
+func (s *PostgresStorage) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error) {
+	query, args := `UPDATE notifications SET read = TRUE WHERE user_id = $1 AND NOT read`, []any{userID}
+	if ids != nil {
+		selected := make([]string, len(ids))
+		for i, id := range ids {
+			selected[i] = id.String()
+		}
+		query += ` AND id = ANY($2::uuid[])`
+		args = append(args, pq.Array(selected))
+	}
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	res, err := s.db.ExecContext(qctx, query, args...)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to mark notifications read", "error", err, "userID", userID)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"golang.org/x/exp/slog"
+)
+
+// insertOutboxEvent queues event within tx.
+func insertOutboxEvent(ctx context.Context, tx *sqlx.Tx, event models.OutboxEvent) error {
+	query := `INSERT INTO outbox (id, channel, topic, message, created_at) VALUES ($1, $2, $3, $4, $5)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err := tx.ExecContext(qctx, query, event.ID, event.Channel, event.Topic, event.Message, event.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to insert outbox event", "error", err, "eventID", event.ID)
+	}
+	return err
+}
+
+// PendingOutboxEvents retrieves up to limit undelivered events, oldest
+// first.
+func (s *PostgresStorage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	events := []models.OutboxEvent{}
+	query := `SELECT id, channel, topic, message, created_at, delivered_at
+              FROM outbox WHERE delivered_at IS NULL ORDER BY seq LIMIT $1`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &events, query, limit)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list pending outbox events", "error", err)
+	}
+	return events, err
+}
+
+// MarkOutboxEventsDelivered records that the events were published at the
+// given time.
+func (s *PostgresStorage) MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	selected := make([]string, len(ids))
+	for i, id := range ids {
+		selected[i] = id.String()
+	}
+	query := `UPDATE outbox SET delivered_at = $1 WHERE delivered_at IS NULL AND id = ANY($2::uuid[])`
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	_, err := s.db.ExecContext(qctx, query, at, pq.Array(selected))
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to mark outbox events delivered", "error", err)
+	}
+	return err
+}
+
+// PurgeOutboxEvents deletes events delivered before the given time.
+func (s *PostgresStorage) PurgeOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM outbox WHERE delivered_at < $1`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	res, err := s.db.ExecContext(qctx, query, before)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to purge outbox events", "error", err)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
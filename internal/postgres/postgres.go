@@ -5,14 +5,23 @@ import (
 	"database/sql"
 	"ozon-test/internal/models"
 
+	"ozon-test/internal/tracing"
+
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
 
+const postColumns = `id, title, content, user_id, allow_comments, created_at, version, updated_at, updated_by, status, publish_at, format, hidden,
+              community_id`
+
 type PostgresStorage struct {
-	db *sqlx.DB
+	db         *sqlx.DB
+	depthLimit models.DepthLimit
 }
 
 // NewPostgresStorage creates a new instance of PostgresStorage.
@@ -20,22 +29,62 @@ func NewPostgresStorage(db *sqlx.DB) *PostgresStorage {
 	return &PostgresStorage{db: db}
 }
 
-// CreatePost inserts a new post into the database.
+// startQuerySpan starts a client span for a single SQL statement.
+func startQuerySpan(ctx context.Context, operation, query string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "postgres."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// CreatePost inserts a new post and its first revision into the database.
 func (s *PostgresStorage) CreatePost(ctx context.Context, post models.Post) error {
-	query := `INSERT INTO posts (id, title, content, user_id, allow_comments, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := s.db.ExecContext(ctx, query, post.ID, post.Title, post.Content, post.UserID, post.AllowComments, post.CreatedAt)
+	post = post.WithDefaultStatus()
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO posts (id, title, content, user_id, allow_comments, created_at, version, updated_at, updated_by, status, publish_at, format,
+              community_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err = tx.ExecContext(qctx, query, post.ID, post.Title, post.Content, post.UserID, post.AllowComments, post.CreatedAt,
+		post.Version, post.UpdatedAt, post.UpdatedBy, post.Status, utcOrNil(post.PublishAt), post.Format, post.CommunityID)
+	endQuerySpan(span, err)
 	if err != nil {
 		slog.Error("Failed to create post", "error", err, "postID", post.ID)
+		return err
+	}
+	if err := insertRevision(ctx, tx, models.RevisionOf(post)); err != nil {
+		return err
 	}
-	return err
+
+	return commit(ctx, tx)
 }
 
 // GetPostByID retrieves a post by its ID from the database.
 func (s *PostgresStorage) GetPostByID(ctx context.Context, postID uuid.UUID) (models.Post, error) {
 	var post models.Post
-	query := `SELECT id, title, content, user_id, allow_comments, created_at FROM posts WHERE id = $1`
-	err := s.db.GetContext(ctx, &post, query, postID)
+	query := `SELECT ` + postColumns + `
+              FROM posts WHERE id = $1`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &post, query, postID)
+	endQuerySpan(span, err)
 	if err == sql.ErrNoRows {
 		slog.Warn("Post not found", "postID", postID)
 		return post, models.ErrPostNotFound
@@ -49,9 +98,11 @@ func (s *PostgresStorage) GetPostByID(ctx context.Context, postID uuid.UUID) (mo
 // ListPosts retrieves a paginated list of posts from the database.
 func (s *PostgresStorage) ListPosts(ctx context.Context, page, pageSize int) ([]models.Post, error) {
 	var posts []models.Post
-	query := `SELECT id, title, content, user_id, allow_comments, created_at 
+	query := `SELECT ` + postColumns + `
               FROM posts ORDER BY created_at DESC LIMIT $1 OFFSET $2`
-	err := s.db.SelectContext(ctx, &posts, query, pageSize, (page-1)*pageSize)
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &posts, query, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
 	if err != nil {
 		slog.Error("Failed to list posts", "error", err)
 	}
@@ -60,91 +111,159 @@ func (s *PostgresStorage) ListPosts(ctx context.Context, page, pageSize int) ([]
 
 // CreateComment inserts a new comment into the database and updates the structure_tree table.
 func (s *PostgresStorage) CreateComment(ctx context.Context, comment models.Comment) error {
+	return s.createComment(ctx, comment, nil)
+}
+
+// CreateCommentWithEvent is CreateComment that also inserts event into the
+// outbox in the same transaction.
+func (s *PostgresStorage) CreateCommentWithEvent(ctx context.Context, comment models.Comment, event models.OutboxEvent) error {
+	return s.createComment(ctx, comment, &event)
+}
+
+func (s *PostgresStorage) createComment(ctx context.Context, comment models.Comment, event *models.OutboxEvent) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		slog.Error("Failed to begin transaction", "error", err)
 		return err
 	}
+	defer tx.Rollback()
 
-	query := `INSERT INTO comments (id, post_id, parent_id, content, user_id, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = tx.ExecContext(ctx, query, comment.ID, comment.PostID, comment.ParentID, comment.Content, comment.UserID, comment.CreatedAt)
-	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			slog.Error("Failed to rollback transaction", "error", rbErr)
-			return rbErr
+	if comment.ParentID != nil {
+		parentID, err := placeReply(ctx, tx, comment.PostID, *comment.ParentID, s.depthLimit)
+		if err != nil {
+			return err
 		}
+		comment.ParentID = &parentID
+	}
+
+	query := `INSERT INTO comments (id, post_id, parent_id, content, user_id, created_at, format) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err = tx.ExecContext(qctx, query, comment.ID, comment.PostID, comment.ParentID, comment.Content, comment.UserID, comment.CreatedAt,
+		comment.Format)
+	endQuerySpan(span, err)
+	if err != nil {
 		slog.Error("Failed to create comment", "error", err)
 		return err
 	}
 
-	if comment.ParentID == nil {
-		query = `INSERT INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id) 
-			 VALUES ($1, $1, $1, 0, $2)`
-		_, err = tx.ExecContext(ctx, query, comment.ID, comment.PostID)
-		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				slog.Error("Failed to rollback transaction", "error", rbErr)
-				return rbErr
-			}
-			slog.Error("Failed to update structure tree", "error", err)
-			return err
-		}
-	} else {
+	nearest := comment.ID
+	if comment.ParentID != nil {
+		nearest = *comment.ParentID
+	}
+	query = `INSERT INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id) 
+		 VALUES ($1, $1, $2, 0, $3)`
+	qctx, span = startQuerySpan(ctx, "INSERT", query)
+	_, err = tx.ExecContext(qctx, query, comment.ID, nearest, comment.PostID)
+	endQuerySpan(span, err)
+	if err == nil && comment.ParentID != nil {
 		query = `INSERT INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id) 
 			 SELECT ancestor_id, $1, $2, level + 1, subject_id 
 			 FROM structure_tree 
 			 WHERE descendant_id = $2`
-		_, err = tx.ExecContext(ctx, query, comment.ID, comment.ParentID)
-		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				slog.Error("Failed to rollback transaction", "error", rbErr)
-				return rbErr
-			}
-			slog.Error("Failed to update structure tree", "error", err)
+		qctx, span = startQuerySpan(ctx, "INSERT", query)
+		_, err = tx.ExecContext(qctx, query, comment.ID, comment.ParentID)
+		endQuerySpan(span, err)
+	}
+	if err != nil {
+		slog.Error("Failed to update structure tree", "error", err)
+		return err
+	}
+
+	if event != nil {
+		if err := insertOutboxEvent(ctx, tx, *event); err != nil {
 			return err
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		slog.Error("Failed to commit transaction", "error", err)
-	}
-	return err
+	return commit(ctx, tx)
 }
 
 // GetCommentsByPostID retrieves a paginated list of comments for a given postID from the database.
 func (s *PostgresStorage) GetCommentsByPostID(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.Comment, error) {
 	var comments []models.Comment
-	query := `SELECT comments.id, comments.post_id, comments.parent_id, comments.content, comments.user_id, comments.created_at 
-              FROM comments 
-              JOIN structure_tree ON comments.id = structure_tree.descendant_id 
-              WHERE structure_tree.subject_id = $1 
-              AND structure_tree.nearest_ancestor_id = structure_tree.ancestor_id
-              ORDER BY comments.created_at ASC 
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden
+              FROM comments
+              WHERE post_id = $1
+              ORDER BY created_at ASC
               LIMIT $2 OFFSET $3`
-	err := s.db.SelectContext(ctx, &comments, query, postID, pageSize, (page-1)*pageSize)
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &comments, query, postID, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
 	if err != nil {
 		slog.Error("Failed to get comments by post ID", "error", err, "postID", postID)
 	}
 	return comments, err
 }
 
-// UpdatePost updates the details of an existing post in the database.
+// ListVisibleComments retrieves a paginated list of a post's comments that
+// viewerID may see.
+func (s *PostgresStorage) ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Comment, error) {
+	var comments []models.Comment
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden
+              FROM comments
+              WHERE post_id = $1 AND ($2 OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = $3)
+              ORDER BY created_at ASC
+              LIMIT $4 OFFSET $5`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &comments, query, postID, includeHidden, viewerID, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list visible comments", "error", err, "postID", postID)
+	}
+	return comments, err
+}
+
+// UpdatePost updates the details of an existing post in the database if
+// its version has not changed since post was read, and records the new
+// version as a revision.
 func (s *PostgresStorage) UpdatePost(ctx context.Context, post models.Post) error {
-	query := `UPDATE posts SET title = $1, content = $2, allow_comments = $3 WHERE id = $4`
-	_, err := s.db.ExecContext(ctx, query, post.Title, post.Content, post.AllowComments, post.ID)
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE posts SET title = $1, content = $2, allow_comments = $3, version = version + 1, updated_at = $4, updated_by = $5
+              WHERE id = $6 AND version = $7`
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	res, err := tx.ExecContext(qctx, query, post.Title, post.Content, post.AllowComments, post.UpdatedAt, post.UpdatedBy,
+		post.ID, post.Version)
+	endQuerySpan(span, err)
 	if err != nil {
 		slog.Error("Failed to update post", "error", err, "postID", post.ID)
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
 	}
-	return err
+	if updated == 0 {
+		// Nothing matched: either the post is gone or someone else updated it.
+		if _, err := s.GetPostByID(ctx, post.ID); err != nil {
+			return err
+		}
+		slog.Warn("Post version conflict", "postID", post.ID, "expected", post.Version)
+		return models.ErrPostVersionConflict
+	}
+
+	post.Version++
+	if err := insertRevision(ctx, tx, models.RevisionOf(post)); err != nil {
+		return err
+	}
+
+	return commit(ctx, tx)
 }
 
 // GetCommentByID retrieves a comment by its ID from the database.
 func (s *PostgresStorage) GetCommentByID(ctx context.Context, commentID uuid.UUID) (models.Comment, error) {
 	var comment models.Comment
-	query := `SELECT id, post_id, parent_id, content, user_id, created_at FROM comments WHERE id = $1`
-	err := s.db.GetContext(ctx, &comment, query, commentID)
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden FROM comments WHERE id = $1`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &comment, query, commentID)
+	endQuerySpan(span, err)
 	if err == sql.ErrNoRows {
 		slog.Warn("Comment not found", "commentID", commentID)
 		return comment, models.ErrCommentNotFound
@@ -3,11 +3,17 @@ package postgres_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
 	"ozon-test/internal/models"
 	"ozon-test/internal/postgres"
+	"ozon-test/internal/ratelimit"
+	"ozon-test/internal/ratelimit/ratelimittest"
+	"ozon-test/internal/storagetest"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -17,7 +23,7 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-func setupTestDB(t *testing.T) *sqlx.DB {
+func setupTestDB(t testing.TB) *sqlx.DB {
 	ctx := context.Background()
 
 	req := testcontainers.ContainerRequest{
@@ -62,37 +68,46 @@ func setupTestDB(t *testing.T) *sqlx.DB {
 	return db
 }
 
-func setupSchema(t *testing.T, db *sqlx.DB) {
-	schema := `
-    CREATE TABLE posts (
-        id UUID PRIMARY KEY,
-        title TEXT NOT NULL,
-        content TEXT NOT NULL,
-        user_id UUID NOT NULL,
-        allow_comments BOOLEAN NOT NULL,
-        created_at TIMESTAMP NOT NULL
-    );
-    CREATE TABLE comments (
-        id UUID PRIMARY KEY,
-        post_id UUID NOT NULL REFERENCES posts(id),
-        parent_id UUID,
-        content TEXT NOT NULL,
-        user_id UUID NOT NULL,
-        created_at TIMESTAMP NOT NULL
-    );
-    CREATE TABLE structure_tree (
-        ancestor_id UUID NOT NULL,
-        descendant_id UUID NOT NULL,
-        nearest_ancestor_id UUID NOT NULL,
-        level INT NOT NULL,
-        subject_id UUID NOT NULL,
-        PRIMARY KEY (ancestor_id, descendant_id)
-    );`
-
-	_, err := db.Exec(schema)
+// setupSchema applies the same scripts that initialise the Docker database,
+// in the order the entrypoint runs them.
+func setupSchema(t testing.TB, db *sqlx.DB) {
+	files, err := filepath.Glob(filepath.Join("init", "*.sql"))
 	if err != nil {
-		t.Fatalf("failed to create schema: %v", err)
+		t.Fatalf("failed to list schema files: %v", err)
 	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		schema, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(schema)); err != nil {
+			t.Fatalf("failed to apply %s: %v", file, err)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) models.Storage {
+		return postgres.NewPostgresStorage(setupTestDB(t))
+	})
+}
+
+func TestRateLimitStore(t *testing.T) {
+	ratelimittest.Run(t, func(t *testing.T) ratelimit.Store {
+		return postgres.NewRateLimitStore(setupTestDB(t))
+	})
+}
+
+func BenchmarkStorage(b *testing.B) {
+	db := setupTestDB(b)
+	storagetest.Bench(b, func(b *testing.B) models.Storage {
+		if _, err := db.Exec(`TRUNCATE posts, comments, structure_tree, idempotency_keys, post_revisions`); err != nil {
+			b.Fatal(err)
+		}
+		return postgres.NewPostgresStorage(db)
+	})
 }
 
 func TestCreateAndRetrievePost(t *testing.T) {
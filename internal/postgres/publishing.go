@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// utcOrNil converts t to UTC, since publish_at has no time zone and would
+// otherwise keep the wall-clock time of whatever offset t was given in.
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// ListPostsVisibleTo retrieves a paginated list of the posts viewerID may see.
+func (s *PostgresStorage) ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	var posts []models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts
+              WHERE (status = 'PUBLISHED' OR user_id = $1) AND ($2 OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = $1)
+              ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &posts, query, viewerID, includeHidden, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list visible posts", "error", err)
+	}
+	return posts, err
+}
+
+// SetPostStatus moves an unpublished post to status.
+func (s *PostgresStorage) SetPostStatus(ctx context.Context, postID uuid.UUID, status models.PostStatus, publishAt *time.Time) error {
+	query := `UPDATE posts SET status = $1, publish_at = $2 WHERE id = $3 AND status <> 'PUBLISHED'`
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	res, err := s.db.ExecContext(qctx, query, status, utcOrNil(publishAt), postID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to set post status", "error", err, "postID", postID)
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil || updated == 1 {
+		return err
+	}
+
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return err
+	}
+	return models.ErrPostAlreadyPublished
+}
+
+// PublishDuePosts publishes every scheduled post due at now. Concurrent
+// callers never publish the same post twice, because the UPDATE locks the
+// rows it changes.
+func (s *PostgresStorage) PublishDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	var posts []models.Post
+	query := `UPDATE posts SET status = 'PUBLISHED'
+              WHERE status = 'SCHEDULED' AND publish_at <= $1
+              RETURNING ` + postColumns
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	err := s.db.SelectContext(qctx, &posts, query, now.UTC())
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to publish scheduled posts", "error", err)
+		return nil, err
+	}
+	if len(posts) > 0 {
+		slog.Info("Published scheduled posts", "count", len(posts))
+	}
+	return posts, nil
+}
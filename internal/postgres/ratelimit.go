@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/ratelimit"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+// RateLimitStore keeps token buckets in the rate_limits table, so that
+// every replica draws from the same buckets.
+type RateLimitStore struct {
+	db *sqlx.DB
+}
+
+// NewRateLimitStore creates a RateLimitStore using db.
+func NewRateLimitStore(db *sqlx.DB) *RateLimitStore {
+	return &RateLimitStore{db: db}
+}
+
+// Take implements ratelimit.Store. The bucket is only written when it
+// holds a token, in a single statement, so concurrent requests cannot
+// both take the last one.
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	now = now.UTC()
+	interval := limit.Interval().Seconds()
+	tolerance := (limit.Per - limit.Interval()).Seconds()
+
+	var fullAt time.Time
+	query := `INSERT INTO rate_limits AS b (key, full_at) VALUES ($1, $2::timestamp + make_interval(secs => $3::float8))
+              ON CONFLICT (key) DO UPDATE SET full_at = GREATEST(b.full_at, $2::timestamp) + make_interval(secs => $3::float8)
+              WHERE b.full_at - $2::timestamp <= make_interval(secs => $4::float8)
+              RETURNING full_at`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	err := s.db.GetContext(qctx, &fullAt, query, key, now, interval, tolerance)
+	endQuerySpan(span, err)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to take rate limit token", "error", err)
+		return false, 0, err
+	}
+
+	query = `SELECT full_at FROM rate_limits WHERE key = $1`
+	qctx, span = startQuerySpan(ctx, "SELECT", query)
+	err = s.db.GetContext(qctx, &fullAt, query, key)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to read rate limit bucket", "error", err)
+		return false, 0, err
+	}
+	return false, max(fullAt.Sub(now)-(limit.Per-limit.Interval()), 0), nil
+}
+
+// Purge deletes buckets that are full again by now and returns how many
+// were deleted.
+func (s *RateLimitStore) Purge(ctx context.Context, now time.Time) (int, error) {
+	query := `DELETE FROM rate_limits WHERE full_at <= $1`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	res, err := s.db.ExecContext(qctx, query, now.UTC())
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to purge rate limit buckets", "error", err)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
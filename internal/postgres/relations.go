@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// AddUserRelation inserts relation unless it exists.
+func (s *PostgresStorage) AddUserRelation(ctx context.Context, relation models.UserRelation) error {
+	query := `INSERT INTO user_relations (user_id, target_id, kind, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err := s.db.ExecContext(qctx, query, relation.UserID, relation.TargetID, relation.Kind, relation.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to add user relation", "error", err, "userID", relation.UserID, "kind", relation.Kind)
+	}
+	return err
+}
+
+// RemoveUserRelation deletes userID's relation of kind to targetID, if any.
+func (s *PostgresStorage) RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) error {
+	query := `DELETE FROM user_relations WHERE user_id = $1 AND target_id = $2 AND kind = $3`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	_, err := s.db.ExecContext(qctx, query, userID, targetID, kind)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to remove user relation", "error", err, "userID", userID, "kind", kind)
+	}
+	return err
+}
+
+// ListUserRelations retrieves a page of userID's relations of kind, newest
+// first.
+func (s *PostgresStorage) ListUserRelations(ctx context.Context, userID uuid.UUID, kind models.RelationKind, page, pageSize int) ([]models.UserRelation, error) {
+	relations := []models.UserRelation{}
+	query := `SELECT user_id, target_id, kind, created_at FROM user_relations
+              WHERE user_id = $1 AND kind = $2
+              ORDER BY created_at DESC, target_id LIMIT $3 OFFSET $4`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &relations, query, userID, kind, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list user relations", "error", err, "userID", userID, "kind", kind)
+	}
+	return relations, err
+}
+
+// GetUserRelations returns what userID has set towards targetID.
+func (s *PostgresStorage) GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (models.Relations, error) {
+	var kinds []models.RelationKind
+	query := `SELECT kind FROM user_relations WHERE user_id = $1 AND target_id = $2`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &kinds, query, userID, targetID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to get user relations", "error", err, "userID", userID)
+		return models.Relations{}, err
+	}
+	return relationsOf(kinds), nil
+}
+
+func relationsOf(kinds []models.RelationKind) models.Relations {
+	var relations models.Relations
+	for _, kind := range kinds {
+		switch kind {
+		case models.RelationMute:
+			relations.Muted = true
+		case models.RelationBlock:
+			relations.Blocked = true
+		}
+	}
+	return relations
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ozon-test/internal/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+const reportColumns = `id, target_type, target_id, reporter_id, reason, details, status, created_at, closed_by, closed_at, target_hidden`
+
+// CreateReport inserts an open report unless the reporter already has one
+// open on the same target.
+func (s *PostgresStorage) CreateReport(ctx context.Context, report models.Report) error {
+	query := `INSERT INTO reports (id, target_type, target_id, reporter_id, reason, details, status, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, 'OPEN', $7) ON CONFLICT DO NOTHING`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	res, err := s.db.ExecContext(qctx, query, report.ID, report.TargetType, report.TargetID, report.ReporterID, report.Reason,
+		report.Details, report.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to create report", "error", err, "reportID", report.ID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrDuplicateReport
+	}
+	return nil
+}
+
+// GetReport retrieves a report by its ID.
+func (s *PostgresStorage) GetReport(ctx context.Context, reportID uuid.UUID) (models.Report, error) {
+	var report models.Report
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE id = $1`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &report, query, reportID)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Report{}, models.ErrReportNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get report", "error", err, "reportID", reportID)
+	}
+	return report, err
+}
+
+// ListReports retrieves a page of the reports matching filter, oldest
+// first.
+func (s *PostgresStorage) ListReports(ctx context.Context, filter models.ReportFilter, page, pageSize int) ([]models.Report, error) {
+	where, args := []string{"TRUE"}, []any{}
+	add := func(column string, value any) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if filter.Status != "" {
+		add("status", filter.Status)
+	}
+	if filter.Reason != "" {
+		add("reason", filter.Reason)
+	}
+	if filter.TargetType != "" {
+		add("target_type", filter.TargetType)
+	}
+	if filter.TargetID != uuid.Nil {
+		add("target_id", filter.TargetID)
+	}
+
+	reports := []models.Report{}
+	query := fmt.Sprintf(`SELECT %s FROM reports WHERE %s
+              ORDER BY created_at, id LIMIT $%d OFFSET $%d`, reportColumns, strings.Join(where, " AND "), len(args)+1, len(args)+2)
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &reports, query, append(args, pageSize, (page-1)*pageSize)...)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list reports", "error", err)
+	}
+	return reports, err
+}
+
+// CloseReport applies decision to an open report and the other open
+// reports on its target, hiding the target in the same transaction if the
+// decision says so.
+func (s *PostgresStorage) CloseReport(ctx context.Context, reportID uuid.UUID, decision models.ReportDecision) (models.Report, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return models.Report{}, err
+	}
+	defer tx.Rollback()
+
+	var target struct {
+		Type models.ReportTarget `db:"target_type"`
+		ID   uuid.UUID           `db:"target_id"`
+	}
+	query := `SELECT target_type, target_id FROM reports WHERE id = $1 AND status = 'OPEN' FOR UPDATE`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err = tx.GetContext(qctx, &target, query, reportID)
+	endQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetReport(ctx, reportID); err != nil {
+			return models.Report{}, err
+		}
+		return models.Report{}, models.ErrReportClosed
+	}
+	if err != nil {
+		slog.Error("Failed to get report", "error", err, "reportID", reportID)
+		return models.Report{}, err
+	}
+
+	query = `UPDATE reports SET status = $1, closed_by = $2, closed_at = $3, target_hidden = $4
+              WHERE target_type = $5 AND target_id = $6 AND status = 'OPEN'`
+	qctx, span = startQuerySpan(ctx, "UPDATE", query)
+	_, err = tx.ExecContext(qctx, query, decision.Status, decision.ModeratorID, decision.At, decision.HideTarget, target.Type, target.ID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to close reports", "error", err, "reportID", reportID)
+		return models.Report{}, err
+	}
+	if decision.HideTarget {
+		if err := hideContent(ctx, tx, target.Type, target.ID); err != nil {
+			return models.Report{}, err
+		}
+	}
+
+	if err := commit(ctx, tx); err != nil {
+		return models.Report{}, err
+	}
+	return s.GetReport(ctx, reportID)
+}
+
+// hideContent hides the post or comment a report is about.
+func hideContent(ctx context.Context, tx *sqlx.Tx, targetType models.ReportTarget, targetID uuid.UUID) error {
+	table := "posts"
+	if targetType == models.ReportComment {
+		table = "comments"
+	}
+	query := `UPDATE ` + table + ` SET hidden = TRUE WHERE id = $1`
+	qctx, span := startQuerySpan(ctx, "UPDATE", query)
+	_, err := tx.ExecContext(qctx, query, targetID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to hide content", "error", err, "target", targetType, "targetID", targetID)
+	}
+	return err
+}
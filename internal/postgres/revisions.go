@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+func insertRevision(ctx context.Context, tx *sqlx.Tx, revision models.PostRevision) error {
+	query := `INSERT INTO post_revisions (post_id, version, title, content, allow_comments, editor_id, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err := tx.ExecContext(qctx, query, revision.PostID, revision.Version, revision.Title, revision.Content,
+		revision.AllowComments, revision.EditorID, revision.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to record post revision", "error", err, "postID", revision.PostID, "version", revision.Version)
+	}
+	return err
+}
+
+func commit(ctx context.Context, tx *sqlx.Tx) error {
+	_, span := startQuerySpan(ctx, "COMMIT", "COMMIT")
+	err := tx.Commit()
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// ListPostRevisions retrieves a paginated list of a post's revisions, newest first.
+func (s *PostgresStorage) ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.PostRevision, error) {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.PostRevision{}
+	query := `SELECT post_id, version, title, content, allow_comments, editor_id, created_at
+              FROM post_revisions
+              WHERE post_id = $1
+              ORDER BY version DESC
+              LIMIT $2 OFFSET $3`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &revisions, query, postID, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list post revisions", "error", err, "postID", postID)
+	}
+	return revisions, err
+}
+
+// GetPostRevision retrieves a post as it was at version.
+func (s *PostgresStorage) GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (models.PostRevision, error) {
+	var revision models.PostRevision
+	query := `SELECT post_id, version, title, content, allow_comments, editor_id, created_at
+              FROM post_revisions WHERE post_id = $1 AND version = $2`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.GetContext(qctx, &revision, query, postID, version)
+	endQuerySpan(span, err)
+	if err == sql.ErrNoRows {
+		slog.Warn("Revision not found", "postID", postID, "version", version)
+		return revision, models.ErrRevisionNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get post revision", "error", err, "postID", postID, "version", version)
+	}
+	return revision, err
+}
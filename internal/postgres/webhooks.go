@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/exp/slog"
+)
+
+// webhookRow is a webhook as stored.
+type webhookRow struct {
+	ID        uuid.UUID      `db:"id"`
+	URL       string         `db:"url"`
+	Secret    string         `db:"secret"`
+	Events    pq.StringArray `db:"events"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// CreateWebhook inserts a webhook.
+func (s *PostgresStorage) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	events := make(pq.StringArray, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+	query := `INSERT INTO webhooks (id, url, secret, events, created_at) VALUES ($1, $2, $3, $4, $5)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	_, err := s.db.ExecContext(qctx, query, webhook.ID, webhook.URL, webhook.Secret, events, webhook.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to create webhook", "error", err, "webhookID", webhook.ID)
+	}
+	return err
+}
+
+// ListWebhooks retrieves every webhook, oldest first.
+func (s *PostgresStorage) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	var rows []webhookRow
+	query := `SELECT id, url, secret, events, created_at FROM webhooks ORDER BY created_at, id`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &rows, query)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list webhooks", "error", err)
+		return nil, err
+	}
+
+	webhooks := make([]models.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhook := models.Webhook{ID: row.ID, URL: row.URL, Secret: row.Secret, CreatedAt: row.CreatedAt}
+		for _, event := range row.Events {
+			webhook.Events = append(webhook.Events, models.WebhookEvent(event))
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook; its deliveries go with it.
+func (s *PostgresStorage) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+	qctx, span := startQuerySpan(ctx, "DELETE", query)
+	res, err := s.db.ExecContext(qctx, query, webhookID)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to delete webhook", "error", err, "webhookID", webhookID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// CreateWebhookDelivery records a delivery attempt of an existing webhook.
+func (s *PostgresStorage) CreateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload, attempt, status_code, error, created_at)
+              SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9 WHERE EXISTS (SELECT 1 FROM webhooks WHERE id = $2)`
+	qctx, span := startQuerySpan(ctx, "INSERT", query)
+	res, err := s.db.ExecContext(qctx, query, d.ID, d.WebhookID, d.EventID, d.Event, d.Payload, d.Attempt, d.StatusCode, d.Error, d.CreatedAt)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to create webhook delivery", "error", err, "webhookID", d.WebhookID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListWebhookDeliveries retrieves a page of a webhook's deliveries, newest
+// first.
+func (s *PostgresStorage) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := `SELECT id, webhook_id, event_id, event, payload, attempt, status_code, error, created_at
+              FROM webhook_deliveries
+              WHERE webhook_id = $1
+              ORDER BY created_at DESC, id DESC
+              LIMIT $2 OFFSET $3`
+	qctx, span := startQuerySpan(ctx, "SELECT", query)
+	err := s.db.SelectContext(qctx, &deliveries, query, webhookID, pageSize, (page-1)*pageSize)
+	endQuerySpan(span, err)
+	if err != nil {
+		slog.Error("Failed to list webhook deliveries", "error", err, "webhookID", webhookID)
+	}
+	return deliveries, err
+}
@@ -3,10 +3,14 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func TestInMemoryPubSub_SubscribeAndPublish(t *testing.T) {
@@ -69,3 +73,158 @@ func TestInMemoryPubSub_Unsubscribe(t *testing.T) {
 		t.Errorf("Publish() error = %v", err)
 	}
 }
+
+func TestInMemoryPubSub_Close(t *testing.T) {
+	ps := NewInMemoryPubSub()
+	postID := uuid.New()
+
+	ch, err := ps.Subscribe(context.Background(), postID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if err := ps.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Errorf("Expected channel to be closed, but it was open")
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("Expected channel to be closed, but it was open")
+	}
+
+	if _, err := ps.Subscribe(context.Background(), postID); err != ErrClosed {
+		t.Errorf("Subscribe() after Close error = %v, want %v", err, ErrClosed)
+	}
+	if err := ps.Publish(context.Background(), postID, "late"); err != ErrClosed {
+		t.Errorf("Publish() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestInMemoryPubSub_CloseWithStalledSubscriber(t *testing.T) {
+	ps := NewInMemoryPubSub()
+	postID := uuid.New()
+
+	if _, err := ps.Subscribe(context.Background(), postID); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	// The first message fills the channel's buffer; the second waits for a
+	// reader that never comes.
+	if err := ps.Publish(context.Background(), postID, "1"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	published := make(chan error, 1)
+	go func() { published <- ps.Publish(context.Background(), postID, "2") }()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- ps.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("Close() blocked on a subscriber that is not reading")
+	}
+	if err := <-published; err != ErrClosed {
+		t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestInMemoryPubSub_PublishSkipsUnsubscribed(t *testing.T) {
+	ps := NewInMemoryPubSub()
+	postID := uuid.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := ps.Subscribe(ctx, postID); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := ps.Publish(context.Background(), postID, "1"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	published := make(chan error, 1)
+	go func() { published <- ps.Publish(context.Background(), postID, "2") }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Errorf("Publish() error = %v", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("Publish() kept waiting for a subscriber that went away")
+	}
+}
+
+// quietLogs discards log output so that logging does not dominate benchmarks.
+func quietLogs(b *testing.B) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+}
+
+// subscribeDrained subscribes n readers to postID that discard every message.
+func subscribeDrained(b *testing.B, ps *InMemoryPubSub, postID uuid.UUID, n int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		ch, err := ps.Subscribe(ctx, postID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ch {
+			}
+		}()
+	}
+	b.Cleanup(func() {
+		cancel()
+		ps.Close()
+		wg.Wait()
+	})
+}
+
+func BenchmarkPublishFanOut(b *testing.B) {
+	quietLogs(b)
+	for _, n := range []int{1, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			ps := NewInMemoryPubSub()
+			postID := uuid.New()
+			subscribeDrained(b, ps, postID, n)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := ps.Publish(context.Background(), postID, "message"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPublishParallel(b *testing.B) {
+	quietLogs(b)
+	ps := NewInMemoryPubSub()
+	posts := make([]uuid.UUID, 100)
+	for i := range posts {
+		posts[i] = uuid.New()
+		subscribeDrained(b, ps, posts[i], 10)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if err := ps.Publish(context.Background(), posts[i%len(posts)], "message"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
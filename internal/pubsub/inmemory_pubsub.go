@@ -10,14 +10,27 @@ import (
 )
 
 type InMemoryPubSub struct {
-	subscribers map[uuid.UUID]map[chan string]struct{}
+	subscribers map[uuid.UUID]map[*subscriber]struct{}
 	mu          sync.RWMutex
+	closed      bool
+	// done is closed as soon as Close is called, before it takes mu, so
+	// that Publish calls blocked on a slow subscriber give up.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type subscriber struct {
+	ch chan string
+	// gone is closed when the subscriber's context ends, so that Publish
+	// stops waiting for it to read.
+	gone chan struct{}
 }
 
 // NewInMemoryPubSub creates a new instance of InMemoryPubSub.
 func NewInMemoryPubSub() *InMemoryPubSub {
 	return &InMemoryPubSub{
-		subscribers: make(map[uuid.UUID]map[chan string]struct{}),
+		subscribers: make(map[uuid.UUID]map[*subscriber]struct{}),
+		done:        make(chan struct{}),
 	}
 }
 
@@ -27,38 +40,59 @@ func (ps *InMemoryPubSub) Subscribe(ctx context.Context, postID uuid.UUID) (<-ch
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
+	if ps.closed {
+		return nil, ErrClosed
+	}
+
 	slog.Info("Subscribing to post", "postID", postID)
 
-	ch := make(chan string, 1)
+	sub := &subscriber{ch: make(chan string, 1), gone: make(chan struct{})}
 	if _, ok := ps.subscribers[postID]; !ok {
-		ps.subscribers[postID] = make(map[chan string]struct{})
+		ps.subscribers[postID] = make(map[*subscriber]struct{})
 	}
-	ps.subscribers[postID][ch] = struct{}{}
+	ps.subscribers[postID][sub] = struct{}{}
 
 	// Goroutine to handle cleanup when context is done.
+	// Close may already have removed and closed the channel.
 	go func() {
-		<-ctx.Done()
+		select {
+		case <-ctx.Done():
+		case <-ps.done:
+			return
+		}
+		close(sub.gone)
 		ps.mu.Lock()
-		delete(ps.subscribers[postID], ch)
+		if _, ok := ps.subscribers[postID][sub]; ok {
+			delete(ps.subscribers[postID], sub)
+			close(sub.ch)
+		}
 		ps.mu.Unlock()
-		close(ch)
 		slog.Info("Unsubscribed from post", "postID", postID)
 	}()
 
-	return ch, nil
+	return sub.ch, nil
 }
 
-// Publish sends a message to all subscribers of the given postID.
+// Publish sends a message to all subscribers of the given postID, waiting
+// for each to have room for it. Subscribers that go away are skipped, and
+// Close makes a waiting Publish return ErrClosed.
 func (ps *InMemoryPubSub) Publish(ctx context.Context, postID uuid.UUID, message string) error {
 	ps.mu.RLock()
 	defer ps.mu.RUnlock()
 
+	if ps.closed {
+		return ErrClosed
+	}
+
 	slog.Info("Publishing message to post", "postID", postID, "message", message)
 
 	if subscribers, ok := ps.subscribers[postID]; ok {
-		for ch := range subscribers {
+		for sub := range subscribers {
 			select {
-			case ch <- message:
+			case sub.ch <- message:
+			case <-sub.gone:
+			case <-ps.done:
+				return ErrClosed
 			case <-ctx.Done():
 				slog.Warn("Publishing interrupted by context done", "postID", postID)
 				return ctx.Err()
@@ -70,3 +104,26 @@ func (ps *InMemoryPubSub) Publish(ctx context.Context, postID uuid.UUID, message
 	}
 	return nil
 }
+
+// Close closes every subscriber channel. Subsequent Subscribe and Publish
+// calls return ErrClosed.
+func (ps *InMemoryPubSub) Close() error {
+	ps.closeOnce.Do(func() { close(ps.done) })
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+
+	for postID, subscribers := range ps.subscribers {
+		for sub := range subscribers {
+			close(sub.ch)
+		}
+		delete(ps.subscribers, postID)
+	}
+
+	slog.Info("PubSub closed")
+	return nil
+}
@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 )
@@ -9,4 +10,9 @@ import (
 type PubSub interface {
 	Subscribe(ctx context.Context, postID uuid.UUID) (<-chan string, error)
 	Publish(ctx context.Context, postID uuid.UUID, message string) error
+	// Close ends all active subscriptions by closing their channels and
+	// rejects further Subscribe and Publish calls.
+	Close() error
 }
+
+var ErrClosed = errors.New("pubsub is closed")
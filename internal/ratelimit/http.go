@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the client's IP address.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the client's IP address, if known.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok
+}
+
+// Middleware records the client's IP address in the request context. With
+// header set, e.g. to X-Forwarded-For, the address is taken from the last
+// entry of that header, which is the one added by the trusted proxy in
+// front of the server; otherwise it is the address of the connection.
+func Middleware(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ""
+		if header != "" {
+			if values := r.Header.Values(header); len(values) > 0 {
+				entries := strings.Split(values[len(values)-1], ",")
+				ip = strings.TrimSpace(entries[len(entries)-1])
+			}
+		}
+		if ip == "" {
+			ip = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				ip = host
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), ip)))
+	})
+}
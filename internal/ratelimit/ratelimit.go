@@ -0,0 +1,168 @@
+// Package ratelimit throttles operations with token buckets keyed by user or
+// client address.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Limit is a token bucket holding Tokens, refilled at Tokens per Per.
+type Limit struct {
+	Tokens int
+	Per    time.Duration
+}
+
+// Interval is how long the bucket takes to regain one token.
+func (l Limit) Interval() time.Duration {
+	return l.Per / time.Duration(l.Tokens)
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Tokens, l.Per)
+}
+
+// DefaultOperation names the rule for operations without one of their own.
+const DefaultOperation = "*"
+
+// ParseRules parses operation=count/period rules, e.g. createComment=20/1m.
+func ParseRules(rules []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(rules))
+	for _, rule := range rules {
+		operation, spec, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		count, period, ok2 := strings.Cut(spec, "/")
+		if !ok || !ok2 || operation == "" {
+			return nil, fmt.Errorf("rate limit %q is not of the form operation=count/period", rule)
+		}
+		tokens, err := strconv.Atoi(count)
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("rate limit %q: count must be a positive integer", rule)
+		}
+		per, err := time.ParseDuration(period)
+		if err != nil || per <= 0 {
+			return nil, fmt.Errorf("rate limit %q: period must be a positive duration", rule)
+		}
+		if _, dup := limits[operation]; dup {
+			return nil, fmt.Errorf("rate limit for %s is set twice", operation)
+		}
+		limits[operation] = Limit{Tokens: tokens, Per: per}
+	}
+	return limits, nil
+}
+
+// Store keeps token buckets. A bucket is stored as the time at which it
+// will be full again, which is all a token bucket needs to know.
+type Store interface {
+	// Take removes a token from the bucket key, which has limit, at now.
+	// An empty bucket is left alone and Take reports how long until it
+	// holds a token again.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (ok bool, retryAfter time.Duration, err error)
+}
+
+// take applies a request at now to a bucket that is full at fullAt,
+// returning the new fullAt, or how long to wait if the bucket is empty.
+func take(fullAt time.Time, limit Limit, now time.Time) (time.Time, time.Duration, bool) {
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+	// The bucket is empty once refilling it takes longer than Per minus
+	// the time one token takes.
+	if wait := fullAt.Sub(now) - (limit.Per - limit.Interval()); wait > 0 {
+		return fullAt, wait, false
+	}
+	return fullAt.Add(limit.Interval()), 0, true
+}
+
+// MemoryStore keeps buckets in memory, so each replica limits on its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time // full at
+	swept   time.Time
+}
+
+// sweepInterval is how often a MemoryStore forgets full buckets.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]time.Time)}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) >= sweepInterval {
+		s.swept = now
+		for k, fullAt := range s.buckets {
+			if !fullAt.After(now) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	fullAt, wait, ok := take(s.buckets[key], limit, now)
+	if ok {
+		s.buckets[key] = fullAt
+	}
+	return ok, wait, nil
+}
+
+// Len returns the number of buckets held.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// LimitedError is returned for an operation whose bucket is empty.
+type LimitedError struct {
+	Operation  string
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s, retry in %s", e.Operation, e.RetryAfter.Round(time.Second))
+}
+
+// Limiter applies per-operation limits.
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+}
+
+// NewLimiter creates a Limiter keeping buckets in store. Operations
+// without an entry in limits use the DefaultOperation entry, if any.
+func NewLimiter(store Store, limits map[string]Limit) *Limiter {
+	return &Limiter{store: store, limits: limits}
+}
+
+// Allow takes a token from subject's bucket for operation and returns a
+// *LimitedError if there is none. Errors of the store are logged and let
+// the operation through, so that an outage of the store does not stop
+// every write.
+func (l *Limiter) Allow(ctx context.Context, operation, subject string) error {
+	limit, ok := l.limits[operation]
+	if !ok {
+		if limit, ok = l.limits[DefaultOperation]; !ok {
+			return nil
+		}
+	}
+	allowed, retryAfter, err := l.store.Take(ctx, operation+"|"+subject, limit, time.Now())
+	if err != nil {
+		slog.Error("Failed to check rate limit", "error", err, "operation", operation)
+		return nil
+	}
+	if !allowed {
+		slog.Warn("Rate limit exceeded", "operation", operation, "subject", subject, "retryAfter", retryAfter)
+		return &LimitedError{Operation: operation, RetryAfter: retryAfter}
+	}
+	return nil
+}
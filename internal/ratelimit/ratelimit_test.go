@@ -0,0 +1,95 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ozon-test/internal/ratelimit"
+	"ozon-test/internal/ratelimit/ratelimittest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ratelimittest.Run(t, func(t *testing.T) ratelimit.Store { return ratelimit.NewMemoryStore() })
+}
+
+func TestMemoryStoreForgetsFullBuckets(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Tokens: 1, Per: time.Second}
+	now := time.Now()
+	_, _, err := store.Take(context.Background(), "a", limit, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.Len())
+
+	_, _, err = store.Take(context.Background(), "b", limit, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.Len(), "Buckets that are full again are dropped")
+}
+
+func TestParseRules(t *testing.T) {
+	limits, err := ratelimit.ParseRules([]string{"createComment=20/1m", " *=100/1h"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ratelimit.Limit{
+		"createComment": {Tokens: 20, Per: time.Minute},
+		"*":             {Tokens: 100, Per: time.Hour},
+	}, limits)
+
+	for _, rule := range []string{"createComment", "=1/1m", "createComment=0/1m", "createComment=1/0s", "createComment=x/1m"} {
+		_, err := ratelimit.ParseRules([]string{rule})
+		assert.Error(t, err, rule)
+	}
+	_, err = ratelimit.ParseRules([]string{"a=1/1s", "a=2/1s"})
+	assert.Error(t, err, "Duplicate rules are rejected")
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+		"createComment": {Tokens: 2, Per: time.Minute},
+		"*":             {Tokens: 1, Per: time.Minute},
+	})
+
+	require.NoError(t, limiter.Allow(ctx, "createComment", "user:a"))
+	require.NoError(t, limiter.Allow(ctx, "createComment", "user:a"))
+	err := limiter.Allow(ctx, "createComment", "user:a")
+	var limited *ratelimit.LimitedError
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, "createComment", limited.Operation)
+	assert.InDelta(t, 30*time.Second, limited.RetryAfter, float64(time.Second))
+
+	require.NoError(t, limiter.Allow(ctx, "createComment", "user:b"), "Subjects have their own buckets")
+	require.NoError(t, limiter.Allow(ctx, "createPost", "user:a"), "Operations have their own buckets")
+	assert.Error(t, limiter.Allow(ctx, "createPost", "user:a"), "The default rule applies to other operations")
+
+	unlimited := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), nil)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, unlimited.Allow(ctx, "createPost", "user:a"))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	clientIP := func(header string, configure func(r *http.Request)) string {
+		var ip string
+		handler := ratelimit.Middleware(header, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _ = ratelimit.ClientIPFromContext(r.Context())
+		}))
+		r := httptest.NewRequest(http.MethodPost, "/query", nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		configure(r)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return ip
+	}
+
+	assert.Equal(t, "10.0.0.1", clientIP("", func(r *http.Request) { r.Header.Set("X-Forwarded-For", "1.2.3.4") }),
+		"Headers are ignored unless configured")
+	assert.Equal(t, "5.6.7.8", clientIP("X-Forwarded-For", func(r *http.Request) {
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
+	}), "The proxy's entry is used, not one the client sent")
+	assert.Equal(t, "10.0.0.1", clientIP("X-Forwarded-For", func(r *http.Request) {}))
+}
@@ -0,0 +1,81 @@
+// Package ratelimittest checks that ratelimit.Store implementations behave
+// alike.
+package ratelimittest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the conformance suite, calling newStore for a fresh, empty store
+// in each subtest.
+func Run(t *testing.T, newStore func(t *testing.T) ratelimit.Store) {
+	t.Run("Burst", func(t *testing.T) { testBurst(t, newStore(t)) })
+	t.Run("Refill", func(t *testing.T) { testRefill(t, newStore(t)) })
+	t.Run("SeparateKeys", func(t *testing.T) { testSeparateKeys(t, newStore(t)) })
+}
+
+// now is truncated so that it survives a round trip through a database.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func take(t *testing.T, store ratelimit.Store, key string, limit ratelimit.Limit, at time.Time) (bool, time.Duration) {
+	t.Helper()
+	ok, retryAfter, err := store.Take(context.Background(), key, limit, at)
+	require.NoError(t, err)
+	return ok, retryAfter
+}
+
+func testBurst(t *testing.T, store ratelimit.Store) {
+	limit := ratelimit.Limit{Tokens: 3, Per: time.Minute}
+	at := now()
+	for i := 0; i < 3; i++ {
+		ok, _ := take(t, store, "k", limit, at)
+		assert.True(t, ok, "A full bucket allows a burst of %d", limit.Tokens)
+	}
+	ok, retryAfter := take(t, store, "k", limit, at)
+	assert.False(t, ok)
+	assert.Equal(t, 20*time.Second, retryAfter, "One token comes back every Per/Tokens")
+
+	ok, retryAfter = take(t, store, "k", limit, at.Add(5*time.Second))
+	assert.False(t, ok, "Refused requests do not use up tokens")
+	assert.Equal(t, 15*time.Second, retryAfter)
+}
+
+func testRefill(t *testing.T, store ratelimit.Store) {
+	limit := ratelimit.Limit{Tokens: 2, Per: time.Minute}
+	at := now()
+	take(t, store, "k", limit, at)
+	take(t, store, "k", limit, at)
+
+	ok, _ := take(t, store, "k", limit, at.Add(30*time.Second))
+	assert.True(t, ok, "A token is back after Per/Tokens")
+	ok, _ = take(t, store, "k", limit, at.Add(30*time.Second))
+	assert.False(t, ok)
+
+	later := at.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		ok, _ = take(t, store, "k", limit, later)
+		assert.True(t, ok)
+	}
+	ok, _ = take(t, store, "k", limit, later)
+	assert.False(t, ok, "An idle bucket holds no more than Tokens")
+}
+
+func testSeparateKeys(t *testing.T, store ratelimit.Store) {
+	limit := ratelimit.Limit{Tokens: 1, Per: time.Minute}
+	at := now()
+	ok, _ := take(t, store, "a", limit, at)
+	assert.True(t, ok)
+	ok, _ = take(t, store, "b", limit, at)
+	assert.True(t, ok, "Buckets are independent")
+	ok, _ = take(t, store, "a", limit, at)
+	assert.False(t, ok)
+}
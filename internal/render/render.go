@@ -0,0 +1,105 @@
+// Package render turns post and comment content into HTML that is safe to
+// embed in a page.
+package render
+
+import (
+	"bytes"
+	"html"
+	"ozon-test/internal/models"
+	"strings"
+
+	"github.com/hashicorp/golang-lru/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/exp/slog"
+)
+
+// Renderer renders content and caches the result. It is safe for
+// concurrent use.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+	cache    *lru.Cache[string, string] // nil if caching is off
+}
+
+// NewRenderer returns a Renderer that keeps up to cacheSize rendered
+// documents; zero turns caching off.
+func NewRenderer(cacheSize int) *Renderer {
+	r := &Renderer{
+		// Raw HTML in the source is dropped by goldmark unless
+		// html.WithUnsafe is set; the sanitizer is a second line of defence.
+		markdown: goldmark.New(goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.Linkify)),
+		policy:   policy(),
+	}
+	if cacheSize > 0 {
+		r.cache, _ = lru.New[string, string](cacheSize)
+	}
+	return r
+}
+
+// policy is the allowlist applied to rendered markdown: text formatting,
+// lists, quotes, code, tables and http(s)/mailto links. Anything else,
+// images and inline styles included, is removed.
+func policy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"strong", "em", "del", "code", "pre", "blockquote", "ul", "ol", "li",
+		"table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(bluemonday.CellAlign).OnElements("th", "td")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	return p
+}
+
+// HTML renders content written in format. key must identify this exact
+// content, such as a post ID and version, since the result is cached under
+// it; an empty key bypasses the cache.
+func (r *Renderer) HTML(key string, format models.ContentFormat, content string) string {
+	if r.cache != nil && key != "" {
+		if out, ok := r.cache.Get(key); ok {
+			return out
+		}
+	}
+
+	var out string
+	if format == models.FormatMarkdown {
+		out = r.renderMarkdown(content)
+	} else {
+		out = renderPlain(content)
+	}
+
+	if r.cache != nil && key != "" {
+		r.cache.Add(key, out)
+	}
+	return out
+}
+
+func (r *Renderer) renderMarkdown(content string) string {
+	var buf bytes.Buffer
+	if err := r.markdown.Convert([]byte(content), &buf); err != nil {
+		slog.Error("Failed to render markdown", "error", err)
+		return renderPlain(content)
+	}
+	return r.policy.Sanitize(buf.String())
+}
+
+// renderPlain escapes content, turning blank-line separated blocks into
+// paragraphs and the remaining newlines into line breaks.
+func renderPlain(content string) string {
+	var b strings.Builder
+	for _, block := range strings.Split(content, "\n\n") {
+		block = strings.Trim(block, "\n")
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(block), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package render_test
+
+import (
+	"testing"
+
+	"ozon-test/internal/models"
+	"ozon-test/internal/render"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdown(t *testing.T) {
+	r := render.NewRenderer(0)
+
+	out := r.HTML("", models.FormatMarkdown, "# Title\n\nSome **bold** and ~~gone~~ text.\n\n- one\n- two")
+	assert.Equal(t, "<h1>Title</h1>\n<p>Some <strong>bold</strong> and <del>gone</del> text.</p>\n<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n", out)
+}
+
+func TestMarkdownIsSanitised(t *testing.T) {
+	r := render.NewRenderer(0)
+
+	for name, src := range map[string]string{
+		"raw html":      "<script>alert(1)</script><b onclick=\"x()\">hi</b>",
+		"js link":       "[click](javascript:alert(1))",
+		"image":         "![pixel](https://tracker.example/p.gif)",
+		"autolink html": "<a href=\"https://example.com\" style=\"color:red\">x</a>",
+	} {
+		out := r.HTML("", models.FormatMarkdown, src)
+		for _, bad := range []string{"<script", "onclick", "javascript:", "<img", "style="} {
+			assert.NotContains(t, out, bad, name)
+		}
+	}
+
+	out := r.HTML("", models.FormatMarkdown, "[site](https://example.com)")
+	assert.Equal(t, `<p><a href="https://example.com" rel="nofollow noreferrer">site</a></p>`+"\n", out)
+}
+
+func TestPlain(t *testing.T) {
+	r := render.NewRenderer(0)
+
+	out := r.HTML("", models.FormatPlain, "a <b> & c\nnext line\n\n\n**not bold**")
+	assert.Equal(t, "<p>a &lt;b&gt; &amp; c<br>\nnext line</p>\n<p>**not bold**</p>\n", out)
+	assert.Equal(t, out, r.HTML("", "", "a <b> & c\nnext line\n\n\n**not bold**"), "An empty format is plain text")
+}
+
+func TestCacheIsKeyed(t *testing.T) {
+	r := render.NewRenderer(10)
+
+	first := r.HTML("post:1", models.FormatMarkdown, "*one*")
+	assert.Equal(t, first, r.HTML("post:1", models.FormatMarkdown, "*changed*"), "The same key is served from the cache")
+	assert.NotEqual(t, first, r.HTML("post:2", models.FormatMarkdown, "*changed*"))
+}
@@ -0,0 +1,241 @@
+// Package seed fills a models.Storage with generated posts and comment
+// threads shaped like real discussions, for benchmarking and load tests.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"ozon-test/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Options controls the size and shape of the generated data.
+type Options struct {
+	// Users is the number of distinct authors. Activity follows a Zipf
+	// distribution, so a few users write most of the content.
+	Users int
+	// Posts is the number of posts to create.
+	Posts int
+	// CommentsPerPost is the mean number of comments per post. Counts are
+	// exponentially distributed, so most posts are quiet and a few are busy.
+	CommentsPerPost float64
+	// ReplyProbability is the chance that a comment replies to an earlier
+	// comment instead of starting a new thread. Replies favour recent
+	// comments, which produces long back-and-forth chains.
+	ReplyProbability float64
+	// MaxDepth caps thread nesting; top-level comments have depth 1.
+	MaxDepth int
+	// Seed makes the output reproducible.
+	Seed int64
+	// Start is the creation time of the oldest post; zero means Posts hours
+	// before now.
+	Start time.Time
+}
+
+// DefaultOptions returns a modest data set suitable for local benchmarking.
+func DefaultOptions() Options {
+	return Options{
+		Users:            1000,
+		Posts:            1000,
+		CommentsPerPost:  50,
+		ReplyProbability: 0.7,
+		MaxDepth:         20,
+		Seed:             1,
+	}
+}
+
+// Stats describes the generated data.
+type Stats struct {
+	Users    int
+	Posts    int
+	Comments int
+	// MaxDepth is the deepest thread actually generated.
+	MaxDepth int
+}
+
+func (o Options) validate() error {
+	switch {
+	case o.Users < 1:
+		return fmt.Errorf("users must be positive")
+	case o.Posts < 0:
+		return fmt.Errorf("posts must not be negative")
+	case o.CommentsPerPost < 0:
+		return fmt.Errorf("comments per post must not be negative")
+	case o.ReplyProbability < 0 || o.ReplyProbability > 1:
+		return fmt.Errorf("reply probability must be between 0 and 1")
+	case o.MaxDepth < 1:
+		return fmt.Errorf("max depth must be positive")
+	}
+	return nil
+}
+
+// Generate writes the data described by opts into storage.
+func Generate(ctx context.Context, storage models.Storage, opts Options) (Stats, error) {
+	if err := opts.validate(); err != nil {
+		return Stats{}, err
+	}
+
+	g := newGenerator(opts)
+	stats := Stats{Users: opts.Users}
+	for i := 0; i < opts.Posts; i++ {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		post := g.post(i)
+		if err := storage.CreatePost(ctx, post); err != nil {
+			return stats, fmt.Errorf("create post: %w", err)
+		}
+		stats.Posts++
+
+		comments, depth := g.thread(post)
+		for _, comment := range comments {
+			if err := storage.CreateComment(ctx, comment); err != nil {
+				return stats, fmt.Errorf("create comment: %w", err)
+			}
+		}
+		stats.Comments += len(comments)
+		stats.MaxDepth = max(stats.MaxDepth, depth)
+
+		if (i+1)%100 == 0 {
+			slog.Info("Seeding", "posts", stats.Posts, "comments", stats.Comments)
+		}
+	}
+
+	slog.Info("Seeding finished", "users", stats.Users, "posts", stats.Posts, "comments", stats.Comments, "maxDepth", stats.MaxDepth)
+	return stats, nil
+}
+
+type generator struct {
+	opts  Options
+	rand  *rand.Rand
+	users []uuid.UUID
+	zipf  *rand.Zipf
+	start time.Time
+}
+
+func newGenerator(opts Options) *generator {
+	r := rand.New(rand.NewSource(opts.Seed))
+
+	users := make([]uuid.UUID, opts.Users)
+	for i := range users {
+		users[i] = randomUUID(r)
+	}
+
+	start := opts.Start
+	if start.IsZero() {
+		start = time.Now().Add(-time.Duration(opts.Posts) * time.Hour)
+	}
+
+	return &generator{
+		opts:  opts,
+		rand:  r,
+		users: users,
+		zipf:  rand.NewZipf(r, 1.1, 1, uint64(opts.Users-1)),
+		start: start.UTC().Truncate(time.Microsecond),
+	}
+}
+
+// randomUUID draws a random UUID from r so that IDs are reproducible.
+func randomUUID(r *rand.Rand) uuid.UUID {
+	var id uuid.UUID
+	r.Read(id[:])
+	id[6] = (id[6] & 0x0f) | 0x40 // version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return id
+}
+
+func (g *generator) user() uuid.UUID {
+	return g.users[g.zipf.Uint64()]
+}
+
+func (g *generator) post(i int) models.Post {
+	post := models.Post{
+		ID:            randomUUID(g.rand),
+		Title:         g.text(3 + g.rand.Intn(8)),
+		Content:       g.text(g.length(80)),
+		UserID:        g.user(),
+		AllowComments: g.rand.Float64() > 0.05,
+		CreatedAt:     g.start.Add(time.Duration(i) * time.Hour).Add(time.Duration(g.rand.Int63n(int64(time.Hour)))),
+		Version:       1,
+	}
+	post.UpdatedAt = post.CreatedAt
+	post.UpdatedBy = post.UserID
+	return post
+}
+
+// thread generates the comments of post, parents before replies, and returns
+// them with the deepest nesting level reached.
+func (g *generator) thread(post models.Post) ([]models.Comment, int) {
+	count := int(g.rand.ExpFloat64() * g.opts.CommentsPerPost)
+	comments := make([]models.Comment, 0, count)
+	depths := make([]int, 0, count)
+	parents := make([]int, 0, count) // index of each comment's parent, -1 for top level
+	maxDepth := 0
+
+	createdAt := post.CreatedAt
+	for i := 0; i < count; i++ {
+		// Gaps between comments grow as the discussion cools down.
+		createdAt = createdAt.Add(time.Duration(g.rand.ExpFloat64() * float64(time.Second) * float64(i+1) * 10))
+		comment := models.Comment{
+			ID:        randomUUID(g.rand),
+			PostID:    post.ID,
+			Content:   g.text(g.length(25)),
+			UserID:    g.user(),
+			CreatedAt: createdAt,
+		}
+
+		depth, parent := 1, -1
+		if len(comments) > 0 && g.opts.MaxDepth > 1 && g.rand.Float64() < g.opts.ReplyProbability {
+			parent = g.recent(len(comments))
+			// Replying below the depth limit moves the reply up the thread.
+			for depths[parent] >= g.opts.MaxDepth {
+				parent = parents[parent]
+			}
+			comment.ParentID = &comments[parent].ID
+			depth = depths[parent] + 1
+		}
+
+		comments = append(comments, comment)
+		depths = append(depths, depth)
+		parents = append(parents, parent)
+		maxDepth = max(maxDepth, depth)
+	}
+	return comments, maxDepth
+}
+
+// recent picks an index in [0, n) biased towards the end.
+func (g *generator) recent(n int) int {
+	back := int(g.rand.ExpFloat64() * 3)
+	if back >= n {
+		back = g.rand.Intn(n)
+	}
+	return n - 1 - back
+}
+
+// length draws a log-normally distributed word count with the given median.
+func (g *generator) length(median float64) int {
+	return max(1, int(median*math.Exp(g.rand.NormFloat64()*0.8)))
+}
+
+var words = strings.Fields(`the a graphql comment post thread reply server query
+	storage latency nested tree closure table index page subscription user
+	think agree disagree why because maybe really interesting point example
+	go postgres sqlite memory benchmark test data fast slow cache review`)
+
+func (g *generator) text(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(words[g.rand.Intn(len(words))])
+	}
+	return b.String()
+}
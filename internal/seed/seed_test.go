@@ -0,0 +1,85 @@
+package seed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/seed"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func options() seed.Options {
+	return seed.Options{
+		Users:            20,
+		Posts:            30,
+		CommentsPerPost:  40,
+		ReplyProbability: 0.9,
+		MaxDepth:         5,
+		Seed:             42,
+		Start:            time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func allComments(t *testing.T, storage models.Storage) []models.Comment {
+	t.Helper()
+	posts, err := storage.ListPosts(context.Background(), 1, 1000)
+	require.NoError(t, err)
+
+	var all []models.Comment
+	for _, post := range posts {
+		comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 100000)
+		require.NoError(t, err)
+		all = append(all, comments...)
+	}
+	return all
+}
+
+func TestGenerate(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	stats, err := seed.Generate(context.Background(), storage, options())
+	require.NoError(t, err)
+	assert.Equal(t, 30, stats.Posts)
+	assert.Equal(t, 5, stats.MaxDepth, "Threads should reach the depth limit")
+
+	comments := allComments(t, storage)
+	assert.Len(t, comments, stats.Comments)
+
+	byID := make(map[uuid.UUID]models.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	for _, c := range comments {
+		depth := 1
+		for parentID := c.ParentID; parentID != nil; depth++ {
+			parent, ok := byID[*parentID]
+			require.True(t, ok, "Parents should exist")
+			assert.Equal(t, c.PostID, parent.PostID)
+			assert.False(t, c.CreatedAt.Before(parent.CreatedAt), "Replies should not predate their parent")
+			parentID = parent.ParentID
+		}
+		assert.LessOrEqual(t, depth, 5)
+	}
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	first, second := inmemory.NewInMemoryStorage(), inmemory.NewInMemoryStorage()
+	_, err := seed.Generate(context.Background(), first, options())
+	require.NoError(t, err)
+	_, err = seed.Generate(context.Background(), second, options())
+	require.NoError(t, err)
+
+	assert.Equal(t, allComments(t, first), allComments(t, second))
+}
+
+func TestGenerateRejectsInvalidOptions(t *testing.T) {
+	opts := options()
+	opts.ReplyProbability = 2
+	_, err := seed.Generate(context.Background(), inmemory.NewInMemoryStorage(), opts)
+	assert.Error(t, err)
+}
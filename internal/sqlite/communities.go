@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+const communityColumns = `id, slug, title, description, owner_id, comment_policy, created_at`
+
+// CreateCommunity inserts a community and its moderators unless the slug is
+// taken.
+func (s *SQLiteStorage) CreateCommunity(ctx context.Context, community models.Community) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO communities (` + communityColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (slug) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, community.ID, community.Slug, community.Title, community.Description, community.OwnerID,
+		community.CommentPolicy, community.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to create community", "error", err, "slug", community.Slug)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrCommunitySlugTaken
+	}
+	if err := insertModerators(ctx, tx, community); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+func insertModerators(ctx context.Context, tx *sqlx.Tx, community models.Community) error {
+	for i, userID := range community.Moderators {
+		query := `INSERT INTO community_moderators (community_id, user_id, position) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, community.ID, userID, i); err != nil {
+			slog.Error("Failed to add community moderator", "error", err, "communityID", community.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCommunity retrieves a community by its ID.
+func (s *SQLiteStorage) GetCommunity(ctx context.Context, communityID uuid.UUID) (models.Community, error) {
+	return s.getCommunity(ctx, `id = ?`, communityID)
+}
+
+// GetCommunityBySlug retrieves a community by its slug.
+func (s *SQLiteStorage) GetCommunityBySlug(ctx context.Context, slug string) (models.Community, error) {
+	return s.getCommunity(ctx, `slug = ?`, slug)
+}
+
+func (s *SQLiteStorage) getCommunity(ctx context.Context, where string, arg any) (models.Community, error) {
+	var community models.Community
+	err := s.db.GetContext(ctx, &community, `SELECT `+communityColumns+` FROM communities WHERE `+where, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Community{}, models.ErrCommunityNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get community", "error", err)
+		return models.Community{}, err
+	}
+	communities := []models.Community{community}
+	if err := s.loadModerators(ctx, communities); err != nil {
+		return models.Community{}, err
+	}
+	return communities[0], nil
+}
+
+// ListCommunities retrieves a page of communities, oldest first.
+func (s *SQLiteStorage) ListCommunities(ctx context.Context, page, pageSize int) ([]models.Community, error) {
+	communities := []models.Community{}
+	query := `SELECT ` + communityColumns + ` FROM communities ORDER BY created_at, rowid LIMIT ? OFFSET ?`
+	if err := s.db.SelectContext(ctx, &communities, query, pageSize, (page-1)*pageSize); err != nil {
+		slog.Error("Failed to list communities", "error", err)
+		return nil, err
+	}
+	if err := s.loadModerators(ctx, communities); err != nil {
+		return nil, err
+	}
+	return communities, nil
+}
+
+// loadModerators fills in the moderators of communities.
+func (s *SQLiteStorage) loadModerators(ctx context.Context, communities []models.Community) error {
+	if len(communities) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*models.Community, len(communities))
+	ids := make([]uuid.UUID, 0, len(communities))
+	for i := range communities {
+		byID[communities[i].ID] = &communities[i]
+		ids = append(ids, communities[i].ID)
+	}
+
+	var rows []struct {
+		CommunityID uuid.UUID `db:"community_id"`
+		UserID      uuid.UUID `db:"user_id"`
+	}
+	query, args, err := sqlx.In(`SELECT community_id, user_id FROM community_moderators
+              WHERE community_id IN (?) ORDER BY community_id, position`, ids)
+	if err != nil {
+		return err
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		slog.Error("Failed to list community moderators", "error", err)
+		return err
+	}
+	for _, row := range rows {
+		community := byID[row.CommunityID]
+		community.Moderators = append(community.Moderators, row.UserID)
+	}
+	return nil
+}
+
+// UpdateCommunity replaces a community's title, description, moderators and
+// comment policy.
+func (s *SQLiteStorage) UpdateCommunity(ctx context.Context, community models.Community) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE communities SET title = ?, description = ?, comment_policy = ? WHERE id = ?`
+	res, err := tx.ExecContext(ctx, query, community.Title, community.Description, community.CommentPolicy, community.ID)
+	if err != nil {
+		slog.Error("Failed to update community", "error", err, "communityID", community.ID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrCommunityNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM community_moderators WHERE community_id = ?`, community.ID); err != nil {
+		slog.Error("Failed to clear community moderators", "error", err, "communityID", community.ID)
+		return err
+	}
+	if err := insertModerators(ctx, tx, community); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// ListCommunityPosts retrieves a paginated list of the posts of a community
+// viewerID may see.
+func (s *SQLiteStorage) ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	var posts []models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts
+              WHERE community_id = ? AND (status = 'PUBLISHED' OR user_id = ?) AND (? OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = ?)
+              ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &posts, query, communityID, viewerID, includeHidden, viewerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list community posts", "error", err, "communityID", communityID)
+	}
+	return posts, err
+}
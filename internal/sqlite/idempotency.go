@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// reserveAttempts bounds retries when a conflicting key disappears between
+// the insert and the lookup of the key that blocked it.
+const reserveAttempts = 3
+
+// ReserveIdempotencyKey inserts key, replacing an expired key with the same
+// scope, or returns the unexpired key that blocks it.
+func (s *SQLiteStorage) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (models.IdempotencyKey, bool, error) {
+	insert := `INSERT INTO idempotency_keys (user_id, key, operation, request_hash, resource_id, created_at, expires_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)
+              ON CONFLICT (user_id, key) DO UPDATE
+              SET operation = EXCLUDED.operation, request_hash = EXCLUDED.request_hash, resource_id = EXCLUDED.resource_id,
+                  created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+              WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`
+	lookup := `SELECT user_id, key, operation, request_hash, resource_id, created_at, expires_at
+              FROM idempotency_keys WHERE user_id = ? AND key = ?`
+
+	for attempt := 0; attempt < reserveAttempts; attempt++ {
+		res, err := s.db.ExecContext(ctx, insert, key.UserID, key.Key, key.Operation, key.RequestHash, key.ResourceID, key.CreatedAt.UTC(), key.ExpiresAt.UTC())
+		if err != nil {
+			slog.Error("Failed to reserve idempotency key", "error", err, "userID", key.UserID)
+			return models.IdempotencyKey{}, false, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return models.IdempotencyKey{}, false, err
+		} else if n == 1 {
+			return key, true, nil
+		}
+
+		var existing models.IdempotencyKey
+		err = s.db.GetContext(ctx, &existing, lookup, key.UserID, key.Key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to get idempotency key", "error", err, "userID", key.UserID)
+			return models.IdempotencyKey{}, false, err
+		}
+		return existing, false, nil
+	}
+	return models.IdempotencyKey{}, false, errors.New("idempotency key changed concurrently")
+}
+
+// DeleteIdempotencyKey removes the key if it still guards resourceID.
+func (s *SQLiteStorage) DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) error {
+	query := `DELETE FROM idempotency_keys WHERE user_id = ? AND key = ? AND resource_id = ?`
+	_, err := s.db.ExecContext(ctx, query, userID, key, resourceID)
+	if err != nil {
+		slog.Error("Failed to delete idempotency key", "error", err, "userID", userID)
+	}
+	return err
+}
+
+// PurgeIdempotencyKeys deletes keys that expired before the given time.
+func (s *SQLiteStorage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at < ?`
+	res, err := s.db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		slog.Error("Failed to purge idempotency keys", "error", err)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
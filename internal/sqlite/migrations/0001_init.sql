@@ -0,0 +1,29 @@
+CREATE TABLE posts (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    allow_comments BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX posts_created_at_idx ON posts (created_at);
+
+CREATE TABLE comments (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL REFERENCES posts(id),
+    parent_id TEXT,
+    content TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE structure_tree (
+    ancestor_id TEXT NOT NULL,
+    descendant_id TEXT NOT NULL,
+    nearest_ancestor_id TEXT NOT NULL,
+    level INTEGER NOT NULL,
+    subject_id TEXT NOT NULL,
+    PRIMARY KEY (ancestor_id, descendant_id)
+);
+CREATE INDEX structure_tree_descendant_idx ON structure_tree (descendant_id);
+CREATE INDEX structure_tree_subject_idx ON structure_tree (subject_id);
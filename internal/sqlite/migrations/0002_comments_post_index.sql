@@ -0,0 +1 @@
+CREATE INDEX comments_post_created_at_idx ON comments (post_id, created_at);
@@ -0,0 +1,18 @@
+ALTER TABLE posts ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN updated_by TEXT;
+UPDATE posts SET updated_at = created_at, updated_by = user_id;
+
+CREATE TABLE post_revisions (
+    post_id TEXT NOT NULL REFERENCES posts(id),
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    allow_comments BOOLEAN NOT NULL,
+    editor_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (post_id, version)
+);
+
+-- Earlier edits were not recorded, so history starts at the current version.
+INSERT INTO post_revisions (post_id, version, title, content, allow_comments, editor_id, created_at)
+SELECT id, version, title, content, allow_comments, updated_by, updated_at FROM posts;
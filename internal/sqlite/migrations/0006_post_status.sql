@@ -0,0 +1,5 @@
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'PUBLISHED';
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMP;
+UPDATE posts SET publish_at = created_at;
+
+CREATE INDEX posts_scheduled_idx ON posts (publish_at) WHERE status = 'SCHEDULED';
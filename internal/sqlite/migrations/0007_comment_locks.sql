@@ -0,0 +1,14 @@
+ALTER TABLE comments ADD COLUMN locked BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Replies used to be stored without their own (self, self) closure row, so
+-- their descendants missed them as ancestors. Rebuild the missing rows from
+-- the parent chain.
+WITH RECURSIVE chain (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id) AS (
+    SELECT id, id, COALESCE(parent_id, id), 0, post_id FROM comments
+    UNION ALL
+    SELECT c.parent_id, chain.descendant_id, chain.nearest_ancestor_id, chain.level + 1, chain.subject_id
+    FROM chain JOIN comments c ON c.id = chain.ancestor_id
+    WHERE c.parent_id IS NOT NULL
+)
+INSERT OR IGNORE INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id)
+SELECT ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id FROM chain;
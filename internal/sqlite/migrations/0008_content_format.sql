@@ -0,0 +1,4 @@
+-- An empty format is rendered as plain text, which is how every existing
+-- post and comment was written.
+ALTER TABLE posts ADD COLUMN format TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN format TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+CREATE TABLE usernames (
+    username TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE notifications (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    actor_id TEXT NOT NULL,
+    post_id TEXT NOT NULL REFERENCES posts(id),
+    comment_id TEXT REFERENCES comments(id),
+    created_at TIMESTAMP NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX notifications_user_idx ON notifications (user_id, created_at);
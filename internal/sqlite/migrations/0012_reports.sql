@@ -0,0 +1,20 @@
+ALTER TABLE posts ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE comments ADD COLUMN hidden BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE reports (
+    id TEXT PRIMARY KEY,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    reporter_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    closed_by TEXT,
+    closed_at TIMESTAMP,
+    target_hidden BOOLEAN NOT NULL DEFAULT FALSE
+);
+-- A user has at most one open report per target.
+CREATE UNIQUE INDEX reports_open_reporter_idx ON reports (reporter_id, target_type, target_id) WHERE status = 'OPEN';
+CREATE INDEX reports_queue_idx ON reports (status, created_at);
+CREATE INDEX reports_target_idx ON reports (target_type, target_id);
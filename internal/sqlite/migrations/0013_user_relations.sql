@@ -0,0 +1,8 @@
+CREATE TABLE user_relations (
+    user_id TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, target_id, kind)
+);
+CREATE INDEX user_relations_list_idx ON user_relations (user_id, kind, created_at);
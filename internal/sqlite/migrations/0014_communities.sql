@@ -0,0 +1,20 @@
+CREATE TABLE communities (
+    id TEXT PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id TEXT NOT NULL,
+    comment_policy TEXT NOT NULL DEFAULT 'OPEN',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Moderators are listed in position order.
+CREATE TABLE community_moderators (
+    community_id TEXT NOT NULL REFERENCES communities(id),
+    user_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (community_id, user_id)
+);
+
+ALTER TABLE posts ADD COLUMN community_id TEXT REFERENCES communities(id);
+CREATE INDEX posts_community_idx ON posts (community_id, created_at);
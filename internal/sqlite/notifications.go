@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+// SetUsername gives userID the username, releasing the one it had.
+func (s *SQLiteStorage) SetUsername(ctx context.Context, userID uuid.UUID, username string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM usernames WHERE user_id = ?`, userID); err != nil {
+		slog.Error("Failed to release username", "error", err, "userID", userID)
+		return err
+	}
+	query := `INSERT INTO usernames (username, user_id) VALUES (?, ?) ON CONFLICT (username) DO NOTHING`
+	res, err := tx.ExecContext(ctx, query, username, userID)
+	if err != nil {
+		slog.Error("Failed to set username", "error", err, "userID", userID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		slog.Warn("Username taken", "username", username)
+		return models.ErrUsernameTaken
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// ResolveUsernames maps each held username to its user.
+func (s *SQLiteStorage) ResolveUsernames(ctx context.Context, usernames []string) (map[string]uuid.UUID, error) {
+	users := make(map[string]uuid.UUID, len(usernames))
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	query, args, err := sqlx.In(`SELECT user_id, username FROM usernames WHERE username IN (?)`, usernames)
+	if err != nil {
+		return nil, err
+	}
+	var rows []models.Username
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		slog.Error("Failed to resolve usernames", "error", err)
+		return nil, err
+	}
+	for _, row := range rows {
+		users[row.Name] = row.UserID
+	}
+	return users, nil
+}
+
+// CreateNotifications inserts new, unread notifications.
+func (s *SQLiteStorage) CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO notifications (id, user_id, kind, actor_id, post_id, comment_id, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for _, n := range notifications {
+		_, err := tx.ExecContext(ctx, query, n.ID, n.UserID, n.Kind, n.ActorID, n.PostID, n.CommentID, n.CreatedAt.UTC())
+		if err != nil {
+			slog.Error("Failed to create notification", "error", err, "userID", n.UserID)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// ListNotifications retrieves a page of userID's notifications, newest first.
+func (s *SQLiteStorage) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	query := `SELECT id, user_id, kind, actor_id, post_id, comment_id, created_at, read
+              FROM notifications
+              WHERE user_id = ? AND (read = FALSE OR NOT ?)
+              ORDER BY created_at DESC, rowid DESC
+              LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &notifications, query, userID, unreadOnly, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list notifications", "error", err, "userID", userID)
+	}
+	return notifications, err
+}
+
+// CountUnreadNotifications returns how many of userID's notifications are
+// unread.
+func (s *SQLiteStorage) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read = FALSE`
+	err := s.db.GetContext(ctx, &count, query, userID)
+	if err != nil {
+		slog.Error("Failed to count unread notifications", "error", err, "userID", userID)
+	}
+	return count, err
+}
+
+// MarkNotificationsRead marks userID's notifications with the given IDs, or
+// all of them if ids is nil, as read.
+func (s *SQLiteStorage) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int, error) {
+	query, args := `UPDATE notifications SET read = TRUE WHERE user_id = ? AND read = FALSE`, []any{userID}
+	if ids != nil {
+		if len(ids) == 0 {
+			return 0, nil
+		}
+		var err error
+		query, args, err = sqlx.In(query+` AND id IN (?)`, userID, ids)
+		if err != nil {
+			return 0, err
+		}
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		slog.Error("Failed to mark notifications read", "error", err, "userID", userID)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
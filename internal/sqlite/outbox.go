@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+// insertOutboxEvent queues event within tx.
+func insertOutboxEvent(ctx context.Context, tx *sqlx.Tx, event models.OutboxEvent) error {
+	query := `INSERT INTO outbox (id, channel, topic, message, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, event.ID, event.Channel, event.Topic, event.Message, event.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to insert outbox event", "error", err, "eventID", event.ID)
+	}
+	return err
+}
+
+// PendingOutboxEvents retrieves up to limit undelivered events, oldest
+// first.
+func (s *SQLiteStorage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	events := []models.OutboxEvent{}
+	query := `SELECT id, channel, topic, message, created_at, delivered_at
+              FROM outbox WHERE delivered_at IS NULL ORDER BY rowid LIMIT ?`
+	err := s.db.SelectContext(ctx, &events, query, limit)
+	if err != nil {
+		slog.Error("Failed to list pending outbox events", "error", err)
+	}
+	return events, err
+}
+
+// MarkOutboxEventsDelivered records that the events were published at the
+// given time.
+func (s *SQLiteStorage) MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`UPDATE outbox SET delivered_at = ? WHERE delivered_at IS NULL AND id IN (?)`, at.UTC(), ids)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		slog.Error("Failed to mark outbox events delivered", "error", err)
+		return err
+	}
+	return nil
+}
+
+// PurgeOutboxEvents deletes events delivered before the given time.
+func (s *SQLiteStorage) PurgeOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE delivered_at < ?`, before.UTC())
+	if err != nil {
+		slog.Error("Failed to purge outbox events", "error", err)
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
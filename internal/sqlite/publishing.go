@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// ListPostsVisibleTo retrieves a paginated list of the posts viewerID may see.
+func (s *SQLiteStorage) ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Post, error) {
+	var posts []models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts
+              WHERE (status = 'PUBLISHED' OR user_id = ?) AND (? OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = ?)
+              ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &posts, query, viewerID, includeHidden, viewerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list visible posts", "error", err)
+	}
+	return posts, err
+}
+
+// SetPostStatus moves an unpublished post to status.
+func (s *SQLiteStorage) SetPostStatus(ctx context.Context, postID uuid.UUID, status models.PostStatus, publishAt *time.Time) error {
+	query := `UPDATE posts SET status = ?, publish_at = ? WHERE id = ? AND status <> 'PUBLISHED'`
+	res, err := s.db.ExecContext(ctx, query, status, utcOrNil(publishAt), postID)
+	if err != nil {
+		slog.Error("Failed to set post status", "error", err, "postID", postID)
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil || updated == 1 {
+		return err
+	}
+
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return err
+	}
+	return models.ErrPostAlreadyPublished
+}
+
+// PublishDuePosts publishes every scheduled post due at now.
+func (s *SQLiteStorage) PublishDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	var posts []models.Post
+	query := `UPDATE posts SET status = 'PUBLISHED'
+              WHERE status = 'SCHEDULED' AND publish_at <= ?
+              RETURNING ` + postColumns
+	err := s.db.SelectContext(ctx, &posts, query, now.UTC())
+	if err != nil {
+		slog.Error("Failed to publish scheduled posts", "error", err)
+		return nil, err
+	}
+	if len(posts) > 0 {
+		slog.Info("Published scheduled posts", "count", len(posts))
+	}
+	return posts, nil
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// AddUserRelation inserts relation unless it exists.
+func (s *SQLiteStorage) AddUserRelation(ctx context.Context, relation models.UserRelation) error {
+	query := `INSERT INTO user_relations (user_id, target_id, kind, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`
+	_, err := s.db.ExecContext(ctx, query, relation.UserID, relation.TargetID, relation.Kind, relation.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to add user relation", "error", err, "userID", relation.UserID, "kind", relation.Kind)
+	}
+	return err
+}
+
+// RemoveUserRelation deletes userID's relation of kind to targetID, if any.
+func (s *SQLiteStorage) RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) error {
+	query := `DELETE FROM user_relations WHERE user_id = ? AND target_id = ? AND kind = ?`
+	_, err := s.db.ExecContext(ctx, query, userID, targetID, kind)
+	if err != nil {
+		slog.Error("Failed to remove user relation", "error", err, "userID", userID, "kind", kind)
+	}
+	return err
+}
+
+// ListUserRelations retrieves a page of userID's relations of kind, newest
+// first.
+func (s *SQLiteStorage) ListUserRelations(ctx context.Context, userID uuid.UUID, kind models.RelationKind, page, pageSize int) ([]models.UserRelation, error) {
+	relations := []models.UserRelation{}
+	query := `SELECT user_id, target_id, kind, created_at FROM user_relations
+              WHERE user_id = ? AND kind = ?
+              ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &relations, query, userID, kind, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list user relations", "error", err, "userID", userID, "kind", kind)
+	}
+	return relations, err
+}
+
+// GetUserRelations returns what userID has set towards targetID.
+func (s *SQLiteStorage) GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (models.Relations, error) {
+	var kinds []models.RelationKind
+	query := `SELECT kind FROM user_relations WHERE user_id = ? AND target_id = ?`
+	if err := s.db.SelectContext(ctx, &kinds, query, userID, targetID); err != nil {
+		slog.Error("Failed to get user relations", "error", err, "userID", userID)
+		return models.Relations{}, err
+	}
+	return relationsOf(kinds), nil
+}
+
+func relationsOf(kinds []models.RelationKind) models.Relations {
+	var relations models.Relations
+	for _, kind := range kinds {
+		switch kind {
+		case models.RelationMute:
+			relations.Muted = true
+		case models.RelationBlock:
+			relations.Blocked = true
+		}
+	}
+	return relations
+}
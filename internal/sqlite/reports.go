@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"ozon-test/internal/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+const reportColumns = `id, target_type, target_id, reporter_id, reason, details, status, created_at, closed_by, closed_at, target_hidden`
+
+// CreateReport inserts an open report unless the reporter already has one
+// open on the same target.
+func (s *SQLiteStorage) CreateReport(ctx context.Context, report models.Report) error {
+	query := `INSERT INTO reports (id, target_type, target_id, reporter_id, reason, details, status, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, 'OPEN', ?) ON CONFLICT DO NOTHING`
+	res, err := s.db.ExecContext(ctx, query, report.ID, report.TargetType, report.TargetID, report.ReporterID, report.Reason,
+		report.Details, report.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to create report", "error", err, "reportID", report.ID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrDuplicateReport
+	}
+	return nil
+}
+
+// GetReport retrieves a report by its ID.
+func (s *SQLiteStorage) GetReport(ctx context.Context, reportID uuid.UUID) (models.Report, error) {
+	var report models.Report
+	err := s.db.GetContext(ctx, &report, `SELECT `+reportColumns+` FROM reports WHERE id = ?`, reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Report{}, models.ErrReportNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get report", "error", err, "reportID", reportID)
+	}
+	return report, err
+}
+
+// ListReports retrieves a page of the reports matching filter, oldest
+// first.
+func (s *SQLiteStorage) ListReports(ctx context.Context, filter models.ReportFilter, page, pageSize int) ([]models.Report, error) {
+	where, args := []string{"TRUE"}, []any{}
+	if filter.Status != "" {
+		where, args = append(where, "status = ?"), append(args, filter.Status)
+	}
+	if filter.Reason != "" {
+		where, args = append(where, "reason = ?"), append(args, filter.Reason)
+	}
+	if filter.TargetType != "" {
+		where, args = append(where, "target_type = ?"), append(args, filter.TargetType)
+	}
+	if filter.TargetID != uuid.Nil {
+		where, args = append(where, "target_id = ?"), append(args, filter.TargetID)
+	}
+
+	reports := []models.Report{}
+	query := `SELECT ` + reportColumns + ` FROM reports WHERE ` + strings.Join(where, " AND ") + `
+              ORDER BY created_at, rowid LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &reports, query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		slog.Error("Failed to list reports", "error", err)
+	}
+	return reports, err
+}
+
+// CloseReport applies decision to an open report and the other open
+// reports on its target, hiding the target in the same transaction if the
+// decision says so.
+func (s *SQLiteStorage) CloseReport(ctx context.Context, reportID uuid.UUID, decision models.ReportDecision) (models.Report, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return models.Report{}, err
+	}
+	defer tx.Rollback()
+
+	var target struct {
+		Type models.ReportTarget `db:"target_type"`
+		ID   uuid.UUID           `db:"target_id"`
+	}
+	err = tx.GetContext(ctx, &target, `SELECT target_type, target_id FROM reports WHERE id = ? AND status = 'OPEN'`, reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetReport(ctx, reportID); err != nil {
+			return models.Report{}, err
+		}
+		return models.Report{}, models.ErrReportClosed
+	}
+	if err != nil {
+		slog.Error("Failed to get report", "error", err, "reportID", reportID)
+		return models.Report{}, err
+	}
+
+	query := `UPDATE reports SET status = ?, closed_by = ?, closed_at = ?, target_hidden = ?
+              WHERE target_type = ? AND target_id = ? AND status = 'OPEN'`
+	_, err = tx.ExecContext(ctx, query, decision.Status, decision.ModeratorID, decision.At.UTC(), decision.HideTarget, target.Type, target.ID)
+	if err != nil {
+		slog.Error("Failed to close reports", "error", err, "reportID", reportID)
+		return models.Report{}, err
+	}
+	if decision.HideTarget {
+		if err := hideContent(ctx, tx, target.Type, target.ID); err != nil {
+			return models.Report{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+		return models.Report{}, err
+	}
+	return s.GetReport(ctx, reportID)
+}
+
+// hideContent hides the post or comment a report is about.
+func hideContent(ctx context.Context, tx *sqlx.Tx, targetType models.ReportTarget, targetID uuid.UUID) error {
+	table := "posts"
+	if targetType == models.ReportComment {
+		table = "comments"
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE `+table+` SET hidden = TRUE WHERE id = ?`, targetID)
+	if err != nil {
+		slog.Error("Failed to hide content", "error", err, "target", targetType, "targetID", targetID)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+func insertRevision(ctx context.Context, tx *sqlx.Tx, revision models.PostRevision) error {
+	query := `INSERT INTO post_revisions (post_id, version, title, content, allow_comments, editor_id, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.ExecContext(ctx, query, revision.PostID, revision.Version, revision.Title, revision.Content,
+		revision.AllowComments, revision.EditorID, revision.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to record post revision", "error", err, "postID", revision.PostID, "version", revision.Version)
+	}
+	return err
+}
+
+// ListPostRevisions retrieves a paginated list of a post's revisions, newest first.
+func (s *SQLiteStorage) ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.PostRevision, error) {
+	if _, err := s.GetPostByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	revisions := []models.PostRevision{}
+	query := `SELECT post_id, version, title, content, allow_comments, editor_id, created_at
+              FROM post_revisions
+              WHERE post_id = ?
+              ORDER BY version DESC
+              LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &revisions, query, postID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list post revisions", "error", err, "postID", postID)
+	}
+	return revisions, err
+}
+
+// GetPostRevision retrieves a post as it was at version.
+func (s *SQLiteStorage) GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (models.PostRevision, error) {
+	var revision models.PostRevision
+	query := `SELECT post_id, version, title, content, allow_comments, editor_id, created_at
+              FROM post_revisions WHERE post_id = ? AND version = ?`
+	err := s.db.GetContext(ctx, &revision, query, postID, version)
+	if err == sql.ErrNoRows {
+		slog.Warn("Revision not found", "postID", postID, "version", version)
+		return revision, models.ErrRevisionNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get post revision", "error", err, "postID", postID, "version", version)
+	}
+	return revision, err
+}
@@ -0,0 +1,264 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+	_ "modernc.org/sqlite"
+)
+
+const postColumns = `id, title, content, user_id, allow_comments, created_at, version, updated_at, updated_by, status, publish_at, format, hidden,
+              community_id`
+
+type SQLiteStorage struct {
+	db         *sqlx.DB
+	depthLimit models.DepthLimit
+}
+
+// NewSQLiteStorage creates a new instance of SQLiteStorage on an already migrated database.
+func NewSQLiteStorage(db *sqlx.DB) *SQLiteStorage {
+	return &SQLiteStorage{db: db}
+}
+
+// Open connects to the database file at path with WAL journaling, foreign
+// keys and a busy timeout enabled, and applies pending migrations.
+func Open(ctx context.Context, path string) (*sqlx.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// CreatePost inserts a new post and its first revision into the database.
+func (s *SQLiteStorage) CreatePost(ctx context.Context, post models.Post) error {
+	post = post.WithDefaultStatus()
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO posts (id, title, content, user_id, allow_comments, created_at, version, updated_at, updated_by, status, publish_at, format,
+              community_id)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, post.ID, post.Title, post.Content, post.UserID, post.AllowComments, post.CreatedAt.UTC(),
+		post.Version, post.UpdatedAt.UTC(), post.UpdatedBy, post.Status, utcOrNil(post.PublishAt), post.Format, post.CommunityID)
+	if err != nil {
+		slog.Error("Failed to create post", "error", err, "postID", post.ID)
+		return err
+	}
+	if err := insertRevision(ctx, tx, models.RevisionOf(post)); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// GetPostByID retrieves a post by its ID from the database.
+func (s *SQLiteStorage) GetPostByID(ctx context.Context, postID uuid.UUID) (models.Post, error) {
+	var post models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts WHERE id = ?`
+	err := s.db.GetContext(ctx, &post, query, postID)
+	if err == sql.ErrNoRows {
+		slog.Warn("Post not found", "postID", postID)
+		return post, models.ErrPostNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get post", "error", err, "postID", postID)
+	}
+	return post, err
+}
+
+// ListPosts retrieves a paginated list of posts from the database.
+func (s *SQLiteStorage) ListPosts(ctx context.Context, page, pageSize int) ([]models.Post, error) {
+	var posts []models.Post
+	query := `SELECT ` + postColumns + `
+              FROM posts ORDER BY created_at DESC LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &posts, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list posts", "error", err)
+	}
+	return posts, err
+}
+
+// CreateComment inserts a new comment into the database and updates the structure_tree table.
+func (s *SQLiteStorage) CreateComment(ctx context.Context, comment models.Comment) error {
+	return s.createComment(ctx, comment, nil)
+}
+
+// CreateCommentWithEvent is CreateComment that also inserts event into the
+// outbox in the same transaction.
+func (s *SQLiteStorage) CreateCommentWithEvent(ctx context.Context, comment models.Comment, event models.OutboxEvent) error {
+	return s.createComment(ctx, comment, &event)
+}
+
+func (s *SQLiteStorage) createComment(ctx context.Context, comment models.Comment, event *models.OutboxEvent) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if comment.ParentID != nil {
+		parentID, err := placeReply(ctx, tx, comment.PostID, *comment.ParentID, s.depthLimit)
+		if err != nil {
+			return err
+		}
+		comment.ParentID = &parentID
+	}
+
+	query := `INSERT INTO comments (id, post_id, parent_id, content, user_id, created_at, format)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, comment.ID, comment.PostID, comment.ParentID, comment.Content, comment.UserID, comment.CreatedAt.UTC(),
+		comment.Format)
+	if err != nil {
+		slog.Error("Failed to create comment", "error", err)
+		return err
+	}
+
+	nearest := comment.ID
+	if comment.ParentID != nil {
+		nearest = *comment.ParentID
+	}
+	query = `INSERT INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id)
+		 VALUES (?1, ?1, ?2, 0, ?3)`
+	_, err = tx.ExecContext(ctx, query, comment.ID, nearest, comment.PostID)
+	if err == nil && comment.ParentID != nil {
+		query = `INSERT INTO structure_tree (ancestor_id, descendant_id, nearest_ancestor_id, level, subject_id)
+			 SELECT ancestor_id, ?1, ?2, level + 1, subject_id
+			 FROM structure_tree
+			 WHERE descendant_id = ?2`
+		_, err = tx.ExecContext(ctx, query, comment.ID, comment.ParentID)
+	}
+	if err != nil {
+		slog.Error("Failed to update structure tree", "error", err)
+		return err
+	}
+
+	if event != nil {
+		if err := insertOutboxEvent(ctx, tx, *event); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// GetCommentsByPostID retrieves a paginated list of comments for a given postID from the database.
+func (s *SQLiteStorage) GetCommentsByPostID(ctx context.Context, postID uuid.UUID, page, pageSize int) ([]models.Comment, error) {
+	var comments []models.Comment
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden
+              FROM comments
+              WHERE post_id = ?
+              ORDER BY created_at ASC
+              LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &comments, query, postID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to get comments by post ID", "error", err, "postID", postID)
+	}
+	return comments, err
+}
+
+// ListVisibleComments retrieves a paginated list of a post's comments that
+// viewerID may see.
+func (s *SQLiteStorage) ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) ([]models.Comment, error) {
+	var comments []models.Comment
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden
+              FROM comments
+              WHERE post_id = ? AND (? OR NOT hidden)
+                AND user_id NOT IN (SELECT target_id FROM user_relations WHERE user_id = ?)
+              ORDER BY created_at ASC
+              LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &comments, query, postID, includeHidden, viewerID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list visible comments", "error", err, "postID", postID)
+	}
+	return comments, err
+}
+
+// UpdatePost updates the details of an existing post in the database if
+// its version has not changed since post was read, and records the new
+// version as a revision.
+func (s *SQLiteStorage) UpdatePost(ctx context.Context, post models.Post) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE posts SET title = ?, content = ?, allow_comments = ?, version = version + 1, updated_at = ?, updated_by = ?
+              WHERE id = ? AND version = ?`
+	res, err := tx.ExecContext(ctx, query, post.Title, post.Content, post.AllowComments, post.UpdatedAt.UTC(), post.UpdatedBy,
+		post.ID, post.Version)
+	if err != nil {
+		slog.Error("Failed to update post", "error", err, "postID", post.ID)
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		// Nothing matched: either the post is gone or someone else updated it.
+		if _, err := s.GetPostByID(ctx, post.ID); err != nil {
+			return err
+		}
+		slog.Warn("Post version conflict", "postID", post.ID, "expected", post.Version)
+		return models.ErrPostVersionConflict
+	}
+
+	post.Version++
+	if err := insertRevision(ctx, tx, models.RevisionOf(post)); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.Error("Failed to commit transaction", "error", err)
+	}
+	return err
+}
+
+// GetCommentByID retrieves a comment by its ID from the database.
+func (s *SQLiteStorage) GetCommentByID(ctx context.Context, commentID uuid.UUID) (models.Comment, error) {
+	var comment models.Comment
+	query := `SELECT id, post_id, parent_id, content, user_id, created_at, locked, format, hidden FROM comments WHERE id = ?`
+	err := s.db.GetContext(ctx, &comment, query, commentID)
+	if err == sql.ErrNoRows {
+		slog.Warn("Comment not found", "commentID", commentID)
+		return comment, models.ErrCommentNotFound
+	}
+	if err != nil {
+		slog.Error("Failed to get comment", "error", err, "commentID", commentID)
+	}
+	return comment, err
+}
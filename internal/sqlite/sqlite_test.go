@@ -0,0 +1,54 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"ozon-test/internal/models"
+	"ozon-test/internal/sqlite"
+	"ozon-test/internal/storagetest"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestDB(t testing.TB) *sqlx.DB {
+	db, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) models.Storage {
+		return sqlite.NewSQLiteStorage(setupTestDB(t))
+	})
+}
+
+func BenchmarkStorage(b *testing.B) {
+	storagetest.Bench(b, func(b *testing.B) models.Storage {
+		return sqlite.NewSQLiteStorage(setupTestDB(b))
+	})
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, sqlite.Migrate(context.Background(), db), "Re-running migrations should be a no-op")
+
+	var version int
+	require.NoError(t, db.Get(&version, `PRAGMA user_version`))
+	assert.Positive(t, version)
+}
+
+func TestCommentRequiresExistingPost(t *testing.T) {
+	storage := sqlite.NewSQLiteStorage(setupTestDB(t))
+
+	comment := storagetest.NewComment(storagetest.NewPost().ID, nil)
+	err := storage.CreateComment(context.Background(), comment)
+	assert.Error(t, err, "Foreign keys should be enforced")
+
+	_, err = storage.GetCommentByID(context.Background(), comment.ID)
+	assert.ErrorIs(t, err, models.ErrCommentNotFound, "A failed insert should be rolled back")
+}
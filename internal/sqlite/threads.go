@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/exp/slog"
+)
+
+type ancestor struct {
+	ID     uuid.UUID `db:"ancestor_id"`
+	Level  int       `db:"level"`
+	PostID uuid.UUID `db:"post_id"`
+	Locked bool      `db:"locked"`
+}
+
+// placeReply checks that parentID is a comment on postID and that no
+// comment from it up is locked, and returns the comment a reply to parentID
+// is stored under to respect limit.
+func placeReply(ctx context.Context, tx *sqlx.Tx, postID, parentID uuid.UUID, limit models.DepthLimit) (uuid.UUID, error) {
+	var chain []ancestor
+	query := `SELECT st.ancestor_id, st.level, c.post_id, c.locked
+              FROM structure_tree st JOIN comments c ON c.id = st.ancestor_id
+              WHERE st.descendant_id = ?
+              ORDER BY st.level`
+	err := tx.SelectContext(ctx, &chain, query, parentID)
+	if err != nil {
+		slog.Error("Failed to read comment ancestors", "error", err, "parentID", parentID)
+		return parentID, err
+	}
+	// The chain starts with the parent itself, at level 0.
+	if len(chain) == 0 || chain[0].PostID != postID {
+		slog.Warn("Parent comment not found", "parentID", parentID, "postID", postID)
+		return parentID, models.ErrParentNotFound
+	}
+
+	for _, a := range chain {
+		if a.Locked {
+			slog.Warn("Reply to locked thread", "parentID", parentID)
+			return parentID, models.ErrThreadLocked
+		}
+	}
+	up, err := limit.Place(len(chain) - 1)
+	if err != nil {
+		slog.Warn("Reply nested too deeply", "parentID", parentID, "maxDepth", limit.MaxDepth)
+		return parentID, err
+	}
+	return chain[up].ID, nil
+}
+
+// SetCommentDepthLimit configures how deeply CreateComment lets comments nest.
+func (s *SQLiteStorage) SetCommentDepthLimit(limit models.DepthLimit) {
+	s.depthLimit = limit
+}
+
+// LockThread locks a comment so that it and its descendants accept no new
+// replies.
+func (s *SQLiteStorage) LockThread(ctx context.Context, commentID uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE comments SET locked = TRUE WHERE id = ?`, commentID)
+	if err != nil {
+		slog.Error("Failed to lock thread", "error", err, "commentID", commentID)
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		slog.Warn("Comment not found", "commentID", commentID)
+		return models.ErrCommentNotFound
+	}
+	return nil
+}
+
+// ListThread retrieves a page of a post's comments no deeper than maxDepth,
+// oldest first, counting the replies hidden below the cut.
+func (s *SQLiteStorage) ListThread(ctx context.Context, postID uuid.UUID, maxDepth, page, pageSize int) ([]models.ThreadComment, error) {
+	comments := []models.ThreadComment{}
+	query := `SELECT c.id, c.post_id, c.parent_id, c.content, c.user_id, c.created_at, c.locked, c.format, c.hidden,
+                     d.depth, COALESCE(m.more_replies, 0) AS more_replies
+              FROM comments c
+              JOIN (SELECT descendant_id, MAX(level) AS depth FROM structure_tree
+                    WHERE subject_id = ?1 GROUP BY descendant_id) d ON d.descendant_id = c.id
+              LEFT JOIN (SELECT ancestor_id, COUNT(*) AS more_replies FROM structure_tree
+                         WHERE subject_id = ?1 AND level > 0 GROUP BY ancestor_id) m
+                ON m.ancestor_id = c.id AND d.depth = ?2
+              WHERE c.post_id = ?1 AND d.depth <= ?2
+              ORDER BY c.created_at ASC
+              LIMIT ?3 OFFSET ?4`
+	err := s.db.SelectContext(ctx, &comments, query, postID, maxDepth, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list thread", "error", err, "postID", postID)
+	}
+	return comments, err
+}
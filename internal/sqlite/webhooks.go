@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// webhookRow is a webhook as stored, with its events comma-separated.
+type webhookRow struct {
+	ID        uuid.UUID `db:"id"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    string    `db:"events"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// CreateWebhook inserts a webhook.
+func (s *SQLiteStorage) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	events := make([]string, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+	query := `INSERT INTO webhooks (id, url, secret, events, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, strings.Join(events, ","), webhook.CreatedAt.UTC())
+	if err != nil {
+		slog.Error("Failed to create webhook", "error", err, "webhookID", webhook.ID)
+	}
+	return err
+}
+
+// ListWebhooks retrieves every webhook, oldest first.
+func (s *SQLiteStorage) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	var rows []webhookRow
+	query := `SELECT id, url, secret, events, created_at FROM webhooks ORDER BY created_at, rowid`
+	if err := s.db.SelectContext(ctx, &rows, query); err != nil {
+		slog.Error("Failed to list webhooks", "error", err)
+		return nil, err
+	}
+
+	webhooks := make([]models.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhook := models.Webhook{ID: row.ID, URL: row.URL, Secret: row.Secret, CreatedAt: row.CreatedAt}
+		for _, event := range strings.Split(row.Events, ",") {
+			if event != "" {
+				webhook.Events = append(webhook.Events, models.WebhookEvent(event))
+			}
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook; its deliveries go with it.
+func (s *SQLiteStorage) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, webhookID)
+	if err != nil {
+		slog.Error("Failed to delete webhook", "error", err, "webhookID", webhookID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// CreateWebhookDelivery records a delivery attempt of an existing webhook.
+func (s *SQLiteStorage) CreateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload, attempt, status_code, error, created_at)
+              SELECT ?, ?, ?, ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM webhooks WHERE id = ?)`
+	res, err := s.db.ExecContext(ctx, query, d.ID, d.WebhookID, d.EventID, d.Event, d.Payload, d.Attempt, d.StatusCode, d.Error,
+		d.CreatedAt.UTC(), d.WebhookID)
+	if err != nil {
+		slog.Error("Failed to create webhook delivery", "error", err, "webhookID", d.WebhookID)
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListWebhookDeliveries retrieves a page of a webhook's deliveries, newest
+// first.
+func (s *SQLiteStorage) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := `SELECT id, webhook_id, event_id, event, payload, attempt, status_code, error, created_at
+              FROM webhook_deliveries
+              WHERE webhook_id = ?
+              ORDER BY created_at DESC, rowid DESC
+              LIMIT ? OFFSET ?`
+	err := s.db.SelectContext(ctx, &deliveries, query, webhookID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to list webhook deliveries", "error", err, "webhookID", webhookID)
+	}
+	return deliveries, err
+}
@@ -0,0 +1,218 @@
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// BenchFactory returns an empty storage for a benchmark.
+type BenchFactory func(b *testing.B) models.Storage
+
+// BenchSizes are the data set sizes every storage is benchmarked at.
+var BenchSizes = []int{100, 10_000}
+
+// BenchDepths are the thread depths used for deep reply inserts.
+var BenchDepths = []int{1, 10, 100}
+
+// Bench benchmarks every models.Storage method against storages pre-filled
+// with BenchSizes posts and comments. Storages are filled once per size and
+// shared by its sub-benchmarks, so Create* benchmarks grow the data set
+// slightly as they run.
+func Bench(b *testing.B, newStorage BenchFactory) {
+	QuietLogs(b)
+
+	for _, size := range BenchSizes {
+		b.Run(fmt.Sprintf("posts=%d", size), func(b *testing.B) {
+			storage := newStorage(b)
+			posts := fillPosts(b, storage, size)
+			benchPosts(b, storage, posts)
+		})
+	}
+	for _, size := range BenchSizes {
+		b.Run(fmt.Sprintf("comments=%d", size), func(b *testing.B) {
+			storage := newStorage(b)
+			post, comments := fillComments(b, storage, size)
+			benchComments(b, storage, post, comments)
+		})
+	}
+	b.Run("CreateCommentDeep", func(b *testing.B) {
+		storage := newStorage(b)
+		for _, depth := range BenchDepths {
+			post, leaf := fillThread(b, storage, depth)
+			b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+				// Every reply goes under the same leaf, so the depth of the
+				// inserted comment stays constant.
+				for i := 0; i < b.N; i++ {
+					if err := storage.CreateComment(context.Background(), NewComment(post.ID, &leaf.ID)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	})
+}
+
+// QuietLogs discards log output for the rest of the benchmark, so that the
+// cost of logging does not dominate the measurements.
+func QuietLogs(b *testing.B) {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b.Cleanup(func() { slog.SetDefault(previous) })
+}
+
+func benchPosts(b *testing.B, storage models.Storage, posts []models.Post) {
+	ctx := context.Background()
+	b.Run("CreatePost", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := storage.CreatePost(ctx, NewPost()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetPostByID", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.GetPostByID(ctx, posts[i%len(posts)].ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ListPosts/first", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.ListPosts(ctx, 1, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ListPosts/last", func(b *testing.B) {
+		last := len(posts) / 20
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.ListPosts(ctx, last, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("UpdatePost", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			post := &posts[i%len(posts)]
+			post.Title = fmt.Sprintf("Updated %d", i)
+			if err := storage.UpdatePost(ctx, *post); err != nil {
+				b.Fatal(err)
+			}
+			post.Version++
+		}
+	})
+	b.Run("Parallel/GetPostByID", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if _, err := storage.GetPostByID(ctx, posts[i%len(posts)].ID); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func benchComments(b *testing.B, storage models.Storage, post models.Post, comments []models.Comment) {
+	ctx := context.Background()
+	b.Run("CreateComment", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := storage.CreateComment(ctx, NewComment(post.ID, nil)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("CreateCommentReply", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			parent := comments[i%len(comments)]
+			if err := storage.CreateComment(ctx, NewComment(post.ID, &parent.ID)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetCommentByID", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.GetCommentByID(ctx, comments[i%len(comments)].ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetCommentsByPostID/first", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.GetCommentsByPostID(ctx, post.ID, 1, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetCommentsByPostID/last", func(b *testing.B) {
+		last := len(comments) / 20
+		for i := 0; i < b.N; i++ {
+			if _, err := storage.GetCommentsByPostID(ctx, post.ID, last, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// fillPosts creates n posts with distinct, increasing creation times.
+func fillPosts(b *testing.B, storage models.Storage, n int) []models.Post {
+	b.Helper()
+	start := time.Now().Add(-time.Duration(n) * time.Second).UTC().Truncate(time.Microsecond)
+	posts := make([]models.Post, n)
+	for i := range posts {
+		post := NewPost()
+		post.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		if err := storage.CreatePost(context.Background(), post); err != nil {
+			b.Fatal(err)
+		}
+		posts[i] = post
+	}
+	return posts
+}
+
+// fillComments creates a post with n comments, half of them replies to an
+// earlier comment.
+func fillComments(b *testing.B, storage models.Storage, n int) (models.Post, []models.Comment) {
+	b.Helper()
+	post := fillPosts(b, storage, 1)[0]
+	comments := make([]models.Comment, n)
+	for i := range comments {
+		var parentID *uuid.UUID
+		if i%2 == 1 {
+			parentID = &comments[i/2].ID
+		}
+		comment := NewComment(post.ID, parentID)
+		comment.CreatedAt = post.CreatedAt.Add(time.Duration(i+1) * time.Millisecond)
+		if err := storage.CreateComment(context.Background(), comment); err != nil {
+			b.Fatal(err)
+		}
+		comments[i] = comment
+	}
+	return post, comments
+}
+
+// fillThread creates a post with a single chain of depth comments and
+// returns the deepest one.
+func fillThread(b *testing.B, storage models.Storage, depth int) (models.Post, models.Comment) {
+	b.Helper()
+	post := fillPosts(b, storage, 1)[0]
+	var leaf models.Comment
+	var parentID *uuid.UUID
+	for i := 0; i < depth; i++ {
+		leaf = NewComment(post.ID, parentID)
+		if err := storage.CreateComment(context.Background(), leaf); err != nil {
+			b.Fatal(err)
+		}
+		id := leaf.ID
+		parentID = &id
+	}
+	return post, leaf
+}
@@ -0,0 +1,1203 @@
+// Package storagetest contains the behaviour shared by every models.Storage
+// implementation. Backends run it from their own tests with Run.
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ozon-test/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty storage for a single test.
+type Factory func(t *testing.T) models.Storage
+
+// Run executes the conformance suite against storages created by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("CreateAndGetPost", func(t *testing.T) { testCreateAndGetPost(t, newStorage(t)) })
+	t.Run("GetMissingPost", func(t *testing.T) { testGetMissingPost(t, newStorage(t)) })
+	t.Run("UpdatePost", func(t *testing.T) { testUpdatePost(t, newStorage(t)) })
+	t.Run("UpdatePostVersionConflict", func(t *testing.T) { testUpdatePostVersionConflict(t, newStorage(t)) })
+	t.Run("UpdateMissingPost", func(t *testing.T) { testUpdateMissingPost(t, newStorage(t)) })
+	t.Run("PostRevisions", func(t *testing.T) { testPostRevisions(t, newStorage(t)) })
+	t.Run("PostRevisionsPagination", func(t *testing.T) { testPostRevisionsPagination(t, newStorage(t)) })
+	t.Run("DefaultPostStatus", func(t *testing.T) { testDefaultPostStatus(t, newStorage(t)) })
+	t.Run("ListPostsVisibleTo", func(t *testing.T) { testListPostsVisibleTo(t, newStorage(t)) })
+	t.Run("SetPostStatus", func(t *testing.T) { testSetPostStatus(t, newStorage(t)) })
+	t.Run("PublishDuePosts", func(t *testing.T) { testPublishDuePosts(t, newStorage(t)) })
+	t.Run("PublishDuePostsTimeZones", func(t *testing.T) { testPublishDuePostsTimeZones(t, newStorage(t)) })
+	t.Run("ListPostsPagination", func(t *testing.T) { testListPostsPagination(t, newStorage(t)) })
+	t.Run("CreateAndListComments", func(t *testing.T) { testCreateAndListComments(t, newStorage(t)) })
+	t.Run("NestedComments", func(t *testing.T) { testNestedComments(t, newStorage(t)) })
+	t.Run("DeeplyNestedComments", func(t *testing.T) { testDeeplyNestedComments(t, newStorage(t)) })
+	t.Run("ReplyParentNotFound", func(t *testing.T) { testReplyParentNotFound(t, newStorage(t)) })
+	t.Run("LockThread", func(t *testing.T) { testLockThread(t, newStorage(t)) })
+	t.Run("LockMissingThread", func(t *testing.T) { testLockMissingThread(t, newStorage(t)) })
+	t.Run("CommentDepthLimitReject", func(t *testing.T) { testCommentDepthLimitReject(t, newStorage(t)) })
+	t.Run("CommentDepthLimitReparent", func(t *testing.T) { testCommentDepthLimitReparent(t, newStorage(t)) })
+	t.Run("ListThread", func(t *testing.T) { testListThread(t, newStorage(t)) })
+	t.Run("ContentFormat", func(t *testing.T) { testContentFormat(t, newStorage(t)) })
+	t.Run("GetCommentByID", func(t *testing.T) { testGetCommentByID(t, newStorage(t)) })
+	t.Run("CommentsPagination", func(t *testing.T) { testCommentsPagination(t, newStorage(t)) })
+	t.Run("ReserveIdempotencyKey", func(t *testing.T) { testReserveIdempotencyKey(t, newStorage(t)) })
+	t.Run("DeleteIdempotencyKey", func(t *testing.T) { testDeleteIdempotencyKey(t, newStorage(t)) })
+	t.Run("PurgeIdempotencyKeys", func(t *testing.T) { testPurgeIdempotencyKeys(t, newStorage(t)) })
+	t.Run("Usernames", func(t *testing.T) { testUsernames(t, newStorage(t)) })
+	t.Run("Notifications", func(t *testing.T) { testNotifications(t, newStorage(t)) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, newStorage(t)) })
+	t.Run("Outbox", func(t *testing.T) { testOutbox(t, newStorage(t)) })
+	t.Run("Reports", func(t *testing.T) { testReports(t, newStorage(t)) })
+	t.Run("HideReportedContent", func(t *testing.T) { testHideReportedContent(t, newStorage(t)) })
+	t.Run("UserRelations", func(t *testing.T) { testUserRelations(t, newStorage(t)) })
+	t.Run("HideMutedAuthors", func(t *testing.T) { testHideMutedAuthors(t, newStorage(t)) })
+	t.Run("Communities", func(t *testing.T) { testCommunities(t, newStorage(t)) })
+	t.Run("CommunityPosts", func(t *testing.T) { testCommunityPosts(t, newStorage(t)) })
+}
+
+// NewPost returns a valid post with a fresh ID.
+func NewPost() models.Post {
+	post := models.Post{
+		ID:            uuid.New(),
+		Title:         "Test Post",
+		Content:       "This is a test post.",
+		UserID:        uuid.New(),
+		AllowComments: true,
+		CreatedAt:     time.Now().UTC().Truncate(time.Microsecond),
+		Version:       1,
+	}
+	post.UpdatedAt = post.CreatedAt
+	post.UpdatedBy = post.UserID
+	return post
+}
+
+// NewComment returns a valid comment on postID with a fresh ID.
+func NewComment(postID uuid.UUID, parentID *uuid.UUID) models.Comment {
+	return models.Comment{
+		ID:        uuid.New(),
+		PostID:    postID,
+		ParentID:  parentID,
+		Content:   "This is a test comment.",
+		UserID:    uuid.New(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+// MustCreatePost stores a new post and returns it.
+func MustCreatePost(t *testing.T, storage models.Storage) models.Post {
+	t.Helper()
+	post := NewPost()
+	require.NoError(t, storage.CreatePost(context.Background(), post))
+	return post
+}
+
+// MustCreateComment stores a new comment and returns it.
+func MustCreateComment(t *testing.T, storage models.Storage, postID uuid.UUID, parentID *uuid.UUID) models.Comment {
+	t.Helper()
+	comment := NewComment(postID, parentID)
+	require.NoError(t, storage.CreateComment(context.Background(), comment))
+	return comment
+}
+
+func testCreateAndGetPost(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+
+	got, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.ID, got.ID, "ID should be preserved")
+	assert.Equal(t, post.Title, got.Title)
+	assert.Equal(t, post.Content, got.Content)
+	assert.Equal(t, post.UserID, got.UserID)
+	assert.Equal(t, post.AllowComments, got.AllowComments)
+	assert.WithinDuration(t, post.CreatedAt, got.CreatedAt, time.Millisecond, "CreatedAt should be preserved")
+}
+
+func testGetMissingPost(t *testing.T, storage models.Storage) {
+	_, err := storage.GetPostByID(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func testUpdatePost(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+
+	post.Title = "Updated Title"
+	post.Content = "Updated content."
+	post.AllowComments = false
+	require.NoError(t, storage.UpdatePost(context.Background(), post))
+
+	got, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Updated Title", got.Title)
+	assert.Equal(t, "Updated content.", got.Content)
+	assert.False(t, got.AllowComments)
+	assert.Equal(t, post.Version+1, got.Version, "Updates should bump the version")
+}
+
+func testUpdatePostVersionConflict(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+
+	first := post
+	first.Title = "First"
+	require.NoError(t, storage.UpdatePost(context.Background(), first))
+
+	stale := post
+	stale.Title = "Second"
+	assert.ErrorIs(t, storage.UpdatePost(context.Background(), stale), models.ErrPostVersionConflict)
+
+	got, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "First", got.Title, "A stale update must not overwrite a newer one")
+	assert.Equal(t, post.Version+1, got.Version)
+}
+
+func testUpdateMissingPost(t *testing.T, storage models.Storage) {
+	assert.ErrorIs(t, storage.UpdatePost(context.Background(), NewPost()), models.ErrPostNotFound)
+}
+
+func testPostRevisions(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+
+	editor := uuid.New()
+	edited := post
+	edited.Title = "Edited"
+	edited.UpdatedAt = post.CreatedAt.Add(time.Minute)
+	edited.UpdatedBy = editor
+	require.NoError(t, storage.UpdatePost(context.Background(), edited))
+
+	got, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, editor, got.UpdatedBy)
+	assert.WithinDuration(t, edited.UpdatedAt, got.UpdatedAt, time.Millisecond)
+
+	first, err := storage.GetPostRevision(context.Background(), post.ID, post.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Post", first.Title, "The original version should be kept")
+	assert.Equal(t, post.UserID, first.EditorID)
+
+	second, err := storage.GetPostRevision(context.Background(), post.ID, post.Version+1)
+	require.NoError(t, err)
+	assert.Equal(t, "Edited", second.Title)
+	assert.Equal(t, post.Content, second.Content)
+	assert.Equal(t, editor, second.EditorID)
+	assert.WithinDuration(t, edited.UpdatedAt, second.CreatedAt, time.Millisecond)
+
+	stale := post
+	stale.Title = "Stale"
+	require.ErrorIs(t, storage.UpdatePost(context.Background(), stale), models.ErrPostVersionConflict)
+	_, err = storage.GetPostRevision(context.Background(), post.ID, post.Version+2)
+	assert.ErrorIs(t, err, models.ErrRevisionNotFound, "A rejected update must not record a revision")
+
+	_, err = storage.ListPostRevisions(context.Background(), uuid.New(), 1, 10)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func testPostRevisionsPagination(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, storage.UpdatePost(context.Background(), post))
+		post.Version++
+	}
+
+	revisions, err := storage.ListPostRevisions(context.Background(), post.ID, 1, 3)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, []int{5, 4, 3}, []int{revisions[0].Version, revisions[1].Version, revisions[2].Version}, "Newest first")
+
+	revisions, err = storage.ListPostRevisions(context.Background(), post.ID, 2, 3)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[1].Version)
+}
+
+// NewDraft returns a valid draft post by userID with a fresh ID.
+func NewDraft(userID uuid.UUID) models.Post {
+	post := NewPost()
+	post.UserID = userID
+	post.UpdatedBy = userID
+	post.Status = models.PostDraft
+	return post
+}
+
+func testDefaultPostStatus(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+
+	got, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostPublished, got.Status, "Posts without a status are published")
+	require.NotNil(t, got.PublishAt)
+	assert.WithinDuration(t, post.CreatedAt, *got.PublishAt, time.Millisecond)
+}
+
+func testListPostsVisibleTo(t *testing.T, storage models.Storage) {
+	author := uuid.New()
+	published := MustCreatePost(t, storage)
+	draft := NewDraft(author)
+	require.NoError(t, storage.CreatePost(context.Background(), draft))
+
+	ids := func(viewerID uuid.UUID) []uuid.UUID {
+		posts, err := storage.ListPostsVisibleTo(context.Background(), viewerID, false, 1, 10)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []uuid.UUID{published.ID, draft.ID}, ids(author), "Authors see their own drafts")
+	assert.Equal(t, []uuid.UUID{published.ID}, ids(uuid.New()), "Other users only see published posts")
+	assert.Equal(t, []uuid.UUID{published.ID}, ids(uuid.Nil), "Anonymous viewers only see published posts")
+
+	all, err := storage.ListPosts(context.Background(), 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, all, 2, "ListPosts includes unpublished posts")
+}
+
+func testSetPostStatus(t *testing.T, storage models.Storage) {
+	draft := NewDraft(uuid.New())
+	require.NoError(t, storage.CreatePost(context.Background(), draft))
+
+	publishAt := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+	require.NoError(t, storage.SetPostStatus(context.Background(), draft.ID, models.PostScheduled, &publishAt))
+
+	// Edits must not undo a status change.
+	edited := draft
+	edited.Title = "Edited"
+	require.NoError(t, storage.UpdatePost(context.Background(), edited))
+
+	got, err := storage.GetPostByID(context.Background(), draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostScheduled, got.Status)
+	require.NotNil(t, got.PublishAt)
+	assert.WithinDuration(t, publishAt, *got.PublishAt, time.Millisecond)
+
+	require.NoError(t, storage.SetPostStatus(context.Background(), draft.ID, models.PostDraft, nil))
+	got, err = storage.GetPostByID(context.Background(), draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostDraft, got.Status)
+	assert.Nil(t, got.PublishAt)
+
+	published := MustCreatePost(t, storage)
+	err = storage.SetPostStatus(context.Background(), published.ID, models.PostDraft, nil)
+	assert.ErrorIs(t, err, models.ErrPostAlreadyPublished)
+
+	err = storage.SetPostStatus(context.Background(), uuid.New(), models.PostDraft, nil)
+	assert.ErrorIs(t, err, models.ErrPostNotFound)
+}
+
+func testPublishDuePosts(t *testing.T, storage models.Storage) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	schedule := func(publishAt time.Time) models.Post {
+		post := NewDraft(uuid.New())
+		require.NoError(t, storage.CreatePost(context.Background(), post))
+		require.NoError(t, storage.SetPostStatus(context.Background(), post.ID, models.PostScheduled, &publishAt))
+		return post
+	}
+	due := schedule(now.Add(-time.Minute))
+	later := schedule(now.Add(time.Hour))
+	MustCreatePost(t, storage)
+
+	published, err := storage.PublishDuePosts(context.Background(), now)
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	assert.Equal(t, due.ID, published[0].ID)
+	assert.Equal(t, models.PostPublished, published[0].Status)
+
+	published, err = storage.PublishDuePosts(context.Background(), now)
+	require.NoError(t, err)
+	assert.Empty(t, published, "A post is published only once")
+
+	got, err := storage.GetPostByID(context.Background(), later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostScheduled, got.Status)
+}
+
+func testPublishDuePostsTimeZones(t *testing.T, storage models.Storage) {
+	east, west := time.FixedZone("UTC+5", 5*60*60), time.FixedZone("UTC-5", -5*60*60)
+	now := time.Now().Truncate(time.Microsecond)
+	schedule := func(publishAt time.Time) models.Post {
+		post := NewDraft(uuid.New())
+		require.NoError(t, storage.CreatePost(context.Background(), post))
+		require.NoError(t, storage.SetPostStatus(context.Background(), post.ID, models.PostScheduled, &publishAt))
+		return post
+	}
+	due := schedule(now.Add(-time.Minute).In(east))
+	later := schedule(now.Add(time.Hour).In(west))
+
+	published, err := storage.PublishDuePosts(context.Background(), now.In(west))
+	require.NoError(t, err)
+	require.Len(t, published, 1, "Publish times are compared as instants, whatever their offset")
+	assert.Equal(t, due.ID, published[0].ID)
+
+	got, err := storage.GetPostByID(context.Background(), later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostScheduled, got.Status)
+	require.NotNil(t, got.PublishAt)
+	assert.WithinDuration(t, now.Add(time.Hour), *got.PublishAt, time.Millisecond)
+}
+
+func testListPostsPagination(t *testing.T, storage models.Storage) {
+	for i := 0; i < 25; i++ {
+		MustCreatePost(t, storage)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for page, want := range map[int]int{1: 10, 2: 10, 3: 5, 4: 0} {
+		posts, err := storage.ListPosts(context.Background(), page, 10)
+		require.NoError(t, err)
+		assert.Len(t, posts, want, "page %d", page)
+		for _, post := range posts {
+			assert.False(t, seen[post.ID], "post %s listed twice", post.ID)
+			seen[post.ID] = true
+		}
+	}
+	assert.Len(t, seen, 25)
+}
+
+func testCreateAndListComments(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	first := MustCreateComment(t, storage, post.ID, nil)
+	second := NewComment(post.ID, nil)
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	require.NoError(t, storage.CreateComment(context.Background(), second))
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, first.ID, comments[0].ID, "Comments are listed oldest first")
+	assert.Equal(t, second.ID, comments[1].ID)
+	assert.Nil(t, comments[0].ParentID, "Top-level comments have no parent")
+
+	other, err := storage.GetCommentsByPostID(context.Background(), uuid.New(), 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, other, "Other posts have no comments")
+}
+
+func testNestedComments(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	parent := MustCreateComment(t, storage, post.ID, nil)
+	reply := NewComment(post.ID, &parent.ID)
+	reply.CreatedAt = parent.CreatedAt.Add(time.Second)
+	require.NoError(t, storage.CreateComment(context.Background(), reply))
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, parent.ID, comments[0].ID)
+	assert.Equal(t, reply.ID, comments[1].ID)
+	require.NotNil(t, comments[1].ParentID)
+	assert.Equal(t, parent.ID, *comments[1].ParentID)
+}
+
+func testDeeplyNestedComments(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	root := MustCreateComment(t, storage, post.ID, nil)
+	parentID := root.ID
+	createdAt := root.CreatedAt
+	for i := 0; i < 3; i++ {
+		reply := NewComment(post.ID, &parentID)
+		createdAt = createdAt.Add(time.Second)
+		reply.CreatedAt = createdAt
+		require.NoError(t, storage.CreateComment(context.Background(), reply))
+		parentID = reply.ID
+	}
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 4, "Replies at every depth are listed")
+	assert.Equal(t, parentID, comments[3].ID)
+}
+
+func testReplyParentNotFound(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	other := MustCreatePost(t, storage)
+	elsewhere := MustCreateComment(t, storage, other.ID, nil)
+
+	missing := uuid.New()
+	err := storage.CreateComment(context.Background(), NewComment(post.ID, &missing))
+	assert.ErrorIs(t, err, models.ErrParentNotFound)
+	err = storage.CreateComment(context.Background(), NewComment(post.ID, &elsewhere.ID))
+	assert.ErrorIs(t, err, models.ErrParentNotFound, "A reply must be on the same post as its parent")
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, comments, "Rejected replies are not stored")
+}
+
+func testLockThread(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	root := MustCreateComment(t, storage, post.ID, nil)
+	locked := MustCreateComment(t, storage, post.ID, &root.ID)
+	child := MustCreateComment(t, storage, post.ID, &locked.ID)
+	grandchild := MustCreateComment(t, storage, post.ID, &child.ID)
+
+	require.NoError(t, storage.LockThread(context.Background(), locked.ID))
+	require.NoError(t, storage.LockThread(context.Background(), locked.ID), "Locking is idempotent")
+
+	got, err := storage.GetCommentByID(context.Background(), locked.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Locked)
+
+	for _, parent := range []models.Comment{locked, child, grandchild} {
+		err := storage.CreateComment(context.Background(), NewComment(post.ID, &parent.ID))
+		assert.ErrorIs(t, err, models.ErrThreadLocked, "Replies anywhere beneath a locked comment are rejected")
+	}
+
+	MustCreateComment(t, storage, post.ID, &root.ID)
+	MustCreateComment(t, storage, post.ID, nil)
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 6, "The rest of the post stays open")
+}
+
+func testContentFormat(t *testing.T, storage models.Storage) {
+	post := NewPost()
+	post.Format = models.FormatMarkdown
+	require.NoError(t, storage.CreatePost(context.Background(), post))
+	comment := NewComment(post.ID, nil)
+	comment.Format = models.FormatMarkdown
+	require.NoError(t, storage.CreateComment(context.Background(), comment))
+	plain := MustCreateComment(t, storage, post.ID, nil)
+
+	gotPost, err := storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, gotPost.Format)
+
+	post.Version = gotPost.Version
+	post.Content = "Edited"
+	require.NoError(t, storage.UpdatePost(context.Background(), post))
+	gotPost, err = storage.GetPostByID(context.Background(), post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, gotPost.Format, "Updates keep the format")
+
+	gotComment, err := storage.GetCommentByID(context.Background(), comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, gotComment.Format)
+	gotComment, err = storage.GetCommentByID(context.Background(), plain.ID)
+	require.NoError(t, err)
+	assert.Empty(t, gotComment.Format, "Comments without a format stay plain")
+}
+
+func testLockMissingThread(t *testing.T, storage models.Storage) {
+	err := storage.LockThread(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, models.ErrCommentNotFound)
+}
+
+// createThread stores a chain of n comments, each replying to the previous
+// one, one second apart, and returns them top-level first.
+func createThread(t *testing.T, storage models.Storage, postID uuid.UUID, start time.Time, n int) []models.Comment {
+	t.Helper()
+	var chain []models.Comment
+	var parentID *uuid.UUID
+	for i := 0; i < n; i++ {
+		comment := NewComment(postID, parentID)
+		comment.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		require.NoError(t, storage.CreateComment(context.Background(), comment))
+		chain = append(chain, comment)
+		parentID = &chain[i].ID
+	}
+	return chain
+}
+
+func testCommentDepthLimitReject(t *testing.T, storage models.Storage) {
+	storage.SetCommentDepthLimit(models.DepthLimit{MaxDepth: 1, Policy: models.DepthReject})
+	post := MustCreatePost(t, storage)
+	chain := createThread(t, storage, post.ID, time.Now(), 2)
+
+	err := storage.CreateComment(context.Background(), NewComment(post.ID, &chain[1].ID))
+	assert.ErrorIs(t, err, models.ErrCommentTooDeep)
+	MustCreateComment(t, storage, post.ID, &chain[0].ID)
+}
+
+func testCommentDepthLimitReparent(t *testing.T, storage models.Storage) {
+	storage.SetCommentDepthLimit(models.DepthLimit{MaxDepth: 2, Policy: models.DepthReparent})
+	post := MustCreatePost(t, storage)
+	chain := createThread(t, storage, post.ID, time.Now(), 3)
+
+	reply := MustCreateComment(t, storage, post.ID, &chain[2].ID)
+	got, err := storage.GetCommentByID(context.Background(), reply.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ParentID)
+	assert.Equal(t, chain[1].ID, *got.ParentID, "A too deep reply continues under the deepest ancestor that takes replies")
+
+	again := MustCreateComment(t, storage, post.ID, &reply.ID)
+	got, err = storage.GetCommentByID(context.Background(), again.ID)
+	require.NoError(t, err)
+	assert.Equal(t, chain[1].ID, *got.ParentID)
+
+	thread, err := storage.ListThread(context.Background(), post.ID, 10, 1, 10)
+	require.NoError(t, err)
+	for _, c := range thread {
+		assert.LessOrEqual(t, c.Depth, 2)
+	}
+}
+
+func testListThread(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	start := time.Now().UTC().Truncate(time.Second)
+	chain := createThread(t, storage, post.ID, start, 4)
+	other := createThread(t, storage, post.ID, start.Add(time.Minute), 1)[0]
+
+	thread, err := storage.ListThread(context.Background(), post.ID, 1, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, thread, 3)
+	assert.Equal(t, []uuid.UUID{chain[0].ID, chain[1].ID, other.ID}, []uuid.UUID{thread[0].ID, thread[1].ID, thread[2].ID})
+	assert.Equal(t, []int{0, 1, 0}, []int{thread[0].Depth, thread[1].Depth, thread[2].Depth})
+	assert.Equal(t, []int{0, 2, 0}, []int{thread[0].MoreReplies, thread[1].MoreReplies, thread[2].MoreReplies},
+		"Comments at the cut count the replies below it")
+
+	thread, err = storage.ListThread(context.Background(), post.ID, 0, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, thread, 1, "Pages only count comments within the depth")
+	assert.Equal(t, other.ID, thread[0].ID)
+
+	thread, err = storage.ListThread(context.Background(), post.ID, 10, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, thread, 5)
+	assert.Equal(t, 3, thread[3].Depth)
+	assert.Equal(t, *chain[3].ParentID, *thread[3].ParentID)
+}
+
+func testGetCommentByID(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	comment := MustCreateComment(t, storage, post.ID, nil)
+
+	got, err := storage.GetCommentByID(context.Background(), comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, comment.ID, got.ID)
+	assert.Equal(t, comment.PostID, got.PostID)
+	assert.Equal(t, comment.Content, got.Content)
+	assert.Equal(t, comment.UserID, got.UserID)
+	assert.WithinDuration(t, comment.CreatedAt, got.CreatedAt, time.Millisecond)
+
+	_, err = storage.GetCommentByID(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, models.ErrCommentNotFound)
+}
+
+func testCommentsPagination(t *testing.T, storage models.Storage) {
+	post := MustCreatePost(t, storage)
+	start := time.Now().UTC().Truncate(time.Microsecond)
+	for i := 0; i < 15; i++ {
+		comment := NewComment(post.ID, nil)
+		comment.CreatedAt = start.Add(time.Duration(i) * time.Millisecond)
+		require.NoError(t, storage.CreateComment(context.Background(), comment))
+	}
+
+	comments, err := storage.GetCommentsByPostID(context.Background(), post.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 10)
+
+	comments, err = storage.GetCommentsByPostID(context.Background(), post.ID, 2, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 5)
+}
+
+// NewIdempotencyKey returns a key for userID created at now that expires an
+// hour later.
+func NewIdempotencyKey(userID uuid.UUID, key string, now time.Time) models.IdempotencyKey {
+	return models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Operation:   "createPost",
+		RequestHash: "hash",
+		ResourceID:  uuid.New(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+}
+
+func testReserveIdempotencyKey(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	userID := uuid.New()
+
+	first := NewIdempotencyKey(userID, "key", now)
+	got, reserved, err := storage.ReserveIdempotencyKey(ctx, first)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, first.ResourceID, got.ResourceID)
+
+	retry := NewIdempotencyKey(userID, "key", now.Add(time.Minute))
+	got, reserved, err = storage.ReserveIdempotencyKey(ctx, retry)
+	require.NoError(t, err)
+	assert.False(t, reserved, "An unexpired key cannot be reserved twice")
+	assert.Equal(t, first.ResourceID, got.ResourceID, "The original reservation is returned")
+	assert.Equal(t, first.RequestHash, got.RequestHash)
+
+	other := NewIdempotencyKey(uuid.New(), "key", now)
+	_, reserved, err = storage.ReserveIdempotencyKey(ctx, other)
+	require.NoError(t, err)
+	assert.True(t, reserved, "Keys are scoped to a user")
+
+	later := NewIdempotencyKey(userID, "key", now.Add(2*time.Hour))
+	got, reserved, err = storage.ReserveIdempotencyKey(ctx, later)
+	require.NoError(t, err)
+	assert.True(t, reserved, "Expired keys can be reused")
+	assert.Equal(t, later.ResourceID, got.ResourceID)
+}
+
+func testDeleteIdempotencyKey(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	key := NewIdempotencyKey(uuid.New(), "key", now)
+	_, _, err := storage.ReserveIdempotencyKey(ctx, key)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.DeleteIdempotencyKey(ctx, key.UserID, key.Key, uuid.New()))
+	_, reserved, err := storage.ReserveIdempotencyKey(ctx, NewIdempotencyKey(key.UserID, key.Key, now))
+	require.NoError(t, err)
+	assert.False(t, reserved, "Deleting on behalf of another resource is a no-op")
+
+	require.NoError(t, storage.DeleteIdempotencyKey(ctx, key.UserID, key.Key, key.ResourceID))
+	_, reserved, err = storage.ReserveIdempotencyKey(ctx, NewIdempotencyKey(key.UserID, key.Key, now))
+	require.NoError(t, err)
+	assert.True(t, reserved, "Deleted keys can be reserved again")
+}
+
+func testPurgeIdempotencyKeys(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	old := NewIdempotencyKey(uuid.New(), "old", now.Add(-2*time.Hour))
+	fresh := NewIdempotencyKey(uuid.New(), "fresh", now)
+	for _, key := range []models.IdempotencyKey{old, fresh} {
+		_, _, err := storage.ReserveIdempotencyKey(ctx, key)
+		require.NoError(t, err)
+	}
+
+	purged, err := storage.PurgeIdempotencyKeys(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, reserved, err := storage.ReserveIdempotencyKey(ctx, NewIdempotencyKey(fresh.UserID, fresh.Key, now))
+	require.NoError(t, err)
+	assert.False(t, reserved, "Unexpired keys survive a purge")
+}
+
+func testUsernames(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	require.NoError(t, storage.SetUsername(ctx, alice, "alice"))
+	require.NoError(t, storage.SetUsername(ctx, alice, "alice"), "Setting the same name again is a no-op")
+	assert.ErrorIs(t, storage.SetUsername(ctx, bob, "alice"), models.ErrUsernameTaken)
+	require.NoError(t, storage.SetUsername(ctx, bob, "bob"))
+
+	users, err := storage.ResolveUsernames(ctx, []string{"alice", "bob", "carol"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uuid.UUID{"alice": alice, "bob": bob}, users)
+
+	require.NoError(t, storage.SetUsername(ctx, alice, "alicia"))
+	require.NoError(t, storage.SetUsername(ctx, bob, "alice"), "A renamed user releases the old name")
+	users, err = storage.ResolveUsernames(ctx, []string{"alice", "alicia", "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uuid.UUID{"alice": bob, "alicia": alice}, users)
+}
+
+func testNotifications(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	post := MustCreatePost(t, storage)
+	comment := MustCreateComment(t, storage, post.ID, nil)
+	user, other := uuid.New(), uuid.New()
+
+	var created []models.Notification
+	for i := 0; i < 3; i++ {
+		n := models.Notification{
+			ID:        uuid.New(),
+			UserID:    user,
+			Kind:      models.NotificationMention,
+			ActorID:   post.UserID,
+			PostID:    post.ID,
+			CreatedAt: post.CreatedAt.Add(time.Duration(i) * time.Second),
+		}
+		if i == 1 {
+			n.CommentID = &comment.ID
+		}
+		created = append(created, n)
+	}
+	otherNotification := created[0]
+	otherNotification.ID = uuid.New()
+	otherNotification.UserID = other
+	require.NoError(t, storage.CreateNotifications(ctx, append(created, otherNotification)))
+
+	got, err := storage.ListNotifications(ctx, user, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, created[2].ID, got[0].ID, "Newest first")
+	assert.Equal(t, created[1], got[1])
+	assert.False(t, got[0].Read)
+
+	page, err := storage.ListNotifications(ctx, user, false, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, created[0].ID, page[0].ID)
+
+	marked, err := storage.MarkNotificationsRead(ctx, user, []uuid.UUID{created[0].ID, otherNotification.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, marked, "Other users' notifications are not marked")
+	marked, err = storage.MarkNotificationsRead(ctx, user, []uuid.UUID{created[0].ID})
+	require.NoError(t, err)
+	assert.Zero(t, marked, "Marking is idempotent")
+
+	count, err := storage.CountUnreadNotifications(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	unread, err := storage.ListNotifications(ctx, user, true, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, unread, 2)
+	assert.Equal(t, created[2].ID, unread[0].ID)
+	assert.Equal(t, created[1].ID, unread[1].ID)
+
+	marked, err = storage.MarkNotificationsRead(ctx, user, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, marked)
+	unread, err = storage.ListNotifications(ctx, user, true, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, unread)
+	count, err = storage.CountUnreadNotifications(ctx, user)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	otherUnread, err := storage.ListNotifications(ctx, other, true, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, otherUnread, 1)
+}
+
+func testWebhooks(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	first := models.Webhook{
+		ID:        uuid.New(),
+		URL:       "https://example.com/hook",
+		Secret:    "s3cret",
+		Events:    []models.WebhookEvent{models.WebhookPostCreated, models.WebhookCommentCreated},
+		CreatedAt: now,
+	}
+	second := models.Webhook{ID: uuid.New(), URL: "https://example.org/hook", Secret: "other",
+		Events: []models.WebhookEvent{models.WebhookPostUpdated}, CreatedAt: now.Add(time.Second)}
+	require.NoError(t, storage.CreateWebhook(ctx, first))
+	require.NoError(t, storage.CreateWebhook(ctx, second))
+
+	webhooks, err := storage.ListWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Webhook{first, second}, webhooks, "Oldest first")
+
+	eventID := uuid.New()
+	var deliveries []models.WebhookDelivery
+	for attempt := 1; attempt <= 3; attempt++ {
+		d := models.WebhookDelivery{
+			ID:        uuid.New(),
+			WebhookID: first.ID,
+			EventID:   eventID,
+			Event:     models.WebhookPostCreated,
+			Payload:   `{"event":"POST_CREATED"}`,
+			Attempt:   attempt,
+			CreatedAt: now.Add(time.Duration(attempt) * time.Second),
+		}
+		if attempt < 3 {
+			d.Error = "connection refused"
+		} else {
+			d.StatusCode = 204
+		}
+		deliveries = append(deliveries, d)
+		require.NoError(t, storage.CreateWebhookDelivery(ctx, d))
+	}
+	err = storage.CreateWebhookDelivery(ctx, models.WebhookDelivery{ID: uuid.New(), WebhookID: uuid.New(), EventID: eventID,
+		Event: models.WebhookPostCreated, Attempt: 1, CreatedAt: now})
+	assert.ErrorIs(t, err, models.ErrWebhookNotFound)
+
+	got, err := storage.ListWebhookDeliveries(ctx, first.ID, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, deliveries[2], got[0], "Newest first")
+	assert.True(t, got[0].Succeeded())
+	assert.Equal(t, deliveries[1], got[1])
+	got, err = storage.ListWebhookDeliveries(ctx, first.ID, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, deliveries[0].ID, got[0].ID)
+	assert.False(t, got[0].Succeeded())
+
+	require.NoError(t, storage.DeleteWebhook(ctx, first.ID))
+	assert.ErrorIs(t, storage.DeleteWebhook(ctx, first.ID), models.ErrWebhookNotFound)
+	webhooks, err = storage.ListWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Webhook{second}, webhooks)
+	got, err = storage.ListWebhookDeliveries(ctx, first.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, got, "Deliveries are deleted with their webhook")
+	err = storage.CreateWebhookDelivery(ctx, deliveries[0])
+	assert.ErrorIs(t, err, models.ErrWebhookNotFound)
+}
+
+func testOutbox(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	storage.SetCommentDepthLimit(models.DepthLimit{MaxDepth: 1, Policy: models.DepthReject})
+	post := MustCreatePost(t, storage)
+
+	var events []models.OutboxEvent
+	var parent *uuid.UUID
+	for i := 0; i < 3; i++ {
+		comment := NewComment(post.ID, parent)
+		event := models.OutboxEvent{ID: uuid.New(), Channel: models.OutboxCommentAdded, Topic: post.ID,
+			Message: comment.ID.String(), CreatedAt: now}
+		err := storage.CreateCommentWithEvent(ctx, comment, event)
+		if i == 2 {
+			assert.ErrorIs(t, err, models.ErrCommentTooDeep)
+			break
+		}
+		require.NoError(t, err)
+		_, err = storage.GetCommentByID(ctx, comment.ID)
+		require.NoError(t, err)
+		events = append(events, event)
+		parent = &comment.ID
+	}
+
+	pending, err := storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, events, pending, "Oldest first, without the event of the rejected comment")
+	pending, err = storage.PendingOutboxEvents(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, events[:1], pending)
+
+	require.NoError(t, storage.MarkOutboxEventsDelivered(ctx, []uuid.UUID{events[0].ID}, now))
+	pending, err = storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, events[1:], pending)
+
+	purged, err := storage.PurgeOutboxEvents(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, purged, "Only events delivered before the cutoff are purged")
+	purged, err = storage.PurgeOutboxEvents(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged, "Pending events are kept")
+	pending, err = storage.PendingOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, events[1:], pending)
+}
+
+// NewReport returns an open report about target with a fresh ID.
+func NewReport(targetType models.ReportTarget, targetID uuid.UUID) models.Report {
+	return models.Report{
+		ID:         uuid.New(),
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReporterID: uuid.New(),
+		Reason:     models.ReasonSpam,
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func testReports(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	post := MustCreatePost(t, storage)
+	comment := MustCreateComment(t, storage, post.ID, nil)
+
+	first := NewReport(models.ReportPost, post.ID)
+	first.Details = "Advertises a casino."
+	second := NewReport(models.ReportPost, post.ID)
+	second.Reason = models.ReasonAbuse
+	other := NewReport(models.ReportComment, comment.ID)
+	for _, report := range []models.Report{first, second, other} {
+		require.NoError(t, storage.CreateReport(ctx, report))
+	}
+
+	duplicate := NewReport(models.ReportPost, post.ID)
+	duplicate.ReporterID = first.ReporterID
+	assert.ErrorIs(t, storage.CreateReport(ctx, duplicate), models.ErrDuplicateReport)
+
+	got, err := storage.GetReport(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+	_, err = storage.GetReport(ctx, uuid.New())
+	assert.ErrorIs(t, err, models.ErrReportNotFound)
+
+	list := func(filter models.ReportFilter, page, pageSize int) []uuid.UUID {
+		reports, err := storage.ListReports(ctx, filter, page, pageSize)
+		require.NoError(t, err)
+		ids := []uuid.UUID{}
+		for _, report := range reports {
+			ids = append(ids, report.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID, other.ID}, list(models.ReportFilter{}, 1, 10), "Oldest first")
+	assert.Equal(t, []uuid.UUID{second.ID}, list(models.ReportFilter{}, 2, 1))
+	assert.Equal(t, []uuid.UUID{second.ID}, list(models.ReportFilter{Reason: models.ReasonAbuse}, 1, 10))
+	assert.Equal(t, []uuid.UUID{other.ID}, list(models.ReportFilter{TargetType: models.ReportComment}, 1, 10))
+	assert.Equal(t, []uuid.UUID{first.ID, second.ID}, list(models.ReportFilter{TargetID: post.ID}, 1, 10))
+
+	moderator := uuid.New()
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	closed, err := storage.CloseReport(ctx, second.ID, models.ReportDecision{
+		Status: models.ReportDismissed, ModeratorID: moderator, At: at})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReportDismissed, closed.Status)
+	require.NotNil(t, closed.ClosedBy)
+	assert.Equal(t, moderator, *closed.ClosedBy)
+	require.NotNil(t, closed.ClosedAt)
+	assert.True(t, at.Equal(*closed.ClosedAt))
+	assert.False(t, closed.TargetHidden)
+
+	got, err = storage.GetReport(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReportDismissed, got.Status, "Other open reports on the target are closed too")
+	assert.Equal(t, []uuid.UUID{other.ID}, list(models.ReportFilter{Status: models.ReportOpen}, 1, 10))
+
+	_, err = storage.CloseReport(ctx, first.ID, models.ReportDecision{
+		Status: models.ReportResolved, ModeratorID: moderator, At: at})
+	assert.ErrorIs(t, err, models.ErrReportClosed)
+	_, err = storage.CloseReport(ctx, uuid.New(), models.ReportDecision{
+		Status: models.ReportResolved, ModeratorID: moderator, At: at})
+	assert.ErrorIs(t, err, models.ErrReportNotFound)
+
+	require.NoError(t, storage.CreateReport(ctx, duplicate), "A closed report no longer blocks a new one")
+
+	unhidden, err := storage.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.False(t, unhidden.Hidden, "Dismissing leaves the target alone")
+}
+
+func testHideReportedContent(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	post := MustCreatePost(t, storage)
+	kept := MustCreatePost(t, storage)
+	comment := MustCreateComment(t, storage, kept.ID, nil)
+	reply := MustCreateComment(t, storage, kept.ID, &comment.ID)
+
+	decision := models.ReportDecision{Status: models.ReportResolved, ModeratorID: uuid.New(),
+		At: time.Now().UTC().Truncate(time.Microsecond), HideTarget: true}
+	for _, report := range []models.Report{NewReport(models.ReportPost, post.ID), NewReport(models.ReportComment, comment.ID)} {
+		require.NoError(t, storage.CreateReport(ctx, report))
+		closed, err := storage.CloseReport(ctx, report.ID, decision)
+		require.NoError(t, err)
+		assert.True(t, closed.TargetHidden)
+	}
+
+	got, err := storage.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Hidden)
+	gotComment, err := storage.GetCommentByID(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.True(t, gotComment.Hidden)
+
+	posts, err := storage.ListPostsVisibleTo(ctx, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, kept.ID, posts[0].ID)
+	posts, err = storage.ListPostsVisibleTo(ctx, uuid.Nil, true, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 2, "Moderators still see hidden posts")
+
+	comments, err := storage.ListVisibleComments(ctx, kept.ID, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, reply.ID, comments[0].ID)
+	comments, err = storage.ListVisibleComments(ctx, kept.ID, uuid.Nil, true, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2, "Moderators still see hidden comments")
+
+	updated := got
+	updated.Title = "Edited"
+	require.NoError(t, storage.UpdatePost(ctx, updated))
+	got, err = storage.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Hidden, "Editing does not unhide a post")
+}
+
+func testUserRelations(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	user, muted, blocked := uuid.New(), uuid.New(), uuid.New()
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	relations := []models.UserRelation{
+		{UserID: user, TargetID: muted, Kind: models.RelationMute, CreatedAt: at},
+		{UserID: user, TargetID: blocked, Kind: models.RelationMute, CreatedAt: at.Add(time.Second)},
+		{UserID: user, TargetID: blocked, Kind: models.RelationBlock, CreatedAt: at.Add(2 * time.Second)},
+	}
+	for _, relation := range relations {
+		require.NoError(t, storage.AddUserRelation(ctx, relation))
+	}
+	again := relations[0]
+	again.CreatedAt = at.Add(time.Hour)
+	require.NoError(t, storage.AddUserRelation(ctx, again), "Adding a relation twice is not an error")
+
+	mutes, err := storage.ListUserRelations(ctx, user, models.RelationMute, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, mutes, 2)
+	assert.Equal(t, blocked, mutes[0].TargetID, "Newest first")
+	assert.Equal(t, muted, mutes[1].TargetID)
+	assert.True(t, at.Equal(mutes[1].CreatedAt), "The original relation is kept")
+	page, err := storage.ListUserRelations(ctx, user, models.RelationMute, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, muted, page[0].TargetID)
+
+	got, err := storage.GetUserRelations(ctx, user, blocked)
+	require.NoError(t, err)
+	assert.Equal(t, models.Relations{Muted: true, Blocked: true}, got)
+	got, err = storage.GetUserRelations(ctx, blocked, user)
+	require.NoError(t, err)
+	assert.False(t, got.Hides(), "Relations are one way")
+
+	require.NoError(t, storage.RemoveUserRelation(ctx, user, blocked, models.RelationMute))
+	require.NoError(t, storage.RemoveUserRelation(ctx, user, blocked, models.RelationMute), "Removing a missing relation is not an error")
+	got, err = storage.GetUserRelations(ctx, user, blocked)
+	require.NoError(t, err)
+	assert.Equal(t, models.Relations{Blocked: true}, got, "Mute and block are removed separately")
+	blocks, err := storage.ListUserRelations(ctx, user, models.RelationBlock, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, blocked, blocks[0].TargetID)
+}
+
+func testHideMutedAuthors(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	viewer := uuid.New()
+	muted := MustCreatePost(t, storage)
+	blocked := MustCreatePost(t, storage)
+	kept := MustCreatePost(t, storage)
+	reply := MustCreateComment(t, storage, kept.ID, nil)
+	mutedReply := NewComment(kept.ID, nil)
+	mutedReply.UserID = muted.UserID
+	require.NoError(t, storage.CreateComment(ctx, mutedReply))
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, storage.AddUserRelation(ctx, models.UserRelation{UserID: viewer, TargetID: muted.UserID, Kind: models.RelationMute, CreatedAt: at}))
+	require.NoError(t, storage.AddUserRelation(ctx, models.UserRelation{UserID: viewer, TargetID: blocked.UserID, Kind: models.RelationBlock, CreatedAt: at}))
+
+	posts, err := storage.ListPostsVisibleTo(ctx, viewer, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, kept.ID, posts[0].ID)
+	posts, err = storage.ListPostsVisibleTo(ctx, uuid.New(), false, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 3, "Other viewers are not affected")
+
+	comments, err := storage.ListVisibleComments(ctx, kept.ID, viewer, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, reply.ID, comments[0].ID)
+	comments, err = storage.ListVisibleComments(ctx, kept.ID, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2)
+}
+
+// NewCommunity returns a valid community with a fresh ID and two
+// moderators.
+func NewCommunity(slug string) models.Community {
+	return models.Community{
+		ID:            uuid.New(),
+		Slug:          slug,
+		Title:         "Test Community",
+		Description:   "A community for tests.",
+		OwnerID:       uuid.New(),
+		Moderators:    []uuid.UUID{uuid.New(), uuid.New()},
+		CommentPolicy: models.CommentsOpen,
+		CreatedAt:     time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func testCommunities(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	first := NewCommunity("golang")
+	second := NewCommunity("rust")
+	second.Moderators = nil
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	require.NoError(t, storage.CreateCommunity(ctx, first))
+	require.NoError(t, storage.CreateCommunity(ctx, second))
+	taken := NewCommunity("golang")
+	assert.ErrorIs(t, storage.CreateCommunity(ctx, taken), models.ErrCommunitySlugTaken)
+
+	got, err := storage.GetCommunity(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+	got, err = storage.GetCommunityBySlug(ctx, "rust")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.ID)
+	assert.Empty(t, got.Moderators)
+	_, err = storage.GetCommunity(ctx, uuid.New())
+	assert.ErrorIs(t, err, models.ErrCommunityNotFound)
+	_, err = storage.GetCommunityBySlug(ctx, "missing")
+	assert.ErrorIs(t, err, models.ErrCommunityNotFound)
+
+	communities, err := storage.ListCommunities(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, communities, 2)
+	assert.Equal(t, first, communities[0], "Oldest first, with moderators")
+	assert.Equal(t, second.ID, communities[1].ID)
+	communities, err = storage.ListCommunities(ctx, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, communities, 1)
+	assert.Equal(t, second.ID, communities[0].ID)
+
+	updated := first
+	updated.Slug = "ignored"
+	updated.OwnerID = uuid.New()
+	updated.Title = "Go"
+	updated.Description = ""
+	updated.CommentPolicy = models.CommentsModerators
+	updated.Moderators = []uuid.UUID{first.Moderators[1], uuid.New()}
+	require.NoError(t, storage.UpdateCommunity(ctx, updated))
+	got, err = storage.GetCommunity(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "golang", got.Slug, "The slug is fixed")
+	assert.Equal(t, first.OwnerID, got.OwnerID, "The owner is fixed")
+	assert.Equal(t, "Go", got.Title)
+	assert.Empty(t, got.Description)
+	assert.Equal(t, models.CommentsModerators, got.CommentPolicy)
+	assert.Equal(t, updated.Moderators, got.Moderators)
+
+	missing := NewCommunity("missing")
+	assert.ErrorIs(t, storage.UpdateCommunity(ctx, missing), models.ErrCommunityNotFound)
+}
+
+func testCommunityPosts(t *testing.T, storage models.Storage) {
+	ctx := context.Background()
+	community := NewCommunity("golang")
+	other := NewCommunity("rust")
+	require.NoError(t, storage.CreateCommunity(ctx, community))
+	require.NoError(t, storage.CreateCommunity(ctx, other))
+
+	inCommunity := NewPost()
+	inCommunity.CommunityID = &community.ID
+	require.NoError(t, storage.CreatePost(ctx, inCommunity))
+	draft := NewPost()
+	draft.CommunityID = &community.ID
+	draft.Status = models.PostDraft
+	require.NoError(t, storage.CreatePost(ctx, draft))
+	elsewhere := NewPost()
+	elsewhere.CommunityID = &other.ID
+	require.NoError(t, storage.CreatePost(ctx, elsewhere))
+	outside := MustCreatePost(t, storage)
+
+	got, err := storage.GetPostByID(ctx, inCommunity.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.CommunityID)
+	assert.Equal(t, community.ID, *got.CommunityID)
+	got, err = storage.GetPostByID(ctx, outside.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.CommunityID)
+
+	posts, err := storage.ListCommunityPosts(ctx, community.ID, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, inCommunity.ID, posts[0].ID)
+	posts, err = storage.ListCommunityPosts(ctx, community.ID, draft.UserID, false, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 2, "Authors see their drafts")
+
+	require.NoError(t, storage.AddUserRelation(ctx, models.UserRelation{UserID: draft.UserID, TargetID: inCommunity.UserID,
+		Kind: models.RelationMute, CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}))
+	posts, err = storage.ListCommunityPosts(ctx, community.ID, draft.UserID, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1, "Muted authors are left out")
+	assert.Equal(t, draft.ID, posts[0].ID)
+
+	posts, err = storage.ListPostsVisibleTo(ctx, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, posts, 3, "Community posts are listed with all the others")
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"ozon-test/internal/pubsub"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PubSub decorates a pubsub.PubSub with publish and deliver spans.
+type PubSub struct {
+	next pubsub.PubSub
+}
+
+// NewPubSub wraps next so that publishing and delivery are traced.
+func NewPubSub(next pubsub.PubSub) *PubSub {
+	return &PubSub{next: next}
+}
+
+// Subscribe forwards every message from the underlying subscription and
+// records a deliver span for it.
+func (p *PubSub) Subscribe(ctx context.Context, postID uuid.UUID) (<-chan string, error) {
+	ch, err := p.next.Subscribe(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string, 1)
+	go func() {
+		defer close(out)
+		for msg := range ch {
+			_, span := Tracer().Start(ctx, "pubsub.deliver",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					semconv.MessagingDestinationName(postID.String()),
+					attribute.String("pubsub.message", msg),
+				),
+			)
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+			}
+			span.End()
+		}
+	}()
+	return out, nil
+}
+
+func (p *PubSub) Publish(ctx context.Context, postID uuid.UUID, message string) (err error) {
+	ctx, span := Tracer().Start(ctx, "pubsub.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingDestinationName(postID.String()),
+			attribute.String("pubsub.message", message),
+		),
+	)
+	defer func() { endSpan(span, err) }()
+	return p.next.Publish(ctx, postID, message)
+}
+
+func (p *PubSub) Close() error {
+	return p.next.Close()
+}
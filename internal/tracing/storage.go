@@ -0,0 +1,406 @@
+package tracing
+
+import (
+	"context"
+	"ozon-test/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Storage decorates a models.Storage with a span per call.
+type Storage struct {
+	next models.Storage
+}
+
+// NewStorage wraps next so that every storage call is traced.
+func NewStorage(next models.Storage) *Storage {
+	return &Storage{next: next}
+}
+
+func startStorageSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "storage."+method, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *Storage) CreatePost(ctx context.Context, post models.Post) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreatePost", attribute.String("post.id", post.ID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.CreatePost(ctx, post)
+}
+
+func (s *Storage) GetPostByID(ctx context.Context, postID uuid.UUID) (_ models.Post, err error) {
+	ctx, span := startStorageSpan(ctx, "GetPostByID", attribute.String("post.id", postID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetPostByID(ctx, postID)
+}
+
+func (s *Storage) ListPosts(ctx context.Context, page, pageSize int) (_ []models.Post, err error) {
+	ctx, span := startStorageSpan(ctx, "ListPosts", attribute.Int("page", page), attribute.Int("page_size", pageSize))
+	defer func() { endSpan(span, err) }()
+	return s.next.ListPosts(ctx, page, pageSize)
+}
+
+func (s *Storage) ListPostsVisibleTo(ctx context.Context, viewerID uuid.UUID, includeHidden bool, page, pageSize int) (_ []models.Post, err error) {
+	ctx, span := startStorageSpan(ctx, "ListPostsVisibleTo",
+		attribute.Bool("include_hidden", includeHidden),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListPostsVisibleTo(ctx, viewerID, includeHidden, page, pageSize)
+}
+
+func (s *Storage) SetPostStatus(ctx context.Context, postID uuid.UUID, status models.PostStatus, publishAt *time.Time) (err error) {
+	ctx, span := startStorageSpan(ctx, "SetPostStatus", attribute.String("post.id", postID.String()), attribute.String("post.status", string(status)))
+	defer func() { endSpan(span, err) }()
+	return s.next.SetPostStatus(ctx, postID, status, publishAt)
+}
+
+func (s *Storage) PublishDuePosts(ctx context.Context, now time.Time) (posts []models.Post, err error) {
+	ctx, span := startStorageSpan(ctx, "PublishDuePosts")
+	defer func() {
+		span.SetAttributes(attribute.Int("posts.published", len(posts)))
+		endSpan(span, err)
+	}()
+	return s.next.PublishDuePosts(ctx, now)
+}
+
+func (s *Storage) CreateComment(ctx context.Context, comment models.Comment) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateComment",
+		attribute.String("comment.id", comment.ID.String()),
+		attribute.String("post.id", comment.PostID.String()),
+		attribute.Bool("comment.is_reply", comment.ParentID != nil),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateComment(ctx, comment)
+}
+
+func (s *Storage) CreateCommentWithEvent(ctx context.Context, comment models.Comment, event models.OutboxEvent) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateCommentWithEvent",
+		attribute.String("comment.id", comment.ID.String()),
+		attribute.String("post.id", comment.PostID.String()),
+		attribute.Bool("comment.is_reply", comment.ParentID != nil),
+		attribute.String("outbox.channel", event.Channel),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateCommentWithEvent(ctx, comment, event)
+}
+
+func (s *Storage) LockThread(ctx context.Context, commentID uuid.UUID) (err error) {
+	ctx, span := startStorageSpan(ctx, "LockThread", attribute.String("comment.id", commentID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.LockThread(ctx, commentID)
+}
+
+func (s *Storage) GetCommentsByPostID(ctx context.Context, postID uuid.UUID, page, pageSize int) (_ []models.Comment, err error) {
+	ctx, span := startStorageSpan(ctx, "GetCommentsByPostID",
+		attribute.String("post.id", postID.String()),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.GetCommentsByPostID(ctx, postID, page, pageSize)
+}
+
+func (s *Storage) ListVisibleComments(ctx context.Context, postID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) (_ []models.Comment, err error) {
+	ctx, span := startStorageSpan(ctx, "ListVisibleComments",
+		attribute.String("post.id", postID.String()),
+		attribute.Bool("include_hidden", includeHidden),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListVisibleComments(ctx, postID, viewerID, includeHidden, page, pageSize)
+}
+
+func (s *Storage) SetCommentDepthLimit(limit models.DepthLimit) {
+	s.next.SetCommentDepthLimit(limit)
+}
+
+func (s *Storage) ListThread(ctx context.Context, postID uuid.UUID, maxDepth, page, pageSize int) (_ []models.ThreadComment, err error) {
+	ctx, span := startStorageSpan(ctx, "ListThread",
+		attribute.String("post.id", postID.String()),
+		attribute.Int("max_depth", maxDepth),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListThread(ctx, postID, maxDepth, page, pageSize)
+}
+
+func (s *Storage) UpdatePost(ctx context.Context, post models.Post) (err error) {
+	ctx, span := startStorageSpan(ctx, "UpdatePost", attribute.String("post.id", post.ID.String()), attribute.Int("post.version", post.Version))
+	defer func() { endSpan(span, err) }()
+	return s.next.UpdatePost(ctx, post)
+}
+
+func (s *Storage) GetCommentByID(ctx context.Context, commentID uuid.UUID) (_ models.Comment, err error) {
+	ctx, span := startStorageSpan(ctx, "GetCommentByID", attribute.String("comment.id", commentID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetCommentByID(ctx, commentID)
+}
+
+func (s *Storage) ListPostRevisions(ctx context.Context, postID uuid.UUID, page, pageSize int) (_ []models.PostRevision, err error) {
+	ctx, span := startStorageSpan(ctx, "ListPostRevisions",
+		attribute.String("post.id", postID.String()),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListPostRevisions(ctx, postID, page, pageSize)
+}
+
+func (s *Storage) GetPostRevision(ctx context.Context, postID uuid.UUID, version int) (_ models.PostRevision, err error) {
+	ctx, span := startStorageSpan(ctx, "GetPostRevision", attribute.String("post.id", postID.String()), attribute.Int("post.version", version))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetPostRevision(ctx, postID, version)
+}
+
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key models.IdempotencyKey) (_ models.IdempotencyKey, reserved bool, err error) {
+	ctx, span := startStorageSpan(ctx, "ReserveIdempotencyKey", attribute.String("idempotency.operation", key.Operation))
+	defer func() {
+		span.SetAttributes(attribute.Bool("idempotency.reserved", reserved))
+		endSpan(span, err)
+	}()
+	return s.next.ReserveIdempotencyKey(ctx, key)
+}
+
+func (s *Storage) DeleteIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, resourceID uuid.UUID) (err error) {
+	ctx, span := startStorageSpan(ctx, "DeleteIdempotencyKey")
+	defer func() { endSpan(span, err) }()
+	return s.next.DeleteIdempotencyKey(ctx, userID, key, resourceID)
+}
+
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (purged int, err error) {
+	ctx, span := startStorageSpan(ctx, "PurgeIdempotencyKeys")
+	defer func() {
+		span.SetAttributes(attribute.Int("idempotency.purged", purged))
+		endSpan(span, err)
+	}()
+	return s.next.PurgeIdempotencyKeys(ctx, before)
+}
+
+func (s *Storage) SetUsername(ctx context.Context, userID uuid.UUID, username string) (err error) {
+	ctx, span := startStorageSpan(ctx, "SetUsername", attribute.String("user.id", userID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.SetUsername(ctx, userID, username)
+}
+
+func (s *Storage) ResolveUsernames(ctx context.Context, usernames []string) (_ map[string]uuid.UUID, err error) {
+	ctx, span := startStorageSpan(ctx, "ResolveUsernames", attribute.Int("usernames", len(usernames)))
+	defer func() { endSpan(span, err) }()
+	return s.next.ResolveUsernames(ctx, usernames)
+}
+
+func (s *Storage) CreateNotifications(ctx context.Context, notifications []models.Notification) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateNotifications", attribute.Int("notifications", len(notifications)))
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateNotifications(ctx, notifications)
+}
+
+func (s *Storage) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, page, pageSize int) (_ []models.Notification, err error) {
+	ctx, span := startStorageSpan(ctx, "ListNotifications",
+		attribute.String("user.id", userID.String()),
+		attribute.Bool("unread_only", unreadOnly),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListNotifications(ctx, userID, unreadOnly, page, pageSize)
+}
+
+func (s *Storage) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	ctx, span := startStorageSpan(ctx, "CountUnreadNotifications", attribute.String("user.id", userID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.CountUnreadNotifications(ctx, userID)
+}
+
+func (s *Storage) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (marked int, err error) {
+	ctx, span := startStorageSpan(ctx, "MarkNotificationsRead", attribute.String("user.id", userID.String()))
+	defer func() {
+		span.SetAttributes(attribute.Int("notifications.marked", marked))
+		endSpan(span, err)
+	}()
+	return s.next.MarkNotificationsRead(ctx, userID, ids)
+}
+
+func (s *Storage) CreateWebhook(ctx context.Context, webhook models.Webhook) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateWebhook", attribute.String("webhook.id", webhook.ID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateWebhook(ctx, webhook)
+}
+
+func (s *Storage) ListWebhooks(ctx context.Context) (_ []models.Webhook, err error) {
+	ctx, span := startStorageSpan(ctx, "ListWebhooks")
+	defer func() { endSpan(span, err) }()
+	return s.next.ListWebhooks(ctx)
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, webhookID uuid.UUID) (err error) {
+	ctx, span := startStorageSpan(ctx, "DeleteWebhook", attribute.String("webhook.id", webhookID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.DeleteWebhook(ctx, webhookID)
+}
+
+func (s *Storage) CreateWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateWebhookDelivery",
+		attribute.String("webhook.id", delivery.WebhookID.String()),
+		attribute.String("webhook.event", string(delivery.Event)),
+		attribute.Int("webhook.attempt", delivery.Attempt),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateWebhookDelivery(ctx, delivery)
+}
+
+func (s *Storage) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, page, pageSize int) (_ []models.WebhookDelivery, err error) {
+	ctx, span := startStorageSpan(ctx, "ListWebhookDeliveries",
+		attribute.String("webhook.id", webhookID.String()),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListWebhookDeliveries(ctx, webhookID, page, pageSize)
+}
+
+func (s *Storage) PendingOutboxEvents(ctx context.Context, limit int) (_ []models.OutboxEvent, err error) {
+	ctx, span := startStorageSpan(ctx, "PendingOutboxEvents", attribute.Int("limit", limit))
+	defer func() { endSpan(span, err) }()
+	return s.next.PendingOutboxEvents(ctx, limit)
+}
+
+func (s *Storage) MarkOutboxEventsDelivered(ctx context.Context, ids []uuid.UUID, at time.Time) (err error) {
+	ctx, span := startStorageSpan(ctx, "MarkOutboxEventsDelivered", attribute.Int("outbox.count", len(ids)))
+	defer func() { endSpan(span, err) }()
+	return s.next.MarkOutboxEventsDelivered(ctx, ids, at)
+}
+
+func (s *Storage) PurgeOutboxEvents(ctx context.Context, before time.Time) (purged int, err error) {
+	ctx, span := startStorageSpan(ctx, "PurgeOutboxEvents")
+	defer func() {
+		span.SetAttributes(attribute.Int("outbox.purged", purged))
+		endSpan(span, err)
+	}()
+	return s.next.PurgeOutboxEvents(ctx, before)
+}
+
+func (s *Storage) CreateReport(ctx context.Context, report models.Report) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateReport",
+		attribute.String("report.id", report.ID.String()),
+		attribute.String("report.target", string(report.TargetType)),
+		attribute.String("report.reason", string(report.Reason)),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateReport(ctx, report)
+}
+
+func (s *Storage) GetReport(ctx context.Context, reportID uuid.UUID) (_ models.Report, err error) {
+	ctx, span := startStorageSpan(ctx, "GetReport", attribute.String("report.id", reportID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetReport(ctx, reportID)
+}
+
+func (s *Storage) ListReports(ctx context.Context, filter models.ReportFilter, page, pageSize int) (_ []models.Report, err error) {
+	ctx, span := startStorageSpan(ctx, "ListReports",
+		attribute.String("report.status", string(filter.Status)),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListReports(ctx, filter, page, pageSize)
+}
+
+func (s *Storage) CloseReport(ctx context.Context, reportID uuid.UUID, decision models.ReportDecision) (_ models.Report, err error) {
+	ctx, span := startStorageSpan(ctx, "CloseReport",
+		attribute.String("report.id", reportID.String()),
+		attribute.String("report.status", string(decision.Status)),
+		attribute.Bool("report.hide_target", decision.HideTarget),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CloseReport(ctx, reportID, decision)
+}
+
+func (s *Storage) AddUserRelation(ctx context.Context, relation models.UserRelation) (err error) {
+	ctx, span := startStorageSpan(ctx, "AddUserRelation", attribute.String("relation.kind", string(relation.Kind)))
+	defer func() { endSpan(span, err) }()
+	return s.next.AddUserRelation(ctx, relation)
+}
+
+func (s *Storage) RemoveUserRelation(ctx context.Context, userID, targetID uuid.UUID, kind models.RelationKind) (err error) {
+	ctx, span := startStorageSpan(ctx, "RemoveUserRelation", attribute.String("relation.kind", string(kind)))
+	defer func() { endSpan(span, err) }()
+	return s.next.RemoveUserRelation(ctx, userID, targetID, kind)
+}
+
+func (s *Storage) ListUserRelations(ctx context.Context, userID uuid.UUID, kind models.RelationKind, page, pageSize int) (_ []models.UserRelation, err error) {
+	ctx, span := startStorageSpan(ctx, "ListUserRelations",
+		attribute.String("relation.kind", string(kind)),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListUserRelations(ctx, userID, kind, page, pageSize)
+}
+
+func (s *Storage) GetUserRelations(ctx context.Context, userID, targetID uuid.UUID) (_ models.Relations, err error) {
+	ctx, span := startStorageSpan(ctx, "GetUserRelations")
+	defer func() { endSpan(span, err) }()
+	return s.next.GetUserRelations(ctx, userID, targetID)
+}
+
+func (s *Storage) CreateCommunity(ctx context.Context, community models.Community) (err error) {
+	ctx, span := startStorageSpan(ctx, "CreateCommunity",
+		attribute.String("community.id", community.ID.String()),
+		attribute.String("community.slug", community.Slug),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.CreateCommunity(ctx, community)
+}
+
+func (s *Storage) GetCommunity(ctx context.Context, communityID uuid.UUID) (_ models.Community, err error) {
+	ctx, span := startStorageSpan(ctx, "GetCommunity", attribute.String("community.id", communityID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetCommunity(ctx, communityID)
+}
+
+func (s *Storage) GetCommunityBySlug(ctx context.Context, slug string) (_ models.Community, err error) {
+	ctx, span := startStorageSpan(ctx, "GetCommunityBySlug", attribute.String("community.slug", slug))
+	defer func() { endSpan(span, err) }()
+	return s.next.GetCommunityBySlug(ctx, slug)
+}
+
+func (s *Storage) ListCommunities(ctx context.Context, page, pageSize int) (_ []models.Community, err error) {
+	ctx, span := startStorageSpan(ctx, "ListCommunities",
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListCommunities(ctx, page, pageSize)
+}
+
+func (s *Storage) UpdateCommunity(ctx context.Context, community models.Community) (err error) {
+	ctx, span := startStorageSpan(ctx, "UpdateCommunity", attribute.String("community.id", community.ID.String()))
+	defer func() { endSpan(span, err) }()
+	return s.next.UpdateCommunity(ctx, community)
+}
+
+func (s *Storage) ListCommunityPosts(ctx context.Context, communityID, viewerID uuid.UUID, includeHidden bool, page, pageSize int) (_ []models.Post, err error) {
+	ctx, span := startStorageSpan(ctx, "ListCommunityPosts",
+		attribute.String("community.id", communityID.String()),
+		attribute.Bool("include_hidden", includeHidden),
+		attribute.Int("page", page),
+		attribute.Int("page_size", pageSize),
+	)
+	defer func() { endSpan(span, err) }()
+	return s.next.ListCommunityPosts(ctx, communityID, viewerID, includeHidden, page, pageSize)
+}
@@ -0,0 +1,112 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ozon-test/internal/gql"
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/pubsub"
+	"ozon-test/internal/tracing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return exporter
+}
+
+func spanByName(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestGraphQLRequestIsTracedEndToEnd(t *testing.T) {
+	exporter := setupExporter(t)
+
+	mem := inmemory.NewInMemoryStorage()
+	post := models.Post{ID: uuid.New(), UserID: uuid.New(), AllowComments: true, CreatedAt: time.Now()}
+	require.NoError(t, mem.CreatePost(context.Background(), post))
+	storage := tracing.NewStorage(mem)
+	ps := tracing.NewPubSub(pubsub.NewInMemoryPubSub())
+	srv := handler.NewDefaultServer(gql.NewExecutableSchema(gql.Config{Resolvers: &gql.Resolver{Storage: storage, PubSub: ps}}))
+	srv.Use(tracing.Extension{})
+	h := tracing.Middleware(srv)
+
+	body := `{"query":"mutation AddComment { createComment(postId: \"` + post.ID.String() + `\", content: \"hi\", userId: \"` + uuid.NewString() + `\") { id } }"}`
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "errors")
+
+	spans := exporter.GetSpans()
+	for _, name := range []string{"POST /query", "graphql.AddComment", "Mutation.createComment", "storage.CreateComment", "pubsub.publish"} {
+		span, ok := spanByName(spans, name)
+		if assert.True(t, ok, "span %q should be recorded", name) {
+			assert.Equal(t, traceID, span.SpanContext.TraceID().String(), "span %q should join the incoming trace", name)
+		}
+	}
+
+	httpSpan, _ := spanByName(spans, "POST /query")
+	assert.True(t, httpSpan.Parent.IsRemote(), "HTTP span should be parented by the propagated context")
+
+	fieldSpan, _ := spanByName(spans, "Mutation.createComment")
+	storageSpan, _ := spanByName(spans, "storage.CreateComment")
+	assert.Equal(t, fieldSpan.SpanContext.SpanID(), storageSpan.Parent.SpanID(), "storage span should be a child of the resolver span")
+}
+
+func TestPubSubDeliverIsTraced(t *testing.T) {
+	exporter := setupExporter(t)
+
+	ps := tracing.NewPubSub(pubsub.NewInMemoryPubSub())
+	postID := uuid.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := ps.Subscribe(ctx, postID)
+	require.NoError(t, err)
+
+	require.NoError(t, ps.Publish(context.Background(), postID, "message"))
+
+	select {
+	case msg := <-ch:
+		assert.Equal(t, "message", msg)
+	case <-time.After(time.Second):
+		t.Fatal("Did not receive message in time")
+	}
+
+	assert.Eventually(t, func() bool {
+		_, ok := spanByName(exporter.GetSpans(), "pubsub.deliver")
+		return ok
+	}, time.Second, 10*time.Millisecond, "deliver span should be recorded")
+
+	_, ok := spanByName(exporter.GetSpans(), "pubsub.publish")
+	assert.True(t, ok, "publish span should be recorded")
+}
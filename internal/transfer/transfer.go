@@ -0,0 +1,445 @@
+// Package transfer streams the contents of a models.Storage to and from
+// newline-delimited JSON, so data can be moved between backends.
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ozon-test/internal/models"
+	"sort"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Record types written to the stream.
+const (
+	TypeCommunity = "community"
+	TypePost      = "post"
+	TypeComment   = "comment"
+	TypeStructure = "structure"
+)
+
+// pageSize is the batch size used when paging through the source storage.
+const pageSize = 100
+
+// Record is a single line of the export. Exactly one payload is set,
+// depending on Type.
+//
+// Structure records describe the comment tree as a closure table: one row
+// per (ancestor, descendant) pair including the comment itself, where Level
+// is the distance between them and NearestAncestorID is the descendant's
+// parent (or the comment itself for top-level self rows). They are emitted
+// for inspection and consistency checking; storages rebuild their own tree
+// from Comment.ParentID on import.
+type Record struct {
+	Type      string                `json:"type"`
+	Community *models.Community     `json:"community,omitempty"`
+	Post      *models.Post          `json:"post,omitempty"`
+	Comment   *models.Comment       `json:"comment,omitempty"`
+	Structure *models.StructureTree `json:"structure,omitempty"`
+}
+
+// Stats counts the records handled by Export or Import.
+type Stats struct {
+	Communities int
+	Posts       int
+	Comments    int
+	Structure   int
+}
+
+// Export writes every community, then every post, followed by its comments
+// and their closure rows, to w. Comments are ordered so that each parent
+// precedes its replies.
+//
+// Export pages through the storage, so writes made while it runs may or may
+// not be included.
+func Export(ctx context.Context, storage models.Storage, w io.Writer) (Stats, error) {
+	var stats Stats
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for page := 1; ; page++ {
+		communities, err := storage.ListCommunities(ctx, page, pageSize)
+		if err != nil {
+			return stats, fmt.Errorf("list communities page %d: %w", page, err)
+		}
+		for i := range communities {
+			if err := enc.Encode(Record{Type: TypeCommunity, Community: &communities[i]}); err != nil {
+				return stats, err
+			}
+			stats.Communities++
+		}
+		if len(communities) < pageSize {
+			break
+		}
+	}
+
+	for page := 1; ; page++ {
+		posts, err := storage.ListPosts(ctx, page, pageSize)
+		if err != nil {
+			return stats, fmt.Errorf("list posts page %d: %w", page, err)
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		for i := range posts {
+			if err := enc.Encode(Record{Type: TypePost, Post: &posts[i]}); err != nil {
+				return stats, err
+			}
+			stats.Posts++
+
+			comments, err := allComments(ctx, storage, posts[i].ID)
+			if err != nil {
+				return stats, err
+			}
+			for _, comment := range treeOrder(comments) {
+				comment := comment
+				if err := enc.Encode(Record{Type: TypeComment, Comment: &comment}); err != nil {
+					return stats, err
+				}
+				stats.Comments++
+			}
+			for _, row := range closure(comments) {
+				row := row
+				if err := enc.Encode(Record{Type: TypeStructure, Structure: &row}); err != nil {
+					return stats, err
+				}
+				stats.Structure++
+			}
+		}
+
+		if len(posts) < pageSize {
+			break
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return stats, err
+	}
+	slog.Info("Export finished", "communities", stats.Communities, "posts", stats.Posts, "comments", stats.Comments, "structure", stats.Structure)
+	return stats, nil
+}
+
+func allComments(ctx context.Context, storage models.Storage, postID uuid.UUID) ([]models.Comment, error) {
+	var all []models.Comment
+	for page := 1; ; page++ {
+		comments, err := storage.GetCommentsByPostID(ctx, postID, page, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list comments of post %s: %w", postID, err)
+		}
+		all = append(all, comments...)
+		if len(comments) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+// treeOrder returns comments depth-first, oldest sibling first, so that every
+// parent appears before its replies. Comments whose parent is missing are
+// appended at the end.
+func treeOrder(comments []models.Comment) []models.Comment {
+	byID := make(map[uuid.UUID]bool, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = true
+	}
+
+	children := make(map[uuid.UUID][]models.Comment)
+	var roots, orphans []models.Comment
+	for _, c := range comments {
+		switch {
+		case c.ParentID == nil:
+			roots = append(roots, c)
+		case byID[*c.ParentID]:
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		default:
+			orphans = append(orphans, c)
+		}
+	}
+
+	ordered := make([]models.Comment, 0, len(comments))
+	var visit func(level []models.Comment)
+	visit = func(level []models.Comment) {
+		sort.SliceStable(level, func(i, j int) bool { return level[i].CreatedAt.Before(level[j].CreatedAt) })
+		for _, c := range level {
+			ordered = append(ordered, c)
+			visit(children[c.ID])
+		}
+	}
+	visit(roots)
+	return append(ordered, orphans...)
+}
+
+// closure derives the closure-table rows for comments from their parents.
+func closure(comments []models.Comment) []models.StructureTree {
+	byID := make(map[uuid.UUID]models.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+
+	var rows []models.StructureTree
+	for _, c := range treeOrder(comments) {
+		nearest := c.ID
+		if c.ParentID != nil {
+			nearest = *c.ParentID
+		}
+		rows = append(rows, models.StructureTree{AncestorID: c.ID, DescendantID: c.ID, NearestAncestorID: nearest, Level: 0, SubjectID: c.PostID})
+
+		level := 1
+		for parentID := c.ParentID; parentID != nil; level++ {
+			parent, ok := byID[*parentID]
+			if !ok {
+				break
+			}
+			rows = append(rows, models.StructureTree{AncestorID: parent.ID, DescendantID: c.ID, NearestAncestorID: nearest, Level: level, SubjectID: c.PostID})
+			parentID = parent.ParentID
+		}
+	}
+	return rows
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// DryRun validates the stream without writing anything.
+	DryRun bool
+}
+
+// ImportError reports the stream line that failed.
+type ImportError struct {
+	Line int
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrUnknownCommunity = errors.New("unknown community")
+	ErrUnknownPost      = errors.New("unknown post")
+	ErrUnknownParent    = errors.New("unknown parent comment")
+	ErrParentOtherPost  = errors.New("parent comment belongs to another post")
+	ErrInvalidStructure = errors.New("structure row does not match comment parents")
+)
+
+// Import reads records produced by Export and creates them in storage,
+// preserving IDs and timestamps. Every post's community and every comment's
+// post and parent must either appear earlier in the stream or already exist
+// in storage. Import stops at
+// the first invalid record; records before it have already been written
+// unless opts.DryRun is set.
+func Import(ctx context.Context, storage models.Storage, r io.Reader, opts ImportOptions) (Stats, error) {
+	var stats Stats
+	imp := importer{
+		storage:     storage,
+		communities: make(map[uuid.UUID]bool),
+		posts:       make(map[uuid.UUID]bool),
+		comments:    make(map[uuid.UUID]models.Comment),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return stats, &ImportError{Line: line, Err: err}
+		}
+		if err := imp.handle(ctx, rec, opts.DryRun, &stats); err != nil {
+			return stats, &ImportError{Line: line, Err: err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	// Threads are locked last, so that replies below them could be created.
+	for _, commentID := range imp.locked {
+		if err := storage.LockThread(ctx, commentID); err != nil {
+			return stats, err
+		}
+	}
+
+	slog.Info("Import finished", "communities", stats.Communities, "posts", stats.Posts, "comments", stats.Comments, "structure", stats.Structure, "dryRun", opts.DryRun)
+	return stats, nil
+}
+
+type importer struct {
+	storage     models.Storage
+	communities map[uuid.UUID]bool
+	posts       map[uuid.UUID]bool
+	comments    map[uuid.UUID]models.Comment
+	locked      []uuid.UUID
+}
+
+func (imp *importer) handle(ctx context.Context, rec Record, dryRun bool, stats *Stats) error {
+	switch rec.Type {
+	case TypeCommunity:
+		if rec.Community == nil || rec.Community.ID == uuid.Nil {
+			return errors.New("community record without an ID")
+		}
+		if !dryRun {
+			if err := imp.storage.CreateCommunity(ctx, *rec.Community); err != nil {
+				return err
+			}
+		}
+		imp.communities[rec.Community.ID] = true
+		stats.Communities++
+
+	case TypePost:
+		if rec.Post == nil || rec.Post.ID == uuid.Nil {
+			return errors.New("post record without an ID")
+		}
+		if rec.Post.CommunityID != nil {
+			if err := imp.checkCommunity(ctx, *rec.Post.CommunityID); err != nil {
+				return err
+			}
+		}
+		// Exports written before posts were versioned have no version or
+		// editor.
+		if rec.Post.Version == 0 {
+			rec.Post.Version = 1
+		}
+		if rec.Post.UpdatedAt.IsZero() {
+			rec.Post.UpdatedAt = rec.Post.CreatedAt
+			rec.Post.UpdatedBy = rec.Post.UserID
+		}
+		if !dryRun {
+			if err := imp.storage.CreatePost(ctx, *rec.Post); err != nil {
+				return err
+			}
+		}
+		imp.posts[rec.Post.ID] = true
+		stats.Posts++
+
+	case TypeComment:
+		comment := rec.Comment
+		if comment == nil || comment.ID == uuid.Nil {
+			return errors.New("comment record without an ID")
+		}
+		if err := imp.checkPost(ctx, comment.PostID); err != nil {
+			return err
+		}
+		if comment.ParentID != nil {
+			parent, err := imp.lookupComment(ctx, *comment.ParentID)
+			if err != nil {
+				return err
+			}
+			if parent.PostID != comment.PostID {
+				return fmt.Errorf("%w: %s", ErrParentOtherPost, parent.ID)
+			}
+		}
+		if !dryRun {
+			if err := imp.storage.CreateComment(ctx, *comment); err != nil {
+				return err
+			}
+			if comment.Locked {
+				imp.locked = append(imp.locked, comment.ID)
+			}
+		}
+		imp.comments[comment.ID] = *comment
+		stats.Comments++
+
+	case TypeStructure:
+		if rec.Structure == nil {
+			return errors.New("structure record without a payload")
+		}
+		if err := imp.checkStructure(ctx, *rec.Structure); err != nil {
+			return err
+		}
+		stats.Structure++
+
+	default:
+		return fmt.Errorf("unknown record type %q", rec.Type)
+	}
+	return nil
+}
+
+func (imp *importer) checkCommunity(ctx context.Context, communityID uuid.UUID) error {
+	if imp.communities[communityID] {
+		return nil
+	}
+	if _, err := imp.storage.GetCommunity(ctx, communityID); err != nil {
+		if errors.Is(err, models.ErrCommunityNotFound) {
+			return fmt.Errorf("%w: %s", ErrUnknownCommunity, communityID)
+		}
+		return err
+	}
+	imp.communities[communityID] = true
+	return nil
+}
+
+func (imp *importer) checkPost(ctx context.Context, postID uuid.UUID) error {
+	if imp.posts[postID] {
+		return nil
+	}
+	if _, err := imp.storage.GetPostByID(ctx, postID); err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			return fmt.Errorf("%w: %s", ErrUnknownPost, postID)
+		}
+		return err
+	}
+	imp.posts[postID] = true
+	return nil
+}
+
+func (imp *importer) lookupComment(ctx context.Context, commentID uuid.UUID) (models.Comment, error) {
+	if comment, ok := imp.comments[commentID]; ok {
+		return comment, nil
+	}
+	comment, err := imp.storage.GetCommentByID(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, models.ErrCommentNotFound) {
+			return comment, fmt.Errorf("%w: %s", ErrUnknownParent, commentID)
+		}
+		return comment, err
+	}
+	imp.comments[commentID] = comment
+	return comment, nil
+}
+
+// checkStructure verifies that a closure row agrees with the parent chain of
+// its descendant.
+func (imp *importer) checkStructure(ctx context.Context, row models.StructureTree) error {
+	descendant, err := imp.lookupComment(ctx, row.DescendantID)
+	if err != nil {
+		return err
+	}
+
+	nearest := descendant.ID
+	if descendant.ParentID != nil {
+		nearest = *descendant.ParentID
+	}
+	if row.SubjectID != descendant.PostID || row.NearestAncestorID != nearest {
+		return ErrInvalidStructure
+	}
+
+	current := descendant
+	for level := 0; ; level++ {
+		if current.ID == row.AncestorID {
+			if level != row.Level {
+				return ErrInvalidStructure
+			}
+			return nil
+		}
+		if current.ParentID == nil {
+			return ErrInvalidStructure
+		}
+		if current, err = imp.lookupComment(ctx, *current.ParentID); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package transfer_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/sqlite"
+	"ozon-test/internal/storagetest"
+	"ozon-test/internal/transfer"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLite(t *testing.T) models.Storage {
+	db, err := sqlite.Open(context.Background(), t.TempDir()+"/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return sqlite.NewSQLiteStorage(db)
+}
+
+func createReply(t *testing.T, storage models.Storage, parent models.Comment, offset time.Duration) models.Comment {
+	reply := storagetest.NewComment(parent.PostID, &parent.ID)
+	reply.CreatedAt = parent.CreatedAt.Add(offset)
+	require.NoError(t, storage.CreateComment(context.Background(), reply))
+	return reply
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, source)
+	root := storagetest.MustCreateComment(t, source, post.ID, nil)
+	reply := createReply(t, source, root, time.Second)
+	nested := createReply(t, source, reply, time.Second)
+	storagetest.MustCreatePost(t, source)
+
+	var buf bytes.Buffer
+	stats, err := transfer.Export(ctx, source, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, transfer.Stats{Posts: 2, Comments: 3, Structure: 6}, stats)
+
+	target := newSQLite(t)
+	imported, err := transfer.Import(ctx, target, &buf, transfer.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, stats, imported)
+
+	got, err := target.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.Title, got.Title)
+	assert.True(t, post.CreatedAt.Equal(got.CreatedAt), "Timestamps should be preserved")
+
+	comments, err := target.GetCommentsByPostID(ctx, post.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 3)
+	assert.Equal(t, []uuid.UUID{root.ID, reply.ID, nested.ID}, []uuid.UUID{comments[0].ID, comments[1].ID, comments[2].ID})
+	assert.Equal(t, reply.ID, *comments[2].ParentID)
+}
+
+func TestImportKeepsLockedThreads(t *testing.T) {
+	ctx := context.Background()
+	source := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, source)
+	root := storagetest.MustCreateComment(t, source, post.ID, nil)
+	reply := createReply(t, source, root, time.Second)
+	require.NoError(t, source.LockThread(ctx, root.ID))
+
+	var buf bytes.Buffer
+	_, err := transfer.Export(ctx, source, &buf)
+	require.NoError(t, err)
+
+	target := newSQLite(t)
+	_, err = transfer.Import(ctx, target, &buf, transfer.ImportOptions{})
+	require.NoError(t, err, "Replies below a locked comment should still be imported")
+
+	got, err := target.GetCommentByID(ctx, root.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Locked)
+	err = target.CreateComment(ctx, storagetest.NewComment(post.ID, &reply.ID))
+	assert.ErrorIs(t, err, models.ErrThreadLocked)
+}
+
+func TestExportOrdersParentsFirst(t *testing.T) {
+	source := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, source)
+	root := storagetest.MustCreateComment(t, source, post.ID, nil)
+	// The reply is older than its parent, e.g. because of clock skew.
+	createReply(t, source, root, -time.Hour)
+
+	var buf bytes.Buffer
+	_, err := transfer.Export(context.Background(), source, &buf)
+	require.NoError(t, err)
+
+	seen := make(map[uuid.UUID]bool)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec transfer.Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		if rec.Type != transfer.TypeComment {
+			continue
+		}
+		if rec.Comment.ParentID != nil {
+			assert.True(t, seen[*rec.Comment.ParentID], "Parent should be exported before its reply")
+		}
+		seen[rec.Comment.ID] = true
+	}
+}
+
+func TestImportRejectsUnknownParent(t *testing.T) {
+	post := storagetest.NewPost()
+	comment := storagetest.NewComment(post.ID, nil)
+	missing := uuid.New()
+	comment.ParentID = &missing
+
+	input := encode(t,
+		transfer.Record{Type: transfer.TypePost, Post: &post},
+		transfer.Record{Type: transfer.TypeComment, Comment: &comment},
+	)
+	_, err := transfer.Import(context.Background(), inmemory.NewInMemoryStorage(), input, transfer.ImportOptions{})
+	assert.ErrorIs(t, err, transfer.ErrUnknownParent)
+
+	var importErr *transfer.ImportError
+	require.ErrorAs(t, err, &importErr)
+	assert.Equal(t, 2, importErr.Line)
+}
+
+func TestImportRejectsParentOnOtherPost(t *testing.T) {
+	first, second := storagetest.NewPost(), storagetest.NewPost()
+	parent := storagetest.NewComment(first.ID, nil)
+	reply := storagetest.NewComment(second.ID, &parent.ID)
+
+	input := encode(t,
+		transfer.Record{Type: transfer.TypePost, Post: &first},
+		transfer.Record{Type: transfer.TypePost, Post: &second},
+		transfer.Record{Type: transfer.TypeComment, Comment: &parent},
+		transfer.Record{Type: transfer.TypeComment, Comment: &reply},
+	)
+	_, err := transfer.Import(context.Background(), inmemory.NewInMemoryStorage(), input, transfer.ImportOptions{})
+	assert.ErrorIs(t, err, transfer.ErrParentOtherPost)
+}
+
+func TestImportRejectsInconsistentStructure(t *testing.T) {
+	post := storagetest.NewPost()
+	comment := storagetest.NewComment(post.ID, nil)
+	row := models.StructureTree{AncestorID: comment.ID, DescendantID: comment.ID, NearestAncestorID: comment.ID, Level: 1, SubjectID: post.ID}
+
+	input := encode(t,
+		transfer.Record{Type: transfer.TypePost, Post: &post},
+		transfer.Record{Type: transfer.TypeComment, Comment: &comment},
+		transfer.Record{Type: transfer.TypeStructure, Structure: &row},
+	)
+	_, err := transfer.Import(context.Background(), inmemory.NewInMemoryStorage(), input, transfer.ImportOptions{})
+	assert.ErrorIs(t, err, transfer.ErrInvalidStructure)
+}
+
+func TestImportParentFromStorage(t *testing.T) {
+	ctx := context.Background()
+	target := inmemory.NewInMemoryStorage()
+	post := storagetest.MustCreatePost(t, target)
+	parent := storagetest.MustCreateComment(t, target, post.ID, nil)
+	reply := storagetest.NewComment(post.ID, &parent.ID)
+
+	input := encode(t, transfer.Record{Type: transfer.TypeComment, Comment: &reply})
+	_, err := transfer.Import(ctx, target, input, transfer.ImportOptions{})
+	require.NoError(t, err, "Parents may already exist in the target storage")
+
+	_, err = target.GetCommentByID(ctx, reply.ID)
+	assert.NoError(t, err)
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	post := storagetest.NewPost()
+	input := encode(t, transfer.Record{Type: transfer.TypePost, Post: &post})
+
+	target := inmemory.NewInMemoryStorage()
+	stats, err := transfer.Import(ctx, target, input, transfer.ImportOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Posts)
+
+	_, err = target.GetPostByID(ctx, post.ID)
+	assert.ErrorIs(t, err, models.ErrPostNotFound, "Dry runs should not write")
+}
+
+func TestImportRejectsUnknownType(t *testing.T) {
+	_, err := transfer.Import(context.Background(), inmemory.NewInMemoryStorage(), strings.NewReader(`{"type":"user"}`+"\n"), transfer.ImportOptions{})
+	assert.Error(t, err)
+}
+
+func encode(t *testing.T, records ...transfer.Record) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		require.NoError(t, enc.Encode(rec))
+	}
+	return &buf
+}
+
+func TestRoundTripCommunities(t *testing.T) {
+	ctx := context.Background()
+	source := inmemory.NewInMemoryStorage()
+	community := storagetest.NewCommunity("golang")
+	require.NoError(t, source.CreateCommunity(ctx, community))
+	post := storagetest.NewPost()
+	post.CommunityID = &community.ID
+	require.NoError(t, source.CreatePost(ctx, post))
+
+	var buf bytes.Buffer
+	stats, err := transfer.Export(ctx, source, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, transfer.Stats{Communities: 1, Posts: 1}, stats)
+
+	target := newSQLite(t)
+	_, err = transfer.Import(ctx, target, &buf, transfer.ImportOptions{})
+	require.NoError(t, err)
+	got, err := target.GetCommunityBySlug(ctx, "golang")
+	require.NoError(t, err)
+	assert.Equal(t, community.Moderators, got.Moderators)
+	posts, err := target.ListCommunityPosts(ctx, community.ID, uuid.Nil, false, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, post.ID, posts[0].ID)
+}
+
+func TestImportRejectsUnknownCommunity(t *testing.T) {
+	post := storagetest.NewPost()
+	communityID := uuid.New()
+	post.CommunityID = &communityID
+	_, err := transfer.Import(context.Background(), inmemory.NewInMemoryStorage(), encode(t, transfer.Record{Type: transfer.TypePost, Post: &post}), transfer.ImportOptions{})
+	assert.ErrorIs(t, err, transfer.ErrUnknownCommunity)
+}
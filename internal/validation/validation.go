@@ -0,0 +1,187 @@
+// Package validation normalises and checks user-supplied post and comment
+// text before it is stored.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits are the maximum lengths of user content, in characters. Zero
+// means unlimited.
+type Limits struct {
+	MaxTitleLength   int
+	MaxPostLength    int
+	MaxCommentLength int
+}
+
+// FieldError describes why the value of one argument was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error lists every invalid field of a request.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return "invalid input: " + strings.Join(messages, "; ")
+}
+
+// Post normalises the title and content of a new post.
+func (l Limits) Post(title, content string) (string, string, error) {
+	var v validator
+	title = v.title("title", title, l.MaxTitleLength)
+	content = v.content("content", content, l.MaxPostLength)
+	return title, content, v.err()
+}
+
+// PostUpdate normalises the title and content of a post update in place;
+// nil fields are left alone.
+func (l Limits) PostUpdate(title, content *string) error {
+	var v validator
+	if title != nil {
+		*title = v.title("title", *title, l.MaxTitleLength)
+	}
+	if content != nil {
+		*content = v.content("content", *content, l.MaxPostLength)
+	}
+	return v.err()
+}
+
+// Comment normalises the content of a new comment.
+func (l Limits) Comment(content string) (string, error) {
+	var v validator
+	content = v.content("content", content, l.MaxCommentLength)
+	return content, v.err()
+}
+
+// Bounds of community slugs and descriptions, in characters.
+const (
+	MinSlugLength        = 3
+	MaxSlugLength        = 32
+	MaxDescriptionLength = 1000
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Community normalises the slug, title and description of a new community.
+// Slugs are lower-cased and may contain letters, digits and single hyphens
+// between them.
+func (l Limits) Community(slug, title, description string) (string, string, string, error) {
+	var v validator
+	slug = v.slug("slug", slug)
+	title = v.title("title", title, l.MaxTitleLength)
+	description = v.description("description", description)
+	return slug, title, description, v.err()
+}
+
+// CommunityUpdate normalises the title and description of a community
+// update in place; nil fields are left alone.
+func (l Limits) CommunityUpdate(title, description *string) error {
+	var v validator
+	if title != nil {
+		*title = v.title("title", *title, l.MaxTitleLength)
+	}
+	if description != nil {
+		*description = v.description("description", *description)
+	}
+	return v.err()
+}
+
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: v.fields}
+}
+
+// titleBreaks turns line breaks and tabs into spaces, so that words on
+// separate lines stay apart when a title is put on one line.
+var titleBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ", "\v", " ", "\f", " ")
+
+// title replaces line breaks and tabs with spaces and strips every other
+// control character, so titles stay on one line, and surrounding
+// whitespace.
+func (v *validator) title(field, value string, max int) string {
+	if !utf8.ValidString(value) {
+		v.fail(field, "must be valid UTF-8")
+		return value
+	}
+	value = strings.TrimSpace(stripControl(titleBreaks.Replace(value), false))
+	v.check(field, value, max)
+	return value
+}
+
+// content strips control characters other than newlines and tabs. Carriage
+// returns are dropped too, so line endings are always "\n".
+func (v *validator) content(field, value string, max int) string {
+	if !utf8.ValidString(value) {
+		v.fail(field, "must be valid UTF-8")
+		return value
+	}
+	value = stripControl(value, true)
+	v.check(field, value, max)
+	return value
+}
+
+func (v *validator) slug(field, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < MinSlugLength || len(value) > MaxSlugLength || !slugPattern.MatchString(value) {
+		v.fail(field, "must be %d to %d letters, digits and hyphens", MinSlugLength, MaxSlugLength)
+	}
+	return value
+}
+
+// description is content that may be empty, without surrounding
+// whitespace.
+func (v *validator) description(field, value string) string {
+	if !utf8.ValidString(value) {
+		v.fail(field, "must be valid UTF-8")
+		return value
+	}
+	value = strings.TrimSpace(stripControl(value, true))
+	if utf8.RuneCountInString(value) > MaxDescriptionLength {
+		v.fail(field, "must be at most %d characters", MaxDescriptionLength)
+	}
+	return value
+}
+
+func (v *validator) check(field, value string, max int) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "must not be empty")
+		return
+	}
+	if max > 0 && utf8.RuneCountInString(value) > max {
+		v.fail(field, "must be at most %d characters", max)
+	}
+}
+
+func stripControl(s string, keepLines bool) string {
+	return strings.Map(func(r rune) rune {
+		if keepLines && (r == '\n' || r == '\t') {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,73 @@
+package validation_test
+
+import (
+	"testing"
+
+	"ozon-test/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostNormalises(t *testing.T) {
+	limits := validation.Limits{MaxTitleLength: 20, MaxPostLength: 100}
+
+	title, content, err := limits.Post("  Hel\x00lo\nworld \t", "line one\r\nline\x07 two\n\tindented")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", title, "Titles lose control characters and surrounding space")
+	assert.Equal(t, "line one\nline two\n\tindented", content, "Content keeps newlines and tabs")
+}
+
+func TestTitleLineBreaksBecomeSpaces(t *testing.T) {
+	title, _, err := validation.Limits{}.Post("Hello\r\nworld\tand\nmore", "body")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world and more", title, "Words on separate lines stay apart")
+}
+
+func TestPostReportsEveryField(t *testing.T) {
+	limits := validation.Limits{MaxTitleLength: 5, MaxPostLength: 5}
+
+	_, _, err := limits.Post(" \x01 ", "too long")
+	var verr *validation.Error
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []validation.FieldError{
+		{Field: "title", Message: "must not be empty"},
+		{Field: "content", Message: "must be at most 5 characters"},
+	}, verr.Fields)
+}
+
+func TestLengthIsCountedInCharacters(t *testing.T) {
+	limits := validation.Limits{MaxCommentLength: 3}
+
+	_, err := limits.Comment("äöü")
+	assert.NoError(t, err)
+	_, err = limits.Comment("äöüß")
+	assert.Error(t, err)
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	_, err := validation.Limits{}.Comment("bad \xff byte")
+	assert.ErrorContains(t, err, "content: must be valid UTF-8")
+}
+
+func TestPostUpdateSkipsMissingFields(t *testing.T) {
+	title := " New "
+	require.NoError(t, validation.Limits{}.PostUpdate(&title, nil))
+	assert.Equal(t, "New", title)
+
+	empty := ""
+	assert.Error(t, validation.Limits{}.PostUpdate(nil, &empty))
+}
+
+func TestCommunity(t *testing.T) {
+	slug, title, description, err := validation.Limits{}.Community(" Go-Lang ", " Go ", " ")
+	require.NoError(t, err)
+	assert.Equal(t, "go-lang", slug, "Slugs are lower-cased")
+	assert.Equal(t, "Go", title)
+	assert.Empty(t, description, "Descriptions may be empty")
+
+	for _, slug := range []string{"go", "-go", "go--lang", "go_lang", "gö"} {
+		_, _, _, err := validation.Limits{}.Community(slug, "Go", "")
+		assert.ErrorContains(t, err, "slug: must be 3 to 32 letters, digits and hyphens", slug)
+	}
+}
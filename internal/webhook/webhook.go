@@ -0,0 +1,253 @@
+// Package webhook sends content events to registered webhooks as signed
+// JSON, retrying failed deliveries with exponential backoff and recording
+// every attempt in the storage.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"ozon-test/internal/models"
+	"ozon-test/internal/validation"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// Headers set on every delivery. SignatureHeader holds "sha256=" and the
+// hex HMAC-SHA256 of the body keyed with the webhook's secret.
+const (
+	EventHeader     = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Event-ID"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Config controls delivery. Zero values fall back to the defaults below.
+type Config struct {
+	// MaxAttempts bounds how often an event is sent to one webhook.
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failed attempt; it
+	// doubles after each further failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+}
+
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Payload is the JSON body of a delivery. Data is the post or comment the
+// event is about.
+type Payload struct {
+	ID        uuid.UUID           `json:"id"`
+	Event     models.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"created_at"`
+	Data      any                 `json:"data"`
+}
+
+// Dispatcher delivers events in the background.
+type Dispatcher struct {
+	storage models.Storage
+	client  *http.Client
+	cfg     Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex // guards closed and wg.Add
+	closed bool
+}
+
+// NewDispatcher creates a dispatcher that reads webhooks from, and records
+// deliveries in, storage.
+func NewDispatcher(storage models.Storage, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		storage: storage,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		cfg:     cfg,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// MinSecretLength is the shortest secret a webhook may be registered with.
+const MinSecretLength = 16
+
+// Validate checks the arguments a webhook is registered with.
+func Validate(rawURL, secret string, events []models.WebhookEvent) error {
+	var invalid validation.Error
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid.Fields = append(invalid.Fields, validation.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	if len(secret) < MinSecretLength {
+		invalid.Fields = append(invalid.Fields, validation.FieldError{Field: "secret", Message: fmt.Sprintf("must be at least %d characters", MinSecretLength)})
+	}
+	if len(events) == 0 {
+		invalid.Fields = append(invalid.Fields, validation.FieldError{Field: "events", Message: "must not be empty"})
+	}
+	if len(invalid.Fields) > 0 {
+		return &invalid
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch sends event about data to every webhook subscribed to it. It
+// returns once the deliveries are queued.
+func (d *Dispatcher) Dispatch(ctx context.Context, event models.WebhookEvent, data any) {
+	webhooks, err := d.storage.ListWebhooks(ctx)
+	if err != nil {
+		slog.Error("Failed to list webhooks", "error", err, "event", event)
+		return
+	}
+
+	payload := Payload{ID: uuid.New(), Event: event, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "error", err, "event", event)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(webhook models.Webhook) {
+			defer d.wg.Done()
+			d.deliver(webhook, payload, body)
+		}(webhook)
+	}
+}
+
+// Close abandons pending retries and waits for running attempts, each
+// bounded by Config.Timeout, to finish and be recorded. Events dispatched
+// afterwards are dropped.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.cancel()
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// deliver sends body to webhook until it is accepted, fails permanently
+// or runs out of attempts.
+func (d *Dispatcher) deliver(webhook models.Webhook, payload Payload, body []byte) {
+	backoff := d.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		status, err := d.send(webhook, payload, body)
+		delivery := models.WebhookDelivery{
+			ID:         uuid.New(),
+			WebhookID:  webhook.ID,
+			EventID:    payload.ID,
+			Event:      payload.Event,
+			Payload:    string(body),
+			Attempt:    attempt,
+			StatusCode: status,
+			CreatedAt:  time.Now().UTC(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		err = d.storage.CreateWebhookDelivery(context.Background(), delivery)
+		if errors.Is(err, models.ErrWebhookNotFound) {
+			slog.Info("Webhook deleted during delivery", "webhookID", webhook.ID, "eventID", payload.ID)
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to record webhook delivery", "error", err, "webhookID", webhook.ID)
+		}
+
+		if delivery.Succeeded() {
+			slog.Info("Webhook delivered", "webhookID", webhook.ID, "eventID", payload.ID, "attempt", attempt)
+			return
+		}
+		if !retryable(status) || attempt >= d.cfg.MaxAttempts {
+			slog.Warn("Webhook delivery failed", "webhookID", webhook.ID, "eventID", payload.ID, "attempt", attempt,
+				"status", status, "error", delivery.Error)
+			return
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, d.cfg.MaxBackoff)
+	}
+}
+
+// send makes one attempt and returns the response status, or 0 and an
+// error if there was none. Non-2xx statuses are reported as errors too.
+func (d *Dispatcher) send(webhook models.Webhook, payload Payload, body []byte) (int, error) {
+	// Attempts are bounded by the client timeout rather than cut short
+	// by Close, so that an accepted delivery is not recorded as failed.
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(payload.Event))
+	req.Header.Set(EventIDHeader, payload.ID.String())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining lets the connection be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt that got status, 0 meaning no
+// response, may succeed if repeated. Other client errors will not.
+func retryable(status int) bool {
+	return status == 0 || status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
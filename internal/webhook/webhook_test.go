@@ -0,0 +1,120 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ozon-test/internal/inmemory"
+	"ozon-test/internal/models"
+	"ozon-test/internal/webhook"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetries = webhook.Config{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+// receiver answers with statuses in turn, repeating the last one, and
+// counts the requests it got.
+func receiver(t *testing.T, secret string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, webhook.Sign(secret, body), r.Header.Get(webhook.SignatureHeader))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		n := int(calls.Add(1))
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func register(t *testing.T, storage models.Storage, url, secret string, events ...models.WebhookEvent) models.Webhook {
+	t.Helper()
+	hook := models.Webhook{ID: uuid.New(), URL: url, Secret: secret, Events: events, CreatedAt: time.Now()}
+	require.NoError(t, storage.CreateWebhook(context.Background(), hook))
+	return hook
+}
+
+// deliveries waits for a webhook to have n deliveries and returns them,
+// newest first.
+func deliveries(t *testing.T, storage models.Storage, webhookID uuid.UUID, n int) []models.WebhookDelivery {
+	t.Helper()
+	var got []models.WebhookDelivery
+	require.Eventually(t, func() bool {
+		var err error
+		got, err = storage.ListWebhookDeliveries(context.Background(), webhookID, 1, 10)
+		return err == nil && len(got) >= n
+	}, 2*time.Second, 5*time.Millisecond)
+	return got
+}
+
+func TestDeliveryIsRetried(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	srv, calls := receiver(t, "s3cret", http.StatusInternalServerError, http.StatusNoContent)
+	hook := register(t, storage, srv.URL, "s3cret", models.WebhookPostCreated)
+	other, otherCalls := receiver(t, "other")
+	register(t, storage, other.URL, "other", models.WebhookCommentCreated)
+
+	d := webhook.NewDispatcher(storage, fastRetries)
+	post := models.Post{ID: uuid.New(), Title: "Hello"}
+	d.Dispatch(context.Background(), models.WebhookPostCreated, post)
+
+	got := deliveries(t, storage, hook.ID, 2)
+	d.Close()
+	assert.EqualValues(t, 2, calls.Load())
+	assert.Zero(t, otherCalls.Load(), "Webhooks only get the events they subscribe to")
+
+	assert.Equal(t, 2, got[0].Attempt)
+	assert.True(t, got[0].Succeeded())
+	assert.Equal(t, 1, got[1].Attempt)
+	assert.Equal(t, http.StatusInternalServerError, got[1].StatusCode)
+	assert.Contains(t, got[1].Error, "500")
+	assert.Equal(t, got[0].EventID, got[1].EventID, "Retries resend the same event")
+
+	var payload struct {
+		ID    uuid.UUID
+		Event models.WebhookEvent
+		Data  models.Post
+	}
+	require.NoError(t, json.Unmarshal([]byte(got[0].Payload), &payload))
+	assert.Equal(t, got[0].EventID, payload.ID)
+	assert.Equal(t, models.WebhookPostCreated, payload.Event)
+	assert.Equal(t, post.ID, payload.Data.ID)
+}
+
+func TestDeliveryGivesUp(t *testing.T) {
+	storage := inmemory.NewInMemoryStorage()
+	unavailable, unavailableCalls := receiver(t, "a", http.StatusServiceUnavailable)
+	retried := register(t, storage, unavailable.URL, "a", models.WebhookCommentCreated)
+	rejecting, rejectingCalls := receiver(t, "b", http.StatusBadRequest)
+	rejected := register(t, storage, rejecting.URL, "b", models.WebhookCommentCreated)
+	unreachable := register(t, storage, "http://127.0.0.1:1/hook", "c", models.WebhookCommentCreated)
+
+	d := webhook.NewDispatcher(storage, fastRetries)
+	d.Dispatch(context.Background(), models.WebhookCommentCreated, models.Comment{ID: uuid.New()})
+
+	assert.Len(t, deliveries(t, storage, retried.ID, 3), 3)
+	assert.Len(t, deliveries(t, storage, rejected.ID, 1), 1)
+	failed := deliveries(t, storage, unreachable.ID, 3)
+	d.Close()
+
+	assert.EqualValues(t, 3, unavailableCalls.Load(), "Attempts are bounded")
+	assert.EqualValues(t, 1, rejectingCalls.Load(), "Client errors are not retried")
+	assert.Zero(t, failed[0].StatusCode)
+	assert.NotEmpty(t, failed[0].Error)
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac key
+	assert.Equal(t, "sha256=88a67f24bbcdaed0e6c997404bb79a743baf44c6bab2f4c27328e3009d22e342", webhook.Sign("key", []byte(`{"a":1}`)))
+}